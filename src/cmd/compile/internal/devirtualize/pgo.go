@@ -0,0 +1,215 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package devirtualize
+
+import (
+	"cmd/compile/internal/base"
+	"cmd/compile/internal/ir"
+	"cmd/compile/internal/pgo"
+	"cmd/compile/internal/typecheck"
+	"cmd/compile/internal/types"
+	"cmd/internal/objabi"
+	"cmd/internal/src"
+	"strings"
+)
+
+// ProfileGuided performs call-site specific devirtualization of
+// interface method calls in fn, using the call edges recorded in p.
+//
+// If the hottest callee observed at an interface call site is a method
+// of a concrete type that we can name, the call
+//
+//	x.M(args)
+//
+// is rewritten to
+//
+//	if t, ok := x.(T); ok {
+//		t.M(args)
+//	} else {
+//		x.M(args)
+//	}
+//
+// The direct call in the likely branch may then be inlined.
+func ProfileGuided(fn *ir.Func, p *pgo.Profile) {
+	ir.CurFunc = fn
+
+	var edit func(n ir.Node) ir.Node
+	edit = func(n ir.Node) ir.Node {
+		if n == nil {
+			return n
+		}
+		if n.Op() == ir.OGO || n.Op() == ir.ODEFER {
+			// We can't rewrite the call of a go or defer
+			// statement into a conditional.
+			return n
+		}
+
+		ir.EditChildren(n, edit)
+
+		call, ok := n.(*ir.CallExpr)
+		if !ok || call.Op() != ir.OCALLINTER {
+			return n
+		}
+		typ := hotConcreteType(fn, p, call)
+		if typ == nil {
+			return n
+		}
+		if base.Flag.LowerM != 0 {
+			base.WarnfAt(call.Pos(), "PGO devirtualizing %v to %v", call.X, typ)
+		}
+		return rewriteCondCall(call, fn, typ)
+	}
+
+	ir.EditChildren(fn, edit)
+}
+
+// hotConcreteType returns the concrete type of the hottest method
+// called from the interface call site call in fn, according to p, or
+// nil if there is no suitable type.
+func hotConcreteType(fn *ir.Func, p *pgo.Profile, call *ir.CallExpr) *types.Type {
+	sel := call.X.(*ir.SelectorExpr)
+	iface := sel.X.Type()
+	for _, e := range p.EdgesAt(fn, pgo.NodeLine(call)) {
+		pkgPrefix, typeName, ptr, method, ok := splitMethodName(e.Callee)
+		if !ok || method != sel.Sel.Name {
+			continue
+		}
+		typ := lookupType(call.Pos(), pkgPrefix, typeName)
+		if typ == nil {
+			continue
+		}
+		if ptr {
+			typ = types.NewPtr(typ)
+		}
+		if !typecheck.Implements(typ, iface) {
+			continue
+		}
+		return typ
+	}
+	return nil
+}
+
+// splitMethodName splits the symbol name of a method, such as
+// "example.com/pkg.(*T).M" or "example.com/pkg.T.M", into its package
+// prefix, receiver type name and method name. ptr reports whether the
+// receiver is a pointer. Names of other functions, closures, wrappers
+// and methods of generic types are rejected.
+func splitMethodName(name string) (pkgPrefix, typeName string, ptr bool, method string, ok bool) {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return "", "", false, "", false
+	}
+	dot += slash + 1
+	pkgPrefix, rest := name[:dot], name[dot+1:]
+	if strings.HasPrefix(rest, "(*") {
+		ptr = true
+		i := strings.Index(rest, ").")
+		if i < 0 {
+			return "", "", false, "", false
+		}
+		typeName, method = rest[len("(*"):i], rest[i+len(")."):]
+	} else {
+		i := strings.Index(rest, ".")
+		if i < 0 {
+			return "", "", false, "", false
+		}
+		typeName, method = rest[:i], rest[i+1:]
+	}
+	if typeName == "" || method == "" || strings.ContainsAny(typeName+method, ".[]()*-") {
+		return "", "", false, "", false
+	}
+	return pkgPrefix, typeName, ptr, method, true
+}
+
+// lookupType returns the named type typeName in the package with
+// symbol prefix pkgPrefix, or nil if it is not known. Only the local
+// package and directly imported packages are searched.
+func lookupType(pos src.XPos, pkgPrefix, typeName string) *types.Type {
+	var pkg *types.Pkg
+	if pkgPrefix == objabi.PathToPrefix(base.Ctxt.Pkgpath) {
+		pkg = types.LocalPkg
+	} else {
+		for _, p := range types.ImportedPkgList() {
+			if p.Prefix == pkgPrefix {
+				pkg = p
+				break
+			}
+		}
+	}
+	if pkg == nil {
+		return nil
+	}
+
+	n := typecheck.Resolve(ir.NewIdent(pos, pkg.Lookup(typeName)))
+	if n == nil || n.Op() != ir.OTYPE {
+		return nil
+	}
+	typ := n.Type()
+	if typ == nil || typ.IsInterface() || typ.HasTParam() {
+		return nil
+	}
+	return typ
+}
+
+// rewriteCondCall rewrites the interface method call to a type
+// assertion of the receiver to typ, followed by a direct method call if
+// the assertion succeeds and the original call otherwise. The result
+// is an OINLCALL node that replaces call.
+func rewriteCondCall(call *ir.CallExpr, curfn *ir.Func, typ *types.Type) ir.Node {
+	sel := call.X.(*ir.SelectorExpr)
+	pos := call.Pos()
+	init := ir.TakeInit(call)
+
+	// Evaluate the receiver and arguments once, before the type
+	// assertion, in their original order.
+	recv := typecheck.TempAt(pos, curfn, sel.X.Type())
+	lhs := []ir.Node{recv}
+	rhs := []ir.Node{sel.X}
+	args := make([]ir.Node, len(call.Args))
+	for i, arg := range call.Args {
+		tmp := typecheck.TempAt(pos, curfn, arg.Type())
+		lhs = append(lhs, tmp)
+		rhs = append(rhs, arg)
+		args[i] = tmp
+	}
+	init.Append(typecheck.Stmt(ir.NewAssignListStmt(pos, ir.OAS2, lhs, rhs)))
+	sel.X = recv
+	call.Args = args
+
+	tmp := typecheck.TempAt(pos, curfn, typ)
+	tmpok := typecheck.TempAt(pos, curfn, types.Types[types.TBOOL])
+	assert := ir.NewTypeAssertExpr(pos, recv, nil)
+	assert.SetType(typ)
+	init.Append(typecheck.Stmt(ir.NewAssignListStmt(pos, ir.OAS2, []ir.Node{tmp, tmpok}, []ir.Node{assert})))
+
+	// Copy the argument slice so the two calls don't share it.
+	concreteArgs := append([]ir.Node(nil), args...)
+	concreteCall := typecheck.Call(pos, ir.NewSelectorExpr(pos, ir.OXDOT, tmp, sel.Sel), concreteArgs, call.IsDDD)
+
+	var retvars []ir.Node
+	var thenBlock, elseBlock ir.Nodes
+	if results := sel.Type().Results(); results.NumFields() == 0 {
+		thenBlock.Append(concreteCall)
+		elseBlock.Append(call)
+	} else {
+		for _, r := range results.FieldSlice() {
+			retvars = append(retvars, typecheck.TempAt(pos, curfn, r.Type))
+		}
+		thenRet := append([]ir.Node(nil), retvars...)
+		thenBlock.Append(typecheck.Stmt(ir.NewAssignListStmt(pos, ir.OAS2, thenRet, []ir.Node{concreteCall})))
+		elseRet := append([]ir.Node(nil), retvars...)
+		elseBlock.Append(typecheck.Stmt(ir.NewAssignListStmt(pos, ir.OAS2, elseRet, []ir.Node{call})))
+	}
+
+	cond := ir.NewIfStmt(pos, tmpok, thenBlock, elseBlock)
+	cond.SetInit(init)
+	cond.Likely = true
+
+	res := ir.NewInlinedCallExpr(pos, []ir.Node{typecheck.Stmt(cond)}, retvars)
+	res.SetType(call.Type())
+	res.SetTypecheck(1)
+	return res
+}
@@ -34,6 +34,7 @@ import (
 	"cmd/compile/internal/base"
 	"cmd/compile/internal/ir"
 	"cmd/compile/internal/logopt"
+	"cmd/compile/internal/pgo"
 	"cmd/compile/internal/typecheck"
 	"cmd/compile/internal/types"
 	"cmd/internal/obj"
@@ -53,8 +54,78 @@ const (
 	inlineBigFunctionMaxCost = 20   // Max cost of inlinee when inlining into a "big" function.
 )
 
+// Profile-guided inlining parameters. See pgoInlinePrologue.
+var (
+	// inlineCDFHotCallSiteThresholdPercent is the percentage of the
+	// profile's total call edge weight covered by the hottest call
+	// sites, which are the ones considered for profile-guided inlining.
+	inlineCDFHotCallSiteThresholdPercent = float64(99)
+
+	// inlineHotMaxBudget is the inlining budget for functions called
+	// from hot call sites.
+	inlineHotMaxBudget int32 = 2000
+
+	// candHotCalleeMap is the set of symbol names of functions called
+	// from hot call sites; they get the larger inlining budget.
+	candHotCalleeMap = make(map[string]bool)
+
+	// candHotEdgeMap is the set of hot call sites, keyed by caller and
+	// line. Only calls at these sites may inline functions whose cost
+	// exceeds the default budget.
+	candHotEdgeMap = make(map[pgo.CallSite]bool)
+)
+
+// pgoInlinePrologue records the hot callees and call sites of profile,
+// the call sites that make up inlineCDFHotCallSiteThresholdPercent of the
+// profile's total call edge weight.
+func pgoInlinePrologue(profile *pgo.Profile) {
+	if s := base.Debug.PGOInlineCDFThreshold; s != "" {
+		threshold, err := pgo.ParseCDFThreshold(s)
+		if err != nil {
+			base.Fatalf("invalid PGOInlineCDFThreshold: %v", err)
+		}
+		inlineCDFHotCallSiteThresholdPercent = threshold
+	}
+	if base.Debug.PGOInlineBudget != 0 {
+		inlineHotMaxBudget = int32(base.Debug.PGOInlineBudget)
+	}
+
+	for _, e := range profile.HotEdges(inlineCDFHotCallSiteThresholdPercent) {
+		candHotCalleeMap[e.Callee] = true
+		candHotEdgeMap[e.CallSite] = true
+		if base.Debug.PGOInline >= 2 {
+			fmt.Printf("hot-cg: %s:%d -> %s (weight %d)\n", e.Caller, e.Line, e.Callee, e.Weight)
+		}
+	}
+	if base.Debug.PGOInline > 0 {
+		fmt.Printf("hot-callsite-thres-from-CDF=%v, hot call sites=%d\n", inlineCDFHotCallSiteThresholdPercent, len(candHotEdgeMap))
+	}
+}
+
+// inlineBudget determines the max budget for function fn to be
+// considered for inlining, taking the profile into account.
+func inlineBudget(fn *ir.Func) int32 {
+	budget := int32(inlineMaxBudget)
+	if candHotCalleeMap[ir.LinkFuncName(fn)] {
+		// Allow functions called from hot call sites to be
+		// inlined with a larger budget. Whether they actually are
+		// is decided at each call site; see mkinlcall.
+		budget = inlineHotMaxBudget
+		if base.Debug.PGOInline > 0 {
+			fmt.Printf("hot-node enabled increased budget=%v for func=%v\n", budget, ir.PkgFuncName(fn))
+		}
+	}
+	return budget
+}
+
 // InlinePackage finds functions that can be inlined and clones them before walk expands them.
-func InlinePackage() {
+// If profile is non-nil, it is used to inline functions called from hot call sites
+// more aggressively.
+func InlinePackage(profile *pgo.Profile) {
+	if profile != nil {
+		pgoInlinePrologue(profile)
+	}
+
 	ir.VisitFuncsBottomUp(typecheck.Target.Decls, func(list []*ir.Func, recursive bool) {
 		numfns := numNonClosures(list)
 		for _, n := range list {
@@ -166,8 +237,10 @@ func CanInline(fn *ir.Func) {
 	// locals, and we use this map to produce a pruned Inline.Dcl
 	// list. See issue 25249 for more context.
 
+	budget := inlineBudget(fn)
 	visitor := hairyVisitor{
-		budget:        inlineMaxBudget,
+		budget:        budget,
+		maxBudget:     budget,
 		extraCallCost: cc,
 	}
 	if visitor.tooHairy(fn) {
@@ -176,7 +249,7 @@ func CanInline(fn *ir.Func) {
 	}
 
 	n.Func.Inl = &ir.Inline{
-		Cost: inlineMaxBudget - visitor.budget,
+		Cost: budget - visitor.budget,
 		Dcl:  pruneUnusedAutos(n.Defn.(*ir.Func).Dcl, &visitor),
 		Body: inlcopylist(fn.Body),
 
@@ -184,12 +257,12 @@ func CanInline(fn *ir.Func) {
 	}
 
 	if base.Flag.LowerM > 1 {
-		fmt.Printf("%v: can inline %v with cost %d as: %v { %v }\n", ir.Line(fn), n, inlineMaxBudget-visitor.budget, fn.Type(), ir.Nodes(n.Func.Inl.Body))
+		fmt.Printf("%v: can inline %v with cost %d as: %v { %v }\n", ir.Line(fn), n, budget-visitor.budget, fn.Type(), ir.Nodes(n.Func.Inl.Body))
 	} else if base.Flag.LowerM != 0 {
 		fmt.Printf("%v: can inline %v\n", ir.Line(fn), n)
 	}
 	if logopt.Enabled() {
-		logopt.LogOpt(fn.Pos(), "canInlineFunction", "inline", ir.FuncName(fn), fmt.Sprintf("cost: %d", inlineMaxBudget-visitor.budget))
+		logopt.LogOpt(fn.Pos(), "canInlineFunction", "inline", ir.FuncName(fn), fmt.Sprintf("cost: %d", budget-visitor.budget))
 	}
 }
 
@@ -229,6 +302,7 @@ func canDelayResults(fn *ir.Func) bool {
 // hairiness and whether or not it can be inlined.
 type hairyVisitor struct {
 	budget        int32
+	maxBudget     int32
 	reason        string
 	extraCallCost int32
 	usedLocals    ir.NameSet
@@ -241,7 +315,7 @@ func (v *hairyVisitor) tooHairy(fn *ir.Func) bool {
 		return true
 	}
 	if v.budget < 0 {
-		v.reason = fmt.Sprintf("function too complex: cost %d exceeds budget %d", inlineMaxBudget-v.budget, inlineMaxBudget)
+		v.reason = fmt.Sprintf("function too complex: cost %d exceeds budget %d", v.maxBudget-v.budget, v.maxBudget)
 		return true
 	}
 	return false
@@ -492,10 +566,7 @@ func inlcopy(n ir.Node) ir.Node {
 func InlineCalls(fn *ir.Func) {
 	savefn := ir.CurFunc
 	ir.CurFunc = fn
-	maxCost := int32(inlineMaxBudget)
-	if isBigFunc(fn) {
-		maxCost = inlineBigFunctionMaxCost
-	}
+	bigCaller := isBigFunc(fn)
 	// Map to keep track of functions that have been inlined at a particular
 	// call site, in order to stop inlining when we reach the beginning of a
 	// recursion cycle again. We don't inline immediately recursive functions,
@@ -505,7 +576,7 @@ func InlineCalls(fn *ir.Func) {
 	inlMap := make(map[*ir.Func]bool)
 	var edit func(ir.Node) ir.Node
 	edit = func(n ir.Node) ir.Node {
-		return inlnode(n, maxCost, inlMap, edit)
+		return inlnode(n, bigCaller, inlMap, edit)
 	}
 	ir.EditChildren(fn, edit)
 	ir.CurFunc = savefn
@@ -524,7 +595,7 @@ func InlineCalls(fn *ir.Func) {
 // shorter and less complicated.
 // The result of inlnode MUST be assigned back to n, e.g.
 // 	n.Left = inlnode(n.Left)
-func inlnode(n ir.Node, maxCost int32, inlMap map[*ir.Func]bool, edit func(ir.Node) ir.Node) ir.Node {
+func inlnode(n ir.Node, bigCaller bool, inlMap map[*ir.Func]bool, edit func(ir.Node) ir.Node) ir.Node {
 	if n == nil {
 		return n
 	}
@@ -542,6 +613,22 @@ func inlnode(n ir.Node, maxCost int32, inlMap map[*ir.Func]bool, edit func(ir.No
 	case ir.OTAILCALL:
 		n := n.(*ir.TailCallStmt)
 		n.Call.NoInline = true // Not inline a tail call for now. Maybe we could inline it just like RETURN fn(arg)?
+	case ir.OFOR:
+		n := n.(*ir.ForStmt)
+		if isTestingBLoop(n) {
+			// Don't inline calls in the body of a b.Loop loop, so
+			// that benchmarks measure them as written: inlining
+			// would let the compiler drop calls whose results are
+			// unused, or specialize them for constant arguments.
+			if base.Flag.LowerM > 1 {
+				fmt.Printf("%v: skip inlining within testing.B.Loop\n", ir.Line(n))
+			}
+			ir.VisitList(n.Body, func(n ir.Node) {
+				if n.Op() == ir.OCALLFUNC {
+					n.(*ir.CallExpr).NoInline = true
+				}
+			})
+		}
 
 	// TODO do them here (or earlier),
 	// so escape analysis can avoid more heapmoves.
@@ -586,7 +673,7 @@ func inlnode(n ir.Node, maxCost int32, inlMap map[*ir.Func]bool, edit func(ir.No
 			break
 		}
 		if fn := inlCallee(call.X); fn != nil && typecheck.HaveInlineBody(fn) {
-			n = mkinlcall(call, fn, maxCost, inlMap, edit)
+			n = mkinlcall(call, fn, bigCaller, inlMap, edit)
 		}
 	}
 
@@ -595,6 +682,24 @@ func inlnode(n ir.Node, maxCost int32, inlMap map[*ir.Func]bool, edit func(ir.No
 	return n
 }
 
+// isTestingBLoop reports whether n is a loop of the form
+// "for b.Loop() { ... }", where b is a *testing.B.
+func isTestingBLoop(n *ir.ForStmt) bool {
+	if n.Cond == nil || n.Cond.Op() != ir.OCALLFUNC {
+		return false
+	}
+	call := n.Cond.(*ir.CallExpr)
+	if call.X.Op() != ir.OMETHEXPR {
+		return false
+	}
+	fn := ir.MethodExprName(call.X)
+	if fn == nil {
+		return false
+	}
+	s := fn.Sym()
+	return s.Name == "(*B).Loop" && s.Pkg != nil && s.Pkg.Path == "testing"
+}
+
 // inlCallee takes a function-typed expression and returns the underlying function ONAME
 // that it refers to if statically known. Otherwise, it returns nil.
 func inlCallee(fn ir.Node) *ir.Func {
@@ -659,7 +764,7 @@ var NewInline = func(call *ir.CallExpr, fn *ir.Func, inlIndex int) *ir.InlinedCa
 // parameters.
 // The result of mkinlcall MUST be assigned back to n, e.g.
 // 	n.Left = mkinlcall(n.Left, fn, isddd)
-func mkinlcall(n *ir.CallExpr, fn *ir.Func, maxCost int32, inlMap map[*ir.Func]bool, edit func(ir.Node) ir.Node) ir.Node {
+func mkinlcall(n *ir.CallExpr, fn *ir.Func, bigCaller bool, inlMap map[*ir.Func]bool, edit func(ir.Node) ir.Node) ir.Node {
 	if fn.Inl == nil {
 		if logopt.Enabled() {
 			logopt.LogOpt(n.Pos(), "cannotInlineCall", "inline", ir.FuncName(ir.CurFunc),
@@ -667,14 +772,44 @@ func mkinlcall(n *ir.CallExpr, fn *ir.Func, maxCost int32, inlMap map[*ir.Func]b
 		}
 		return n
 	}
+
+	maxCost := int32(inlineMaxBudget)
+	if bigCaller {
+		// We use this to restrict inlining into very big functions.
+		// See issue 26546 and 17566.
+		maxCost = inlineBigFunctionMaxCost
+	}
+
 	if fn.Inl.Cost > maxCost {
-		// The inlined function body is too big. Typically we use this check to restrict
-		// inlining into very big functions.  See issue 26546 and 17566.
-		if logopt.Enabled() {
-			logopt.LogOpt(n.Pos(), "cannotInlineCall", "inline", ir.FuncName(ir.CurFunc),
-				fmt.Sprintf("cost %d of %s exceeds max large caller cost %d", fn.Inl.Cost, ir.PkgFuncName(fn), maxCost))
+		// If the call site is hot and the callee is under the
+		// inlineHotMaxBudget budget, then try to inline it, or else bail.
+		if len(candHotEdgeMap) == 0 || !candHotEdgeMap[pgo.SiteOf(n, ir.CurFunc)] {
+			// The inlined function body is too big. Typically we use this check to restrict
+			// inlining into very big functions.  See issue 26546 and 17566.
+			if logopt.Enabled() {
+				logopt.LogOpt(n.Pos(), "cannotInlineCall", "inline", ir.FuncName(ir.CurFunc),
+					fmt.Sprintf("cost %d of %s exceeds max large caller cost %d", fn.Inl.Cost, ir.PkgFuncName(fn), maxCost))
+			}
+			return n
+		}
+		if fn.Inl.Cost > inlineHotMaxBudget {
+			if logopt.Enabled() {
+				logopt.LogOpt(n.Pos(), "cannotInlineCall", "inline", ir.FuncName(ir.CurFunc),
+					fmt.Sprintf("cost %d of %s exceeds max hot call site cost %d", fn.Inl.Cost, ir.PkgFuncName(fn), inlineHotMaxBudget))
+			}
+			return n
+		}
+		if bigCaller {
+			if base.Debug.PGOInline > 0 {
+				fmt.Printf("hot-big check disallows inlining for call %s (cost %d) at %v in big function %s\n",
+					ir.PkgFuncName(fn), fn.Inl.Cost, ir.Line(n), ir.PkgFuncName(ir.CurFunc))
+			}
+			return n
+		}
+		if base.Debug.PGOInline > 0 {
+			fmt.Printf("hot-budget check allows inlining for call %s (cost %d) at %v in function %s\n",
+				ir.PkgFuncName(fn), fn.Inl.Cost, ir.Line(n), ir.PkgFuncName(ir.CurFunc))
 		}
-		return n
 	}
 
 	if fn == ir.CurFunc {
@@ -8,6 +8,7 @@ import (
 	"cmd/compile/internal/base"
 	"cmd/compile/internal/types"
 	"cmd/internal/obj"
+	"cmd/internal/objabi"
 	"cmd/internal/src"
 	"fmt"
 )
@@ -279,6 +280,23 @@ func PkgFuncName(f *Func) string {
 	return p + "." + s.Name
 }
 
+// LinkFuncName returns the name of the function f, as it appears in the
+// symbol table of the linked binary (and therefore in profiles of it).
+// Unlike PkgFuncName, the package path is escaped as in symbol names.
+func LinkFuncName(f *Func) string {
+	if f == nil || f.Nname == nil {
+		return "<nil>"
+	}
+	s := f.Sym()
+	pkg := s.Pkg
+
+	p := base.Ctxt.Pkgpath
+	if pkg != nil && pkg.Path != "" {
+		p = pkg.Path
+	}
+	return objabi.PathToPrefix(p) + "." + s.Name
+}
+
 var CurFunc *Func
 
 // WithFunc invokes do with CurFunc and base.Pos set to curfn and
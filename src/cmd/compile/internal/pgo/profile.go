@@ -0,0 +1,226 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pgo implements the compiler's support for profile-guided
+// optimization (PGO).
+//
+// The compiler reads a CPU profile in the pprof format, as written by
+// runtime/pprof, and reduces it to a weighted call graph: for every call
+// site observed in the profile, the total weight of the samples in which
+// the caller was executing that call. Optimization passes such as
+// inlining and devirtualization query this graph to find hot call sites.
+//
+// Call sites are identified by the symbol names of the caller and the
+// callee, and the line number of the call within the caller. Profiles
+// are therefore most useful when collected from a binary built from the
+// same source that is being compiled; stale profile entries that no
+// longer match any call site are simply ignored.
+package pgo
+
+import (
+	"cmd/compile/internal/base"
+	"cmd/compile/internal/ir"
+	"fmt"
+	"internal/profile"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CallSite identifies a call site in the profile.
+type CallSite struct {
+	Caller string // symbol name of the calling function
+	Line   int    // line number of the call within Caller
+}
+
+// CallEdge is a weighted call from a call site to a callee.
+type CallEdge struct {
+	CallSite
+	Callee string // symbol name of the called function
+	Weight int64  // total sample weight of the call
+}
+
+// Profile is the compiler's view of a CPU profile.
+type Profile struct {
+	// TotalEdgeWeight is the sum of the weights of all call edges.
+	TotalEdgeWeight int64
+
+	// Edges holds every call edge in the profile, sorted by decreasing
+	// weight. Edges of equal weight are ordered by caller, line, and
+	// callee name, so the order is deterministic.
+	Edges []*CallEdge
+
+	// sites maps a call site to its outgoing edges, in decreasing
+	// order of weight.
+	sites map[CallSite][]*CallEdge
+}
+
+// New reads the pprof CPU profile in profileFile and returns the
+// corresponding Profile.
+//
+// An empty profile file is accepted, and results in a nil Profile,
+// which is equivalent to not using PGO at all.
+func New(profileFile string) (*Profile, error) {
+	f, err := os.Open(profileFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if fi, err := f.Stat(); err == nil && fi.Size() == 0 {
+		// We accept empty profiles, but there is nothing to do.
+		return nil, nil
+	}
+
+	p, err := profile.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing profile: %v", err)
+	}
+	return FromProfile(p)
+}
+
+// FromProfile returns the Profile corresponding to the pprof profile p.
+// It returns a nil Profile if p has no samples.
+func FromProfile(p *profile.Profile) (*Profile, error) {
+	if len(p.Sample) == 0 {
+		// We accept empty profiles, but there is nothing to do.
+		return nil, nil
+	}
+
+	valueIndex := -1
+	for i, s := range p.SampleType {
+		// Samples count is the raw data collected, and CPU nanoseconds is just
+		// a scaled version of it, so either one we can find is fine.
+		if (s.Type == "samples" && s.Unit == "count") ||
+			(s.Type == "cpu" && s.Unit == "nanoseconds") {
+			valueIndex = i
+			break
+		}
+	}
+	if valueIndex == -1 {
+		return nil, fmt.Errorf(`profile does not contain a sample index with value/type "samples/count" or "cpu/nanoseconds"`)
+	}
+
+	type edgeKey struct {
+		CallSite
+		callee string
+	}
+	weights := make(map[edgeKey]int64)
+	var frames []profile.Line
+	for _, s := range p.Sample {
+		w := s.Value[valueIndex]
+		if w == 0 {
+			continue
+		}
+
+		// Flatten the stack into frames, innermost first, including
+		// frames for calls that were inlined in the profiled binary.
+		frames = frames[:0]
+		for _, loc := range s.Location {
+			for _, line := range loc.Line {
+				if line.Function != nil {
+					frames = append(frames, line)
+				}
+			}
+		}
+
+		// Each pair of adjacent frames is a call edge from the outer
+		// frame, at its current line, to the inner one. Recursive
+		// stacks may contain the same edge more than once; count it
+		// only once per sample.
+		var seen map[edgeKey]bool
+		for i := 0; i+1 < len(frames); i++ {
+			callee, caller := frames[i], frames[i+1]
+			k := edgeKey{
+				CallSite: CallSite{Caller: caller.Function.Name, Line: int(caller.Line)},
+				callee:   callee.Function.Name,
+			}
+			if seen[k] {
+				continue
+			}
+			if seen == nil {
+				seen = make(map[edgeKey]bool)
+			}
+			seen[k] = true
+			weights[k] += w
+		}
+	}
+
+	pgo := &Profile{
+		sites: make(map[CallSite][]*CallEdge),
+	}
+	for k, w := range weights {
+		pgo.Edges = append(pgo.Edges, &CallEdge{CallSite: k.CallSite, Callee: k.callee, Weight: w})
+		pgo.TotalEdgeWeight += w
+	}
+	sort.Slice(pgo.Edges, func(i, j int) bool {
+		ei, ej := pgo.Edges[i], pgo.Edges[j]
+		if ei.Weight != ej.Weight {
+			return ei.Weight > ej.Weight
+		}
+		if ei.Caller != ej.Caller {
+			return ei.Caller < ej.Caller
+		}
+		if ei.Line != ej.Line {
+			return ei.Line < ej.Line
+		}
+		return ei.Callee < ej.Callee
+	})
+	for _, e := range pgo.Edges {
+		pgo.sites[e.CallSite] = append(pgo.sites[e.CallSite], e)
+	}
+	return pgo, nil
+}
+
+// HotEdges returns the hottest call edges whose cumulative weight
+// reaches cdfPercent percent of the total edge weight. The result is in
+// decreasing order of weight.
+func (p *Profile) HotEdges(cdfPercent float64) []*CallEdge {
+	if p == nil || p.TotalEdgeWeight == 0 {
+		return nil
+	}
+	var cum int64
+	for i, e := range p.Edges {
+		if float64(cum)*100 >= cdfPercent*float64(p.TotalEdgeWeight) {
+			return p.Edges[:i]
+		}
+		cum += e.Weight
+	}
+	return p.Edges
+}
+
+// EdgesAt returns the call edges leaving the call site at line line of
+// the function fn, hottest first.
+func (p *Profile) EdgesAt(fn *ir.Func, line int) []*CallEdge {
+	if p == nil {
+		return nil
+	}
+	return p.sites[CallSite{Caller: ir.LinkFuncName(fn), Line: line}]
+}
+
+// SiteOf returns the call site of the call n within the function fn.
+func SiteOf(n ir.Node, fn *ir.Func) CallSite {
+	return CallSite{Caller: ir.LinkFuncName(fn), Line: NodeLine(n)}
+}
+
+// NodeLine returns the line number of n, as recorded in the line
+// table of the compiled binary and thus in profiles of it.
+func NodeLine(n ir.Node) int {
+	return int(base.Ctxt.PosTable.Pos(n.Pos()).RelLine())
+}
+
+// ParseCDFThreshold parses a cumulative distribution threshold, given
+// as a percentage between 0 and 100.
+func ParseCDFThreshold(s string) (float64, error) {
+	s = strings.TrimSuffix(s, "%")
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if f < 0 || f > 100 {
+		return 0, fmt.Errorf("threshold %v out of range [0, 100]", f)
+	}
+	return f, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pgo
+
+import (
+	"internal/profile"
+	"reflect"
+	"testing"
+)
+
+type frame struct {
+	fn   string
+	line int64
+}
+
+// makeProfile returns a CPU profile with one sample per stack, each of
+// the given weight. Stacks are listed innermost frame first.
+func makeProfile(stacks [][]frame, weights []int64) *profile.Profile {
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+	}
+	funcs := make(map[string]*profile.Function)
+	for i, stack := range stacks {
+		s := &profile.Sample{Value: []int64{weights[i], weights[i] * 1e7}}
+		for _, f := range stack {
+			fn := funcs[f.fn]
+			if fn == nil {
+				fn = &profile.Function{ID: uint64(len(funcs) + 1), Name: f.fn}
+				funcs[f.fn] = fn
+				p.Function = append(p.Function, fn)
+			}
+			loc := &profile.Location{
+				ID:   uint64(len(p.Location) + 1),
+				Line: []profile.Line{{Function: fn, Line: f.line}},
+			}
+			p.Location = append(p.Location, loc)
+			s.Location = append(s.Location, loc)
+		}
+		p.Sample = append(p.Sample, s)
+	}
+	return p
+}
+
+func TestFromProfile(t *testing.T) {
+	p := makeProfile([][]frame{
+		{{"p.leaf", 3}, {"p.mid", 10}, {"p.main", 20}},
+		{{"p.leaf", 4}, {"p.mid", 10}, {"p.main", 20}},
+		{{"p.other", 1}, {"p.main", 21}},
+		{{"p.main", 22}},
+		// Recursive stack: the p.rec -> p.rec edge counts once.
+		{{"p.rec", 5}, {"p.rec", 6}, {"p.rec", 6}, {"p.main", 23}},
+	}, []int64{5, 2, 1, 9, 4})
+
+	pgo, err := FromProfile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []CallEdge{
+		{CallSite{"p.main", 20}, "p.mid", 7},
+		{CallSite{"p.mid", 10}, "p.leaf", 7},
+		{CallSite{"p.main", 23}, "p.rec", 4},
+		{CallSite{"p.rec", 6}, "p.rec", 4},
+		{CallSite{"p.main", 21}, "p.other", 1},
+	}
+	var got []CallEdge
+	for _, e := range pgo.Edges {
+		got = append(got, *e)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got edges %v, want %v", got, want)
+	}
+	if pgo.TotalEdgeWeight != 23 {
+		t.Errorf("got total edge weight %d, want 23", pgo.TotalEdgeWeight)
+	}
+	if es := pgo.sites[CallSite{"p.mid", 10}]; len(es) != 1 || es[0].Callee != "p.leaf" {
+		t.Errorf("got edges %v at p.mid:10, want a single edge to p.leaf", es)
+	}
+}
+
+func TestHotEdges(t *testing.T) {
+	p := makeProfile([][]frame{
+		{{"p.a", 1}, {"p.main", 1}},
+		{{"p.b", 1}, {"p.main", 2}},
+		{{"p.c", 1}, {"p.main", 3}},
+	}, []int64{60, 30, 10})
+
+	pgo, err := FromProfile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		cdf  float64
+		want int
+	}{
+		{0, 0},
+		{50, 1},
+		{60, 1},
+		{61, 2},
+		{90, 2},
+		{99, 3},
+		{100, 3},
+	} {
+		if got := len(pgo.HotEdges(tc.cdf)); got != tc.want {
+			t.Errorf("HotEdges(%v) returned %d edges, want %d", tc.cdf, got, tc.want)
+		}
+	}
+}
+
+func TestFromProfileEmpty(t *testing.T) {
+	pgo, err := FromProfile(&profile.Profile{})
+	if pgo != nil || err != nil {
+		t.Errorf("FromProfile(empty) = %v, %v, want nil, nil", pgo, err)
+	}
+
+	p := makeProfile([][]frame{{{"p.a", 1}, {"p.main", 1}}}, []int64{1})
+	p.SampleType = []*profile.ValueType{{Type: "alloc_space", Unit: "bytes"}}
+	if _, err := FromProfile(p); err == nil {
+		t.Errorf("FromProfile of a heap profile succeeded, want error")
+	}
+}
+
+func TestParseCDFThreshold(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"99", 99, true},
+		{"99.5%", 99.5, true},
+		{"0", 0, true},
+		{"100", 100, true},
+		{"101", 0, false},
+		{"-1", 0, false},
+		{"x", 0, false},
+	} {
+		got, err := ParseCDFThreshold(tc.in)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("ParseCDFThreshold(%q) = %v, %v; want %v, ok=%v", tc.in, got, err, tc.want, tc.ok)
+		}
+	}
+}
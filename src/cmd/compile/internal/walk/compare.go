@@ -281,6 +281,19 @@ func walkCompareInterface(n *ir.BinaryExpr, init *ir.Nodes) ir.Node {
 }
 
 func walkCompareString(n *ir.BinaryExpr, init *ir.Nodes) ir.Node {
+	if base.Debug.Libfuzzer != 0 {
+		if !ir.IsConst(n.X, constant.String) || !ir.IsConst(n.Y, constant.String) {
+			// Report both operands, so that the fuzzer can learn the
+			// strings that its inputs are compared against. Short
+			// constant strings are also traced below as integer
+			// comparisons, but only a few bytes at a time.
+			n.X = cheapExpr(n.X, init)
+			n.Y = cheapExpr(n.Y, init)
+			paramType := types.Types[types.TSTRING]
+			init.Append(mkcall("libfuzzerHookStrCmp", nil, init, tracecmpArg(n.X, paramType, init), tracecmpArg(n.Y, paramType, init)))
+		}
+	}
+
 	// Rewrite comparisons to short constant strings as length+byte-wise comparisons.
 	var cs, ncs ir.Node // const string, non-const string
 	switch {
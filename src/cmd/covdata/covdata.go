@@ -0,0 +1,313 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"internal/coverage"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"cmd/internal/objabi"
+)
+
+const usageMessage = "" +
+	`usage: go tool covdata <mode> -i=<directories> [flags]
+
+Covdata processes the coverage data files written by programs built
+with 'go build -cover'. The modes are:
+
+	textfmt   convert coverage data to the text format read by 'go tool cover'
+	merge     merge the coverage data of several directories
+	subtract  subtract the coverage data of later directories from the first
+
+For help on a mode, run 'go tool covdata <mode> -help'.
+`
+
+func usage() {
+	fmt.Fprint(os.Stderr, usageMessage)
+	os.Exit(2)
+}
+
+// A command is a mode of operation of covdata.
+type command struct {
+	usage   string // one-line usage message
+	minDirs int    // minimum number of input directories
+	run     func(inputs []string, output string) error
+}
+
+var commands = map[string]*command{
+	"textfmt": {
+		usage:   "go tool covdata textfmt -i=<directories> -o=<file>",
+		minDirs: 1,
+		run:     textfmt,
+	},
+	"merge": {
+		usage:   "go tool covdata merge -i=<directories> -o=<directory>",
+		minDirs: 1,
+		run:     merge,
+	},
+	"subtract": {
+		usage:   "go tool covdata subtract -i=<dir1,dir2,...> -o=<directory>",
+		minDirs: 2,
+		run:     subtract,
+	},
+}
+
+func main() {
+	objabi.AddVersionFlag()
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+	}
+
+	name := flag.Arg(0)
+	cmd := commands[name]
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "covdata: unknown mode %q\n", name)
+		fmt.Fprintln(os.Stderr, `For usage information, run "go tool covdata -help"`)
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	input := fs.String("i", "", "comma-separated list of input `directories`")
+	output := fs.String("o", "", "output file or directory")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\nFlags:\n", cmd.usage)
+		fs.PrintDefaults()
+		os.Exit(2)
+	}
+	fs.Parse(flag.Args()[1:])
+
+	var inputs []string
+	if *input != "" {
+		inputs = strings.Split(*input, ",")
+	}
+	if len(inputs) < cmd.minDirs || *output == "" || fs.NArg() != 0 {
+		fs.Usage()
+	}
+	if err := cmd.run(inputs, *output); err != nil {
+		fmt.Fprintf(os.Stderr, "covdata: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// textfmt writes the merged coverage data of the input directories to
+// the file output, in the text profile format.
+func textfmt(inputs []string, output string) error {
+	m, err := readDirs(inputs)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	err = writeText(f, m.profile())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// merge writes the merged coverage data of the input directories to a
+// data file in the output directory.
+func merge(inputs []string, output string) error {
+	m, err := readDirs(inputs)
+	if err != nil {
+		return err
+	}
+	return writeDir(output, m.profile())
+}
+
+// subtract writes the coverage data of the first input directory,
+// less the blocks covered in the other input directories, to a data
+// file in the output directory.
+func subtract(inputs []string, output string) error {
+	m, err := readDirs(inputs[:1])
+	if err != nil {
+		return err
+	}
+	for _, dir := range inputs[1:] {
+		m1, err := readDirs([]string{dir})
+		if err != nil {
+			return err
+		}
+		if err := m.subtract(m1.profile()); err != nil {
+			return err
+		}
+	}
+	return writeDir(output, m.profile())
+}
+
+// readDirs reads and merges the coverage data files of the
+// directories dirs.
+func readDirs(dirs []string) (*merger, error) {
+	m := newMerger()
+	for _, dir := range dirs {
+		profiles, err := coverage.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(profiles) == 0 {
+			return nil, fmt.Errorf("no coverage data files found in %s", dir)
+		}
+		for _, p := range profiles {
+			if err := m.add(p); err != nil {
+				return nil, fmt.Errorf("%s: %v", dir, err)
+			}
+		}
+	}
+	return m, nil
+}
+
+// writeDir writes p to a new data file in dir, creating dir if needed.
+func writeDir(dir string, p *coverage.Profile) error {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	return coverage.WriteDir(dir, p)
+}
+
+// writeText writes p to w in the text profile format
+// used by 'go test -coverprofile'.
+func writeText(w io.Writer, p *coverage.Profile) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "mode: %s\n", p.Mode)
+	for _, f := range p.Files {
+		for _, b := range f.Blocks {
+			fmt.Fprintf(bw, "%s:%d.%d,%d.%d %d %d\n", f.Name,
+				b.Line0, b.Col0, b.Line1, b.Col1, b.Stmts, b.Count)
+		}
+	}
+	return bw.Flush()
+}
+
+// A blockPos identifies a block within a source file.
+type blockPos struct {
+	line0 uint32
+	col0  uint16
+	line1 uint32
+	col1  uint16
+}
+
+func posOf(b *coverage.Block) blockPos {
+	return blockPos{b.Line0, b.Col0, b.Line1, b.Col1}
+}
+
+// A merger accumulates the coverage data of several profiles.
+// Blocks are identified by source file name and position, so
+// profiles of different programs sharing the same packages
+// can be combined.
+type merger struct {
+	mode  string
+	files map[string]map[blockPos]*coverage.Block
+}
+
+func newMerger() *merger {
+	return &merger{files: make(map[string]map[blockPos]*coverage.Block)}
+}
+
+// checkMode reports an error if p was collected in a different
+// coverage mode than the profiles already added to m.
+func (m *merger) checkMode(p *coverage.Profile) error {
+	if m.mode == "" {
+		m.mode = p.Mode
+	} else if m.mode != p.Mode {
+		return fmt.Errorf("coverage mode mismatch: %s and %s", m.mode, p.Mode)
+	}
+	return nil
+}
+
+// add adds the counters of p to m.
+func (m *merger) add(p *coverage.Profile) error {
+	if err := m.checkMode(p); err != nil {
+		return err
+	}
+	for _, f := range p.Files {
+		blocks := m.files[f.Name]
+		if blocks == nil {
+			blocks = make(map[blockPos]*coverage.Block)
+			m.files[f.Name] = blocks
+		}
+		for i := range f.Blocks {
+			b := &f.Blocks[i]
+			if old := blocks[posOf(b)]; old != nil {
+				old.Count = mergeCount(m.mode, old.Count, b.Count)
+			} else {
+				b := *b
+				blocks[posOf(&b)] = &b
+			}
+		}
+	}
+	return nil
+}
+
+// subtract marks the blocks of m that are covered in p as not covered.
+func (m *merger) subtract(p *coverage.Profile) error {
+	if err := m.checkMode(p); err != nil {
+		return err
+	}
+	for _, f := range p.Files {
+		blocks := m.files[f.Name]
+		for i := range f.Blocks {
+			b := &f.Blocks[i]
+			if old := blocks[posOf(b)]; old != nil && b.Count != 0 {
+				old.Count = 0
+			}
+		}
+	}
+	return nil
+}
+
+// mergeCount returns the combination of two counter values.
+func mergeCount(mode string, x, y uint32) uint32 {
+	if mode == "set" {
+		if x != 0 || y != 0 {
+			return 1
+		}
+		return 0
+	}
+	if x > math.MaxUint32-y {
+		return math.MaxUint32
+	}
+	return x + y
+}
+
+// profile returns the data accumulated in m, with files sorted by name
+// and blocks sorted by position.
+func (m *merger) profile() *coverage.Profile {
+	p := &coverage.Profile{Mode: m.mode}
+	for name, blocks := range m.files {
+		f := &coverage.File{Name: name}
+		for _, b := range blocks {
+			f.Blocks = append(f.Blocks, *b)
+		}
+		sort.Slice(f.Blocks, func(i, j int) bool {
+			bi, bj := &f.Blocks[i], &f.Blocks[j]
+			if bi.Line0 != bj.Line0 {
+				return bi.Line0 < bj.Line0
+			}
+			if bi.Col0 != bj.Col0 {
+				return bi.Col0 < bj.Col0
+			}
+			if bi.Line1 != bj.Line1 {
+				return bi.Line1 < bj.Line1
+			}
+			return bi.Col1 < bj.Col1
+		})
+		p.Files = append(p.Files, f)
+	}
+	sort.Slice(p.Files, func(i, j int) bool {
+		return p.Files[i].Name < p.Files[j].Name
+	})
+	return p
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"internal/coverage"
+	"math"
+	"testing"
+)
+
+func profile(mode string, counts map[string][]uint32) *coverage.Profile {
+	p := &coverage.Profile{Mode: mode}
+	for _, name := range []string{"p/b.go", "p/a.go"} {
+		if counts[name] == nil {
+			continue
+		}
+		f := &coverage.File{Name: name}
+		// Add the blocks in reverse order to check that they are sorted.
+		for i := len(counts[name]) - 1; i >= 0; i-- {
+			line := uint32(10 * (i + 1))
+			f.Blocks = append(f.Blocks, coverage.Block{
+				Line0: line, Col0: 2, Line1: line + 5, Col1: 3,
+				Stmts: 1, Count: counts[name][i],
+			})
+		}
+		p.Files = append(p.Files, f)
+	}
+	return p
+}
+
+func text(t *testing.T, p *coverage.Profile) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writeText(&buf, p); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"set", `mode: set
+p/a.go:10.2,15.3 1 1
+p/a.go:20.2,25.3 1 0
+p/a.go:30.2,35.3 1 1
+p/b.go:10.2,15.3 1 1
+`},
+		{"count", `mode: count
+p/a.go:10.2,15.3 1 3
+p/a.go:20.2,25.3 1 0
+p/a.go:30.2,35.3 1 4294967295
+p/b.go:10.2,15.3 1 1
+`},
+	}
+	for _, tt := range tests {
+		m := newMerger()
+		for _, p := range []*coverage.Profile{
+			profile(tt.mode, map[string][]uint32{"p/a.go": {1, 0, math.MaxUint32 - 1}}),
+			profile(tt.mode, map[string][]uint32{"p/a.go": {2, 0, 2}, "p/b.go": {1}}),
+		} {
+			if err := m.add(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := text(t, m.profile()); got != tt.want {
+			t.Errorf("%s: merged profile:\n%s\nwant:\n%s", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	m := newMerger()
+	if err := m.add(profile("count", map[string][]uint32{"p/a.go": {1, 2, 3}, "p/b.go": {4}})); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.subtract(profile("count", map[string][]uint32{"p/a.go": {0, 7, 0}})); err != nil {
+		t.Fatal(err)
+	}
+	want := `mode: count
+p/a.go:10.2,15.3 1 1
+p/a.go:20.2,25.3 1 0
+p/a.go:30.2,35.3 1 3
+p/b.go:10.2,15.3 1 4
+`
+	if got := text(t, m.profile()); got != want {
+		t.Errorf("subtracted profile:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestModeMismatch(t *testing.T) {
+	m := newMerger()
+	if err := m.add(profile("set", map[string][]uint32{"p/a.go": {1}})); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.add(profile("count", map[string][]uint32{"p/a.go": {1}})); err == nil {
+		t.Error("merging set and count profiles succeeded, want error")
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Covdata is a program for manipulating the coverage data files written
+by programs built with 'go build -cover'. Such programs write a data
+file into the directory named by the GOCOVERDIR environment variable
+each time they exit.
+
+Covdata has several modes, each of which reads the data files in one
+or more input directories, given with the -i flag:
+
+	textfmt   convert the coverage data to the text profile format
+	          accepted by 'go tool cover -html' and 'go tool cover -func'
+	merge     merge the coverage data of several directories into a
+	          single data file in the output directory
+	subtract  write the coverage data of the first input directory,
+	          with the blocks covered by the later input directories
+	          marked as not covered, into the output directory
+
+Examples:
+
+	$ go build -cover -o myprogram .
+	$ mkdir somedata otherdata
+	$ GOCOVERDIR=somedata ./myprogram
+	$ GOCOVERDIR=otherdata ./myprogram -flag
+	$ go tool covdata merge -i=somedata,otherdata -o=merged
+	$ go tool covdata textfmt -i=merged -o=profile.txt
+	$ go tool cover -html=profile.txt
+
+For usage information, please see:
+	go tool covdata -help
+	go tool covdata <mode> -help
+*/
+package main
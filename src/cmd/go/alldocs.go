@@ -125,6 +125,22 @@
 // 	-asan
 // 		enable interoperation with address sanitizer.
 // 		Supported only on linux/arm64, linux/amd64.
+// 	-cover
+// 		enable code coverage instrumentation of the built program
+// 		(go build, go install and go run only). When the program
+// 		exits, it writes its coverage data into the directory named
+// 		by the GOCOVERDIR environment variable. Use 'go tool covdata'
+// 		to process the data files.
+// 	-covermode set,count,atomic
+// 		set the mode for coverage analysis, as for 'go test -cover'.
+// 		The default is "set" unless -race is enabled,
+// 		in which case it is "atomic". Sets -cover.
+// 	-coverpkg pattern1,pattern2,pattern3
+// 		apply coverage analysis to each package matching the patterns
+// 		that the built program depends on. The default is to apply
+// 		coverage analysis to the packages named on the command line
+// 		and the packages in the main module. See 'go help packages'
+// 		for a description of package patterns. Sets -cover.
 // 	-v
 // 		print the names of packages as they are compiled.
 // 	-work
@@ -201,6 +217,14 @@
 // 		include path must be in the same directory as the Go package they are
 // 		included from, and overlays will not appear when binaries and tests are
 // 		run through go run and go test respectively.
+// 	-pgo file
+// 		specify the file path of a CPU profile, as written by runtime/pprof,
+// 		for profile-guided optimization (PGO). The compiler uses the profile
+// 		to inline functions called from hot call sites more aggressively and
+// 		to devirtualize hot interface method calls. The contents of the
+// 		profile are part of the build cache key. An empty file is accepted
+// 		and has no effect. Special name "off" turns off PGO, which is the
+// 		default.
 // 	-pkgdir dir
 // 		install and load all packages from dir instead of the usual locations.
 // 		For example, when building with a non-standard configuration,
@@ -2907,12 +2931,16 @@
 // 	    Do not start new tests after the first test failure.
 //
 // 	-fuzz regexp
-// 	    Run the fuzz test matching the regular expression. When specified,
-// 	    the command line argument must match exactly one package within the
-// 	    main module, and regexp must match exactly one fuzz test within
-// 	    that package. Fuzzing will occur after tests, benchmarks, seed corpora
-// 	    of other fuzz tests, and examples have completed. See the Fuzzing
-// 	    section of the testing package documentation for details.
+// 	    Run the fuzz tests matching the regular expression. When specified,
+// 	    the command line arguments must match only packages within the
+// 	    main module. Fuzzing will occur after tests, benchmarks, seed corpora
+// 	    of other fuzz tests, and examples have completed, and only in packages
+// 	    whose tests passed. If regexp matches more than one fuzz test, in one
+// 	    or more packages, the fuzz tests share the time given by -fuzztime and
+// 	    take turns according to -fuzzschedule, and a summary of the new
+// 	    corpus entries and crashers found by each fuzz test is printed when
+// 	    fuzzing ends. See the Fuzzing section of the testing package
+// 	    documentation for details.
 //
 // 	-fuzztime t
 // 	    Run enough iterations of the fuzz target during fuzzing to take t,
@@ -2920,6 +2948,8 @@
 // 		The default is to run forever.
 // 	    The special syntax Nx means to run the fuzz target N times
 // 	    (for example, -fuzztime 1000x).
+// 	    When fuzzing more than one fuzz test, t is the total time for all of
+// 	    them, while Nx runs each fuzz test N times.
 //
 // 	-fuzzminimizetime t
 // 	    Run enough iterations of the fuzz target during each minimization
@@ -2929,6 +2959,14 @@
 // 	    The special syntax Nx means to run the fuzz target N times
 // 	    (for example, -fuzzminimizetime 100x).
 //
+// 	-fuzzschedule roundrobin,coverage
+// 	    When fuzzing more than one fuzz test, set how the fuzz tests take
+// 	    turns. With roundrobin, the default, the fuzz tests are fuzzed in turn
+// 	    for equal periods of at most a minute. With coverage, each period goes
+// 	    to the fuzz test that has found the most new coverage, measured in new
+// 	    corpus entries, relative to the time it has been fuzzed so far, so
+// 	    fuzz tests that keep finding new coverage are fuzzed for longer.
+//
 // 	-json
 // 	    Log verbose output and test results in JSON. This presents the
 // 	    same information as the -v flag in a machine-readable format.
@@ -0,0 +1,240 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Support for coverage instrumentation of packages.
+
+package load
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/cfg"
+)
+
+// DeclareCoverVars attaches the required cover variables names
+// to the files, to be used when annotating the files.
+func DeclareCoverVars(p *Package, files ...string) map[string]*CoverVar {
+	coverVars := make(map[string]*CoverVar)
+	coverIndex := 0
+	// We create the cover counters as new top-level variables in the package.
+	// We need to avoid collisions with user variables (GoCover_0 is unlikely but still)
+	// and more importantly with dot imports of other covered packages,
+	// so we append 12 hex digits from the SHA-256 of the import path.
+	// The point is only to avoid accidents, not to defeat users determined to
+	// break things.
+	sum := sha256.Sum256([]byte(p.ImportPath))
+	h := fmt.Sprintf("%x", sum[:6])
+	for _, file := range files {
+		if base.IsTestFile(file) {
+			continue
+		}
+		// For a package that is "local" (imported via ./ import or command line, outside GOPATH),
+		// we record the full path to the file name.
+		// Otherwise we record the import path, then a forward slash, then the file name.
+		// This makes profiles within GOPATH file system-independent.
+		// These names appear in the cmd/cover HTML interface.
+		var longFile string
+		if p.Internal.Local {
+			longFile = filepath.Join(p.Dir, file)
+		} else {
+			longFile = path.Join(p.ImportPath, file)
+		}
+		coverVars[file] = &CoverVar{
+			File: longFile,
+			Var:  fmt.Sprintf("GoCover_%d_%x", coverIndex, h),
+		}
+		coverIndex++
+	}
+	return coverVars
+}
+
+// EnsureImport ensures that package p imports the named package.
+func EnsureImport(p *Package, pkg string) {
+	for _, d := range p.Internal.Imports {
+		if d.Name == pkg {
+			return
+		}
+	}
+
+	p1 := LoadImportWithFlags(pkg, p.Dir, p, &ImportStack{}, nil, 0)
+	if p1.Error != nil {
+		base.Fatalf("load %s: %v", pkg, p1.Error)
+	}
+
+	p.Internal.Imports = append(p.Internal.Imports, p1)
+}
+
+// PrepareForCoverageBuild is invoked for "go build -cover", "go install
+// -cover" and "go run -cover" (but not "go test -cover"). It walks
+// through the packages being built and their dependencies, and marks
+// the ones selected by -coverpkg for coverage instrumentation. Without
+// -coverpkg, the packages named on the command line and the packages
+// of the main module are instrumented.
+//
+// The main packages in pkgs are set up to register the coverage
+// counters of all the instrumented packages they depend on, so that
+// the counters are written out when the program exits.
+func PrepareForCoverageBuild(pkgs []*Package) {
+	var match []func(*Package) bool
+	if len(cfg.BuildCoverPkg) != 0 {
+		match = make([]func(*Package) bool, len(cfg.BuildCoverPkg))
+		for i := range cfg.BuildCoverPkg {
+			match[i] = MatchPackage(cfg.BuildCoverPkg[i], base.Cwd())
+		}
+	} else {
+		match = []func(*Package) bool{func(p *Package) bool {
+			return p.Internal.CmdlineFiles || p.Internal.CmdlinePkg || (p.Module != nil && p.Module.Main)
+		}}
+	}
+
+	matched := make([]bool, len(match))
+	covered := make(map[*Package]bool)
+	for _, p := range PackageList(pkgs) {
+		haveMatch := false
+		for i := range match {
+			if match[i](p) {
+				matched[i] = true
+				haveMatch = true
+			}
+		}
+		if !haveMatch || !canCover(p) {
+			continue
+		}
+		covered[p] = true
+		p.Internal.CoverMode = cfg.BuildCoverMode
+		var coverFiles []string
+		coverFiles = append(coverFiles, p.GoFiles...)
+		coverFiles = append(coverFiles, p.CgoFiles...)
+		p.Internal.CoverVars = DeclareCoverVars(p, coverFiles...)
+		if cfg.BuildCoverMode == "atomic" {
+			EnsureImport(p, "sync/atomic")
+		}
+	}
+
+	// Warn about -coverpkg arguments that are not actually used.
+	for i := range cfg.BuildCoverPkg {
+		if !matched[i] {
+			fmt.Fprintf(os.Stderr, "warning: no packages being built depend on matches for pattern %s\n", cfg.BuildCoverPkg[i])
+		}
+	}
+
+	for _, p := range pkgs {
+		if p.Name != "main" {
+			continue
+		}
+		for _, p1 := range PackageList([]*Package{p}) {
+			if !covered[p1] {
+				continue
+			}
+			p.Internal.CoverRegister = append(p.Internal.CoverRegister, p1)
+			if p1 != p && !p.hasImport(p1) {
+				p.Internal.Imports = append(p.Internal.Imports, p1)
+			}
+		}
+		if len(p.Internal.CoverRegister) == 0 {
+			continue
+		}
+		// The registration code imports internal/coverage, which
+		// is not otherwise importable from outside the standard
+		// library, so load it without an importing package.
+		p1 := LoadImportWithFlags("internal/coverage", p.Dir, nil, &ImportStack{}, nil, 0)
+		if p1.Error != nil {
+			base.Fatalf("load internal/coverage: %v", p1.Error)
+		}
+		if !p.hasImport(p1) {
+			p.Internal.Imports = append(p.Internal.Imports, p1)
+		}
+	}
+}
+
+// canCover reports whether p can be instrumented for coverage
+// in a "go build -cover" build.
+func canCover(p *Package) bool {
+	// There is nothing to cover in package unsafe; it comes from
+	// the compiler, and packages with no Go files can't be imported.
+	if p.ImportPath == "unsafe" || len(p.GoFiles)+len(p.CgoFiles) == 0 {
+		return false
+	}
+	if p.Standard {
+		switch {
+		case p.ImportPath == "internal/coverage",
+			p.ImportPath == "runtime" || strings.HasPrefix(p.ImportPath, "runtime/internal"):
+			// Don't instrument the runtime and the package that
+			// writes out the coverage data: they run during
+			// program startup and exit, while the data is being
+			// registered and collected.
+			return false
+		case cfg.BuildCoverMode == "atomic" && p.ImportPath == "sync/atomic":
+			// Atomic coverage mode uses sync/atomic, so
+			// we can't also do coverage on it.
+			return false
+		}
+	}
+	return true
+}
+
+// hasImport reports whether p directly imports p1.
+func (p *Package) hasImport(p1 *Package) bool {
+	for _, d := range p.Internal.Imports {
+		if d == p1 {
+			return true
+		}
+	}
+	return false
+}
+
+// CoverRegistration returns the source of a Go file to be compiled
+// into main package p of a "go build -cover" build, which registers
+// the coverage counters of the packages in p.Internal.CoverRegister.
+// It returns nil if p doesn't register any counters.
+func CoverRegistration(p *Package) []byte {
+	if len(p.Internal.CoverRegister) == 0 {
+		return nil
+	}
+	type coverPkg struct {
+		Name string // import name, or "" for p itself
+		Path string
+		Vars map[string]*CoverVar
+	}
+	data := struct {
+		Mode string
+		Pkgs []coverPkg
+	}{Mode: cfg.BuildCoverMode}
+	for i, p1 := range p.Internal.CoverRegister {
+		cp := coverPkg{Path: p1.ImportPath, Vars: p1.Internal.CoverVars}
+		if p1 != p {
+			cp.Name = fmt.Sprintf("_cover%d", i)
+		}
+		data.Pkgs = append(data.Pkgs, cp)
+	}
+
+	var buf bytes.Buffer
+	if err := coverRegistrationTmpl.Execute(&buf, data); err != nil {
+		panic(err)
+	}
+	return buf.Bytes()
+}
+
+var coverRegistrationTmpl = template.Must(template.New("coverreg").Parse(`
+// Code generated by 'go build -cover'. DO NOT EDIT.
+
+package main
+
+import (
+	_coverage "internal/coverage"
+{{range .Pkgs}}{{if .Name}}	{{.Name}} {{printf "%q" .Path}}
+{{end}}{{end}})
+
+func init() {
+{{range $p := .Pkgs}}{{range $file, $cover := $p.Vars}}	_coverage.RegisterFile({{printf "%q" $.Mode}}, {{printf "%q" $cover.File}}, {{with $p.Name}}{{.}}.{{end}}{{$cover.Var}}.Count[:], {{with $p.Name}}{{.}}.{{end}}{{$cover.Var}}.Pos[:], {{with $p.Name}}{{.}}.{{end}}{{$cover.Var}}.NumStmt[:])
+{{end}}{{end}}}
+`))
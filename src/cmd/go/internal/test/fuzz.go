@@ -0,0 +1,346 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cmd/go/internal/base"
+	"cmd/go/internal/cache"
+	"cmd/go/internal/cfg"
+	"cmd/go/internal/str"
+	"cmd/go/internal/work"
+	"cmd/internal/test2json"
+)
+
+// When -fuzz matches more than one fuzz test, in one or more packages,
+// builderRunTest runs only the tests of each package, and keeps the test
+// binaries of the packages whose tests pass. Once all the packages have
+// been tested, builderFuzzTests fuzzes each of the matched fuzz tests in
+// turn, for periods of at most fuzzPeriod, until the time given by
+// -fuzztime has run out.
+
+// fuzzPeriod is the longest a fuzz test is fuzzed before another fuzz test
+// takes its turn.
+const fuzzPeriod = 1 * time.Minute
+
+// A fuzzTest is a fuzz test sharing the fuzzing time with others,
+// along with the results of fuzzing it.
+type fuzzTest struct {
+	a    *work.Action // test run action for the fuzz test's package
+	name string
+
+	elapsed  time.Duration // time spent fuzzing
+	corpus   int           // new entries in the cached corpus
+	crashers int           // new failing inputs in testdata/fuzz
+	failed   bool
+
+	// Number of corpus entries and failing inputs before fuzzing.
+	corpus0, crashers0 int
+}
+
+var sharedFuzz struct {
+	sync.Mutex
+	tests []*fuzzTest
+	dirs  map[string]bool // directories holding the kept test binaries
+}
+
+// listFuzzTests returns the names of the fuzz tests matching -fuzz in the
+// test binary run by a.
+func listFuzzTests(b *work.Builder, a *work.Action, execCmd []string) ([]string, error) {
+	args := str.StringList(execCmd, a.Deps[0].BuiltTarget(), "-test.list="+testFuzz)
+	if cfg.BuildX {
+		b.Showcmd("", "%s", strings.Join(args, " "))
+	}
+	var out bytes.Buffer
+	if err := testCommand(a.Package, args, &out).Run(); err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, name := range strings.Fields(out.String()) {
+		// -test.list also lists matching tests, benchmarks and examples.
+		if strings.HasPrefix(name, "Fuzz") {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// addFuzzTests schedules the named fuzz tests in the package tested by a
+// for fuzzing by builderFuzzTests.
+func addFuzzTests(a *work.Action, names []string) {
+	if len(names) == 0 {
+		return
+	}
+	sharedFuzz.Lock()
+	defer sharedFuzz.Unlock()
+	if sharedFuzz.dirs == nil {
+		sharedFuzz.dirs = make(map[string]bool)
+	}
+	sharedFuzz.dirs[a.Objdir] = true
+	for _, name := range names {
+		sharedFuzz.tests = append(sharedFuzz.tests, &fuzzTest{a: a, name: name})
+	}
+}
+
+// keepForFuzzing reports whether the test binary in dir must be kept
+// until builderFuzzTests is done with it.
+func keepForFuzzing(dir string) bool {
+	sharedFuzz.Lock()
+	defer sharedFuzz.Unlock()
+	return sharedFuzz.dirs[dir]
+}
+
+// builderFuzzTests is the action for fuzzing the fuzz tests scheduled by
+// addFuzzTests, once all packages have been tested.
+func builderFuzzTests(b *work.Builder, ctx context.Context, a *work.Action) error {
+	sharedFuzz.Lock()
+	tests, dirs := sharedFuzz.tests, sharedFuzz.dirs
+	sharedFuzz.Unlock()
+	if !cfg.BuildWork {
+		defer func() {
+			for dir := range dirs {
+				if cfg.BuildX {
+					b.Showcmd("", "rm -r %s", dir)
+				}
+				os.RemoveAll(dir)
+			}
+		}()
+	}
+	if len(tests) == 0 {
+		return nil
+	}
+
+	budget, count, err := parseFuzzTime(testFuzzTime)
+	if err != nil {
+		base.Errorf("invalid value %q for flag -fuzztime: %v", testFuzzTime, err)
+		return nil
+	}
+
+	base.StartSigHandlers()
+	for _, t := range tests {
+		t.corpus0 = countFiles(t.corpusDir())
+		t.crashers0 = countFiles(t.crashersDir())
+	}
+
+	if count > 0 {
+		// Each fuzz test runs the requested number of times.
+		for _, t := range tests {
+			if interrupted() {
+				break
+			}
+			t.fuzz(b, testFuzzTime)
+		}
+	} else {
+		period := fuzzPeriod
+		if budget > 0 && budget/time.Duration(len(tests)) < period {
+			period = (budget / time.Duration(len(tests))).Round(time.Millisecond)
+			if period <= 0 {
+				period = time.Millisecond
+			}
+		}
+		remaining := budget
+		next := 0
+		for !interrupted() && (budget == 0 || remaining > 0) {
+			var t *fuzzTest
+			if testFuzzSchedule == "coverage" {
+				t = nextByCoverage(tests)
+			} else {
+				t, next = nextRoundRobin(tests, next)
+			}
+			if t == nil {
+				break // All fuzz tests have failed.
+			}
+			d := period
+			if budget > 0 && d > remaining {
+				d = remaining.Round(time.Millisecond)
+				if d <= 0 {
+					break
+				}
+			}
+			start := time.Now()
+			t.fuzz(b, d.String())
+			remaining -= time.Since(start)
+		}
+	}
+
+	printFuzzSummary(tests)
+	return nil
+}
+
+// nextRoundRobin returns the first fuzz test that has not failed, starting
+// at tests[i], and the index at which to start looking for the one after it.
+func nextRoundRobin(tests []*fuzzTest, i int) (*fuzzTest, int) {
+	for j := 0; j < len(tests); j++ {
+		k := (i + j) % len(tests)
+		if !tests[k].failed {
+			return tests[k], k + 1
+		}
+	}
+	return nil, i
+}
+
+// nextByCoverage returns the fuzz test that has not failed and has found
+// the most new corpus entries relative to the time it has been fuzzed.
+// Fuzz tests that have not been fuzzed yet come first.
+func nextByCoverage(tests []*fuzzTest) *fuzzTest {
+	var best *fuzzTest
+	var bestRate float64
+	for _, t := range tests {
+		if t.failed {
+			continue
+		}
+		if t.elapsed == 0 {
+			return t
+		}
+		// Count one entry and one second more than measured, so that
+		// fuzz tests that have found nothing yet still get a turn.
+		rate := float64(t.corpus+1) / (t.elapsed.Seconds() + 1)
+		if best == nil || rate > bestRate {
+			best, bestRate = t, rate
+		}
+	}
+	return best
+}
+
+// fuzz runs the test binary to fuzz t for the given -fuzztime.
+func (t *fuzzTest) fuzz(b *work.Builder, fuzztime string) {
+	p := t.a.Package
+	args := str.StringList(
+		work.FindExecCmd(),
+		t.a.Deps[0].BuiltTarget(),
+		"-test.paniconexit0",
+		"-test.fuzzcachedir="+filepath.Join(cache.Default().FuzzDir(), p.ImportPath),
+		"-test.run=^$",
+		"-test.fuzz=^"+regexp.QuoteMeta(t.name)+"$",
+		"-test.fuzztime="+fuzztime,
+		withoutTestFlags(testArgs, "run", "fuzz", "fuzztime"))
+	if cfg.BuildX {
+		b.Showcmd("", "%s", strings.Join(args, " "))
+	}
+
+	var stdout io.Writer = os.Stdout
+	var err error
+	if testJSON {
+		json := test2json.NewConverter(lockedStdout{}, p.ImportPath, test2json.Timestamp)
+		defer func() {
+			json.Exited(err)
+			json.Close()
+		}()
+		stdout = json
+	} else {
+		fmt.Fprintf(stdout, "=== FUZZ  %s %s -fuzztime=%s\n", p.ImportPath, t.name, fuzztime)
+	}
+
+	start := time.Now()
+	err = testCommand(p, args, stdout).Run()
+	t.elapsed += time.Since(start)
+	t.corpus = countFiles(t.corpusDir()) - t.corpus0
+	t.crashers = countFiles(t.crashersDir()) - t.crashers0
+	if err != nil && !interrupted() {
+		// The fuzz test found a failing input, or could not be fuzzed at all.
+		// Either way, fuzzing it again would fail the same way.
+		t.failed = true
+		base.SetExitStatus(1)
+	}
+}
+
+// corpusDir returns the directory holding t's cached corpus.
+func (t *fuzzTest) corpusDir() string {
+	return filepath.Join(cache.Default().FuzzDir(), t.a.Package.ImportPath, t.name)
+}
+
+// crashersDir returns the directory in which failing inputs for t are written.
+func (t *fuzzTest) crashersDir() string {
+	return filepath.Join(t.a.Package.Dir, "testdata", "fuzz", t.name)
+}
+
+// printFuzzSummary prints the results of fuzzing each of tests.
+// In JSON mode, the results have already been reported as test events.
+func printFuzzSummary(tests []*fuzzTest) {
+	if testJSON {
+		return
+	}
+	fmt.Printf("fuzz summary:\n")
+	for _, t := range tests {
+		status := "ok  "
+		if t.failed {
+			status = "FAIL"
+		}
+		fmt.Printf("%s\t%s\t%s\t%.3fs\tnew corpus entries: %d, crashers: %d\n",
+			status, t.a.Package.ImportPath, t.name, t.elapsed.Seconds(), t.corpus, t.crashers)
+	}
+}
+
+// withoutTestFlags returns args without any -test.<name>= flags
+// for the given names.
+func withoutTestFlags(args []string, names ...string) []string {
+	var kept []string
+Args:
+	for _, arg := range args {
+		for _, name := range names {
+			if strings.HasPrefix(arg, "-test."+name+"=") {
+				continue Args
+			}
+		}
+		kept = append(kept, arg)
+	}
+	return kept
+}
+
+// parseFuzzTime parses the value of the -fuzztime flag, which is either
+// a duration or a count of the form Nx. A zero duration means to fuzz
+// until interrupted.
+func parseFuzzTime(s string) (d time.Duration, n int64, err error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	if strings.HasSuffix(s, "x") {
+		n, err = strconv.ParseInt(s[:len(s)-1], 10, 0)
+		if err == nil && n <= 0 {
+			err = errors.New("count must be positive")
+		}
+		return 0, n, err
+	}
+	d, err = time.ParseDuration(s)
+	if err == nil && d < 0 {
+		err = errors.New("duration must not be negative")
+	}
+	return d, 0, err
+}
+
+// countFiles returns the number of files in dir,
+// or 0 if dir cannot be read.
+func countFiles(dir string) int {
+	entries, _ := os.ReadDir(dir)
+	n := 0
+	for _, e := range entries {
+		if !e.IsDir() {
+			n++
+		}
+	}
+	return n
+}
+
+// interrupted reports whether the go command has received an interrupt.
+func interrupted() bool {
+	select {
+	case <-base.Interrupted:
+		return true
+	default:
+		return false
+	}
+}
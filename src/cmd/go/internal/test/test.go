@@ -7,7 +7,6 @@ package test
 import (
 	"bytes"
 	"context"
-	"crypto/sha256"
 	"errors"
 	"fmt"
 	"go/build"
@@ -15,7 +14,6 @@ import (
 	"io"
 	"io/fs"
 	"os"
-	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -249,12 +247,16 @@ control the execution of any test:
 	    Do not start new tests after the first test failure.
 
 	-fuzz regexp
-	    Run the fuzz test matching the regular expression. When specified,
-	    the command line argument must match exactly one package within the
-	    main module, and regexp must match exactly one fuzz test within
-	    that package. Fuzzing will occur after tests, benchmarks, seed corpora
-	    of other fuzz tests, and examples have completed. See the Fuzzing
-	    section of the testing package documentation for details.
+	    Run the fuzz tests matching the regular expression. When specified,
+	    the command line arguments must match only packages within the
+	    main module. Fuzzing will occur after tests, benchmarks, seed corpora
+	    of other fuzz tests, and examples have completed, and only in packages
+	    whose tests passed. If regexp matches more than one fuzz test, in one
+	    or more packages, the fuzz tests share the time given by -fuzztime and
+	    take turns according to -fuzzschedule, and a summary of the new
+	    corpus entries and crashers found by each fuzz test is printed when
+	    fuzzing ends. See the Fuzzing section of the testing package
+	    documentation for details.
 
 	-fuzztime t
 	    Run enough iterations of the fuzz target during fuzzing to take t,
@@ -262,6 +264,8 @@ control the execution of any test:
 		The default is to run forever.
 	    The special syntax Nx means to run the fuzz target N times
 	    (for example, -fuzztime 1000x).
+	    When fuzzing more than one fuzz test, t is the total time for all of
+	    them, while Nx runs each fuzz test N times.
 
 	-fuzzminimizetime t
 	    Run enough iterations of the fuzz target during each minimization
@@ -271,6 +275,14 @@ control the execution of any test:
 	    The special syntax Nx means to run the fuzz target N times
 	    (for example, -fuzzminimizetime 100x).
 
+	-fuzzschedule roundrobin,coverage
+	    When fuzzing more than one fuzz test, set how the fuzz tests take
+	    turns. With roundrobin, the default, the fuzz tests are fuzzed in turn
+	    for equal periods of at most a minute. With coverage, each period goes
+	    to the fuzz test that has found the most new coverage, measured in new
+	    corpus entries, relative to the time it has been fuzzed so far, so
+	    fuzz tests that keep finding new coverage are fuzzed for longer.
+
 	-json
 	    Log verbose output and test results in JSON. This presents the
 	    same information as the -v flag in a machine-readable format.
@@ -533,6 +545,8 @@ var (
 	testCoverPkgs    []*load.Package                   // -coverpkg flag
 	testCoverProfile string                            // -coverprofile flag
 	testFuzz         string                            // -fuzz flag
+	testFuzzSchedule fuzzScheduleFlag                  // -fuzzschedule flag
+	testFuzzTime     string                            // -fuzztime flag
 	testJSON         bool                              // -json flag
 	testList         string                            // -list flag
 	testO            string                            // -o flag
@@ -670,9 +684,6 @@ func runTest(ctx context.Context, cmd *base.Command, args []string) {
 		if !sys.FuzzSupported(cfg.Goos, cfg.Goarch) {
 			base.Fatalf("-fuzz flag is not supported on %s/%s", cfg.Goos, cfg.Goarch)
 		}
-		if len(pkgs) != 1 {
-			base.Fatalf("cannot use -fuzz flag with multiple packages")
-		}
 		if testCoverProfile != "" {
 			base.Fatalf("cannot use -coverprofile flag with -fuzz flag")
 		}
@@ -680,34 +691,36 @@ func runTest(ctx context.Context, cmd *base.Command, args []string) {
 			base.Fatalf("cannot use %s flag with -fuzz flag", profileFlag)
 		}
 
-		// Reject the '-fuzz' flag if the package is outside the main module.
+		// Reject the '-fuzz' flag if any package is outside the main module.
 		// Otherwise, if fuzzing identifies a failure it could corrupt checksums in
 		// the module cache (or permanently alter the behavior of std tests for all
 		// users) by writing the failing input to the package's testdata directory.
 		// (See https://golang.org/issue/48495 and test_fuzz_modcache.txt.)
 		mainMods := modload.MainModules
-		if m := pkgs[0].Module; m != nil && m.Path != "" {
-			if !mainMods.Contains(m.Path) {
-				base.Fatalf("cannot use -fuzz flag on package outside the main module")
-			}
-		} else if pkgs[0].Standard && modload.Enabled() {
-			// Because packages in 'std' and 'cmd' are part of the standard library,
-			// they are only treated as part of a module in 'go mod' subcommands and
-			// 'go get'. However, we still don't want to accidentally corrupt their
-			// testdata during fuzzing, nor do we want to fail with surprising errors
-			// if GOROOT isn't writable (as is often the case for Go toolchains
-			// installed through package managers).
-			//
-			// If the user is requesting to fuzz a standard-library package, ensure
-			// that they are in the same module as that package (just like when
-			// fuzzing any other package).
-			if strings.HasPrefix(pkgs[0].ImportPath, "cmd/") {
-				if !mainMods.Contains("cmd") || !mainMods.InGorootSrc(module.Version{Path: "cmd"}) {
+		for _, p := range pkgs {
+			if m := p.Module; m != nil && m.Path != "" {
+				if !mainMods.Contains(m.Path) {
 					base.Fatalf("cannot use -fuzz flag on package outside the main module")
 				}
-			} else {
-				if !mainMods.Contains("std") || !mainMods.InGorootSrc(module.Version{Path: "std"}) {
-					base.Fatalf("cannot use -fuzz flag on package outside the main module")
+			} else if p.Standard && modload.Enabled() {
+				// Because packages in 'std' and 'cmd' are part of the standard library,
+				// they are only treated as part of a module in 'go mod' subcommands and
+				// 'go get'. However, we still don't want to accidentally corrupt their
+				// testdata during fuzzing, nor do we want to fail with surprising errors
+				// if GOROOT isn't writable (as is often the case for Go toolchains
+				// installed through package managers).
+				//
+				// If the user is requesting to fuzz a standard-library package, ensure
+				// that they are in the same module as that package (just like when
+				// fuzzing any other package).
+				if strings.HasPrefix(p.ImportPath, "cmd/") {
+					if !mainMods.Contains("cmd") || !mainMods.InGorootSrc(module.Version{Path: "cmd"}) {
+						base.Fatalf("cannot use -fuzz flag on package outside the main module")
+					}
+				} else {
+					if !mainMods.Contains("std") || !mainMods.InGorootSrc(module.Version{Path: "std"}) {
+						base.Fatalf("cannot use -fuzz flag on package outside the main module")
+					}
 				}
 			}
 		}
@@ -867,9 +880,9 @@ func runTest(ctx context.Context, cmd *base.Command, args []string) {
 			coverFiles = append(coverFiles, p.GoFiles...)
 			coverFiles = append(coverFiles, p.CgoFiles...)
 			coverFiles = append(coverFiles, p.TestGoFiles...)
-			p.Internal.CoverVars = declareCoverVars(p, coverFiles...)
+			p.Internal.CoverVars = load.DeclareCoverVars(p, coverFiles...)
 			if testCover && testCoverMode == "atomic" {
-				ensureImport(p, "sync/atomic")
+				load.EnsureImport(p, "sync/atomic")
 			}
 		}
 	}
@@ -913,7 +926,7 @@ func runTest(ctx context.Context, cmd *base.Command, args []string) {
 	for _, p := range pkgs {
 		// sync/atomic import is inserted by the cover tool. See #18486
 		if testCover && testCoverMode == "atomic" {
-			ensureImport(p, "sync/atomic")
+			load.EnsureImport(p, "sync/atomic")
 		}
 
 		buildTest, runTest, printTest, err := builderTest(&b, ctx, pkgOpts, p, allImports[p])
@@ -936,6 +949,13 @@ func runTest(ctx context.Context, cmd *base.Command, args []string) {
 	// Ultimately the goal is to print the output.
 	root := &work.Action{Mode: "go test", Func: printExitStatus, Deps: prints}
 
+	// Fuzz tests that share the fuzzing time are fuzzed once all the
+	// packages have been tested.
+	if !testC && testFuzz != "" {
+		fuzz := &work.Action{Mode: "test fuzz", Func: builderFuzzTests, Deps: prints}
+		root.Deps = []*work.Action{fuzz}
+	}
+
 	// Force the printing of results to happen in order,
 	// one at a time.
 	for i, a := range prints {
@@ -944,8 +964,8 @@ func runTest(ctx context.Context, cmd *base.Command, args []string) {
 		}
 	}
 
-	// Force benchmarks to run in serial.
-	if !testC && (testBench != "") {
+	// Force benchmarks and fuzzing to run in serial.
+	if !testC && (testBench != "" || testFuzz != "") {
 		// The first run must wait for all builds.
 		// Later runs must wait for the previous run's print.
 		for i, run := range runs {
@@ -961,21 +981,6 @@ func runTest(ctx context.Context, cmd *base.Command, args []string) {
 }
 
 // ensures that package p imports the named package
-func ensureImport(p *load.Package, pkg string) {
-	for _, d := range p.Internal.Imports {
-		if d.Name == pkg {
-			return
-		}
-	}
-
-	p1 := load.LoadImportWithFlags(pkg, p.Dir, p, &load.ImportStack{}, nil, 0)
-	if p1.Error != nil {
-		base.Fatalf("load %s: %v", pkg, p1.Error)
-	}
-
-	p.Internal.Imports = append(p.Internal.Imports, p1)
-}
-
 var windowsBadWords = []string{
 	"install",
 	"patch",
@@ -1003,7 +1008,7 @@ func builderTest(b *work.Builder, ctx context.Context, pkgOpts load.PackageOpts,
 			Local:    testCover && testCoverPaths == nil,
 			Pkgs:     testCoverPkgs,
 			Paths:    testCoverPaths,
-			DeclVars: declareCoverVars,
+			DeclVars: load.DeclareCoverVars,
 		}
 	}
 	pmain, ptest, pxtest, err := load.TestPackagesFor(ctx, pkgOpts, p, cover)
@@ -1179,50 +1184,6 @@ func addTestVet(b *work.Builder, p *load.Package, runAction, installAction *work
 	}
 }
 
-// isTestFile reports whether the source file is a set of tests and should therefore
-// be excluded from coverage analysis.
-func isTestFile(file string) bool {
-	// We don't cover tests, only the code they test.
-	return strings.HasSuffix(file, "_test.go")
-}
-
-// declareCoverVars attaches the required cover variables names
-// to the files, to be used when annotating the files.
-func declareCoverVars(p *load.Package, files ...string) map[string]*load.CoverVar {
-	coverVars := make(map[string]*load.CoverVar)
-	coverIndex := 0
-	// We create the cover counters as new top-level variables in the package.
-	// We need to avoid collisions with user variables (GoCover_0 is unlikely but still)
-	// and more importantly with dot imports of other covered packages,
-	// so we append 12 hex digits from the SHA-256 of the import path.
-	// The point is only to avoid accidents, not to defeat users determined to
-	// break things.
-	sum := sha256.Sum256([]byte(p.ImportPath))
-	h := fmt.Sprintf("%x", sum[:6])
-	for _, file := range files {
-		if isTestFile(file) {
-			continue
-		}
-		// For a package that is "local" (imported via ./ import or command line, outside GOPATH),
-		// we record the full path to the file name.
-		// Otherwise we record the import path, then a forward slash, then the file name.
-		// This makes profiles within GOPATH file system-independent.
-		// These names appear in the cmd/cover HTML interface.
-		var longFile string
-		if p.Internal.Local {
-			longFile = filepath.Join(p.Dir, file)
-		} else {
-			longFile = path.Join(p.ImportPath, file)
-		}
-		coverVars[file] = &load.CoverVar{
-			File: longFile,
-			Var:  fmt.Sprintf("GoCover_%d_%x", coverIndex, h),
-		}
-		coverIndex++
-	}
-	return coverVars
-}
-
 var noTestsToRun = []byte("\ntesting: warning: no tests to run\n")
 var noFuzzTestsToFuzz = []byte("\ntesting: warning: no fuzz tests to fuzz\n")
 var tooManyFuzzTestsToFuzz = []byte("\ntesting: warning: -fuzz matches more than one fuzz test, won't fuzz\n")
@@ -1330,11 +1291,27 @@ func (c *runCache) builderRunTest(b *work.Builder, ctx context.Context, a *work.
 	}
 	panicArg := "-test.paniconexit0"
 	fuzzArg := []string{}
+	runArgs := testArgs
+	var fuzzTests []string
+	fuzzLater := false
 	if testFuzz != "" {
 		fuzzCacheDir := filepath.Join(cache.Default().FuzzDir(), a.Package.ImportPath)
 		fuzzArg = []string{"-test.fuzzcachedir=" + fuzzCacheDir}
+
+		// If -fuzz matches more than one fuzz test, here or in other
+		// packages, run only the tests now, and leave fuzzing to
+		// builderFuzzTests.
+		if !cfg.BuildN {
+			var listErr error
+			fuzzTests, listErr = listFuzzTests(b, a, execCmd)
+			fuzzLater = listErr == nil && (len(pkgs) > 1 || len(fuzzTests) > 1)
+		}
+		if fuzzLater {
+			fuzzArg = nil
+			runArgs = withoutTestFlags(testArgs, "fuzz")
+		}
 	}
-	args := str.StringList(execCmd, a.Deps[0].BuiltTarget(), testlogArg, panicArg, fuzzArg, testArgs)
+	args := str.StringList(execCmd, a.Deps[0].BuiltTarget(), testlogArg, panicArg, fuzzArg, runArgs)
 
 	if testCoverProfile != "" {
 		// Write coverage to temporary profile, for merging later.
@@ -1352,30 +1329,7 @@ func (c *runCache) builderRunTest(b *work.Builder, ctx context.Context, a *work.
 		}
 	}
 
-	cmd := exec.Command(args[0], args[1:]...)
-	cmd.Dir = a.Package.Dir
-	cmd.Env = base.AppendPWD(cfg.OrigEnv[:len(cfg.OrigEnv):len(cfg.OrigEnv)], cmd.Dir)
-	cmd.Stdout = stdout
-	cmd.Stderr = stdout
-
-	// If there are any local SWIG dependencies, we want to load
-	// the shared library from the build directory.
-	if a.Package.UsesSwig() {
-		env := cmd.Env
-		found := false
-		prefix := "LD_LIBRARY_PATH="
-		for i, v := range env {
-			if strings.HasPrefix(v, prefix) {
-				env[i] = v + ":."
-				found = true
-				break
-			}
-		}
-		if !found {
-			env = append(env, "LD_LIBRARY_PATH=.")
-		}
-		cmd.Env = env
-	}
+	cmd := testCommand(a.Package, args, stdout)
 
 	t0 := time.Now()
 	err = cmd.Start()
@@ -1433,6 +1387,12 @@ func (c *runCache) builderRunTest(b *work.Builder, ctx context.Context, a *work.
 		if bytes.HasPrefix(out, tooManyFuzzTestsToFuzz[1:]) || bytes.Contains(out, tooManyFuzzTestsToFuzz) {
 			norun = "[-fuzz matches more than one fuzz test, won't fuzz]"
 		}
+		if fuzzLater {
+			if len(fuzzTests) == 0 {
+				norun = " [no fuzz tests to fuzz]"
+			}
+			addFuzzTests(a, fuzzTests)
+		}
 		if len(out) > 0 && !bytes.HasSuffix(out, []byte("\n")) {
 			// Ensure that the output ends with a newline before the "ok"
 			// line we're about to print (https://golang.org/issue/49317).
@@ -1469,6 +1429,36 @@ func (c *runCache) builderRunTest(b *work.Builder, ctx context.Context, a *work.
 	return nil
 }
 
+// testCommand returns a command that runs a test binary for package p
+// with the given arguments, writing its output to stdout.
+func testCommand(p *load.Package, args []string, stdout io.Writer) *exec.Cmd {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = p.Dir
+	cmd.Env = base.AppendPWD(cfg.OrigEnv[:len(cfg.OrigEnv):len(cfg.OrigEnv)], cmd.Dir)
+	cmd.Stdout = stdout
+	cmd.Stderr = stdout
+
+	// If there are any local SWIG dependencies, we want to load
+	// the shared library from the build directory.
+	if p.UsesSwig() {
+		env := cmd.Env
+		found := false
+		prefix := "LD_LIBRARY_PATH="
+		for i, v := range env {
+			if strings.HasPrefix(v, prefix) {
+				env[i] = v + ":."
+				found = true
+				break
+			}
+		}
+		if !found {
+			env = append(env, "LD_LIBRARY_PATH=.")
+		}
+		cmd.Env = env
+	}
+	return cmd
+}
+
 // tryCache is called just before the link attempt,
 // to see if the test result is cached and therefore the link is unneeded.
 // It reports whether the result can be satisfied from cache.
@@ -1846,7 +1836,7 @@ func coveragePercentage(out []byte) string {
 
 // builderCleanTest is the action for cleaning up after a test.
 func builderCleanTest(b *work.Builder, ctx context.Context, a *work.Action) error {
-	if cfg.BuildWork {
+	if cfg.BuildWork || keepForFuzzing(a.Objdir) {
 		return nil
 	}
 	if cfg.BuildX {
@@ -1890,12 +1880,11 @@ func builderNoTest(b *work.Builder, ctx context.Context, a *work.Action) error {
 // anyway, so the failure will not be missed and would be
 // awkward to try to wedge into the JSON stream.
 //
-// In fuzz mode, we only allow a single package for now
-// (see CL 350156 and https://golang.org/issue/46312),
-// so there is no possibility of scrolling off and no need
-// to print the final status.
+// When fuzzing a single package, the fuzzing output is the last
+// thing printed, so there is no possibility of scrolling off and no
+// need to print the final status.
 func printExitStatus(b *work.Builder, ctx context.Context, a *work.Action) error {
-	if !testJSON && testFuzz == "" && len(pkgArgs) != 0 {
+	if !testJSON && (testFuzz == "" || len(pkgs) > 1) && len(pkgArgs) != 0 {
 		if base.GetExitStatus() != 0 {
 			fmt.Println("FAIL")
 			return nil
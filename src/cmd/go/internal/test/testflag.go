@@ -40,6 +40,7 @@ func init() {
 	cf.Var(coverFlag{commaListFlag{&testCoverPaths}}, "coverpkg", "")
 
 	cf.Var((*base.StringsFlag)(&work.ExecCmd), "exec", "")
+	cf.Var(&testFuzzSchedule, "fuzzschedule", "")
 	cf.BoolVar(&testJSON, "json", false, "")
 	cf.Var(&testVet, "vet", "")
 
@@ -68,7 +69,7 @@ func init() {
 	cf.String("run", "", "")
 	cf.Bool("short", false, "")
 	cf.DurationVar(&testTimeout, "timeout", 10*time.Minute, "")
-	cf.String("fuzztime", "", "")
+	cf.StringVar(&testFuzzTime, "fuzztime", "", "")
 	cf.String("fuzzminimizetime", "", "")
 	cf.StringVar(&testTrace, "trace", "", "")
 	cf.BoolVar(&testV, "v", false, "")
@@ -105,6 +106,19 @@ func (f *coverModeFlag) Set(value string) error {
 	}
 }
 
+type fuzzScheduleFlag string
+
+func (f *fuzzScheduleFlag) String() string { return string(*f) }
+func (f *fuzzScheduleFlag) Set(value string) error {
+	switch value {
+	case "", "roundrobin", "coverage":
+		*f = fuzzScheduleFlag(value)
+		return nil
+	default:
+		return errors.New(`valid schedules are "roundrobin" or "coverage"`)
+	}
+}
+
 // A commaListFlag is a flag.Value representing a comma-separated list.
 type commaListFlag struct{ vals *[]string }
 
@@ -9,6 +9,7 @@ package work
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -297,6 +298,9 @@ func (b *Builder) buildActionID(a *Action) cache.ActionID {
 	if p.Internal.CoverMode != "" {
 		fmt.Fprintf(h, "cover %q %q\n", p.Internal.CoverMode, b.toolID("cover"))
 	}
+	if src := load.CoverRegistration(p); src != nil {
+		fmt.Fprintf(h, "coverregister %x\n", sha256.Sum256(src))
+	}
 	if p.Internal.FuzzInstrument {
 		if fuzzFlags := fuzzInstrumentFlags(); fuzzFlags != nil {
 			fmt.Fprintf(h, "fuzz %q\n", fuzzFlags)
@@ -310,6 +314,9 @@ func (b *Builder) buildActionID(a *Action) cache.ActionID {
 		base.Fatalf("buildActionID: unknown build toolchain %q", cfg.BuildToolchainName)
 	case "gc":
 		fmt.Fprintf(h, "compile %s %q %q\n", b.toolID("compile"), forcedGcflags, p.Internal.Gcflags)
+		if cfg.BuildPGO != "" {
+			fmt.Fprintf(h, "pgofile %s\n", b.fileHash(cfg.BuildPGO))
+		}
 		if len(p.SFiles) > 0 {
 			fmt.Fprintf(h, "asm %q %q %q\n", b.toolID("asm"), forcedAsmflags, p.Internal.Asmflags)
 		}
@@ -658,6 +665,17 @@ OverlayLoop:
 		}
 	}
 
+	// If this is the main package of a "go build -cover" build, add a
+	// file that registers the coverage counters of the instrumented
+	// packages, so that they are written out when the program exits.
+	if src := load.CoverRegistration(a.Package); src != nil {
+		file := objdir + "_coverregister.go"
+		if err := b.writeFile(file, src); err != nil {
+			return err
+		}
+		gofiles = append(gofiles, file)
+	}
+
 	// Run cgo.
 	if a.Package.UsesCgo() || a.Package.UsesSwig() {
 		// In a package using cgo, cgo compiles the C, C++ and assembly files with gcc.
@@ -39,6 +39,35 @@ func BuildInit() {
 		cfg.BuildPkgdir = p
 	}
 
+	// Make sure -pgo is absolute, for the same reason,
+	// and that it names an existing file.
+	if cfg.BuildPGO == "off" {
+		cfg.BuildPGO = ""
+	}
+	if cfg.BuildPGO != "" {
+		p, err := filepath.Abs(cfg.BuildPGO)
+		if err == nil {
+			_, err = fsys.Stat(p)
+		}
+		if err != nil {
+			base.Fatalf("go: invalid -pgo profile: %v", err)
+		}
+		cfg.BuildPGO = p
+	}
+
+	if cfg.BuildCover {
+		if cfg.BuildCoverMode == "" {
+			cfg.BuildCoverMode = "set"
+			if cfg.BuildRace {
+				// Default coverage mode is atomic when -race is set.
+				cfg.BuildCoverMode = "atomic"
+			}
+		}
+		if cfg.BuildRace && cfg.BuildCoverMode != "atomic" {
+			base.Fatalf(`go: -covermode must be "atomic", not %q, when -race is enabled`, cfg.BuildCoverMode)
+		}
+	}
+
 	if cfg.BuildP <= 0 {
 		base.Fatalf("go: -p must be a positive integer: %v\n", cfg.BuildP)
 	}
@@ -684,7 +684,7 @@ func generateTrace(params *traceParams, consumer traceConsumer) error {
 				text = "MARK ASSIST (unfinished)"
 			}
 			ctx.emitSlice(&fakeMarkStart, text)
-		case trace.EvGCSweepStart:
+		case trace.EvGCSweepStart, trace.EvGCSweepActive:
 			slice := ctx.makeSlice(ev, "SWEEP")
 			if done := ev.Link; done != nil && done.Args[0] != 0 {
 				slice.Arg = struct {
@@ -6,6 +6,7 @@ package context_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -118,3 +119,41 @@ func ExampleWithValue() {
 	// found value: Go
 	// key not found: color
 }
+
+// This example records why a context was canceled and retrieves
+// that reason with Cause.
+func ExampleWithCancelCause() {
+	errShutdown := errors.New("server shutting down")
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errShutdown)
+
+	fmt.Println(ctx.Err())
+	fmt.Println(context.Cause(ctx))
+
+	// Output:
+	// context canceled
+	// server shutting down
+}
+
+// This example uses AfterFunc to run cleanup work once a context is
+// canceled, without dedicating a goroutine to waiting on ctx.Done.
+func ExampleAfterFunc() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		fmt.Println("cleaning up")
+		close(done)
+	})
+
+	cancel()
+	<-done
+
+	// The function has already been started, so stop reports false.
+	fmt.Println("stopped:", stop())
+
+	// Output:
+	// cleaning up
+	// stopped: false
+}
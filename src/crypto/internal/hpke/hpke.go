@@ -0,0 +1,277 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package hpke implements the base mode of Hybrid Public Key Encryption,
+// as specified in RFC 9180, for the algorithms needed by crypto/tls.
+package hpke
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	_ "crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Algorithm identifiers, from the IANA HPKE registry.
+const (
+	DHKEM_X25519_HKDF_SHA256 uint16 = 0x0020
+
+	KDF_HKDF_SHA256 uint16 = 0x0001
+
+	AEAD_AES_128_GCM      uint16 = 0x0001
+	AEAD_AES_256_GCM      uint16 = 0x0002
+	AEAD_ChaCha20Poly1305 uint16 = 0x0003
+)
+
+// SupportedKEMs, SupportedKDFs and SupportedAEADs report which algorithms
+// are implemented by this package.
+var (
+	SupportedKEMs = map[uint16]bool{
+		DHKEM_X25519_HKDF_SHA256: true,
+	}
+	SupportedKDFs = map[uint16]bool{
+		KDF_HKDF_SHA256: true,
+	}
+	SupportedAEADs = map[uint16]bool{
+		AEAD_AES_128_GCM:      true,
+		AEAD_AES_256_GCM:      true,
+		AEAD_ChaCha20Poly1305: true,
+	}
+)
+
+// hkdfHash is the hash of HKDF-SHA256, which is both the KDF of
+// DHKEM(X25519, HKDF-SHA256) and the only supported KDF.
+const hkdfHash = crypto.SHA256
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func labeledExtract(suiteID, salt []byte, label string, ikm []byte) []byte {
+	labeledIKM := make([]byte, 0, 7+len(suiteID)+len(label)+len(ikm))
+	labeledIKM = append(labeledIKM, "HPKE-v1"...)
+	labeledIKM = append(labeledIKM, suiteID...)
+	labeledIKM = append(labeledIKM, label...)
+	labeledIKM = append(labeledIKM, ikm...)
+	return hkdf.Extract(hkdfHash.New, labeledIKM, salt)
+}
+
+func labeledExpand(suiteID, prk []byte, label string, info []byte, length uint16) []byte {
+	labeledInfo := make([]byte, 0, 2+7+len(suiteID)+len(label)+len(info))
+	labeledInfo = appendUint16(labeledInfo, length)
+	labeledInfo = append(labeledInfo, "HPKE-v1"...)
+	labeledInfo = append(labeledInfo, suiteID...)
+	labeledInfo = append(labeledInfo, label...)
+	labeledInfo = append(labeledInfo, info...)
+	out := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.Expand(hkdfHash.New, prk, labeledInfo), out); err != nil {
+		panic("hpke: internal error: " + err.Error())
+	}
+	return out
+}
+
+// dhKEM implements DHKEM(X25519, HKDF-SHA256), RFC 9180, Section 4.1.
+type dhKEM struct{}
+
+var kemSuiteID = appendUint16([]byte("KEM"), DHKEM_X25519_HKDF_SHA256)
+
+func (dhKEM) extractAndExpand(dh, kemContext []byte) []byte {
+	eaePRK := labeledExtract(kemSuiteID, nil, "eae_prk", dh)
+	return labeledExpand(kemSuiteID, eaePRK, "shared_secret", kemContext, 32)
+}
+
+func (k dhKEM) encap(rand io.Reader, pubRecipient []byte) (sharedSecret, encapPub []byte, err error) {
+	priv := make([]byte, curve25519.ScalarSize)
+	if _, err := io.ReadFull(rand, priv); err != nil {
+		return nil, nil, err
+	}
+	encapPub, err = curve25519.X25519(priv, curve25519.Basepoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	dh, err := curve25519.X25519(priv, pubRecipient)
+	if err != nil {
+		return nil, nil, err
+	}
+	kemContext := append(append([]byte(nil), encapPub...), pubRecipient...)
+	return k.extractAndExpand(dh, kemContext), encapPub, nil
+}
+
+func (k dhKEM) decap(priv, encapPub []byte) ([]byte, error) {
+	pub, err := curve25519.X25519(priv, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	dh, err := curve25519.X25519(priv, encapPub)
+	if err != nil {
+		return nil, err
+	}
+	kemContext := append(append([]byte(nil), encapPub...), pub...)
+	return k.extractAndExpand(dh, kemContext), nil
+}
+
+// context is an HPKE encryption context, RFC 9180, Section 5.
+type context struct {
+	aead      cipher.AEAD
+	baseNonce []byte
+	seqNum    uint64
+}
+
+// A Sender is an HPKE context for encrypting messages to a recipient.
+type Sender struct {
+	context
+}
+
+// A Recipient is an HPKE context for decrypting messages from a sender.
+type Recipient struct {
+	context
+}
+
+func checkAlgorithms(kemID, kdfID, aeadID uint16) error {
+	if !SupportedKEMs[kemID] {
+		return errors.New("hpke: unsupported KEM")
+	}
+	if !SupportedKDFs[kdfID] {
+		return errors.New("hpke: unsupported KDF")
+	}
+	if !SupportedAEADs[aeadID] {
+		return errors.New("hpke: unsupported AEAD")
+	}
+	return nil
+}
+
+// newContext runs the key schedule of the base mode, RFC 9180, Section 5.1.
+func newContext(sharedSecret []byte, kemID, kdfID, aeadID uint16, info []byte) (context, error) {
+	suiteID := []byte("HPKE")
+	suiteID = appendUint16(suiteID, kemID)
+	suiteID = appendUint16(suiteID, kdfID)
+	suiteID = appendUint16(suiteID, aeadID)
+
+	pskIDHash := labeledExtract(suiteID, nil, "psk_id_hash", nil)
+	infoHash := labeledExtract(suiteID, nil, "info_hash", info)
+	ksContext := append([]byte{0}, pskIDHash...) // mode_base
+	ksContext = append(ksContext, infoHash...)
+
+	secret := labeledExtract(suiteID, sharedSecret, "secret", nil)
+
+	var keySize uint16
+	switch aeadID {
+	case AEAD_AES_128_GCM:
+		keySize = 16
+	case AEAD_AES_256_GCM, AEAD_ChaCha20Poly1305:
+		keySize = 32
+	}
+	key := labeledExpand(suiteID, secret, "key", ksContext, keySize)
+
+	var aead cipher.AEAD
+	var err error
+	switch aeadID {
+	case AEAD_AES_128_GCM, AEAD_AES_256_GCM:
+		var block cipher.Block
+		block, err = aes.NewCipher(key)
+		if err == nil {
+			aead, err = cipher.NewGCM(block)
+		}
+	case AEAD_ChaCha20Poly1305:
+		aead, err = chacha20poly1305.New(key)
+	}
+	if err != nil {
+		return context{}, err
+	}
+
+	baseNonce := labeledExpand(suiteID, secret, "base_nonce", ksContext, uint16(aead.NonceSize()))
+	return context{aead: aead, baseNonce: baseNonce}, nil
+}
+
+// SetupSender generates an ephemeral key pair using rand and sets up a
+// context for encrypting messages to the holder of the private key
+// corresponding to pubRecipient. It returns the encapsulated key to send
+// to the recipient along with the context.
+func SetupSender(rand io.Reader, kemID, kdfID, aeadID uint16, pubRecipient, info []byte) ([]byte, *Sender, error) {
+	if err := checkAlgorithms(kemID, kdfID, aeadID); err != nil {
+		return nil, nil, err
+	}
+	sharedSecret, encapPub, err := dhKEM{}.encap(rand, pubRecipient)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, err := newContext(sharedSecret, kemID, kdfID, aeadID, info)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encapPub, &Sender{ctx}, nil
+}
+
+// SetupRecipient sets up a context for decrypting messages encrypted to
+// the public key corresponding to priv, given the encapsulated key encapPub
+// sent by the sender.
+func SetupRecipient(kemID, kdfID, aeadID uint16, priv, info, encapPub []byte) (*Recipient, error) {
+	if err := checkAlgorithms(kemID, kdfID, aeadID); err != nil {
+		return nil, err
+	}
+	sharedSecret, err := dhKEM{}.decap(priv, encapPub)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := newContext(sharedSecret, kemID, kdfID, aeadID, info)
+	if err != nil {
+		return nil, err
+	}
+	return &Recipient{ctx}, nil
+}
+
+// PublicKey returns the public key corresponding to the private key priv
+// of the given KEM.
+func PublicKey(kemID uint16, priv []byte) ([]byte, error) {
+	if !SupportedKEMs[kemID] {
+		return nil, errors.New("hpke: unsupported KEM")
+	}
+	return curve25519.X25519(priv, curve25519.Basepoint)
+}
+
+// nonce returns the nonce for the current sequence number.
+func (ctx *context) nonce() ([]byte, error) {
+	if ctx.seqNum == 1<<64-1 {
+		return nil, errors.New("hpke: message limit reached")
+	}
+	nonce := make([]byte, len(ctx.baseNonce))
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], ctx.seqNum)
+	for i := range nonce {
+		nonce[i] ^= ctx.baseNonce[i]
+	}
+	return nonce, nil
+}
+
+// Seal encrypts and authenticates plaintext and authenticates aad.
+// Messages must be opened in the order they were sealed.
+func (s *Sender) Seal(aad, plaintext []byte) ([]byte, error) {
+	nonce, err := s.nonce()
+	if err != nil {
+		return nil, err
+	}
+	s.seqNum++
+	return s.aead.Seal(nil, nonce, plaintext, aad), nil
+}
+
+// Open decrypts and authenticates ciphertext and authenticates aad.
+// The sequence number only advances if decryption succeeds.
+func (r *Recipient) Open(aad, ciphertext []byte) ([]byte, error) {
+	nonce, err := r.nonce()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := r.aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, err
+	}
+	r.seqNum++
+	return plaintext, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package hpke
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestRFC9180Vector checks the test vector of RFC 9180, Appendix A.1.1:
+// DHKEM(X25519, HKDF-SHA256), HKDF-SHA256, AES-128-GCM, base mode.
+func TestRFC9180Vector(t *testing.T) {
+	info := mustDecodeHex(t, "4f6465206f6e2061204772656369616e2055726e")
+	skEm := mustDecodeHex(t, "52c4a758a802cd8b936eceea314432798d5baf2d7e9235dc084ab1b9cfa2f736")
+	pkRm := mustDecodeHex(t, "3948cfe0ad1ddb695d780e59077195da6c56506b027329794ab02bca80815c4d")
+	skRm := mustDecodeHex(t, "4612c550263fc8ad58375df3f557aac531d26850903e55a9f23f21d8534e8ac8")
+	wantEnc := mustDecodeHex(t, "37fda3567bdbd628e88668c3c8d7e97d1d1253b6d4ea6d44c150f741f1bf4431")
+	plaintext := mustDecodeHex(t, "4265617574792069732074727574682c20747275746820626561757479")
+	aad := mustDecodeHex(t, "436f756e742d30")
+	wantCiphertext := mustDecodeHex(t, "f938558b5d72f1a23810b4be2ab4f84331acc02fc97babc53a52ae8218a355a96d8770ac83d07bea87e13c512a")
+
+	pub, err := PublicKey(DHKEM_X25519_HKDF_SHA256, skRm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pub, pkRm) {
+		t.Errorf("PublicKey = %x, want %x", pub, pkRm)
+	}
+
+	enc, sender, err := SetupSender(bytes.NewReader(skEm), DHKEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, AEAD_AES_128_GCM, pkRm, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(enc, wantEnc) {
+		t.Errorf("enc = %x, want %x", enc, wantEnc)
+	}
+	ciphertext, err := sender.Seal(aad, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ciphertext, wantCiphertext) {
+		t.Errorf("ciphertext = %x, want %x", ciphertext, wantCiphertext)
+	}
+
+	recipient, err := SetupRecipient(DHKEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, AEAD_AES_128_GCM, skRm, info, enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := recipient.Open(aad, wantCiphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("plaintext = %x, want %x", got, plaintext)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	skR := bytes.Repeat([]byte{1}, 32)
+	pkR, err := PublicKey(DHKEM_X25519_HKDF_SHA256, skR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, aeadID := range []uint16{AEAD_AES_128_GCM, AEAD_AES_256_GCM, AEAD_ChaCha20Poly1305} {
+		enc, sender, err := SetupSender(bytes.NewReader(bytes.Repeat([]byte{2}, 32)), DHKEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, aeadID, pkR, []byte("info"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		recipient, err := SetupRecipient(DHKEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, aeadID, skR, []byte("info"), enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, msg := range []string{"first", "second", ""} {
+			ct, err := sender.Seal([]byte("aad"), []byte(msg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := recipient.Open([]byte("bad aad"), ct); err == nil {
+				t.Errorf("AEAD %#04x: message %d opened with the wrong aad", aeadID, i)
+			}
+			pt, err := recipient.Open([]byte("aad"), ct)
+			if err != nil {
+				t.Fatalf("AEAD %#04x: message %d: %v", aeadID, i, err)
+			}
+			if string(pt) != msg {
+				t.Errorf("AEAD %#04x: message %d = %q, want %q", aeadID, i, pt, msg)
+			}
+		}
+	}
+
+	if _, _, err := SetupSender(bytes.NewReader(make([]byte, 32)), DHKEM_X25519_HKDF_SHA256, KDF_HKDF_SHA256, 0xffff, pkR, nil); err == nil {
+		t.Error("SetupSender accepted an unsupported AEAD")
+	}
+}
@@ -0,0 +1,377 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mlkem768
+
+import (
+	"bytes"
+	"crypto/internal/sha3"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestFieldReduce(t *testing.T) {
+	for a := uint32(0); a < 2*q*q; a++ {
+		got := fieldReduce(a)
+		exp := fieldElement(a % q)
+		if got != exp {
+			t.Fatalf("reduce(%d) = %d, expected %d", a, got, exp)
+		}
+	}
+}
+
+func TestFieldAdd(t *testing.T) {
+	for a := fieldElement(0); a < q; a++ {
+		for b := fieldElement(0); b < q; b++ {
+			got := fieldAdd(a, b)
+			exp := (a + b) % q
+			if got != exp {
+				t.Fatalf("%d + %d = %d, expected %d", a, b, got, exp)
+			}
+		}
+	}
+}
+
+func TestFieldSub(t *testing.T) {
+	for a := fieldElement(0); a < q; a++ {
+		for b := fieldElement(0); b < q; b++ {
+			got := fieldSub(a, b)
+			exp := (a - b + q) % q
+			if got != exp {
+				t.Fatalf("%d - %d = %d, expected %d", a, b, got, exp)
+			}
+		}
+	}
+}
+
+func TestFieldMul(t *testing.T) {
+	for a := fieldElement(0); a < q; a++ {
+		for b := fieldElement(0); b < q; b++ {
+			got := fieldMul(a, b)
+			exp := fieldElement((uint32(a) * uint32(b)) % q)
+			if got != exp {
+				t.Fatalf("%d * %d = %d, expected %d", a, b, got, exp)
+			}
+		}
+	}
+}
+
+// compressRat and decompressRat compute the rounding of FIPS 203,
+// Definitions 4.7 and 4.8, with exact rational arithmetic.
+
+func compressRat(x fieldElement, d uint8) uint16 {
+	if x >= q {
+		panic("x out of range")
+	}
+	if d <= 0 || d >= 12 {
+		panic("d out of range")
+	}
+
+	precise := big.NewRat((1<<d)*int64(x), q) // (2ᵈ / q) * x == (2ᵈ * x) / q
+
+	// FloatString rounds halfway points away from 0, and our result should
+	// always be positive, so it should work as we expect. (There's no direct
+	// way to round a Rat.)
+	rounded, err := new(big.Int).SetString(precise.FloatString(0), 10)
+	if !err {
+		panic("precise.FloatString didn't return a valid int")
+	}
+
+	// Don't use bitMask here, since we want to have an independent
+	// implementation of the rounding.
+	result := new(big.Int).Mod(rounded, big.NewInt(1<<d))
+	return uint16(result.Uint64())
+}
+
+func TestCompress(t *testing.T) {
+	for d := 1; d < 12; d++ {
+		for n := 0; n < q; n++ {
+			expected := compressRat(fieldElement(n), uint8(d))
+			result := compress(fieldElement(n), uint8(d))
+			if result != expected {
+				t.Errorf("compress(%d, %d): got %d, expected %d", n, d, result, expected)
+			}
+		}
+	}
+}
+
+func decompressRat(y uint16, d uint8) fieldElement {
+	if y >= 1<<d {
+		panic("y out of range")
+	}
+	if d <= 0 || d >= 12 {
+		panic("d out of range")
+	}
+
+	precise := big.NewRat(q*int64(y), 1<<d) // (q / 2ᵈ) * y  ==  (q * y) / 2ᵈ
+
+	// FloatString rounds halfway points away from 0, and our result should
+	// always be positive, so it should work as we expect. (There's no direct
+	// way to round a Rat.)
+	rounded, err := new(big.Int).SetString(precise.FloatString(0), 10)
+	if !err {
+		panic("precise.FloatString didn't return a valid int")
+	}
+
+	// Don't use bitMask here, since we want to have an independent
+	// implementation of the rounding.
+	result := new(big.Int).Mod(rounded, big.NewInt(q))
+	return fieldElement(result.Uint64())
+}
+
+func TestDecompress(t *testing.T) {
+	for d := 1; d < 12; d++ {
+		for n := 0; n < (1 << d); n++ {
+			expected := decompressRat(uint16(n), uint8(d))
+			result := decompress(uint16(n), uint8(d))
+			if result != expected {
+				t.Errorf("decompress(%d, %d): got %d, expected %d", n, d, result, expected)
+			}
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	dk, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, Ke, err := Encapsulate(dk.EncapsulationKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	Kd, err := Decapsulate(dk, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Ke, Kd) {
+		t.Fail()
+	}
+
+	dk1, err := NewKeyFromSeed(dk.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dk.EncapsulationKey(), dk1.EncapsulationKey()) {
+		t.Error("NewKeyFromSeed(Bytes()) produced a different key")
+	}
+
+	dk2, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(dk.EncapsulationKey(), dk2.EncapsulationKey()) {
+		t.Fail()
+	}
+
+	Kd2, err := Decapsulate(dk2, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(Ke, Kd2) {
+		t.Fail()
+	}
+
+	c1, Ke1, err := Encapsulate(dk.EncapsulationKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(c, c1) {
+		t.Fail()
+	}
+	if bytes.Equal(Ke, Ke1) {
+		t.Fail()
+	}
+}
+
+func TestBadLengths(t *testing.T) {
+	dk, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek := dk.EncapsulationKey()
+
+	for i := 0; i < len(ek)-1; i++ {
+		if _, _, err := Encapsulate(ek[:i]); err == nil {
+			t.Errorf("expected error for ek length %d", i)
+		}
+	}
+	ekLong := ek
+	for i := 0; i < 100; i++ {
+		ekLong = append(ekLong, 0)
+		if _, _, err := Encapsulate(ekLong); err == nil {
+			t.Errorf("expected error for ek length %d", len(ekLong))
+		}
+	}
+
+	c, _, err := Encapsulate(ek)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < len(c)-1; i++ {
+		if _, err := Decapsulate(dk, c[:i]); err == nil {
+			t.Errorf("expected error for c length %d", i)
+		}
+	}
+	cLong := c
+	for i := 0; i < 100; i++ {
+		cLong = append(cLong, 0)
+		if _, err := Decapsulate(dk, cLong); err == nil {
+			t.Errorf("expected error for c length %d", len(cLong))
+		}
+	}
+
+	for i := 0; i < SeedSize+10; i++ {
+		if i == SeedSize {
+			continue
+		}
+		if _, err := NewKeyFromSeed(make([]byte, i)); err == nil {
+			t.Errorf("expected error for seed length %d", i)
+		}
+	}
+}
+
+func TestUnreducedEncapsulationKey(t *testing.T) {
+	dk, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek := dk.EncapsulationKey()
+
+	// Set the first coefficient of the second polynomial of t to q, which
+	// is unreduced, without changing the other encoded values.
+	bad := append([]byte(nil), ek...)
+	bad[encodingSize12] = q & 0xff
+	bad[encodingSize12+1] = bad[encodingSize12+1]&0xf0 | q>>8
+	if _, _, err := Encapsulate(bad); err == nil {
+		t.Error("expected error for unreduced encapsulation key")
+	}
+}
+
+// TestVector checks a single encapsulation with all-zero seed and message
+// against values computed with an independent implementation of FIPS 203.
+func TestVector(t *testing.T) {
+	dk, err := NewKeyFromSeed(make([]byte, SeedSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ek := dk.EncapsulationKey()
+	if got, want := sha3.Sum256(ek), "07f81a8b0e266a3ee92d3a63cdae5cff921905544c9dd797a849e1d054180eca"; hex.EncodeToString(got[:]) != want {
+		t.Errorf("H(ek) = %x, want %s", got, want)
+	}
+	c, K, err := encapsulate(ek, &[messageSize]byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sha3.Sum256(c), "458a9896b26a4cba613b45288e09d89f688d69f181d4f11e3c486057fb3066ac"; hex.EncodeToString(got[:]) != want {
+		t.Errorf("H(c) = %x, want %s", got, want)
+	}
+	if got, want := hex.EncodeToString(K), "b4d29cd55bab43e16554b74b9098cdfce583996c968bcd2cfd1ad9455e351fbf"; got != want {
+		t.Errorf("K = %s, want %s", got, want)
+	}
+}
+
+// TestAccumulated accumulates 100 deterministic key generations,
+// encapsulations, decapsulations, and implicit rejections into a single
+// SHAKE-128 hash. The inputs are drawn from a separate SHAKE-128 stream.
+// The expected value was computed with an independent implementation of
+// FIPS 203.
+func TestAccumulated(t *testing.T) {
+	n := 100
+	expected := "2257e956a26ace747b194bf1225aaeeb21d085b31455067036524c3f3656310b"
+
+	s := sha3.NewShake128()
+	o := sha3.NewShake128()
+	seed := make([]byte, SeedSize)
+	var msg [messageSize]byte
+	ct1 := make([]byte, CiphertextSize)
+
+	for i := 0; i < n; i++ {
+		s.Read(seed)
+		dk, err := NewKeyFromSeed(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ek := dk.EncapsulationKey()
+		o.Write(ek)
+
+		s.Read(msg[:])
+		ct, k, err := encapsulate(ek, &msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o.Write(ct)
+		o.Write(k)
+
+		kk, err := Decapsulate(dk, ct)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(kk, k) {
+			t.Errorf("k: got %x, expected %x", kk, k)
+		}
+
+		s.Read(ct1)
+		o.Write(ct1)
+		k1, err := Decapsulate(dk, ct1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o.Write(k1)
+	}
+
+	got := make([]byte, 32)
+	o.Read(got)
+	if h := hex.EncodeToString(got); h != expected {
+		t.Errorf("got %s, expected %s", h, expected)
+	}
+}
+
+var sink byte
+
+func BenchmarkKeyGen(b *testing.B) {
+	seed := make([]byte, SeedSize)
+	for i := 0; i < b.N; i++ {
+		dk, err := NewKeyFromSeed(seed)
+		if err != nil {
+			b.Fatal(err)
+		}
+		sink ^= dk.EncapsulationKey()[0]
+	}
+}
+
+func BenchmarkEncaps(b *testing.B) {
+	dk, err := GenerateKey()
+	if err != nil {
+		b.Fatal(err)
+	}
+	ek := dk.EncapsulationKey()
+	var m [messageSize]byte
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c, K, err := encapsulate(ek, &m)
+		if err != nil {
+			b.Fatal(err)
+		}
+		sink ^= c[0] ^ K[0]
+	}
+}
+
+func BenchmarkDecaps(b *testing.B) {
+	dk, err := GenerateKey()
+	if err != nil {
+		b.Fatal(err)
+	}
+	c, _, err := Encapsulate(dk.EncapsulationKey())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		K, _ := Decapsulate(dk, c)
+		sink ^= K[0]
+	}
+}
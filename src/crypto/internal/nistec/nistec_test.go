@@ -5,7 +5,7 @@
 package nistec_test
 
 import (
-	"crypto/elliptic/internal/nistec"
+	"crypto/internal/nistec"
 	"math/rand"
 	"os"
 	"strings"
@@ -0,0 +1,201 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sha3 implements the SHA-3 hash functions and the SHAKE extendable
+// output functions defined in FIPS 202, for use by other crypto packages.
+package sha3
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Domain separation bytes, including the first bit of the pad10*1 padding.
+const (
+	dsbyteSHA3  = 0x06
+	dsbyteShake = 0x1f
+)
+
+// A Digest is a SHA-3 or SHAKE state. It implements hash.Hash and, for
+// SHAKE, io.Reader to read an arbitrary amount of output.
+type Digest struct {
+	a [25]uint64 // Keccak state, as little-endian lanes
+
+	rate      int  // bytes absorbed or squeezed per permutation
+	dsbyte    byte // domain separation byte
+	outputLen int  // default output size in bytes, for Sum
+
+	n         int  // bytes absorbed into or squeezed from the current block
+	squeezing bool // whether the input has been padded
+}
+
+// New256 returns a new Digest computing the SHA3-256 hash.
+func New256() *Digest {
+	return &Digest{rate: 136, dsbyte: dsbyteSHA3, outputLen: 32}
+}
+
+// New512 returns a new Digest computing the SHA3-512 hash.
+func New512() *Digest {
+	return &Digest{rate: 72, dsbyte: dsbyteSHA3, outputLen: 64}
+}
+
+// NewShake128 returns a new Digest computing the SHAKE128 XOF.
+func NewShake128() *Digest {
+	return &Digest{rate: 168, dsbyte: dsbyteShake, outputLen: 32}
+}
+
+// NewShake256 returns a new Digest computing the SHAKE256 XOF.
+func NewShake256() *Digest {
+	return &Digest{rate: 136, dsbyte: dsbyteShake, outputLen: 64}
+}
+
+// Sum256 returns the SHA3-256 digest of data.
+func Sum256(data []byte) [32]byte {
+	var out [32]byte
+	d := New256()
+	d.Write(data)
+	d.Read(out[:])
+	return out
+}
+
+// Sum512 returns the SHA3-512 digest of data.
+func Sum512(data []byte) [64]byte {
+	var out [64]byte
+	d := New512()
+	d.Write(data)
+	d.Read(out[:])
+	return out
+}
+
+func (d *Digest) Size() int      { return d.outputLen }
+func (d *Digest) BlockSize() int { return d.rate }
+
+// Reset resets the Digest to its initial state.
+func (d *Digest) Reset() {
+	d.a = [25]uint64{}
+	d.n = 0
+	d.squeezing = false
+}
+
+// Clone returns a copy of d in its current state.
+func (d *Digest) Clone() *Digest {
+	d1 := *d
+	return &d1
+}
+
+// xorByte XORs b into the i-th byte of the state.
+func (d *Digest) xorByte(i int, b byte) {
+	d.a[i/8] ^= uint64(b) << (8 * (i % 8))
+}
+
+// Write absorbs more data into the state. It panics if any output has
+// already been read.
+func (d *Digest) Write(p []byte) (int, error) {
+	if d.squeezing {
+		panic("sha3: Write after Read")
+	}
+	written := len(p)
+	for len(p) > 0 {
+		if d.n == 0 && len(p) >= d.rate {
+			// Absorb a full block a lane at a time.
+			for i := 0; i < d.rate/8; i++ {
+				d.a[i] ^= binary.LittleEndian.Uint64(p[8*i:])
+			}
+			p = p[d.rate:]
+			keccakF1600(&d.a)
+			continue
+		}
+		d.xorByte(d.n, p[0])
+		p = p[1:]
+		d.n++
+		if d.n == d.rate {
+			keccakF1600(&d.a)
+			d.n = 0
+		}
+	}
+	return written, nil
+}
+
+// Read squeezes output from the state. After the first Read, Write can't be
+// called anymore.
+func (d *Digest) Read(out []byte) (int, error) {
+	if !d.squeezing {
+		d.xorByte(d.n, d.dsbyte)
+		d.xorByte(d.rate-1, 0x80)
+		keccakF1600(&d.a)
+		d.n = 0
+		d.squeezing = true
+	}
+	read := len(out)
+	for len(out) > 0 {
+		if d.n == d.rate {
+			keccakF1600(&d.a)
+			d.n = 0
+		}
+		out[0] = byte(d.a[d.n/8] >> (8 * (d.n % 8)))
+		out = out[1:]
+		d.n++
+	}
+	return read, nil
+}
+
+// Sum appends the default-length output of the current state to b, without
+// changing the state.
+func (d *Digest) Sum(b []byte) []byte {
+	out := make([]byte, d.outputLen)
+	d.Clone().Read(out)
+	return append(b, out...)
+}
+
+// rc are the round constants of the ι step.
+var rc = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+	0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+	0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+// rotc are the rotation offsets of the ρ step, indexed by x+5y.
+var rotc = [25]int{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+// keccakF1600 applies the Keccak-f[1600] permutation to a, where the lane
+// (x, y) of the state is a[x+5y]. See FIPS 202, Section 3.
+func keccakF1600(a *[25]uint64) {
+	var c [5]uint64
+	var b [25]uint64
+	for round := 0; round < 24; round++ {
+		// θ
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d := c[(x+4)%5] ^ bits.RotateLeft64(c[(x+1)%5], 1)
+			for y := 0; y < 25; y += 5 {
+				a[x+y] ^= d
+			}
+		}
+		// ρ and π
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				b[y+5*((2*x+3*y)%5)] = bits.RotateLeft64(a[x+5*y], rotc[x+5*y])
+			}
+		}
+		// χ
+		for y := 0; y < 25; y += 5 {
+			for x := 0; x < 5; x++ {
+				a[x+y] = b[x+y] ^ (^b[(x+1)%5+y] & b[(x+2)%5+y])
+			}
+		}
+		// ι
+		a[0] ^= rc[round]
+	}
+}
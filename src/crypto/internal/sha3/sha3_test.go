@@ -0,0 +1,131 @@
+// Copyright 2023 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sha3
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// The expected values were computed with an independent FIPS 202
+// implementation. The SHAKE columns are bytes 368 to 400 of the output,
+// which spans several squeezed blocks.
+var sha3Tests = []struct {
+	name     string
+	msg      []byte
+	sha3_256 string
+	sha3_512 string
+	shake128 string
+	shake256 string
+}{
+	{
+		"empty", nil,
+		"a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a",
+		"a69f73cca23a9ac5c8b567dc185a756e97c982164fe25859e0d1dcc1475c80a615b2123af1f5f94c11e3e9402c3ac558f500199d95b6d3e301758586281dcd26",
+		"3a7a9c4a95d91c55d495e9f51dd0b5e9d83c6d5e8ce803aa62b8d654db53d09b",
+		"29d310912f729ec6cfa36c6ac6a75837143045d791cc85eff5b21932f23861bc",
+	},
+	{
+		"abc", []byte("abc"),
+		"3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532",
+		"b751850b1a57168a5693cd924b6b096e08f621827444f70d884f5d0240d2712e10e116e9192af3c91a7ec57647e3934057340b4cf408d5a56592f8274eec53f0",
+		"35d6dbb75651b284076f5fde47b4a0586ee173e30bd4d08f2bc59c6114bdd745",
+		"f2abbd26edad1553ea3a626f359e8f79ade16384e151755c47e822fc74c5d710",
+	},
+	{
+		"135 bytes", bytes.Repeat([]byte("x"), 135),
+		"c150125edc74b56fb5cbfdd024fabe20ea5a99bd3c97305bbf7cb55885c106fe",
+		"8e6c97077cf3abcbeff3f6e9dfb54d30b21139d2cc91fa6eb8885fa06ef6179b70dd6101dab98351d8108e3298977f1ce264895cf9a13223dc5862e85c877cbb",
+		"ef1f69868d8988c17278addbfb713ecdc89d256a26e5e448a3558a513c80a873",
+		"56151fce3f996abc1015e9c99b654dbd7c71cc9feb925632821dd7e2bc412242",
+	},
+	{
+		"136 bytes", bytes.Repeat([]byte("y"), 136),
+		"2d93d1f07f6df340ab11b29dd361bb9f05797dee18470724c969d8eaed62027e",
+		"fefaa2e67004bc39ff2ba294448b0087875ee9071b2c1600bb0bef9cb28c64c7a3535f323dd7a2a7e5d81a99eebbccc557c586d2a9b1419ecb11c9a9b259f223",
+		"ad77d282a1c8a58c4d835ce508c45bd5697c2f9709452c557c749cf00932ab7e",
+		"9efeee6e8247f98e54de918780df2e173f3d9c57a87c56b96107d4702b93b3c7",
+	},
+	{
+		"137 bytes", bytes.Repeat([]byte("z"), 137),
+		"5ac4a3a647f7be4ab27094c859bf66e2b3e92064f8cc3365aed7feec53724977",
+		"014b6b48fd1b1c4db841432490d1ff08b496ba7b251bde1142f2bc732afa01f1908525b5e15649f5a21c27dd6e37584093ee7fbb12a9660ab13c7c7e634c224b",
+		"6b4223639cc5e3c7effd48b4db12592efc3bd6b5283f21af00e44fd5dc946ce4",
+		"9c4072ba23f1a6d08ade17f30588409779075e8dc5c9d81804cec05e261a9d60",
+	},
+	{
+		"768 bytes", bytes.Repeat(func() []byte {
+			b := make([]byte, 256)
+			for i := range b {
+				b[i] = byte(i)
+			}
+			return b
+		}(), 3),
+		"c043b2b15d405c9f4cd92fdaef420eba6201d328fb34ec0e2c16e4981b9e4b39",
+		"ad3a11a3430f0fac234a6c15bff0cb609b3d6fde0eac5873893e2775d15cf47d6791b4db907361c53719da248a7662f759b3f6c7ffb4fe69492a9449728cdad7",
+		"230ed26168bace7ef0f666b6f36502a8a288a29a6eb5a3fa797a834520964a3d",
+		"7b21d55924509d182f98bd79525c4e7fb9a59a4a91d48b914d5cfaf4ba8e642e",
+	},
+}
+
+func TestVectors(t *testing.T) {
+	for _, tt := range sha3Tests {
+		if got := Sum256(tt.msg); hex.EncodeToString(got[:]) != tt.sha3_256 {
+			t.Errorf("%s: Sum256 = %x, want %s", tt.name, got, tt.sha3_256)
+		}
+		if got := Sum512(tt.msg); hex.EncodeToString(got[:]) != tt.sha3_512 {
+			t.Errorf("%s: Sum512 = %x, want %s", tt.name, got, tt.sha3_512)
+		}
+
+		// Write the message one byte at a time, and use Sum, to exercise
+		// the partial block paths.
+		h := New256()
+		for _, b := range tt.msg {
+			h.Write([]byte{b})
+		}
+		if got := hex.EncodeToString(h.Sum(nil)); got != tt.sha3_256 {
+			t.Errorf("%s: bytewise SHA3-256 = %s, want %s", tt.name, got, tt.sha3_256)
+		}
+
+		for _, s := range []struct {
+			name string
+			d    *Digest
+			want string
+		}{
+			{"SHAKE128", NewShake128(), tt.shake128},
+			{"SHAKE256", NewShake256(), tt.shake256},
+		} {
+			s.d.Write(tt.msg)
+			out := make([]byte, 400)
+			// Read in uneven chunks, which must not change the output.
+			for i := 0; i < len(out); i += 7 {
+				end := i + 7
+				if end > len(out) {
+					end = len(out)
+				}
+				s.d.Read(out[i:end])
+			}
+			if got := hex.EncodeToString(out[368:]); got != s.want {
+				t.Errorf("%s: %s output[368:] = %s, want %s", tt.name, s.name, got, s.want)
+			}
+		}
+	}
+}
+
+func TestSumDoesNotChangeState(t *testing.T) {
+	h := New512()
+	h.Write([]byte("ab"))
+	h.Sum(nil)
+	h.Write([]byte("c"))
+	if got, want := hex.EncodeToString(h.Sum(nil)), sha3Tests[1].sha3_512; got != want {
+		t.Errorf("Sum512 after Sum = %s, want %s", got, want)
+	}
+	h.Reset()
+	h.Write([]byte("abc"))
+	if got, want := hex.EncodeToString(h.Sum(nil)), sha3Tests[1].sha3_512; got != want {
+		t.Errorf("Sum512 after Reset = %s, want %s", got, want)
+	}
+}
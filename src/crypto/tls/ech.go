@@ -0,0 +1,710 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"crypto/internal/hpke"
+	"errors"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// This file implements Encrypted Client Hello, as specified in
+// draft-ietf-tls-esni-18.
+
+// ECHClientHello types, draft-ietf-tls-esni-18, Section 5.
+const (
+	outerECHExt uint8 = 0
+	innerECHExt uint8 = 1
+)
+
+// echAEADTagSize is the tag size of all the AEADs supported by hpke.
+const echAEADTagSize = 16
+
+type echCipher struct {
+	kdfID  uint16
+	aeadID uint16
+}
+
+type echExtension struct {
+	typ  uint16
+	data []byte
+}
+
+// echConfig is a parsed ECHConfig. See draft-ietf-tls-esni-18, Section 4.
+type echConfig struct {
+	raw []byte
+
+	version uint16
+	length  uint16
+
+	configID             uint8
+	kemID                uint16
+	publicKey            []byte
+	symmetricCipherSuite []echCipher
+
+	maxNameLength uint8
+	publicName    []byte
+	extensions    []echExtension
+}
+
+var errMalformedECHConfig = errors.New("tls: malformed ECHConfigList")
+
+// parseECHConfig parses the first ECHConfig in enc. It reports skip if the
+// config has a version other than the one implemented by this package, in
+// which case ec is empty.
+func parseECHConfig(enc []byte) (skip bool, ec echConfig, err error) {
+	s := cryptobyte.String(enc)
+	ec.raw = enc
+	if !s.ReadUint16(&ec.version) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	if !s.ReadUint16(&ec.length) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	if len(ec.raw) < int(ec.length)+4 {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	ec.raw = ec.raw[:ec.length+4]
+	if ec.version != extensionEncryptedClientHello {
+		return true, echConfig{}, nil
+	}
+
+	if !s.ReadUint8(&ec.configID) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	if !s.ReadUint16(&ec.kemID) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	var publicKey cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&publicKey) || publicKey.Empty() {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	ec.publicKey = publicKey
+	var cipherSuites cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&cipherSuites) || cipherSuites.Empty() {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	for !cipherSuites.Empty() {
+		var c echCipher
+		if !cipherSuites.ReadUint16(&c.kdfID) || !cipherSuites.ReadUint16(&c.aeadID) {
+			return false, echConfig{}, errMalformedECHConfig
+		}
+		ec.symmetricCipherSuite = append(ec.symmetricCipherSuite, c)
+	}
+	if !s.ReadUint8(&ec.maxNameLength) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	var publicName cryptobyte.String
+	if !s.ReadUint8LengthPrefixed(&publicName) || publicName.Empty() {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	ec.publicName = publicName
+	var extensions cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&extensions) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+	for !extensions.Empty() {
+		var e echExtension
+		var data cryptobyte.String
+		if !extensions.ReadUint16(&e.typ) || !extensions.ReadUint16LengthPrefixed(&data) {
+			return false, echConfig{}, errMalformedECHConfig
+		}
+		e.data = data
+		ec.extensions = append(ec.extensions, e)
+	}
+	if len(enc)-len(s) != len(ec.raw) {
+		return false, echConfig{}, errMalformedECHConfig
+	}
+
+	return false, ec, nil
+}
+
+// parseECHConfigList parses a draft-ietf-tls-esni-18 ECHConfigList, returning
+// the configs with a supported version.
+func parseECHConfigList(data []byte) ([]echConfig, error) {
+	s := cryptobyte.String(data)
+	var configList cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&configList) || !s.Empty() || configList.Empty() {
+		return nil, errMalformedECHConfig
+	}
+	var configs []echConfig
+	for len(configList) > 0 {
+		skip, ec, err := parseECHConfig(configList)
+		if err != nil {
+			return nil, err
+		}
+		configLen := 4 + int(uint16(configList[2])<<8|uint16(configList[3]))
+		configList = configList[configLen:]
+		if !skip {
+			configs = append(configs, ec)
+		}
+	}
+	return configs, nil
+}
+
+// pickECHConfig returns the first config in list that uses algorithms
+// implemented by this package, or nil if there is none.
+func pickECHConfig(list []echConfig) *echConfig {
+	for _, ec := range list {
+		if !hpke.SupportedKEMs[ec.kemID] {
+			continue
+		}
+		if _, err := pickECHCipherSuite(ec.symmetricCipherSuite); err != nil {
+			continue
+		}
+		if !validDNSName(string(ec.publicName)) {
+			continue
+		}
+		var unsupportedExt bool
+		for _, ext := range ec.extensions {
+			// If the high order bit of the extension type is set, it is a
+			// mandatory extension, and since we don't support any, we must
+			// skip the config. See draft-ietf-tls-esni-18, Section 4.2.
+			if ext.typ&0x8000 != 0 {
+				unsupportedExt = true
+				break
+			}
+		}
+		if unsupportedExt {
+			continue
+		}
+		ec := ec
+		return &ec
+	}
+	return nil
+}
+
+func pickECHCipherSuite(suites []echCipher) (echCipher, error) {
+	for _, s := range suites {
+		if hpke.SupportedKDFs[s.kdfID] && hpke.SupportedAEADs[s.aeadID] {
+			return s, nil
+		}
+	}
+	return echCipher{}, errors.New("tls: no supported ECH cipher suite")
+}
+
+// validDNSName reports whether s is a syntactically valid DNS name with at
+// least two labels, as required of an ECHConfig public_name.
+func validDNSName(s string) bool {
+	if len(s) > 253 {
+		return false
+	}
+	labels := strings.Split(s, ".")
+	if len(labels) <= 1 {
+		return false
+	}
+	for _, l := range labels {
+		labelLen := len(l)
+		if labelLen == 0 || labelLen > 63 {
+			return false
+		}
+		for i := 0; i < labelLen; i++ {
+			r := l[i]
+			if r == '-' && (i == 0 || i == labelLen-1) {
+				return false
+			}
+			if (r < '0' || r > '9') && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && r != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// echClientContext holds the client state of an Encrypted Client Hello
+// attempt.
+type echClientContext struct {
+	config          *echConfig
+	hpkeContext     *hpke.Sender
+	encapsulatedKey []byte
+	innerHello      *clientHelloMsg
+	innerTranscript hash.Hash
+	kdfID           uint16
+	aeadID          uint16
+	hrrAccepted     bool
+	echRejected     bool
+	retryConfigs    []byte
+}
+
+// echServerContext holds the server state of an Encrypted Client Hello
+// attempt. If accepted is false, the client offered ECH but it could not be
+// decrypted, either because it was GREASE or because the client used an
+// unknown or outdated config, and the outer ClientHello is being used.
+type echServerContext struct {
+	hpkeContext *hpke.Recipient
+	configID    uint8
+	cipherSuite echCipher
+	accepted    bool
+}
+
+// newECHClientContext sets up the HPKE context used to encrypt the inner
+// ClientHello to one of the configs in configList.
+func (c *Conn) newECHClientContext(configList []byte) (*echClientContext, error) {
+	configs, err := parseECHConfigList(configList)
+	if err != nil {
+		return nil, err
+	}
+	config := pickECHConfig(configs)
+	if config == nil {
+		return nil, errors.New("tls: EncryptedClientHelloConfigList contains no valid configs")
+	}
+	suite, err := pickECHCipherSuite(config.symmetricCipherSuite)
+	if err != nil {
+		return nil, err
+	}
+	ech := &echClientContext{config: config, kdfID: suite.kdfID, aeadID: suite.aeadID}
+	info := append([]byte("tls ech\x00"), config.raw...)
+	ech.encapsulatedKey, ech.hpkeContext, err = hpke.SetupSender(c.config.rand(),
+		config.kemID, suite.kdfID, suite.aeadID, config.publicKey, info)
+	if err != nil {
+		return nil, err
+	}
+	return ech, nil
+}
+
+// encodeInnerClientHello returns the EncodedClientHelloInner for inner,
+// padded as recommended by draft-ietf-tls-esni-18, Section 6.1.3.
+func encodeInnerClientHello(inner *clientHelloMsg, maxNameLength int) []byte {
+	// The legacy_session_id of the encoded ClientHelloInner is empty, as the
+	// server copies it over from the ClientHelloOuter.
+	encoded := *inner
+	encoded.sessionId = nil
+	encoded.raw = nil
+	h := encoded.marshal()[4:]
+
+	var paddingLen int
+	if inner.serverName != "" {
+		if len(inner.serverName) < maxNameLength {
+			paddingLen = maxNameLength - len(inner.serverName)
+		}
+	} else {
+		paddingLen = maxNameLength + 9
+	}
+	if rem := (len(h) + paddingLen) % 32; rem != 0 {
+		paddingLen += 32 - rem
+	}
+
+	return append(h, make([]byte, paddingLen)...)
+}
+
+func marshalOuterECHExt(configID uint8, suite echCipher, encapKey, payload []byte) []byte {
+	var b cryptobyte.Builder
+	b.AddUint8(outerECHExt)
+	b.AddUint16(suite.kdfID)
+	b.AddUint16(suite.aeadID)
+	b.AddUint8(configID)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(encapKey)
+	})
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddBytes(payload)
+	})
+	return b.BytesOrPanic()
+}
+
+// parseECHExt parses the contents of an encrypted_client_hello extension sent
+// in a ClientHello. For an inner extension, only echType is set.
+func parseECHExt(ext []byte) (echType uint8, suite echCipher, configID uint8, encap, payload []byte, err error) {
+	s := cryptobyte.String(ext)
+	if !s.ReadUint8(&echType) {
+		return 0, echCipher{}, 0, nil, nil, errors.New("tls: malformed encrypted_client_hello extension")
+	}
+	if echType == innerECHExt {
+		if !s.Empty() {
+			return 0, echCipher{}, 0, nil, nil, errors.New("tls: malformed encrypted_client_hello extension")
+		}
+		return echType, echCipher{}, 0, nil, nil, nil
+	}
+	if echType != outerECHExt {
+		return 0, echCipher{}, 0, nil, nil, errors.New("tls: unknown encrypted_client_hello type")
+	}
+	var encapString, payloadString cryptobyte.String
+	if !s.ReadUint16(&suite.kdfID) || !s.ReadUint16(&suite.aeadID) ||
+		!s.ReadUint8(&configID) ||
+		!s.ReadUint16LengthPrefixed(&encapString) ||
+		!s.ReadUint16LengthPrefixed(&payloadString) || payloadString.Empty() ||
+		!s.Empty() {
+		return 0, echCipher{}, 0, nil, nil, errors.New("tls: malformed encrypted_client_hello extension")
+	}
+	return echType, suite, configID, encapString, payloadString, nil
+}
+
+// computeAndUpdateOuterECHExtension encrypts inner and stores the result in
+// the encrypted_client_hello extension of outer. The encapsulated key is only
+// sent with the first ClientHelloOuter, that is if useKey is true.
+func computeAndUpdateOuterECHExtension(outer, inner *clientHelloMsg, ech *echClientContext, useKey bool) error {
+	var encapKey []byte
+	if useKey {
+		encapKey = ech.encapsulatedKey
+	}
+	suite := echCipher{ech.kdfID, ech.aeadID}
+	encodedInner := encodeInnerClientHello(inner, int(ech.config.maxNameLength))
+
+	// The ClientHelloOuter is authenticated as additional data, with the
+	// payload replaced by zeroes. See draft-ietf-tls-esni-18, Section 5.2.
+	outer.encryptedClientHello = marshalOuterECHExt(ech.config.configID, suite, encapKey,
+		make([]byte, len(encodedInner)+echAEADTagSize))
+	outer.raw = nil
+	aad := outer.marshal()[4:]
+
+	payload, err := ech.hpkeContext.Seal(aad, encodedInner)
+	if err != nil {
+		return err
+	}
+	outer.encryptedClientHello = marshalOuterECHExt(ech.config.configID, suite, encapKey, payload)
+	outer.raw = nil
+	return nil
+}
+
+// clientHelloExtension is an extension in a marshaled ClientHello. offset is
+// the position of data in the message.
+type clientHelloExtension struct {
+	typ    uint16
+	data   []byte
+	offset int
+}
+
+// clientHelloExtensions returns the extensions of the marshaled ClientHello
+// raw, including its handshake message header, in the order they appear.
+func clientHelloExtensions(raw []byte) ([]clientHelloExtension, bool) {
+	s := cryptobyte.String(raw)
+	var extensions cryptobyte.String
+	if !s.Skip(4) || // message type and uint24 length field
+		!s.Skip(2) || !s.Skip(32) || // vers, random
+		!skipUint8LengthPrefixed(&s) || // session_id
+		!skipUint16LengthPrefixed(&s) || // cipher_suites
+		!skipUint8LengthPrefixed(&s) { // compression_methods
+		return nil, false
+	}
+	if s.Empty() {
+		// ClientHello is optionally followed by extension data.
+		return nil, true
+	}
+	if !s.ReadUint16LengthPrefixed(&extensions) || !s.Empty() {
+		return nil, false
+	}
+	var exts []clientHelloExtension
+	for !extensions.Empty() {
+		var ext clientHelloExtension
+		var data cryptobyte.String
+		if !extensions.ReadUint16(&ext.typ) || !extensions.ReadUint16LengthPrefixed(&data) {
+			return nil, false
+		}
+		ext.data = data
+		ext.offset = len(raw) - len(s) - len(extensions) - len(data)
+		exts = append(exts, ext)
+	}
+	return exts, true
+}
+
+func skipUint8LengthPrefixed(s *cryptobyte.String) bool {
+	var skip uint8
+	if !s.ReadUint8(&skip) {
+		return false
+	}
+	return s.Skip(int(skip))
+}
+
+func skipUint16LengthPrefixed(s *cryptobyte.String) bool {
+	var skip uint16
+	if !s.ReadUint16(&skip) {
+		return false
+	}
+	return s.Skip(int(skip))
+}
+
+// decryptECHPayload decrypts the encrypted_client_hello payload of the
+// marshaled ClientHelloOuter outer.
+func decryptECHPayload(context *hpke.Recipient, outer, payload []byte) ([]byte, error) {
+	exts, ok := clientHelloExtensions(outer)
+	if !ok {
+		return nil, errors.New("tls: malformed ClientHelloOuter")
+	}
+	aad := append([]byte(nil), outer[4:]...)
+	for _, ext := range exts {
+		if ext.typ != extensionEncryptedClientHello {
+			continue
+		}
+		// The payload is the last field of the extension.
+		end := ext.offset + len(ext.data) - 4
+		for i := end - len(payload); i < end; i++ {
+			aad[i] = 0
+		}
+		return context.Open(aad, payload)
+	}
+	return nil, errors.New("tls: missing encrypted_client_hello extension")
+}
+
+// decodeInnerClientHello reconstructs the ClientHelloInner from the
+// EncodedClientHelloInner encoded and the ClientHelloOuter outer, as
+// described in draft-ietf-tls-esni-18, Section 5.1.
+func decodeInnerClientHello(outer *clientHelloMsg, encoded []byte) (*clientHelloMsg, error) {
+	errMalformed := errors.New("tls: client sent invalid encrypted_client_hello extension")
+
+	s := cryptobyte.String(encoded)
+	var version uint16
+	var random []byte
+	var sessionID, cipherSuites, compressionMethods, extensions cryptobyte.String
+	if !s.ReadUint16(&version) || !s.ReadBytes(&random, 32) ||
+		!s.ReadUint8LengthPrefixed(&sessionID) || !sessionID.Empty() ||
+		!s.ReadUint16LengthPrefixed(&cipherSuites) ||
+		!s.ReadUint8LengthPrefixed(&compressionMethods) ||
+		!s.ReadUint16LengthPrefixed(&extensions) {
+		return nil, errMalformed
+	}
+	for _, p := range s {
+		if p != 0 {
+			return nil, errMalformed
+		}
+	}
+
+	outerExts, ok := clientHelloExtensions(outer.marshal())
+	if !ok {
+		return nil, errMalformed
+	}
+
+	var b cryptobyte.Builder
+	b.AddUint8(typeClientHello)
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint16(version)
+		b.AddBytes(random)
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(outer.sessionId)
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(cipherSuites)
+		})
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(compressionMethods)
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			// Referenced outer extensions must appear in the same relative
+			// order as in the ClientHelloOuter, so scan it only forward.
+			next := 0
+			for !extensions.Empty() {
+				var typ uint16
+				var data cryptobyte.String
+				if !extensions.ReadUint16(&typ) || !extensions.ReadUint16LengthPrefixed(&data) {
+					b.SetError(errMalformed)
+					return
+				}
+				if typ != extensionECHOuterExtensions {
+					b.AddUint16(typ)
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						b.AddBytes(data)
+					})
+					continue
+				}
+				var refs cryptobyte.String
+				if !data.ReadUint8LengthPrefixed(&refs) || refs.Empty() || !data.Empty() {
+					b.SetError(errMalformed)
+					return
+				}
+				for !refs.Empty() {
+					var ref uint16
+					if !refs.ReadUint16(&ref) || ref == extensionEncryptedClientHello {
+						b.SetError(errMalformed)
+						return
+					}
+					for next < len(outerExts) && outerExts[next].typ != ref {
+						next++
+					}
+					if next == len(outerExts) {
+						b.SetError(errMalformed)
+						return
+					}
+					ext := outerExts[next]
+					b.AddUint16(ext.typ)
+					b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+						b.AddBytes(ext.data)
+					})
+					next++
+				}
+			}
+		})
+	})
+	raw, err := b.Bytes()
+	if err != nil {
+		return nil, errMalformed
+	}
+
+	inner := new(clientHelloMsg)
+	if !inner.unmarshal(raw) {
+		return nil, errMalformed
+	}
+	if len(inner.encryptedClientHello) != 1 || inner.encryptedClientHello[0] != innerECHExt {
+		return nil, errMalformed
+	}
+	// ECH implies TLS 1.3. See draft-ietf-tls-esni-18, Section 7.1.
+	if len(inner.supportedVersions) == 0 {
+		return nil, errMalformed
+	}
+	for _, v := range inner.supportedVersions {
+		if v < VersionTLS13 {
+			return nil, errMalformed
+		}
+	}
+
+	return inner, nil
+}
+
+// processECHClientHello attempts to decrypt the inner ClientHello carried by
+// outer with one of the configured EncryptedClientHelloKeys. If it can't, the
+// offer is treated like GREASE and the outer ClientHello is used, as required
+// by draft-ietf-tls-esni-18, Section 7.1.
+func (c *Conn) processECHClientHello(outer *clientHelloMsg) (*clientHelloMsg, *echServerContext, error) {
+	echType, suite, configID, encap, payload, err := parseECHExt(outer.encryptedClientHello)
+	if err != nil {
+		c.sendAlert(alertIllegalParameter)
+		return nil, nil, err
+	}
+	if echType == innerECHExt {
+		c.sendAlert(alertIllegalParameter)
+		return nil, nil, errors.New("tls: client sent an inner encrypted_client_hello extension in the ClientHelloOuter")
+	}
+
+	for _, key := range c.config.EncryptedClientHelloKeys {
+		skip, config, err := parseECHConfig(key.Config)
+		if err != nil {
+			c.sendAlert(alertInternalError)
+			return nil, nil, errors.New("tls: invalid EncryptedClientHelloKeys Config: " + err.Error())
+		}
+		if skip || config.configID != configID {
+			continue
+		}
+		suiteOK := false
+		for _, s := range config.symmetricCipherSuite {
+			if s == suite {
+				suiteOK = true
+				break
+			}
+		}
+		if !suiteOK {
+			continue
+		}
+		info := append([]byte("tls ech\x00"), key.Config...)
+		hpkeContext, err := hpke.SetupRecipient(config.kemID, suite.kdfID, suite.aeadID,
+			key.PrivateKey, info, encap)
+		if err != nil {
+			continue
+		}
+		encodedInner, err := decryptECHPayload(hpkeContext, outer.marshal(), payload)
+		if err != nil {
+			continue
+		}
+		inner, err := decodeInnerClientHello(outer, encodedInner)
+		if err != nil {
+			c.sendAlert(alertIllegalParameter)
+			return nil, nil, err
+		}
+		c.echAccepted = true
+		return inner, &echServerContext{
+			hpkeContext: hpkeContext,
+			configID:    configID,
+			cipherSuite: suite,
+			accepted:    true,
+		}, nil
+	}
+
+	return outer, &echServerContext{}, nil
+}
+
+// processECHClientHelloRetry decrypts the inner ClientHello carried by the
+// second ClientHelloOuter outer, after a HelloRetryRequest for an accepted
+// ECH offer. See draft-ietf-tls-esni-18, Section 7.1.1.
+func (c *Conn) processECHClientHelloRetry(outer *clientHelloMsg, ech *echServerContext) (*clientHelloMsg, error) {
+	if len(outer.encryptedClientHello) == 0 {
+		c.sendAlert(alertMissingExtension)
+		return nil, errors.New("tls: second ClientHello is missing the encrypted_client_hello extension")
+	}
+	echType, suite, configID, encap, payload, err := parseECHExt(outer.encryptedClientHello)
+	if err != nil {
+		c.sendAlert(alertIllegalParameter)
+		return nil, err
+	}
+	if echType != outerECHExt || suite != ech.cipherSuite || configID != ech.configID || len(encap) != 0 {
+		c.sendAlert(alertIllegalParameter)
+		return nil, errors.New("tls: client sent invalid encrypted_client_hello extension in second ClientHello")
+	}
+	encodedInner, err := decryptECHPayload(ech.hpkeContext, outer.marshal(), payload)
+	if err != nil {
+		c.sendAlert(alertDecryptError)
+		return nil, errors.New("tls: failed to decrypt second ClientHelloInner")
+	}
+	inner, err := decodeInnerClientHello(outer, encodedInner)
+	if err != nil {
+		c.sendAlert(alertIllegalParameter)
+		return nil, err
+	}
+	return inner, nil
+}
+
+// echRetryConfigList returns the ECHConfigList of the keys with SendAsRetry
+// set, or nil if there are none.
+func echRetryConfigList(keys []EncryptedClientHelloKey) []byte {
+	var b cryptobyte.Builder
+	var found bool
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, key := range keys {
+			if key.SendAsRetry {
+				b.AddBytes(key.Config)
+				found = true
+			}
+		}
+	})
+	if !found {
+		return nil
+	}
+	return b.BytesOrPanic()
+}
+
+// echAcceptConfirmation computes the ECH acceptance signal for a ServerHello
+// or HelloRetryRequest, given the transcript up to and including that message
+// with the signal replaced by zeroes. See draft-ietf-tls-esni-18, Section 7.2.
+func echAcceptConfirmation(suite *cipherSuiteTLS13, innerRandom []byte, label string, transcript hash.Hash) []byte {
+	return suite.expandLabel(suite.extract(innerRandom, nil), label, transcript.Sum(nil), 8)
+}
+
+// hrrECHConfirmationOffset returns the offset of the ECH acceptance
+// confirmation in the marshaled HelloRetryRequest hrr, if any.
+func hrrECHConfirmationOffset(hrr []byte) (int, bool) {
+	s := cryptobyte.String(hrr)
+	var extensions cryptobyte.String
+	if !s.Skip(4) || // message type and uint24 length field
+		!s.Skip(2) || !s.Skip(32) || // vers, random
+		!skipUint8LengthPrefixed(&s) || // session_id
+		!s.Skip(2) || !s.Skip(1) || // cipher_suite, compression_method
+		!s.ReadUint16LengthPrefixed(&extensions) {
+		return 0, false
+	}
+	for !extensions.Empty() {
+		var typ uint16
+		var data cryptobyte.String
+		if !extensions.ReadUint16(&typ) || !extensions.ReadUint16LengthPrefixed(&data) {
+			return 0, false
+		}
+		if typ == extensionEncryptedClientHello && len(data) == 8 {
+			return len(hrr) - len(s) - len(extensions) - len(data), true
+		}
+	}
+	return 0, false
+}
+
+// ECHRejectionError is the error type returned when ECH is rejected by a remote
+// server. If the server offered a ECHConfigList to use for retries, the
+// RetryConfigList field will contain this list.
+//
+// The client may treat an ECHRejectionError with an empty set of RetryConfigs
+// as a secure signal from the server.
+type ECHRejectionError struct {
+	RetryConfigList []byte
+}
+
+func (e *ECHRejectionError) Error() string {
+	return "tls: server rejected ECH"
+}
@@ -0,0 +1,437 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"crypto/internal/hpke"
+	"crypto/x509"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+func marshalTestECHConfig(t *testing.T, version uint16, id uint8, pubKey []byte, publicName string, maxNameLength uint8) []byte {
+	t.Helper()
+	b := cryptobyte.NewBuilder(nil)
+	b.AddUint16(version)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint8(id)
+		b.AddUint16(hpke.DHKEM_X25519_HKDF_SHA256)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes(pubKey)
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16(hpke.KDF_HKDF_SHA256)
+			b.AddUint16(hpke.AEAD_AES_128_GCM)
+			b.AddUint16(hpke.KDF_HKDF_SHA256)
+			b.AddUint16(hpke.AEAD_ChaCha20Poly1305)
+		})
+		b.AddUint8(maxNameLength)
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddBytes([]byte(publicName))
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {})
+	})
+	out, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func marshalTestECHConfigList(t *testing.T, configs ...[]byte) []byte {
+	t.Helper()
+	b := cryptobyte.NewBuilder(nil)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+		for _, c := range configs {
+			b.AddBytes(c)
+		}
+	})
+	out, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// newTestECHKey returns an EncryptedClientHelloKey for a config with the
+// given ID and the public name "example.golang", for which testRSACertificate
+// is valid.
+func newTestECHKey(t *testing.T, id uint8) EncryptedClientHelloKey {
+	t.Helper()
+	priv := bytes.Repeat([]byte{id + 1}, 32)
+	pub, err := hpke.PublicKey(hpke.DHKEM_X25519_HKDF_SHA256, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return EncryptedClientHelloKey{
+		Config:      marshalTestECHConfig(t, extensionEncryptedClientHello, id, pub, "example.golang", 32),
+		PrivateKey:  priv,
+		SendAsRetry: true,
+	}
+}
+
+func TestECHConfigList(t *testing.T) {
+	key := newTestECHKey(t, 5)
+	unknown := marshalTestECHConfig(t, 0xfe0a, 1, []byte{1, 2, 3}, "unknown.example", 0)
+	list := marshalTestECHConfigList(t, unknown, key.Config)
+
+	configs, err := parseECHConfigList(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 1 {
+		t.Fatalf("got %d configs, want 1", len(configs))
+	}
+	ec := configs[0]
+	if !bytes.Equal(ec.raw, key.Config) {
+		t.Errorf("raw = %x, want %x", ec.raw, key.Config)
+	}
+	if ec.configID != 5 || ec.kemID != hpke.DHKEM_X25519_HKDF_SHA256 ||
+		string(ec.publicName) != "example.golang" || ec.maxNameLength != 32 ||
+		len(ec.symmetricCipherSuite) != 2 {
+		t.Errorf("unexpected parsed config: %+v", ec)
+	}
+	if pickECHConfig(configs) == nil {
+		t.Error("pickECHConfig did not pick the supported config")
+	}
+
+	badName := marshalTestECHConfig(t, extensionEncryptedClientHello, 1, ec.publicKey, "localhost", 0)
+	configs, err = parseECHConfigList(marshalTestECHConfigList(t, badName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pickECHConfig(configs) != nil {
+		t.Error("pickECHConfig picked a config with an invalid public name")
+	}
+
+	for _, bad := range [][]byte{
+		nil,
+		list[:len(list)-1],
+		append(list[:len(list):len(list)], 0),
+		marshalTestECHConfigList(t),
+		marshalTestECHConfigList(t, key.Config[:len(key.Config)-1]),
+	} {
+		if _, err := parseECHConfigList(bad); err == nil {
+			t.Errorf("parseECHConfigList(%x) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestEncodeInnerClientHelloPadding(t *testing.T) {
+	for _, serverName := range []string{"", "a.example", "a-much-longer-name-than-the-maximum-name-length.example"} {
+		hello := &clientHelloMsg{
+			vers:                 VersionTLS12,
+			random:               make([]byte, 32),
+			sessionId:            make([]byte, 32),
+			cipherSuites:         []uint16{TLS_AES_128_GCM_SHA256},
+			compressionMethods:   []uint8{compressionNone},
+			serverName:           serverName,
+			supportedVersions:    []uint16{VersionTLS13},
+			encryptedClientHello: []byte{innerECHExt},
+		}
+		encoded := encodeInnerClientHello(hello, 32)
+		if len(encoded)%32 != 0 {
+			t.Errorf("%q: encoded length %d is not a multiple of 32", serverName, len(encoded))
+		}
+		outer := &clientHelloMsg{
+			vers:               VersionTLS12,
+			random:             make([]byte, 32),
+			sessionId:          bytes.Repeat([]byte{1}, 32),
+			cipherSuites:       []uint16{TLS_AES_128_GCM_SHA256},
+			compressionMethods: []uint8{compressionNone},
+		}
+		inner, err := decodeInnerClientHello(outer, encoded)
+		if err != nil {
+			t.Fatalf("%q: %v", serverName, err)
+		}
+		if inner.serverName != serverName || !bytes.Equal(inner.sessionId, outer.sessionId) {
+			t.Errorf("%q: decoded ClientHelloInner does not match: %+v", serverName, inner)
+		}
+
+		encoded[len(encoded)-1] = 1
+		if _, err := decodeInnerClientHello(outer, encoded); err == nil {
+			t.Errorf("%q: decodeInnerClientHello accepted non-zero padding", serverName)
+		}
+	}
+}
+
+func TestDecodeInnerClientHelloOuterExtensions(t *testing.T) {
+	outer := &clientHelloMsg{
+		vers:                 VersionTLS12,
+		random:               make([]byte, 32),
+		sessionId:            make([]byte, 32),
+		cipherSuites:         []uint16{TLS_AES_128_GCM_SHA256},
+		compressionMethods:   []uint8{compressionNone},
+		supportedCurves:      []CurveID{X25519, CurveP256},
+		alpnProtocols:        []string{"h2", "http/1.1"},
+		supportedVersions:    []uint16{VersionTLS13},
+		encryptedClientHello: []byte{outerECHExt},
+	}
+	encode := func(refs ...uint16) []byte {
+		b := cryptobyte.NewBuilder(nil)
+		b.AddUint16(VersionTLS12)
+		b.AddBytes(make([]byte, 32))
+		b.AddUint8(0) // empty legacy_session_id
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16(TLS_AES_128_GCM_SHA256)
+		})
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint8(compressionNone)
+		})
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			b.AddUint16(extensionECHOuterExtensions)
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+					for _, ref := range refs {
+						b.AddUint16(ref)
+					}
+				})
+			})
+			b.AddUint16(extensionSupportedVersions)
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) {
+					b.AddUint16(VersionTLS13)
+				})
+			})
+			b.AddUint16(extensionEncryptedClientHello)
+			b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+				b.AddUint8(innerECHExt)
+			})
+		})
+		return b.BytesOrPanic()
+	}
+
+	inner, err := decodeInnerClientHello(outer, encode(extensionSupportedCurves, extensionALPN))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inner.supportedCurves) != 2 || len(inner.alpnProtocols) != 2 || inner.alpnProtocols[0] != "h2" {
+		t.Errorf("outer extensions were not copied: %v, %v", inner.supportedCurves, inner.alpnProtocols)
+	}
+
+	for _, refs := range [][]uint16{
+		{extensionALPN, extensionSupportedCurves}, // wrong order
+		{extensionEncryptedClientHello},
+		{extensionStatusRequest}, // not in the outer ClientHello
+	} {
+		if _, err := decodeInnerClientHello(outer, encode(refs...)); err == nil {
+			t.Errorf("references %v were accepted", refs)
+		}
+	}
+}
+
+type echHandshakeResult struct {
+	clientState, serverState ConnectionState
+	clientErr, serverErr     error
+}
+
+func runECHHandshake(t *testing.T, clientConfig, serverConfig *Config) echHandshakeResult {
+	t.Helper()
+	const sentinel = "SENTINEL\n"
+	c, s := localPipe(t)
+	done := make(chan echHandshakeResult)
+	go func() {
+		var res echHandshakeResult
+		srv := Server(s, serverConfig)
+		res.serverErr = srv.Handshake()
+		if res.serverErr == nil {
+			res.serverState = srv.ConnectionState()
+			// The client might have aborted the connection after the
+			// handshake, so ignore errors.
+			io.WriteString(srv, sentinel)
+			srv.Close()
+		} else {
+			s.Close()
+		}
+		done <- res
+	}()
+
+	cli := Client(c, clientConfig)
+	clientErr := cli.Handshake()
+	var clientState ConnectionState
+	if clientErr == nil {
+		clientState = cli.ConnectionState()
+		// Read so that session tickets are processed.
+		buf, err := io.ReadAll(cli)
+		if err != nil {
+			t.Errorf("failed to read from client connection: %v", err)
+		}
+		if string(buf) != sentinel {
+			t.Errorf("read %q, want %q", buf, sentinel)
+		}
+	}
+	cli.Close()
+
+	res := <-done
+	res.clientState = clientState
+	res.clientErr = clientErr
+	return res
+}
+
+func TestECHHandshake(t *testing.T) {
+	key := newTestECHKey(t, 1)
+	otherKey := newTestECHKey(t, 2)
+
+	roots := x509.NewCertPool()
+	issuer, err := x509.ParseCertificate(testRSACertificateIssuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots.AddCert(issuer)
+	// testRSACertificate is valid from 2016 to 2025.
+	validTime := func() time.Time { return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	newConfigs := func(serverKeys ...EncryptedClientHelloKey) (*Config, *Config) {
+		clientConfig := testConfig.Clone()
+		clientConfig.MinVersion = VersionTLS13
+		clientConfig.ServerName = "secret.example"
+		clientConfig.EncryptedClientHelloConfigList = marshalTestECHConfigList(t, key.Config)
+		serverConfig := testConfig.Clone()
+		serverConfig.EncryptedClientHelloKeys = serverKeys
+		return clientConfig, serverConfig
+	}
+
+	t.Run("Accepted", func(t *testing.T) {
+		clientConfig, serverConfig := newConfigs(otherKey, key)
+		res := runECHHandshake(t, clientConfig, serverConfig)
+		if res.clientErr != nil || res.serverErr != nil {
+			t.Fatalf("handshake failed: client: %v, server: %v", res.clientErr, res.serverErr)
+		}
+		if !res.clientState.ECHAccepted || !res.serverState.ECHAccepted {
+			t.Errorf("ECHAccepted: client %v, server %v, want true", res.clientState.ECHAccepted, res.serverState.ECHAccepted)
+		}
+		if res.serverState.ServerName != "secret.example" || res.clientState.ServerName != "secret.example" {
+			t.Errorf("ServerName: client %q, server %q, want the inner name", res.clientState.ServerName, res.serverState.ServerName)
+		}
+	})
+
+	t.Run("HelloRetryRequest", func(t *testing.T) {
+		clientConfig, serverConfig := newConfigs(key)
+		clientConfig.CurvePreferences = []CurveID{X25519, CurveP256}
+		serverConfig.CurvePreferences = []CurveID{CurveP256}
+		res := runECHHandshake(t, clientConfig, serverConfig)
+		if res.clientErr != nil || res.serverErr != nil {
+			t.Fatalf("handshake failed: client: %v, server: %v", res.clientErr, res.serverErr)
+		}
+		if !res.clientState.ECHAccepted || !res.serverState.ECHAccepted {
+			t.Errorf("ECHAccepted: client %v, server %v, want true", res.clientState.ECHAccepted, res.serverState.ECHAccepted)
+		}
+		if res.serverState.ServerName != "secret.example" {
+			t.Errorf("server ServerName = %q, want the inner name", res.serverState.ServerName)
+		}
+	})
+
+	t.Run("Resumption", func(t *testing.T) {
+		clientConfig, serverConfig := newConfigs(key)
+		clientConfig.ClientSessionCache = NewLRUClientSessionCache(1)
+		for i, wantResume := range []bool{false, true} {
+			res := runECHHandshake(t, clientConfig, serverConfig)
+			if res.clientErr != nil || res.serverErr != nil {
+				t.Fatalf("handshake %d failed: client: %v, server: %v", i, res.clientErr, res.serverErr)
+			}
+			if !res.clientState.ECHAccepted || res.clientState.DidResume != wantResume {
+				t.Errorf("handshake %d: ECHAccepted = %v, DidResume = %v, want true, %v",
+					i, res.clientState.ECHAccepted, res.clientState.DidResume, wantResume)
+			}
+		}
+	})
+
+	t.Run("RejectedWithRetryConfigs", func(t *testing.T) {
+		clientConfig, serverConfig := newConfigs(otherKey)
+		clientConfig.RootCAs = roots
+		clientConfig.Time = validTime
+		serverConfig.Time = validTime
+		res := runECHHandshake(t, clientConfig, serverConfig)
+		if res.serverErr != nil {
+			t.Fatalf("server handshake failed: %v", res.serverErr)
+		}
+		if res.serverState.ECHAccepted || res.serverState.ServerName != "example.golang" {
+			t.Errorf("server: ECHAccepted = %v, ServerName = %q, want false, the public name",
+				res.serverState.ECHAccepted, res.serverState.ServerName)
+		}
+		var echErr *ECHRejectionError
+		if !errors.As(res.clientErr, &echErr) {
+			t.Fatalf("client error = %v, want ECHRejectionError", res.clientErr)
+		}
+		if want := marshalTestECHConfigList(t, otherKey.Config); !bytes.Equal(echErr.RetryConfigList, want) {
+			t.Errorf("RetryConfigList = %x, want %x", echErr.RetryConfigList, want)
+		}
+	})
+
+	t.Run("RejectedUntrustedPublicName", func(t *testing.T) {
+		// InsecureSkipVerify is ignored for the client-facing server.
+		clientConfig, serverConfig := newConfigs(otherKey)
+		clientConfig.Time = validTime
+		serverConfig.Time = validTime
+		res := runECHHandshake(t, clientConfig, serverConfig)
+		var certErr x509.UnknownAuthorityError
+		if !errors.As(res.clientErr, &certErr) {
+			t.Errorf("client error = %v, want x509.UnknownAuthorityError", res.clientErr)
+		}
+	})
+
+	t.Run("RejectionVerify", func(t *testing.T) {
+		clientConfig, serverConfig := newConfigs()
+		serverConfig.EncryptedClientHelloKeys = nil
+		var called bool
+		clientConfig.EncryptedClientHelloRejectionVerify = func(cs ConnectionState) error {
+			called = true
+			if cs.ServerName != "example.golang" || len(cs.PeerCertificates) == 0 {
+				t.Errorf("unexpected ConnectionState: ServerName = %q, %d certificates", cs.ServerName, len(cs.PeerCertificates))
+			}
+			return nil
+		}
+		clientConfig.VerifyConnection = func(ConnectionState) error {
+			t.Error("VerifyConnection called after ECH rejection")
+			return nil
+		}
+		res := runECHHandshake(t, clientConfig, serverConfig)
+		if !called {
+			t.Error("EncryptedClientHelloRejectionVerify was not called")
+		}
+		var echErr *ECHRejectionError
+		if !errors.As(res.clientErr, &echErr) {
+			t.Fatalf("client error = %v, want ECHRejectionError", res.clientErr)
+		}
+		if echErr.RetryConfigList != nil {
+			t.Errorf("RetryConfigList = %x from a server not supporting ECH", echErr.RetryConfigList)
+		}
+	})
+
+	t.Run("RejectionVerifyError", func(t *testing.T) {
+		clientConfig, serverConfig := newConfigs(otherKey)
+		wantErr := errors.New("rejected")
+		clientConfig.EncryptedClientHelloRejectionVerify = func(ConnectionState) error {
+			return wantErr
+		}
+		res := runECHHandshake(t, clientConfig, serverConfig)
+		if res.clientErr != wantErr {
+			t.Errorf("client error = %v, want %v", res.clientErr, wantErr)
+		}
+	})
+
+	t.Run("ServerTLS12", func(t *testing.T) {
+		clientConfig, serverConfig := newConfigs(key)
+		serverConfig.MaxVersion = VersionTLS12
+		res := runECHHandshake(t, clientConfig, serverConfig)
+		if res.serverErr == nil {
+			t.Error("server negotiated TLS 1.2 with an ECH ClientHelloInner")
+		}
+	})
+
+	t.Run("MinVersion", func(t *testing.T) {
+		clientConfig, _ := newConfigs()
+		clientConfig.MinVersion = VersionTLS12
+		cli := Client(nil, clientConfig)
+		if err := cli.Handshake(); err == nil {
+			t.Error("handshake with ECH and MinVersion TLS 1.2 succeeded")
+		}
+	})
+}
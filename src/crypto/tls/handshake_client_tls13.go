@@ -10,6 +10,7 @@ import (
 	"crypto"
 	"crypto/hmac"
 	"crypto/rsa"
+	"crypto/subtle"
 	"errors"
 	"hash"
 	"sync/atomic"
@@ -23,10 +24,12 @@ type clientHandshakeStateTLS13 struct {
 	hello       *clientHelloMsg
 	ecdheParams ecdheParameters
 
-	session     *ClientSessionState
+	session     *SessionState
 	earlySecret []byte
 	binderKey   []byte
 
+	echContext *echClientContext
+
 	certReq       *certificateRequestMsgTLS13
 	usingPSK      bool
 	sentDummyCCS  bool
@@ -37,7 +40,8 @@ type clientHandshakeStateTLS13 struct {
 }
 
 // handshake requires hs.c, hs.hello, hs.serverHello, hs.ecdheParams, and,
-// optionally, hs.session, hs.earlySecret and hs.binderKey to be set.
+// optionally, hs.session, hs.earlySecret, hs.binderKey and hs.echContext to
+// be set.
 func (hs *clientHandshakeStateTLS13) handshake() error {
 	c := hs.c
 
@@ -60,7 +64,14 @@ func (hs *clientHandshakeStateTLS13) handshake() error {
 	hs.transcript = hs.suite.hash.New()
 	hs.transcript.Write(hs.hello.marshal())
 
+	if hs.echContext != nil {
+		hs.echContext.innerTranscript = hs.suite.hash.New()
+		hs.echContext.innerTranscript.Write(hs.echContext.innerHello.marshal())
+	}
+
+	sentHRR := false
 	if bytes.Equal(hs.serverHello.random, helloRetryRequestRandom) {
+		sentHRR = true
 		if err := hs.sendDummyChangeCipherSpec(); err != nil {
 			return err
 		}
@@ -69,6 +80,39 @@ func (hs *clientHandshakeStateTLS13) handshake() error {
 		}
 	}
 
+	if hs.echContext != nil && !hs.echContext.echRejected {
+		// The server signals that it accepted ECH by replacing the last 8
+		// bytes of the ServerHello random. See draft-ietf-tls-esni-18,
+		// Section 7.2.
+		confTranscript := cloneHash(hs.echContext.innerTranscript, hs.suite.hash)
+		if confTranscript == nil {
+			return c.sendAlert(alertInternalError)
+		}
+		serverHello := hs.serverHello.marshal()
+		confTranscript.Write(serverHello[:30])
+		confTranscript.Write(make([]byte, 8))
+		confTranscript.Write(serverHello[38:])
+		acceptConfirmation := echAcceptConfirmation(hs.suite, hs.echContext.innerHello.random,
+			"ech accept confirmation", confTranscript)
+		if subtle.ConstantTimeCompare(acceptConfirmation, hs.serverHello.random[24:]) == 1 {
+			hs.hello = hs.echContext.innerHello
+			hs.transcript = hs.echContext.innerTranscript
+			c.echAccepted = true
+		} else if sentHRR {
+			// The HelloRetryRequest accepted ECH.
+			c.sendAlert(alertIllegalParameter)
+			return errors.New("tls: server rejected Encrypted Client Hello after accepting it in the HelloRetryRequest")
+		} else {
+			hs.echContext.echRejected = true
+		}
+	}
+	if hs.echContext != nil && hs.echContext.echRejected {
+		// The handshake proceeds with the client-facing server, and will be
+		// authenticated for the public name. See draft-ietf-tls-esni-18,
+		// Section 6.1.6.
+		c.serverName = string(hs.echContext.config.publicName)
+	}
+
 	hs.transcript.Write(hs.serverHello.marshal())
 
 	c.buffering = true
@@ -100,6 +144,11 @@ func (hs *clientHandshakeStateTLS13) handshake() error {
 		return err
 	}
 
+	if hs.echContext != nil && hs.echContext.echRejected {
+		c.sendAlert(alertECHRequired)
+		return &ECHRejectionError{hs.echContext.retryConfigs}
+	}
+
 	atomic.StoreUint32(&c.handshakeStatus, 1)
 
 	return nil
@@ -167,6 +216,9 @@ func (hs *clientHandshakeStateTLS13) sendDummyChangeCipherSpec() error {
 		return nil
 	}
 	hs.sentDummyCCS = true
+	if hs.c.quic != nil {
+		return nil
+	}
 
 	_, err := hs.c.writeRecord(recordTypeChangeCipherSpec, []byte{1})
 	return err
@@ -186,6 +238,39 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 	hs.transcript.Write(chHash)
 	hs.transcript.Write(hs.serverHello.marshal())
 
+	var innerCHHash []byte
+	if hs.echContext != nil {
+		innerCHHash = hs.echContext.innerTranscript.Sum(nil)
+		hs.echContext.innerTranscript.Reset()
+		hs.echContext.innerTranscript.Write([]byte{typeMessageHash, 0, 0, uint8(len(innerCHHash))})
+		hs.echContext.innerTranscript.Write(innerCHHash)
+
+		// A HelloRetryRequest that accepts ECH carries a confirmation computed
+		// over the transcript with the confirmation itself zeroed. See
+		// draft-ietf-tls-esni-18, Section 7.2.1.
+		hrr := hs.serverHello.marshal()
+		if offset, ok := hrrECHConfirmationOffset(hrr); ok {
+			confTranscript := cloneHash(hs.echContext.innerTranscript, hs.suite.hash)
+			if confTranscript == nil {
+				return c.sendAlert(alertInternalError)
+			}
+			confTranscript.Write(hrr[:offset])
+			confTranscript.Write(make([]byte, 8))
+			confTranscript.Write(hrr[offset+8:])
+			acceptConfirmation := echAcceptConfirmation(hs.suite, hs.echContext.innerHello.random,
+				"hrr ech accept confirmation", confTranscript)
+			hs.echContext.hrrAccepted = subtle.ConstantTimeCompare(acceptConfirmation, hrr[offset:offset+8]) == 1
+		} else if hs.serverHello.encryptedClientHello != nil {
+			c.sendAlert(alertDecodeError)
+			return errors.New("tls: received malformed encrypted_client_hello extension")
+		}
+		hs.echContext.echRejected = !hs.echContext.hrrAccepted
+		hs.echContext.innerTranscript.Write(hrr)
+	} else if hs.serverHello.encryptedClientHello != nil {
+		c.sendAlert(alertUnsupportedExtension)
+		return errors.New("tls: server sent an unexpected encrypted_client_hello extension")
+	}
+
 	// The only HelloRetryRequest extensions we support are key_share and
 	// cookie, and clients must abort the handshake if the HRR would not result
 	// in any change in the ClientHello.
@@ -222,7 +307,7 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 			c.sendAlert(alertIllegalParameter)
 			return errors.New("tls: server sent an unnecessary HelloRetryRequest key_share")
 		}
-		if _, ok := curveForCurveID(curveID); curveID != X25519 && !ok {
+		if _, ok := curveForCurveID(curveID); curveID != X25519 && curveID != X25519MLKEM768 && !ok {
 			c.sendAlert(alertInternalError)
 			return errors.New("tls: CurvePreferences includes unsupported curve")
 		}
@@ -236,27 +321,25 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 	}
 
 	hs.hello.raw = nil
-	if len(hs.hello.pskIdentities) > 0 {
-		pskSuite := cipherSuiteTLS13ByID(hs.session.cipherSuite)
-		if pskSuite == nil {
-			return c.sendAlert(alertInternalError)
+	if err := hs.updatePSK(hs.hello, chHash); err != nil {
+		return err
+	}
+
+	if hs.echContext != nil {
+		// Apply the same changes to the ClientHelloInner, and encrypt it again
+		// with the same HPKE context. See draft-ietf-tls-esni-18, Section 6.1.5.
+		inner := hs.echContext.innerHello
+		inner.cookie = hs.hello.cookie
+		inner.keyShares = hs.hello.keyShares
+		inner.raw = nil
+		if err := hs.updatePSK(inner, innerCHHash); err != nil {
+			return err
 		}
-		if pskSuite.hash == hs.suite.hash {
-			// Update binders and obfuscated_ticket_age.
-			ticketAge := uint32(c.config.time().Sub(hs.session.receivedAt) / time.Millisecond)
-			hs.hello.pskIdentities[0].obfuscatedTicketAge = ticketAge + hs.session.ageAdd
-
-			transcript := hs.suite.hash.New()
-			transcript.Write([]byte{typeMessageHash, 0, 0, uint8(len(chHash))})
-			transcript.Write(chHash)
-			transcript.Write(hs.serverHello.marshal())
-			transcript.Write(hs.hello.marshalWithoutBinders())
-			pskBinders := [][]byte{hs.suite.finishedHash(hs.binderKey, transcript)}
-			hs.hello.updateBinders(pskBinders)
-		} else {
-			// Server selected a cipher suite incompatible with the PSK.
-			hs.hello.pskIdentities = nil
-			hs.hello.pskBinders = nil
+		hs.echContext.innerTranscript.Write(inner.marshal())
+
+		if err := computeAndUpdateOuterECHExtension(hs.hello, inner, hs.echContext, false); err != nil {
+			c.sendAlert(alertInternalError)
+			return err
 		}
 	}
 
@@ -284,6 +367,39 @@ func (hs *clientHandshakeStateTLS13) processHelloRetryRequest() error {
 	return nil
 }
 
+// updatePSK updates the binders and obfuscated_ticket_age of the
+// pre_shared_key extension of hello, if any, for a second ClientHello sent
+// after a HelloRetryRequest, given the hash of the first one.
+func (hs *clientHandshakeStateTLS13) updatePSK(hello *clientHelloMsg, chHash []byte) error {
+	c := hs.c
+
+	if len(hello.pskIdentities) == 0 {
+		return nil
+	}
+	pskSuite := cipherSuiteTLS13ByID(hs.session.cipherSuite)
+	if pskSuite == nil {
+		return c.sendAlert(alertInternalError)
+	}
+	if pskSuite.hash == hs.suite.hash {
+		// Update binders and obfuscated_ticket_age.
+		ticketAge := c.config.time().Sub(time.Unix(int64(hs.session.createdAt), 0))
+		hello.pskIdentities[0].obfuscatedTicketAge = uint32(ticketAge/time.Millisecond) + hs.session.ageAdd
+
+		transcript := hs.suite.hash.New()
+		transcript.Write([]byte{typeMessageHash, 0, 0, uint8(len(chHash))})
+		transcript.Write(chHash)
+		transcript.Write(hs.serverHello.marshal())
+		transcript.Write(hello.marshalWithoutBinders())
+		pskBinders := [][]byte{hs.suite.finishedHash(hs.binderKey, transcript)}
+		hello.updateBinders(pskBinders)
+	} else {
+		// Server selected a cipher suite incompatible with the PSK.
+		hello.pskIdentities = nil
+		hello.pskBinders = nil
+	}
+	return nil
+}
+
 func (hs *clientHandshakeStateTLS13) processServerHello() error {
 	c := hs.c
 
@@ -302,6 +418,11 @@ func (hs *clientHandshakeStateTLS13) processServerHello() error {
 		return errors.New("tls: malformed key_share extension")
 	}
 
+	if hs.serverHello.encryptedClientHello != nil {
+		c.sendAlert(alertUnsupportedExtension)
+		return errors.New("tls: server sent an encrypted_client_hello extension in a normal ServerHello")
+	}
+
 	if hs.serverHello.serverShare.group == 0 {
 		c.sendAlert(alertIllegalParameter)
 		return errors.New("tls: server did not send a key share")
@@ -334,7 +455,7 @@ func (hs *clientHandshakeStateTLS13) processServerHello() error {
 
 	hs.usingPSK = true
 	c.didResume = true
-	c.peerCertificates = hs.session.serverCertificates
+	c.peerCertificates = hs.session.peerCertificates
 	c.verifiedChains = hs.session.verifiedChains
 	c.ocspResponse = hs.session.ocspResponse
 	c.scts = hs.session.scts
@@ -359,10 +480,18 @@ func (hs *clientHandshakeStateTLS13) establishHandshakeKeys() error {
 
 	clientSecret := hs.suite.deriveSecret(handshakeSecret,
 		clientHandshakeTrafficLabel, hs.transcript)
-	c.out.setTrafficSecret(hs.suite, clientSecret)
+	c.out.setTrafficSecret(hs.suite, QUICEncryptionLevelHandshake, clientSecret)
 	serverSecret := hs.suite.deriveSecret(handshakeSecret,
 		serverHandshakeTrafficLabel, hs.transcript)
-	c.in.setTrafficSecret(hs.suite, serverSecret)
+	c.in.setTrafficSecret(hs.suite, QUICEncryptionLevelHandshake, serverSecret)
+
+	if c.quic != nil {
+		if c.hand.Len() != 0 {
+			c.sendAlert(alertUnexpectedMessage)
+		}
+		c.quicSetWriteSecret(QUICEncryptionLevelHandshake, hs.suite.id, clientSecret)
+		c.quicSetReadSecret(QUICEncryptionLevelHandshake, hs.suite.id, serverSecret)
+	}
 
 	err := c.config.writeKeyLog(keyLogLabelClientHandshake, hs.hello.random, clientSecret)
 	if err != nil {
@@ -396,12 +525,46 @@ func (hs *clientHandshakeStateTLS13) readServerParameters() error {
 	}
 	hs.transcript.Write(encryptedExtensions.marshal())
 
-	if err := checkALPN(hs.hello.alpnProtocols, encryptedExtensions.alpnProtocol); err != nil {
-		c.sendAlert(alertUnsupportedExtension)
+	if err := checkALPN(hs.hello.alpnProtocols, encryptedExtensions.alpnProtocol, c.quic != nil); err != nil {
+		if c.quic != nil {
+			// RFC 9001, Section 8.1
+			c.sendAlert(alertNoApplicationProtocol)
+		} else {
+			c.sendAlert(alertUnsupportedExtension)
+		}
 		return err
 	}
 	c.clientProtocol = encryptedExtensions.alpnProtocol
 
+	if c.quic != nil {
+		if encryptedExtensions.quicTransportParameters == nil {
+			// RFC 9001 Section 8.2.
+			c.sendAlert(alertMissingExtension)
+			return errors.New("tls: server did not send a quic_transport_parameters extension")
+		}
+		c.quicSetTransportParameters(encryptedExtensions.quicTransportParameters)
+	} else {
+		if encryptedExtensions.quicTransportParameters != nil {
+			c.sendAlert(alertUnsupportedExtension)
+			return errors.New("tls: server sent an unexpected quic_transport_parameters extension")
+		}
+	}
+
+	if encryptedExtensions.echRetryConfigs != nil {
+		if hs.echContext == nil || !hs.echContext.echRejected {
+			c.sendAlert(alertUnsupportedExtension)
+			return errors.New("tls: server sent encrypted client hello retry configs without rejecting Encrypted Client Hello")
+		}
+		// The retry configs must be syntactically valid, but need not contain
+		// any config supported by this client. See draft-ietf-tls-esni-18,
+		// Section 6.1.6.
+		if _, err := parseECHConfigList(encryptedExtensions.echRetryConfigs); err != nil {
+			c.sendAlert(alertDecodeError)
+			return err
+		}
+		hs.echContext.retryConfigs = encryptedExtensions.echRetryConfigs
+	}
+
 	return nil
 }
 
@@ -522,7 +685,7 @@ func (hs *clientHandshakeStateTLS13) readServerFinished() error {
 		clientApplicationTrafficLabel, hs.transcript)
 	serverSecret := hs.suite.deriveSecret(hs.masterSecret,
 		serverApplicationTrafficLabel, hs.transcript)
-	c.in.setTrafficSecret(hs.suite, serverSecret)
+	c.in.setTrafficSecret(hs.suite, QUICEncryptionLevelApplication, serverSecret)
 
 	err = c.config.writeKeyLog(keyLogLabelClientTraffic, hs.hello.random, hs.trafficSecret)
 	if err != nil {
@@ -547,14 +710,22 @@ func (hs *clientHandshakeStateTLS13) sendClientCertificate() error {
 		return nil
 	}
 
-	cert, err := c.getClientCertificate(&CertificateRequestInfo{
-		AcceptableCAs:    hs.certReq.certificateAuthorities,
-		SignatureSchemes: hs.certReq.supportedSignatureAlgorithms,
-		Version:          c.vers,
-		ctx:              hs.ctx,
-	})
-	if err != nil {
-		return err
+	var cert *Certificate
+	var err error
+	if hs.echContext != nil && hs.echContext.echRejected {
+		// Don't reveal the client certificate to the client-facing server,
+		// as required by draft-ietf-tls-esni-18, Section 6.1.6.
+		cert = new(Certificate)
+	} else {
+		cert, err = c.getClientCertificate(&CertificateRequestInfo{
+			AcceptableCAs:    hs.certReq.certificateAuthorities,
+			SignatureSchemes: hs.certReq.supportedSignatureAlgorithms,
+			Version:          c.vers,
+			ctx:              hs.ctx,
+		})
+		if err != nil {
+			return err
+		}
 	}
 
 	certMsg := new(certificateMsgTLS13)
@@ -621,13 +792,20 @@ func (hs *clientHandshakeStateTLS13) sendClientFinished() error {
 		return err
 	}
 
-	c.out.setTrafficSecret(hs.suite, hs.trafficSecret)
+	c.out.setTrafficSecret(hs.suite, QUICEncryptionLevelApplication, hs.trafficSecret)
 
 	if !c.config.SessionTicketsDisabled && c.config.ClientSessionCache != nil {
 		c.resumptionSecret = hs.suite.deriveSecret(hs.masterSecret,
 			resumptionLabel, hs.transcript)
 	}
 
+	if c.quic != nil {
+		if c.hand.Len() != 0 {
+			c.sendAlert(alertUnexpectedMessage)
+		}
+		c.quicSetWriteSecret(QUICEncryptionLevelApplication, hs.suite.id, hs.trafficSecret)
+	}
+
 	return nil
 }
 
@@ -656,27 +834,18 @@ func (c *Conn) handleNewSessionTicket(msg *newSessionTicketMsgTLS13) error {
 		return c.sendAlert(alertInternalError)
 	}
 
-	// Save the resumption_master_secret and nonce instead of deriving the PSK
-	// to do the least amount of work on NewSessionTicket messages before we
-	// know if the ticket will be used. Forward secrecy of resumed connections
-	// is guaranteed by the requirement for pskModeDHE.
-	session := &ClientSessionState{
-		sessionTicket:      msg.label,
-		vers:               c.vers,
-		cipherSuite:        c.cipherSuite,
-		masterSecret:       c.resumptionSecret,
-		serverCertificates: c.peerCertificates,
-		verifiedChains:     c.verifiedChains,
-		receivedAt:         c.config.time(),
-		nonce:              msg.nonce,
-		useBy:              c.config.time().Add(lifetime),
-		ageAdd:             msg.ageAdd,
-		ocspResponse:       c.ocspResponse,
-		scts:               c.scts,
-	}
-
-	cacheKey := clientSessionCacheKey(c.conn.RemoteAddr(), c.config)
-	c.config.ClientSessionCache.Put(cacheKey, session)
+	psk := cipherSuite.expandLabel(c.resumptionSecret, "resumption",
+		msg.nonce, cipherSuite.hash.Size())
+
+	session := c.sessionState()
+	session.secret = psk
+	session.useBy = uint64(c.config.time().Add(lifetime).Unix())
+	session.ageAdd = msg.ageAdd
+	cs := &ClientSessionState{ticket: msg.label, session: session}
+
+	if cacheKey := c.clientSessionCacheKey(); cacheKey != "" {
+		c.config.ClientSessionCache.Put(cacheKey, cs)
+	}
 
 	return nil
 }
@@ -42,7 +42,7 @@ func testClientHelloFailure(t *testing.T, serverConfig *Config, m handshakeMessa
 	}()
 	ctx := context.Background()
 	conn := Server(s, serverConfig)
-	ch, err := conn.readClientHello(ctx)
+	ch, _, err := conn.readClientHello(ctx)
 	hs := serverHandshakeState{
 		c:           conn,
 		ctx:         ctx,
@@ -1448,7 +1448,7 @@ func TestSNIGivenOnFailure(t *testing.T) {
 	}()
 	conn := Server(s, serverConfig)
 	ctx := context.Background()
-	ch, err := conn.readClientHello(ctx)
+	ch, _, err := conn.readClientHello(ctx)
 	hs := serverHandshakeState{
 		c:           conn,
 		ctx:         ctx,
@@ -2044,3 +2044,78 @@ func TestHandshakeContextHierarchy(t *testing.T) {
 		t.Errorf("Unexpected client error: %v", err)
 	}
 }
+
+func TestHandshakeX25519MLKEM768(t *testing.T) {
+	t.Run("KeyShare", func(t *testing.T) {
+		clientConfig := testConfig.Clone()
+		clientConfig.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+		serverConfig := testConfig.Clone()
+		serverConfig.CurvePreferences = []CurveID{X25519MLKEM768}
+		serverConfig.GetConfigForClient = func(chi *ClientHelloInfo) (*Config, error) {
+			if len(chi.SupportedCurves) != 2 || chi.SupportedCurves[0] != X25519MLKEM768 {
+				t.Errorf("client offered groups %v", chi.SupportedCurves)
+			}
+			return nil, nil
+		}
+		if _, _, err := testHandshake(t, clientConfig, serverConfig); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("HelloRetryRequest", func(t *testing.T) {
+		// The client sends an X25519 key share, and the server only
+		// supports X25519MLKEM768, so it must request a new key share.
+		clientConfig := testConfig.Clone()
+		clientConfig.CurvePreferences = []CurveID{X25519, X25519MLKEM768}
+		serverConfig := testConfig.Clone()
+		serverConfig.CurvePreferences = []CurveID{X25519MLKEM768}
+		if _, _, err := testHandshake(t, clientConfig, serverConfig); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		clientConfig := testConfig.Clone()
+		clientConfig.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+		serverConfig := testConfig.Clone()
+		if _, _, err := testHandshake(t, clientConfig, serverConfig); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("TLS12", func(t *testing.T) {
+		// X25519MLKEM768 is not offered by TLS 1.2 clients, and not
+		// selected by servers negotiating TLS 1.2.
+		clientConfig := testConfig.Clone()
+		clientConfig.MaxVersion = VersionTLS12
+		clientConfig.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+		serverConfig := testConfig.Clone()
+		serverConfig.CurvePreferences = []CurveID{X25519MLKEM768, X25519}
+		serverConfig.GetConfigForClient = func(chi *ClientHelloInfo) (*Config, error) {
+			for _, curve := range chi.SupportedCurves {
+				if curve == X25519MLKEM768 {
+					t.Errorf("TLS 1.2 client offered X25519MLKEM768")
+				}
+			}
+			return nil, nil
+		}
+		if _, _, err := testHandshake(t, clientConfig, serverConfig); err != nil {
+			t.Fatal(err)
+		}
+
+		clientConfig.CurvePreferences = []CurveID{X25519}
+		clientConfig.CipherSuites = []uint16{TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+		serverConfig.CurvePreferences = []CurveID{X25519MLKEM768}
+		serverConfig.GetConfigForClient = nil
+		if _, _, err := testHandshake(t, clientConfig, serverConfig); err == nil {
+			t.Fatal("expected TLS 1.2 handshake with only X25519MLKEM768 to fail")
+		}
+
+		serverConfig.MaxVersion = VersionTLS12
+		clientConfig.MaxVersion = 0
+		clientConfig.CurvePreferences = []CurveID{X25519MLKEM768}
+		if _, _, err := testHandshake(t, clientConfig, serverConfig); err == nil {
+			t.Fatal("expected TLS 1.2 handshake with only X25519MLKEM768 to fail")
+		}
+	})
+}
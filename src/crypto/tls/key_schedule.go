@@ -7,6 +7,7 @@ package tls
 import (
 	"crypto/elliptic"
 	"crypto/hmac"
+	"crypto/internal/mlkem768"
 	"errors"
 	"hash"
 	"io"
@@ -102,7 +103,8 @@ func (c *cipherSuiteTLS13) exportKeyingMaterial(masterSecret []byte, transcript
 }
 
 // ecdheParameters implements Diffie-Hellman with either NIST curves or X25519,
-// according to RFC 8446, Section 4.2.8.2.
+// according to RFC 8446, Section 4.2.8.2, or the client side of the
+// X25519MLKEM768 hybrid key exchange.
 type ecdheParameters interface {
 	CurveID() CurveID
 	PublicKey() []byte
@@ -110,16 +112,11 @@ type ecdheParameters interface {
 }
 
 func generateECDHEParameters(rand io.Reader, curveID CurveID) (ecdheParameters, error) {
-	if curveID == X25519 {
-		privateKey := make([]byte, curve25519.ScalarSize)
-		if _, err := io.ReadFull(rand, privateKey); err != nil {
-			return nil, err
-		}
-		publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
-		if err != nil {
-			return nil, err
-		}
-		return &x25519Parameters{privateKey: privateKey, publicKey: publicKey}, nil
+	switch curveID {
+	case X25519:
+		return generateX25519Parameters(rand)
+	case X25519MLKEM768:
+		return generateX25519MLKEM768Parameters(rand)
 	}
 
 	curve, ok := curveForCurveID(curveID)
@@ -182,6 +179,18 @@ type x25519Parameters struct {
 	publicKey  []byte
 }
 
+func generateX25519Parameters(rand io.Reader) (*x25519Parameters, error) {
+	privateKey := make([]byte, curve25519.ScalarSize)
+	if _, err := io.ReadFull(rand, privateKey); err != nil {
+		return nil, err
+	}
+	publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	return &x25519Parameters{privateKey: privateKey, publicKey: publicKey}, nil
+}
+
 func (p *x25519Parameters) CurveID() CurveID {
 	return X25519
 }
@@ -197,3 +206,83 @@ func (p *x25519Parameters) SharedKey(peerPublicKey []byte) []byte {
 	}
 	return sharedKey
 }
+
+// x25519MLKEM768Parameters implements the client side of the X25519MLKEM768
+// hybrid key exchange, which combines an ML-KEM-768 encapsulation and an
+// X25519 exchange. See draft-kwiatkowski-tls-ecdhe-mlkem-02, Section 3.
+//
+// The client key share is the ML-KEM-768 encapsulation key followed by the
+// X25519 public key, and the server key share is the ML-KEM-768 ciphertext
+// followed by the X25519 public key. The shared secret is the ML-KEM-768
+// shared key followed by the X25519 shared secret.
+type x25519MLKEM768Parameters struct {
+	mlkem  *mlkem768.DecapsulationKey
+	x25519 *x25519Parameters
+}
+
+const x25519PublicKeySize = 32
+
+func generateX25519MLKEM768Parameters(rand io.Reader) (*x25519MLKEM768Parameters, error) {
+	seed := make([]byte, mlkem768.SeedSize)
+	if _, err := io.ReadFull(rand, seed); err != nil {
+		return nil, err
+	}
+	dk, err := mlkem768.NewKeyFromSeed(seed)
+	if err != nil {
+		return nil, err
+	}
+	x, err := generateX25519Parameters(rand)
+	if err != nil {
+		return nil, err
+	}
+	return &x25519MLKEM768Parameters{mlkem: dk, x25519: x}, nil
+}
+
+func (p *x25519MLKEM768Parameters) CurveID() CurveID {
+	return X25519MLKEM768
+}
+
+func (p *x25519MLKEM768Parameters) PublicKey() []byte {
+	return append(p.mlkem.EncapsulationKey(), p.x25519.PublicKey()...)
+}
+
+func (p *x25519MLKEM768Parameters) SharedKey(peerPublicKey []byte) []byte {
+	if len(peerPublicKey) != mlkem768.CiphertextSize+x25519PublicKeySize {
+		return nil
+	}
+	mlkemShared, err := mlkem768.Decapsulate(p.mlkem, peerPublicKey[:mlkem768.CiphertextSize])
+	if err != nil {
+		return nil
+	}
+	x25519Shared := p.x25519.SharedKey(peerPublicKey[mlkem768.CiphertextSize:])
+	if x25519Shared == nil {
+		return nil
+	}
+	return append(mlkemShared, x25519Shared...)
+}
+
+// x25519MLKEM768ServerShare implements the server side of the X25519MLKEM768
+// hybrid key exchange, given the client key share. It returns the server key
+// share and the shared secret, or a nil sharedKey if clientShare is invalid.
+func x25519MLKEM768ServerShare(rand io.Reader, clientShare []byte) (serverShare, sharedKey []byte, err error) {
+	if len(clientShare) != mlkem768.EncapsulationKeySize+x25519PublicKeySize {
+		return nil, nil, nil
+	}
+	ciphertext, mlkemShared, err := mlkem768.Encapsulate(clientShare[:mlkem768.EncapsulationKeySize])
+	if err != nil {
+		// The only possible failure, other than a crypto/rand error, is an
+		// encapsulation key with unreduced coefficients.
+		return nil, nil, nil
+	}
+	x, err := generateX25519Parameters(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	x25519Shared := x.SharedKey(clientShare[mlkem768.EncapsulationKeySize:])
+	if x25519Shared == nil {
+		return nil, nil, nil
+	}
+	serverShare = append(ciphertext, x.PublicKey()...)
+	sharedKey = append(mlkemShared, x25519Shared...)
+	return serverShare, sharedKey, nil
+}
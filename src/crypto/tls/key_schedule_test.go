@@ -6,6 +6,8 @@ package tls
 
 import (
 	"bytes"
+	"crypto/internal/mlkem768"
+	"crypto/rand"
 	"encoding/hex"
 	"hash"
 	"strings"
@@ -173,3 +175,54 @@ func TestExtract(t *testing.T) {
 		})
 	}
 }
+
+func TestX25519MLKEM768KeyExchange(t *testing.T) {
+	params, err := generateECDHEParameters(rand.Reader, X25519MLKEM768)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.CurveID() != X25519MLKEM768 {
+		t.Errorf("CurveID() = %v, want %v", params.CurveID(), X25519MLKEM768)
+	}
+	clientShare := params.PublicKey()
+	if len(clientShare) != mlkem768.EncapsulationKeySize+x25519PublicKeySize {
+		t.Fatalf("client key share is %d bytes", len(clientShare))
+	}
+
+	serverShare, serverKey, err := x25519MLKEM768ServerShare(rand.Reader, clientShare)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(serverShare) != mlkem768.CiphertextSize+x25519PublicKeySize {
+		t.Fatalf("server key share is %d bytes", len(serverShare))
+	}
+	if len(serverKey) != mlkem768.SharedKeySize+32 {
+		t.Fatalf("shared key is %d bytes", len(serverKey))
+	}
+	if clientKey := params.SharedKey(serverShare); !bytes.Equal(clientKey, serverKey) {
+		t.Fatalf("client shared key %x, server shared key %x", clientKey, serverKey)
+	}
+
+	// A modified ciphertext is implicitly rejected by ML-KEM, producing a
+	// different shared key rather than an error.
+	badServerShare := append([]byte(nil), serverShare...)
+	badServerShare[0] ^= 1
+	if clientKey := params.SharedKey(badServerShare); clientKey == nil || bytes.Equal(clientKey, serverKey) {
+		t.Errorf("modified ciphertext produced shared key %x", clientKey)
+	}
+	if clientKey := params.SharedKey(serverShare[1:]); clientKey != nil {
+		t.Errorf("short server key share produced shared key %x", clientKey)
+	}
+
+	if _, key, err := x25519MLKEM768ServerShare(rand.Reader, clientShare[1:]); err != nil || key != nil {
+		t.Errorf("short client key share: key %x, err %v", key, err)
+	}
+	// Set the first coefficient of the encapsulation key to 4095, which is
+	// not reduced modulo q.
+	badClientShare := append([]byte(nil), clientShare...)
+	badClientShare[0] = 0xff
+	badClientShare[1] |= 0x0f
+	if _, key, err := x25519MLKEM768ServerShare(rand.Reader, badClientShare); err != nil || key != nil {
+		t.Errorf("unreduced encapsulation key: key %x, err %v", key, err)
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tls
+
+import (
+	"bytes"
+	"crypto/x509"
+	"reflect"
+	"testing"
+)
+
+func TestSessionStateBytes(t *testing.T) {
+	leaf, err := x509.ParseCertificate(testRSACertificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := x509.ParseCertificate(testRSACertificateIssuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	states := []*SessionState{
+		{
+			version:     VersionTLS12,
+			cipherSuite: TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			createdAt:   12345,
+			secret:      []byte("master secret"),
+		},
+		{
+			Extra:            [][]byte{[]byte("app1"), {}, []byte("app2")},
+			version:          VersionTLS13,
+			cipherSuite:      TLS_AES_128_GCM_SHA256,
+			createdAt:        12345,
+			secret:           []byte("psk"),
+			peerCertificates: []*x509.Certificate{leaf},
+		},
+		{
+			version:          VersionTLS12,
+			isClient:         true,
+			cipherSuite:      TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			createdAt:        12345,
+			secret:           []byte("master secret"),
+			peerCertificates: []*x509.Certificate{leaf, issuer},
+			ocspResponse:     []byte("ocsp"),
+			scts:             [][]byte{[]byte("sct1"), []byte("sct2")},
+			verifiedChains:   [][]*x509.Certificate{{leaf, issuer}, {leaf}},
+		},
+		{
+			Extra:            [][]byte{[]byte("app")},
+			version:          VersionTLS13,
+			isClient:         true,
+			cipherSuite:      TLS_AES_128_GCM_SHA256,
+			createdAt:        12345,
+			secret:           []byte("psk"),
+			peerCertificates: []*x509.Certificate{leaf},
+			verifiedChains:   [][]*x509.Certificate{{leaf, issuer}},
+			useBy:            67890,
+			ageAdd:           42,
+		},
+	}
+	for i, s := range states {
+		b, err := s.Bytes()
+		if err != nil {
+			t.Fatalf("#%d: Bytes: %v", i, err)
+		}
+		got, err := ParseSessionState(b)
+		if err != nil {
+			t.Fatalf("#%d: ParseSessionState: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, s) {
+			t.Errorf("#%d: got %#v, want %#v", i, got, s)
+		}
+		for j := 0; j < len(b); j++ {
+			if _, err := ParseSessionState(b[:j]); err == nil {
+				t.Errorf("#%d: parsed a prefix of length %d", i, j)
+				break
+			}
+		}
+	}
+}
+
+func TestEncryptDecryptTicket(t *testing.T) {
+	config := testConfig.Clone()
+	s := &SessionState{
+		Extra:       [][]byte{[]byte("extra")},
+		version:     VersionTLS13,
+		cipherSuite: TLS_AES_128_GCM_SHA256,
+		secret:      []byte("psk"),
+	}
+	ticket, err := config.EncryptTicket(ConnectionState{}, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := config.DecryptTicket(ticket, ConnectionState{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, s) {
+		t.Errorf("got %#v, want %#v", got, s)
+	}
+
+	ticket[len(ticket)-1] ^= 0xff
+	if got, err := config.DecryptTicket(ticket, ConnectionState{}); got != nil || err != nil {
+		t.Errorf("DecryptTicket of a corrupted ticket = %v, %v; want nil, nil", got, err)
+	}
+}
+
+// serializingSessionCache is a ClientSessionCache that stores sessions in
+// their serialized form, as a persistent cache would.
+type serializingSessionCache struct {
+	tickets map[string][]byte
+	states  map[string][]byte
+	extra   []byte
+}
+
+func (c *serializingSessionCache) Get(key string) (*ClientSessionState, bool) {
+	ticket, ok := c.tickets[key]
+	if !ok {
+		return nil, false
+	}
+	state, err := ParseSessionState(c.states[key])
+	if err != nil {
+		return nil, false
+	}
+	cs, err := NewResumptionState(ticket, state)
+	if err != nil {
+		return nil, false
+	}
+	return cs, true
+}
+
+func (c *serializingSessionCache) Put(key string, cs *ClientSessionState) {
+	if cs == nil {
+		delete(c.tickets, key)
+		delete(c.states, key)
+		return
+	}
+	ticket, state, err := cs.ResumptionState()
+	if err != nil {
+		panic(err)
+	}
+	if c.extra != nil {
+		state.Extra = append(state.Extra, c.extra)
+	}
+	b, err := state.Bytes()
+	if err != nil {
+		panic(err)
+	}
+	c.tickets[key] = ticket
+	c.states[key] = b
+}
+
+func TestWrapUnwrapSession(t *testing.T) {
+	t.Run("TLSv12", func(t *testing.T) { testWrapUnwrapSession(t, VersionTLS12) })
+	t.Run("TLSv13", func(t *testing.T) { testWrapUnwrapSession(t, VersionTLS13) })
+}
+
+func testWrapUnwrapSession(t *testing.T, version uint16) {
+	// The server stores the sessions itself and only sends out handles, as a
+	// fleet of servers sharing a session store would.
+	store := make(map[string][]byte)
+	var unwrapped *SessionState
+	serverConfig := testConfig.Clone()
+	serverConfig.MaxVersion = version
+	serverConfig.WrapSession = func(cs ConnectionState, ss *SessionState) ([]byte, error) {
+		ss.Extra = append(ss.Extra, []byte("server data"))
+		b, err := ss.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		handle := []byte{byte(len(store))}
+		store[string(handle)] = b
+		return handle, nil
+	}
+	serverConfig.UnwrapSession = func(identity []byte, cs ConnectionState) (*SessionState, error) {
+		b, ok := store[string(identity)]
+		if !ok {
+			return nil, nil
+		}
+		ss, err := ParseSessionState(b)
+		if err != nil {
+			return nil, err
+		}
+		unwrapped = ss
+		return ss, nil
+	}
+
+	cache := &serializingSessionCache{
+		tickets: make(map[string][]byte),
+		states:  make(map[string][]byte),
+		extra:   []byte("client data"),
+	}
+	clientConfig := testConfig.Clone()
+	clientConfig.MaxVersion = version
+	clientConfig.ClientSessionCache = cache
+
+	if _, _, err := testHandshake(t, clientConfig, serverConfig); err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	if len(store) == 0 || len(cache.tickets) == 0 {
+		t.Fatal("no session was stored")
+	}
+
+	ss, cs, err := testHandshake(t, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatalf("resumption failed: %v", err)
+	}
+	if !ss.DidResume || !cs.DidResume {
+		t.Fatal("session was not resumed")
+	}
+	if unwrapped == nil || len(unwrapped.Extra) == 0 ||
+		!bytes.Equal(unwrapped.Extra[len(unwrapped.Extra)-1], []byte("server data")) {
+		t.Errorf("unwrapped session is missing the server data: %v", unwrapped)
+	}
+	for _, b := range cache.states {
+		state, err := ParseSessionState(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(state.Extra) == 0 || !bytes.Equal(state.Extra[0], []byte("client data")) {
+			t.Errorf("cached session is missing the client data: %q", state.Extra)
+		}
+	}
+
+	// Sessions unknown to UnwrapSession are ignored.
+	for k := range store {
+		delete(store, k)
+	}
+	ss, _, err = testHandshake(t, clientConfig, serverConfig)
+	if err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	if ss.DidResume {
+		t.Error("resumed a session UnwrapSession did not return")
+	}
+}
@@ -6,6 +6,7 @@ package x509
 import (
 	"bytes"
 	"crypto/dsa"
+	"crypto/ecdh"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
@@ -318,7 +319,19 @@ func parsePublicKey(algo PublicKeyAlgorithm, keyData *publicKeyInfo) (any, error
 		}
 		return pub, nil
 	default:
-		return nil, nil
+		if !keyData.Algorithm.Algorithm.Equal(oidPublicKeyX25519) {
+			return nil, nil
+		}
+		// RFC 8410, Section 3
+		// > For all of the OIDs, the parameters MUST be absent.
+		if len(keyData.Algorithm.Parameters.FullBytes) != 0 {
+			return nil, errors.New("x509: X25519 key encoded with illegal parameters")
+		}
+		pub, err := ecdh.X25519().NewPublicKey(der)
+		if err != nil {
+			return nil, errors.New("x509: invalid X25519 public key")
+		}
+		return pub, nil
 	}
 }
 
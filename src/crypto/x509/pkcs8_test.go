@@ -6,6 +6,7 @@ package x509
 
 import (
 	"bytes"
+	"crypto/ecdh"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
@@ -44,6 +45,10 @@ var pkcs8P521PrivateKeyHex = `3081ee020100301006072a8648ce3d020106052b8104002304
 // From RFC 8410, Section 7.
 var pkcs8Ed25519PrivateKeyHex = `302e020100300506032b657004220420d4ee72dbf913584ad5b6d8f1f769f8ad3afe7c28cbf1d4fbe097a88f44755842`
 
+// Generated using:
+//   openssl genpkey -algorithm x25519
+var pkcs8X25519PrivateKeyHex = `302e020100300506032b656e0422042048bd9ffbd606dff42b37479ed2879e54b2bfd34d5169375c027ab4edfe47e25f`
+
 func TestPKCS8(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -85,6 +90,11 @@ func TestPKCS8(t *testing.T) {
 			keyHex:  pkcs8Ed25519PrivateKeyHex,
 			keyType: reflect.TypeOf(ed25519.PrivateKey{}),
 		},
+		{
+			name:    "X25519 private key",
+			keyHex:  pkcs8X25519PrivateKeyHex,
+			keyType: reflect.TypeOf(&ecdh.PrivateKey{}),
+		},
 	}
 
 	for _, test := range tests {
@@ -115,6 +125,33 @@ func TestPKCS8(t *testing.T) {
 			t.Errorf("%s: marshaled PKCS#8 didn't match original: got %x, want %x", test.name, reserialised, derBytes)
 			continue
 		}
+
+		var ecdhCurve ecdh.Curve
+		switch test.curve {
+		case elliptic.P256():
+			ecdhCurve = ecdh.P256()
+		case elliptic.P384():
+			ecdhCurve = ecdh.P384()
+		case elliptic.P521():
+			ecdhCurve = ecdh.P521()
+		default:
+			continue
+		}
+		ecKey := privKey.(*ecdsa.PrivateKey)
+		ecdhKey, err := ecdhCurve.NewPrivateKey(ecKey.D.FillBytes(make([]byte, (test.curve.Params().N.BitLen()+7)/8)))
+		if err != nil {
+			t.Errorf("%s: failed to convert to ECDH key: %s", test.name, err)
+			continue
+		}
+		reserialised, err = MarshalPKCS8PrivateKey(ecdhKey)
+		if err != nil {
+			t.Errorf("%s: failed to marshal ECDH key into PKCS#8: %s", test.name, err)
+			continue
+		}
+		if !bytes.Equal(derBytes, reserialised) {
+			t.Errorf("%s: marshaled ECDH PKCS#8 didn't match original: got %x, want %x", test.name, reserialised, derBytes)
+			continue
+		}
 	}
 }
 
@@ -0,0 +1,176 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package trace reads execution traces produced by the runtime/trace
+// package and by "go test -trace".
+//
+// Traces produced by Go 1.19 and later are split into generations, each
+// of which can be decoded without the ones before it. A Reader decodes
+// such traces one generation at a time, so that a tool can process a
+// long trace, or a trace that is still being written, as a stream of
+// events without loading all of it into memory.
+package trace
+
+import (
+	"bufio"
+	"fmt"
+	"internal/trace"
+	"io"
+)
+
+// EventType is the type of an event.
+type EventType byte
+
+// Event types. Args and SArgs of an event hold the arguments
+// named by the Args and SArgs methods of its type.
+const (
+	EvGomaxprocs        = EventType(trace.EvGomaxprocs)        // current value of GOMAXPROCS [GOMAXPROCS]
+	EvProcStart         = EventType(trace.EvProcStart)         // start of P [thread id]
+	EvProcStop          = EventType(trace.EvProcStop)          // stop of P
+	EvGCStart           = EventType(trace.EvGCStart)           // GC start [seq]
+	EvGCDone            = EventType(trace.EvGCDone)            // GC done
+	EvGCSTWStart        = EventType(trace.EvGCSTWStart)        // GC stop-the-world start [kind id; kind]
+	EvGCSTWDone         = EventType(trace.EvGCSTWDone)         // GC stop-the-world done
+	EvGCSweepStart      = EventType(trace.EvGCSweepStart)      // GC sweep start
+	EvGCSweepDone       = EventType(trace.EvGCSweepDone)       // GC sweep done [swept, reclaimed]
+	EvGCSweepActive     = EventType(trace.EvGCSweepActive)     // GC sweep in progress when the trace starts
+	EvGoCreate          = EventType(trace.EvGoCreate)          // goroutine creation [new goroutine id, new stack id]
+	EvGoStart           = EventType(trace.EvGoStart)           // goroutine starts running [goroutine id, seq]
+	EvGoEnd             = EventType(trace.EvGoEnd)             // goroutine ends
+	EvGoStop            = EventType(trace.EvGoStop)            // goroutine stops (like in select{})
+	EvGoSched           = EventType(trace.EvGoSched)           // goroutine calls Gosched
+	EvGoPreempt         = EventType(trace.EvGoPreempt)         // goroutine is preempted
+	EvGoSleep           = EventType(trace.EvGoSleep)           // goroutine calls Sleep
+	EvGoBlock           = EventType(trace.EvGoBlock)           // goroutine blocks
+	EvGoUnblock         = EventType(trace.EvGoUnblock)         // goroutine is unblocked [goroutine id, seq]
+	EvGoBlockSend       = EventType(trace.EvGoBlockSend)       // goroutine blocks on chan send
+	EvGoBlockRecv       = EventType(trace.EvGoBlockRecv)       // goroutine blocks on chan recv
+	EvGoBlockSelect     = EventType(trace.EvGoBlockSelect)     // goroutine blocks on select
+	EvGoBlockSync       = EventType(trace.EvGoBlockSync)       // goroutine blocks on Mutex/RWMutex
+	EvGoBlockCond       = EventType(trace.EvGoBlockCond)       // goroutine blocks on Cond
+	EvGoBlockNet        = EventType(trace.EvGoBlockNet)        // goroutine blocks on network
+	EvGoSysCall         = EventType(trace.EvGoSysCall)         // syscall enter
+	EvGoSysExit         = EventType(trace.EvGoSysExit)         // syscall exit [goroutine id, seq, real timestamp]
+	EvGoSysBlock        = EventType(trace.EvGoSysBlock)        // syscall blocks
+	EvGoWaiting         = EventType(trace.EvGoWaiting)         // goroutine is blocked when tracing starts [goroutine id]
+	EvGoInSyscall       = EventType(trace.EvGoInSyscall)       // goroutine is in syscall when tracing starts [goroutine id]
+	EvHeapAlloc         = EventType(trace.EvHeapAlloc)         // live heap size change [heap live bytes]
+	EvHeapGoal          = EventType(trace.EvHeapGoal)          // heap goal change [heap goal bytes]
+	EvFutileWakeup      = EventType(trace.EvFutileWakeup)      // the previous wakeup of this goroutine was futile
+	EvGoStartLabel      = EventType(trace.EvGoStartLabel)      // goroutine starts running with label [goroutine id, seq, label id; label]
+	EvGoBlockGC         = EventType(trace.EvGoBlockGC)         // goroutine blocks on GC assist
+	EvGCMarkAssistStart = EventType(trace.EvGCMarkAssistStart) // GC mark assist start
+	EvGCMarkAssistDone  = EventType(trace.EvGCMarkAssistDone)  // GC mark assist done
+	EvUserTaskCreate    = EventType(trace.EvUserTaskCreate)    // runtime/trace.NewTask [task id, parent task id, type id; type]
+	EvUserTaskEnd       = EventType(trace.EvUserTaskEnd)       // end of task [task id]
+	EvUserRegion        = EventType(trace.EvUserRegion)        // runtime/trace.WithRegion [task id, mode (0: start, 1: end), type id; type]
+	EvUserLog           = EventType(trace.EvUserLog)           // runtime/trace.Log [task id, category id; category, message]
+)
+
+// String returns the name of the event type, such as "GoCreate".
+func (t EventType) String() string {
+	if int(t) < len(trace.EventDescriptions) && trace.EventDescriptions[t].Name != "" {
+		return trace.EventDescriptions[t].Name
+	}
+	return fmt.Sprintf("EventType(%d)", byte(t))
+}
+
+// Args returns the names of the integer arguments of events of type t.
+func (t EventType) Args() []string {
+	if int(t) < len(trace.EventDescriptions) {
+		return trace.EventDescriptions[t].Args
+	}
+	return nil
+}
+
+// SArgs returns the names of the string arguments of events of type t.
+func (t EventType) SArgs() []string {
+	if int(t) < len(trace.EventDescriptions) {
+		return trace.EventDescriptions[t].SArgs
+	}
+	return nil
+}
+
+// Special values of Event.P for events that do not happen on a P.
+const (
+	TimerP   = trace.TimerP   // timer unblocks
+	NetpollP = trace.NetpollP // network unblocks
+	SyscallP = trace.SyscallP // returns from syscalls
+)
+
+// An Event is a single event in a trace.
+type Event struct {
+	Type  EventType
+	Time  int64    // time of the event in nanoseconds since the beginning of the trace
+	P     int      // P on which the event happened, or one of TimerP, NetpollP, SyscallP
+	G     uint64   // goroutine on which the event happened, or 0
+	Args  []uint64 // event-type-specific arguments, named by Type.Args
+	SArgs []string // event-type-specific string arguments, named by Type.SArgs
+	Stack []Frame  // stack trace of the event (can be empty)
+}
+
+// A Frame is a frame in a stack trace.
+type Frame struct {
+	PC   uint64
+	Fn   string
+	File string
+	Line int
+}
+
+// A Reader reads the events of a trace in order.
+type Reader struct {
+	r      *trace.Reader
+	events []*trace.Event
+	stacks map[uint64][]*trace.Frame
+}
+
+// NewReader reads the header of the trace from r and returns a Reader
+// for its events. Traces produced by Go 1.6 and earlier are not supported.
+func NewReader(r io.Reader) (*Reader, error) {
+	tr, err := trace.NewReader(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	if tr.Version() < 1007 {
+		return nil, fmt.Errorf("traces produced by go 1.6 or below are not supported")
+	}
+	return &Reader{r: tr}, nil
+}
+
+// ReadEvent returns the next event of the trace.
+// At the end of the trace, ReadEvent returns io.EOF.
+//
+// Events are decoded one generation of the trace at a time, so
+// ReadEvent reads from the underlying reader only when all events of
+// the previous generation have been returned. Unlike "go tool trace",
+// ReadEvent does not check that the events are consistent across the
+// whole trace.
+func (r *Reader) ReadEvent() (Event, error) {
+	for len(r.events) == 0 {
+		events, stacks, err := r.r.ReadGeneration()
+		if err != nil {
+			return Event{}, err
+		}
+		r.events, r.stacks = events, stacks
+	}
+	ev := r.events[0]
+	r.events[0] = nil
+	r.events = r.events[1:]
+
+	e := Event{
+		Type:  EventType(ev.Type),
+		Time:  ev.Ts,
+		P:     ev.P,
+		G:     ev.G,
+		Args:  append([]uint64(nil), ev.Args[:len(EventType(ev.Type).Args())]...),
+		SArgs: ev.SArgs,
+	}
+	if stk := r.stacks[ev.StkID]; ev.StkID != 0 && len(stk) > 0 {
+		e.Stack = make([]Frame, len(stk))
+		for i, f := range stk {
+			e.Stack[i] = Frame{PC: f.PC, Fn: f.Fn, File: f.File, Line: f.Line}
+		}
+	}
+	return e, nil
+}
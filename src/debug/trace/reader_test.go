@@ -0,0 +1,84 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace_test
+
+import (
+	"bytes"
+	"context"
+	. "debug/trace"
+	"io"
+	rtrace "runtime/trace"
+	"strings"
+	"testing"
+)
+
+func TestReadEvent(t *testing.T) {
+	if rtrace.IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	// Take the trace from a flight recorder, so that it has
+	// more than one generation.
+	fr := rtrace.NewFlightRecorder(rtrace.FlightRecorderConfig{})
+	if err := fr.Start(); err != nil {
+		t.Fatalf("failed to start flight recorder: %v", err)
+	}
+	ctx := context.Background()
+	rtrace.Log(ctx, "reader", "first")
+	buf := new(bytes.Buffer)
+	if _, err := fr.WriteTo(buf); err != nil {
+		t.Fatalf("failed to write snapshot: %v", err)
+	}
+	rtrace.Log(ctx, "reader", "second")
+	buf.Reset()
+	if _, err := fr.WriteTo(buf); err != nil {
+		t.Fatalf("failed to write snapshot: %v", err)
+	}
+	fr.Stop()
+
+	r, err := NewReader(buf)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	var logs []string
+	var last int64
+	for {
+		ev, err := r.ReadEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadEvent failed: %v", err)
+		}
+		if ev.Time < last {
+			t.Errorf("%v event at %d follows event at %d", ev.Type, ev.Time, last)
+		}
+		last = ev.Time
+		if len(ev.Args) != len(ev.Type.Args()) || len(ev.SArgs) != len(ev.Type.SArgs()) {
+			t.Errorf("%v event has arguments %v %q", ev.Type, ev.Args, ev.SArgs)
+		}
+		if ev.Type == EvUserLog {
+			if len(ev.Stack) == 0 {
+				t.Errorf("UserLog event has no stack")
+			}
+			logs = append(logs, ev.SArgs[1])
+		}
+	}
+	if got, want := strings.Join(logs, ","), "first,second"; got != want {
+		t.Errorf("logged messages: got %q, want %q", got, want)
+	}
+}
+
+func TestNewReaderBadInput(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"not a trace file",
+		"go 1.5 trace\x00\x00\x00\x00",
+		"go 1.19 trace\x00\x00\x00\x02\x00",
+	} {
+		if _, err := NewReader(strings.NewReader(data)); err == nil {
+			t.Errorf("NewReader(%q) succeeded", data)
+		}
+	}
+}
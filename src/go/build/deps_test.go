@@ -74,6 +74,7 @@ var depsRules = `
 	  internal/cfg, internal/cpu, internal/goarch,
 	  internal/goexperiment, internal/goos,
 	  internal/goversion, internal/nettrace,
+	  log/internal, maps,
 	  unicode/utf8, unicode/utf16, unicode,
 	  unsafe;
 
@@ -103,11 +104,18 @@ var depsRules = `
 	< sort
 	< container/heap;
 
+	RUNTIME
+	< internal/synctest
+	< testing/synctest;
+
+	RUNTIME, constraints
+	< slices;
+
 	RUNTIME
 	< io;
 
 	syscall !< io;
-	reflect !< sort;
+	reflect !< sort, slices;
 
 	RUNTIME, unicode/utf8
 	< path;
@@ -389,14 +397,23 @@ var depsRules = `
 	< NET;
 
 	# logging - most packages should not import; http and up is allowed
-	FMT
+	FMT, log/internal
 	< log;
 
-	log !< crypto/tls, database/sql, go/importer, testing;
+	log, log/slog !< crypto/tls, database/sql, go/importer, testing;
 
 	FMT, log, net
 	< log/syslog;
 
+	RUNTIME
+	< log/slog/internal/buffer;
+
+	FMT,
+	encoding, encoding/json,
+	log, log/internal,
+	log/slog/internal/buffer
+	< log/slog;
+
 	NET, log
 	< net/mail;
 
@@ -406,13 +423,13 @@ var depsRules = `
 	< crypto
 	< crypto/subtle
 	< crypto/internal/subtle
-	< crypto/elliptic/internal/fiat
-	< crypto/elliptic/internal/nistec
+	< crypto/internal/nistec/fiat
+	< crypto/internal/nistec
 	< crypto/ed25519/internal/edwards25519/field, golang.org/x/crypto/curve25519/internal/field
 	< crypto/ed25519/internal/edwards25519
 	< crypto/cipher
 	< crypto/aes, crypto/des, crypto/hmac, crypto/md5, crypto/rc4,
-	  crypto/sha1, crypto/sha256, crypto/sha512
+	  crypto/sha1, crypto/sha256, crypto/sha512, crypto/internal/sha3
 	< CRYPTO;
 
 	CGO, fmt, net !< CRYPTO;
@@ -427,18 +444,19 @@ var depsRules = `
 	< golang.org/x/crypto/cryptobyte
 	< golang.org/x/crypto/curve25519
 	< crypto/dsa, crypto/elliptic, crypto/rsa
-	< crypto/ecdsa
+	< crypto/ecdh, crypto/ecdsa, crypto/internal/mlkem768
 	< CRYPTO-MATH;
 
 	CGO, net !< CRYPTO-MATH;
 
 	# TLS, Prince of Dependencies.
 	CRYPTO-MATH, NET, container/list, encoding/hex, encoding/pem
-	< golang.org/x/crypto/internal/subtle
+	< golang.org/x/crypto/internal/alias
 	< golang.org/x/crypto/chacha20
 	< golang.org/x/crypto/internal/poly1305
 	< golang.org/x/crypto/chacha20poly1305
 	< golang.org/x/crypto/hkdf
+	< crypto/internal/hpke
 	< crypto/x509/internal/macos
 	< crypto/x509/pkix
 	< crypto/x509
@@ -506,6 +524,10 @@ var depsRules = `
 	html, internal/profile, net/http, runtime/pprof, runtime/trace
 	< net/http/pprof;
 
+	# Coverage
+	FMT, encoding/binary
+	< internal/coverage;
+
 	# RPC
 	encoding/gob, encoding/json, go/token, html/template, net/http
 	< net/rpc
@@ -523,7 +545,7 @@ var depsRules = `
 	FMT, flag, math/rand
 	< testing/quick;
 
-	FMT, DEBUG, flag, runtime/trace, internal/sysinfo, math/rand
+	FMT, DEBUG, encoding, flag, runtime/trace, internal/sysinfo, math/rand
 	< testing;
 
 	FMT, crypto/sha256, encoding/json, go/ast, go/parser, go/token,
@@ -549,7 +571,8 @@ var depsRules = `
 	< os/exec/internal/fdtest;
 
 	FMT, container/heap, math/rand
-	< internal/trace;
+	< internal/trace
+	< debug/trace;
 `
 
 // listStdPkgs returns the same list of packages as "go list std".
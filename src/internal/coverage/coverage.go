@@ -0,0 +1,218 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package coverage implements coverage data collection for programs
+// built with "go build -cover", and defines the format of the data
+// files written by such programs.
+//
+// A program built with -cover registers the coverage counters of its
+// instrumented packages at init time. When the program exits, either
+// by returning from main.main or by calling os.Exit, it writes a data
+// file into the directory named by the GOCOVERDIR environment
+// variable. Each run writes a new file, named
+//
+//	covdata.<pid>.<nanotime>
+//
+// The files are processed by "go tool covdata", which can merge and
+// subtract them and convert them to the text profile format read by
+// "go tool cover".
+//
+// A data file is a binary encoding of a Profile. It starts with the
+// 8-byte magic string "\x00gocov1\n". All integers are encoded as
+// unsigned varints and strings as a length followed by the bytes:
+//
+//	mode   string
+//	nfiles uint
+//	nfiles times:
+//		name    string
+//		nblocks uint
+//		nblocks times:
+//			line0, col0, line1, col1, stmts, count uint
+package coverage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DirEnv is the environment variable naming the directory into which
+// instrumented programs write their coverage data.
+const DirEnv = "GOCOVERDIR"
+
+// FilePrefix is the prefix of the names of coverage data files.
+const FilePrefix = "covdata."
+
+const magic = "\x00gocov1\n"
+
+// Block records the coverage data for a single basic block. As in
+// testing.CoverBlock, the positions are 1-indexed and columns are
+// measured in bytes.
+type Block struct {
+	Line0 uint32 // Line number for block start.
+	Col0  uint16 // Column number for block start.
+	Line1 uint32 // Line number for block end.
+	Col1  uint16 // Column number for block end.
+	Stmts uint16 // Number of statements included in this block.
+	Count uint32 // Number of times the block was executed.
+}
+
+// File holds the coverage data for the blocks of a source file.
+type File struct {
+	Name   string // import path of the package, a slash, and the file name
+	Blocks []Block
+}
+
+// A Profile holds the coverage data of a program run, or the result of
+// combining the data of several runs.
+type Profile struct {
+	Mode  string // "set", "count" or "atomic"
+	Files []*File
+}
+
+// Write writes p to w in the coverage data file format.
+func (p *Profile) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	var buf [binary.MaxVarintLen64]byte
+	putUint := func(x uint64) {
+		n := binary.PutUvarint(buf[:], x)
+		bw.Write(buf[:n])
+	}
+	putString := func(s string) {
+		putUint(uint64(len(s)))
+		bw.WriteString(s)
+	}
+
+	bw.WriteString(magic)
+	putString(p.Mode)
+	putUint(uint64(len(p.Files)))
+	for _, f := range p.Files {
+		putString(f.Name)
+		putUint(uint64(len(f.Blocks)))
+		for _, b := range f.Blocks {
+			putUint(uint64(b.Line0))
+			putUint(uint64(b.Col0))
+			putUint(uint64(b.Line1))
+			putUint(uint64(b.Col1))
+			putUint(uint64(b.Stmts))
+			putUint(uint64(b.Count))
+		}
+	}
+	return bw.Flush()
+}
+
+// errCorrupt is returned by Read for malformed data.
+var errCorrupt = errors.New("corrupt coverage data")
+
+// Read reads a Profile in the coverage data file format from r.
+func Read(r io.Reader) (*Profile, error) {
+	br := bufio.NewReader(r)
+	var hdr [len(magic)]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil || string(hdr[:]) != magic {
+		return nil, errors.New("not a coverage data file")
+	}
+
+	var err error
+	getUint := func(max uint64) uint64 {
+		if err != nil {
+			return 0
+		}
+		var x uint64
+		x, err = binary.ReadUvarint(br)
+		if err == nil && x > max {
+			err = errCorrupt
+		}
+		return x
+	}
+	getString := func() string {
+		n := getUint(1 << 20)
+		if err != nil {
+			return ""
+		}
+		b := make([]byte, n)
+		if _, err = io.ReadFull(br, b); err != nil {
+			return ""
+		}
+		return string(b)
+	}
+
+	p := &Profile{Mode: getString()}
+	nfiles := getUint(1 << 30)
+	for i := uint64(0); i < nfiles && err == nil; i++ {
+		f := &File{Name: getString()}
+		nblocks := getUint(1 << 30)
+		for j := uint64(0); j < nblocks && err == nil; j++ {
+			f.Blocks = append(f.Blocks, Block{
+				Line0: uint32(getUint(1<<32 - 1)),
+				Col0:  uint16(getUint(1<<16 - 1)),
+				Line1: uint32(getUint(1<<32 - 1)),
+				Col1:  uint16(getUint(1<<16 - 1)),
+				Stmts: uint16(getUint(1<<16 - 1)),
+				Count: uint32(getUint(1<<32 - 1)),
+			})
+		}
+		p.Files = append(p.Files, f)
+	}
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, fmt.Errorf("reading coverage data: %v", err)
+	}
+	return p, nil
+}
+
+// WriteDir writes p into a new data file in dir.
+func WriteDir(dir string, p *Profile) error {
+	name := fmt.Sprintf("%s%d.%d", FilePrefix, os.Getpid(), time.Now().UnixNano())
+
+	// Write to a temporary file and rename it into place,
+	// so that readers never see a partially written file.
+	f, err := os.CreateTemp(dir, "tmp."+name+".*")
+	if err != nil {
+		return err
+	}
+	err = p.Write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(dir, name))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// ReadDir reads all the coverage data files in dir.
+func ReadDir(dir string) ([]*Profile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var profiles []*Profile
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), FilePrefix) || !e.Type().IsRegular() {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		p, err := Read(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filepath.Join(dir, e.Name()), err)
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coverage
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testProfile = &Profile{
+	Mode: "count",
+	Files: []*File{
+		{
+			Name: "example.com/p/a.go",
+			Blocks: []Block{
+				{Line0: 3, Col0: 22, Line1: 5, Col1: 2, Stmts: 1, Count: 7},
+				{Line0: 5, Col0: 2, Line1: 7, Col1: 16, Stmts: 2, Count: 0},
+			},
+		},
+		{
+			Name: "example.com/p/b.go",
+			Blocks: []Block{
+				{Line0: 1 << 20, Col0: 1<<16 - 1, Line1: 1<<20 + 1, Col1: 1, Stmts: 1<<16 - 1, Count: 1<<32 - 1},
+			},
+		},
+	},
+}
+
+func TestReadWrite(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testProfile.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	p, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, testProfile) {
+		t.Errorf("Read(Write(p)) = %+v, want %+v", p, testProfile)
+	}
+
+	// Truncated data must be rejected.
+	for _, n := range []int{0, 4, len(magic), len(data) / 2, len(data) - 1} {
+		if _, err := Read(bytes.NewReader(data[:n])); err == nil {
+			t.Errorf("Read of %d of %d bytes succeeded, want error", n, len(data))
+		}
+	}
+}
+
+func TestWriteReadDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteDir(dir, testProfile); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/other", []byte("not coverage data"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if name := e.Name(); name != "other" && !strings.HasPrefix(name, FilePrefix) {
+			t.Errorf("unexpected file %s left in directory", name)
+		}
+	}
+
+	profiles, err := ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 1 || !reflect.DeepEqual(profiles[0], testProfile) {
+		t.Errorf("ReadDir returned %+v, want [%+v]", profiles, testProfile)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	// The layout of the variables declared by cmd/cover.
+	var cover = struct {
+		Count   [2]uint32
+		Pos     [3 * 2]uint32
+		NumStmt [2]uint16
+	}{
+		Pos: [3 * 2]uint32{
+			3, 5, 0x20016, // [3:22-5:2]
+			5, 7, 0x100002, // [5:2-7:16]
+		},
+		NumStmt: [2]uint16{1, 2},
+	}
+
+	registered.hooked = true // don't install the exit hook
+	defer func() { registered.hooked = false }()
+	RegisterFile("count", "example.com/p/a.go", cover.Count[:], cover.Pos[:], cover.NumStmt[:])
+	RegisterFile("count", "example.com/p/a.go", cover.Count[:], cover.Pos[:], cover.NumStmt[:])
+	cover.Count[0] = 7
+
+	want := &Profile{Mode: "count", Files: testProfile.Files[:1]}
+	if p := snapshot(); !reflect.DeepEqual(p, want) {
+		t.Errorf("snapshot() = %+v, want %+v", p, want)
+	}
+}
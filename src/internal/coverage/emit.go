@@ -0,0 +1,109 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package coverage
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// counterFile holds the counters of a source file, as registered by
+// RegisterFile.
+type counterFile struct {
+	name     string
+	counter  []uint32
+	pos      []uint32
+	numStmts []uint16
+}
+
+var registered struct {
+	sync.Mutex
+	mode   string
+	files  []*counterFile
+	names  map[string]bool
+	hooked bool
+}
+
+// RegisterFile records the coverage counters of a source file
+// instrumented by cmd/cover in the given mode, so that they are
+// written out when the program exits. counter, pos and numStmts are
+// the Count, Pos and NumStmt fields of the variable declared by
+// cmd/cover for the file.
+//
+// RegisterFile is called from code generated by "go build -cover" in
+// the main package of an instrumented program.
+func RegisterFile(mode, fileName string, counter []uint32, pos []uint32, numStmts []uint16) {
+	if 3*len(counter) != len(pos) || len(counter) != len(numStmts) {
+		panic("coverage: mismatched sizes")
+	}
+
+	registered.Lock()
+	defer registered.Unlock()
+	if registered.mode == "" {
+		registered.mode = mode
+	} else if registered.mode != mode {
+		panic("coverage: mismatched coverage modes " + registered.mode + " and " + mode)
+	}
+	if registered.names[fileName] {
+		// Already registered.
+		return
+	}
+	if registered.names == nil {
+		registered.names = make(map[string]bool)
+	}
+	registered.names[fileName] = true
+	registered.files = append(registered.files, &counterFile{fileName, counter, pos, numStmts})
+
+	if !registered.hooked {
+		registered.hooked = true
+		runtime_addExitHook(emit, true)
+	}
+}
+
+// runtime_addExitHook arranges for f to be called when the program
+// exits; if runOnNonZeroExit is false, only on a zero exit status.
+func runtime_addExitHook(f func(), runOnNonZeroExit bool) // implemented in runtime
+
+// snapshot returns the current values of the registered counters.
+func snapshot() *Profile {
+	registered.Lock()
+	defer registered.Unlock()
+
+	p := &Profile{Mode: registered.mode}
+	for _, cf := range registered.files {
+		f := &File{Name: cf.name, Blocks: make([]Block, len(cf.counter))}
+		for i := range cf.counter {
+			count := cf.counter[i]
+			if p.Mode == "atomic" {
+				count = atomic.LoadUint32(&cf.counter[i])
+			}
+			f.Blocks[i] = Block{
+				Line0: cf.pos[3*i+0],
+				Col0:  uint16(cf.pos[3*i+2]),
+				Line1: cf.pos[3*i+1],
+				Col1:  uint16(cf.pos[3*i+2] >> 16),
+				Stmts: cf.numStmts[i],
+				Count: count,
+			}
+		}
+		p.Files = append(p.Files, f)
+	}
+	return p
+}
+
+// emit writes the coverage data of the program into the directory
+// named by $GOCOVERDIR. It is run as an exit hook.
+func emit() {
+	dir := os.Getenv(DirEnv)
+	if dir == "" {
+		fmt.Fprintf(os.Stderr, "warning: %s not set, no coverage data emitted\n", DirEnv)
+		return
+	}
+	if err := WriteDir(dir, snapshot()); err != nil {
+		fmt.Fprintf(os.Stderr, "error: coverage data emit failed: %v\n", err)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import "sync"
+
+// When fuzzing, the code under test is built with instrumentation that
+// reports the operands of its integer and string comparisons (see trace.go).
+// A worker records recent operands in cmpTable and returns them to the
+// coordinator, which passes them back with the next input to fuzz. The
+// mutator then splices the operands into inputs, which lets fuzzing get past
+// comparisons against magic numbers and keywords that random mutations
+// rarely satisfy.
+//
+// The mutator only uses the operands passed in fuzzArgs, never ones recorded
+// during the same call, so that the coordinator can replay the mutations made
+// by a worker from the state of the random number generator.
+
+const (
+	maxIntCmps    = 128 // number of integer comparisons kept in cmpTable
+	maxStringCmps = 32  // number of string comparisons kept in cmpTable
+	maxCmpString  = 64  // length of the longest string operand kept
+)
+
+// cmpLog holds the operands of comparisons made by the code under test.
+type cmpLog struct {
+	Ints    []intCmp    `json:",omitempty"`
+	Strings []stringCmp `json:",omitempty"`
+}
+
+// intCmp is a comparison of two integers that are Size bytes long.
+type intCmp struct {
+	Size int
+	X, Y uint64
+}
+
+// stringCmp is a comparison of two strings. The operands are kept as byte
+// slices, so that they are not changed by JSON encoding if they are not valid
+// UTF-8.
+type stringCmp struct {
+	X, Y []byte
+}
+
+// cmpTable records comparisons as the code under test makes them. Each
+// comparison is stored in a slot chosen by hashing its operands, so that
+// repeating a comparison does not fill the table, and newer comparisons
+// replace older ones that hash to the same slot.
+var cmpTable struct {
+	mu      sync.Mutex
+	ints    [maxIntCmps]intCmp
+	strings [maxStringCmps]struct {
+		x, y   [maxCmpString]byte
+		nx, ny uint8
+		used   bool
+	}
+}
+
+// recordIntCmp records a comparison of integers that are size bytes long.
+func recordIntCmp(size int, x, y uint64) {
+	if x == y {
+		// The input already satisfies the comparison.
+		return
+	}
+	// Comparisons may be made by several goroutines at once, but there is
+	// no need to record every one of them. Don't make the code under test
+	// wait.
+	if !cmpTable.mu.TryLock() {
+		return
+	}
+	h := (x*31+y)*0x9e3779b97f4a7c15 + uint64(size)
+	cmpTable.ints[h%maxIntCmps] = intCmp{Size: size, X: x, Y: y}
+	cmpTable.mu.Unlock()
+}
+
+// recordStringCmp records a comparison of strings.
+func recordStringCmp(x, y string) {
+	if x == y || len(x) > maxCmpString || len(y) > maxCmpString {
+		// Longer operands could only be used in part, which would not
+		// satisfy the comparison anyway.
+		return
+	}
+	if !cmpTable.mu.TryLock() {
+		return
+	}
+	// FNV-1a.
+	h := uint32(2166136261)
+	for i := 0; i < len(x); i++ {
+		h = (h ^ uint32(x[i])) * 16777619
+	}
+	h = (h ^ 0xff) * 16777619
+	for i := 0; i < len(y); i++ {
+		h = (h ^ uint32(y[i])) * 16777619
+	}
+	s := &cmpTable.strings[h%maxStringCmps]
+	s.nx = uint8(copy(s.x[:], x))
+	s.ny = uint8(copy(s.y[:], y))
+	s.used = true
+	cmpTable.mu.Unlock()
+}
+
+// snapshotCmpLog returns the comparisons recorded so far.
+func snapshotCmpLog() cmpLog {
+	cmpTable.mu.Lock()
+	defer cmpTable.mu.Unlock()
+	var l cmpLog
+	for _, c := range cmpTable.ints {
+		if c.Size != 0 {
+			l.Ints = append(l.Ints, c)
+		}
+	}
+	for i := range cmpTable.strings {
+		s := &cmpTable.strings[i]
+		if s.used {
+			l.Strings = append(l.Strings, stringCmp{
+				X: append([]byte(nil), s.x[:s.nx]...),
+				Y: append([]byte(nil), s.y[:s.ny]...),
+			})
+		}
+	}
+	return l
+}
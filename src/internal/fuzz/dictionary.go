@@ -0,0 +1,64 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// readDictionary reads the tokens in the named dictionary file. It returns
+// no tokens and no error if the file does not exist.
+func readDictionary(filename string) ([][]byte, error) {
+	data, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading fuzzing dictionary: %v", err)
+	}
+	dict, err := parseDictionary(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", filename, err)
+	}
+	return dict, nil
+}
+
+// parseDictionary parses a dictionary of tokens, such as keywords or magic
+// numbers, for the mutator to insert into inputs. Dictionaries use the format
+// of libFuzzer and AFL dictionaries: each line holds a token as a quoted
+// string, optionally preceded by a name and an equals sign. Blank lines and
+// lines starting with '#' are ignored. For example:
+//
+//	# HTTP
+//	"GET"
+//	kw_post="POST"
+//	"\r\n\r\n"
+//
+// The quoted strings use Go syntax, which includes the \\, \" and \xAB escapes
+// of the original format.
+func parseDictionary(data []byte) ([][]byte, error) {
+	var dict [][]byte
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		quoted := line
+		if eq := bytes.IndexByte(line, '='); eq >= 0 && line[0] != '"' {
+			// Drop the name, which is not used.
+			quoted = bytes.TrimSpace(line[eq+1:])
+		}
+		tok, err := strconv.Unquote(string(quoted))
+		if err != nil || quoted[0] != '"' {
+			return nil, fmt.Errorf("%d: malformed dictionary entry: %s", i+1, line)
+		}
+		if tok != "" {
+			dict = append(dict, []byte(tok))
+		}
+	}
+	return dict, nil
+}
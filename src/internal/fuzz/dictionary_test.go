@@ -0,0 +1,71 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDictionary(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		in      string
+		want    [][]byte
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			in:   "",
+		},
+		{
+			name: "tokens",
+			in: `# comment
+"GET"
+
+kw_post="POST"
+  magic = "\x89PNG\r\n"
+empty=""
+`,
+			want: [][]byte{[]byte("GET"), []byte("POST"), []byte("\x89PNG\r\n")},
+		},
+		{
+			name:    "unquoted",
+			in:      "GET\n",
+			wantErr: true,
+		},
+		{
+			name:    "unquoted_value",
+			in:      "kw=GET\n",
+			wantErr: true,
+		},
+		{
+			name:    "backquoted",
+			in:      "kw=`GET`\n",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated",
+			in:      "\"GET\n",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseDictionary([]byte(tc.in))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
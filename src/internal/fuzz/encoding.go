@@ -10,6 +10,7 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"reflect"
 	"strconv"
 )
 
@@ -17,7 +18,9 @@ import (
 var encVersion1 = "go test fuzz v1"
 
 // marshalCorpusFile encodes an arbitrary number of arguments into the file format for the
-// corpus.
+// corpus. Values of the basic types listed in zeroVals are written as a conversion
+// of a literal to their type; see encoding_composite.go for the encoding of values
+// of other types.
 func marshalCorpusFile(vals ...any) []byte {
 	if len(vals) == 0 {
 		panic("must have at least one value to marshal")
@@ -37,15 +40,23 @@ func marshalCorpusFile(vals ...any) []byte {
 			fmt.Fprintf(b, "byte(%q)\n", t)
 		case []byte: // []uint8
 			fmt.Fprintf(b, "[]byte(%q)\n", t)
+		case nil:
+			panic("unsupported type: nil")
 		default:
-			panic(fmt.Sprintf("unsupported type: %T", t))
+			marshalValue(b, reflect.ValueOf(t))
+			b.WriteByte('\n')
 		}
 	}
 	return b.Bytes()
 }
 
 // unmarshalCorpusFile decodes corpus bytes into their respective values.
-func unmarshalCorpusFile(b []byte) ([]any, error) {
+//
+// types holds the types of the arguments of the fuzz target. Values of types
+// other than the basic types listed in zeroVals can only be decoded if types
+// is provided; values of basic types are decoded according to the type
+// written in the file, and must be checked with CheckCorpus.
+func unmarshalCorpusFile(b []byte, types []reflect.Type) ([]any, error) {
 	if len(b) == 0 {
 		return nil, fmt.Errorf("cannot unmarshal empty string")
 	}
@@ -62,7 +73,13 @@ func unmarshalCorpusFile(b []byte) ([]any, error) {
 		if len(line) == 0 {
 			continue
 		}
-		v, err := parseCorpusValue(line)
+		var v any
+		var err error
+		if i := len(vals); i < len(types) && !isBasicType(types[i]) {
+			v, err = parseTypedValue(line, types[i])
+		} else {
+			v, err = parseCorpusValue(line)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("malformed line %q: %v", line, err)
 		}
@@ -0,0 +1,492 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Values of types other than the basic types listed in zeroVals (structs,
+// slices, arrays, maps, named types, and types implementing
+// encoding.BinaryMarshaler) are encoded with reflection. Each is written on
+// one line as its type followed by a composite literal, or, for types that
+// have no composite literal form, by a conversion:
+//
+//	fuzz_test.Point{X: 1, Y: -2}
+//	[]fuzz_test.Point{{X: 1, Y: 2}, {X: 3, Y: 4}}
+//	map[string]int{"a": 1, "b": 2}
+//	[4]uint8("\x00\x01\x02\x03")
+//	fuzz_test.Level(3)
+//	(*fuzz_test.Message)("\b\x96\x01")
+//
+// Inside a literal, the types of elements, fields and map entries are
+// elided. Slices and arrays of bytes are written as string literals, values
+// of types implementing encoding.BinaryMarshaler as a string literal holding
+// their binary form, and nil slices, maps and pointers as nil. The type at
+// the start of the line is only there for the reader; values are decoded
+// according to the types of the fuzz target's arguments.
+
+var (
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// isBasicType reports whether t is one of the types listed in zeroVals,
+// which are encoded and mutated without reflection.
+func isBasicType(t reflect.Type) bool {
+	for _, v := range zeroVals {
+		if reflect.TypeOf(v) == t {
+			return true
+		}
+	}
+	return false
+}
+
+// isBinaryType reports whether values of type t are encoded and mutated in
+// their binary form, using encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler.
+func isBinaryType(t reflect.Type) bool {
+	if t.Kind() != reflect.Pointer {
+		t = reflect.PointerTo(t)
+	}
+	return t.Implements(binaryMarshalerType) && t.Implements(binaryUnmarshalerType)
+}
+
+// isByteSequence reports whether t is a slice or array of bytes, which is
+// encoded as a string literal and mutated like a []byte.
+func isByteSequence(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return t.Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+// byteSequence returns the contents of v, a slice or array of bytes.
+func byteSequence(v reflect.Value) []byte {
+	if v.Kind() == reflect.Slice {
+		return v.Bytes()
+	}
+	b := make([]byte, v.Len())
+	for i := range b {
+		b[i] = byte(v.Index(i).Uint())
+	}
+	return b
+}
+
+func marshalBinary(v reflect.Value) ([]byte, error) {
+	if v.Kind() != reflect.Pointer {
+		// MarshalBinary may have a pointer receiver.
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		v = p
+	}
+	return v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+}
+
+// unmarshalBinary sets v to the value decoded from data. v is left unchanged
+// if decoding fails.
+func unmarshalBinary(v reflect.Value, data []byte) error {
+	t := v.Type()
+	var p reflect.Value
+	if t.Kind() == reflect.Pointer {
+		p = reflect.New(t.Elem())
+	} else {
+		p = reflect.New(t)
+	}
+	if err := p.Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(data); err != nil {
+		return err
+	}
+	if t.Kind() == reflect.Pointer {
+		v.Set(p)
+	} else {
+		v.Set(p.Elem())
+	}
+	return nil
+}
+
+// marshalValue writes the line encoding v, whose type is not a basic type,
+// to b.
+func marshalValue(b *bytes.Buffer, v reflect.Value) {
+	t := v.Type()
+	if t.Kind() == reflect.Pointer {
+		fmt.Fprintf(b, "(%v)", t)
+	} else {
+		b.WriteString(t.String())
+	}
+	if isCompositeLit(v) {
+		appendValue(b, v)
+		return
+	}
+	b.WriteByte('(')
+	appendValue(b, v)
+	b.WriteByte(')')
+}
+
+// isCompositeLit reports whether v is encoded as a composite literal.
+func isCompositeLit(v reflect.Value) bool {
+	t := v.Type()
+	if isBinaryType(t) || isByteSequence(t) {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Array:
+		return true
+	case reflect.Slice, reflect.Map:
+		return !v.IsNil()
+	}
+	return false
+}
+
+// appendValue writes the literal for v, with types elided, to b.
+func appendValue(b *bytes.Buffer, v reflect.Value) {
+	t := v.Type()
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map:
+		if v.IsNil() {
+			b.WriteString("nil")
+			return
+		}
+	}
+	if isBinaryType(t) {
+		data, err := marshalBinary(v)
+		if err != nil {
+			panic(fmt.Sprintf("cannot marshal value of type %v: %v", t, err))
+		}
+		b.WriteString(strconv.Quote(string(data)))
+		return
+	}
+	if isByteSequence(t) {
+		b.WriteString(strconv.Quote(string(byteSequence(v))))
+		return
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		b.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		b.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		b.WriteString(strconv.FormatFloat(v.Float(), 'g', -1, t.Bits()))
+	case reflect.String:
+		b.WriteString(strconv.Quote(v.String()))
+	case reflect.Slice, reflect.Array:
+		b.WriteByte('{')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			appendValue(b, v.Index(i))
+		}
+		b.WriteByte('}')
+	case reflect.Map:
+		b.WriteByte('{')
+		keys, vals := sortedMapEntries(v)
+		for i := range keys {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			appendValue(b, keys[i])
+			b.WriteString(": ")
+			appendValue(b, vals[i])
+		}
+		b.WriteByte('}')
+	case reflect.Struct:
+		b.WriteByte('{')
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				panic(fmt.Sprintf("unsupported type: %v has unexported field %s", t, f.Name))
+			}
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(f.Name)
+			b.WriteString(": ")
+			appendValue(b, v.Field(i))
+		}
+		b.WriteByte('}')
+	default:
+		panic(fmt.Sprintf("unsupported type: %v", t))
+	}
+}
+
+// sortedMapEntries returns the keys and values of the map v, ordered by the
+// encoding of the keys so that encoding and mutating maps is deterministic.
+func sortedMapEntries(v reflect.Value) (keys, vals []reflect.Value) {
+	type entry struct {
+		k, v reflect.Value
+		enc  string
+	}
+	entries := make([]entry, 0, v.Len())
+	var b bytes.Buffer
+	iter := v.MapRange()
+	for iter.Next() {
+		b.Reset()
+		appendValue(&b, iter.Key())
+		entries = append(entries, entry{iter.Key(), iter.Value(), b.String()})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].enc < entries[j].enc })
+	keys = make([]reflect.Value, len(entries))
+	vals = make([]reflect.Value, len(entries))
+	for i, e := range entries {
+		keys[i], vals[i] = e.k, e.v
+	}
+	return keys, vals
+}
+
+// parseTypedValue decodes a line written by marshalValue into a value of
+// type t.
+func parseTypedValue(line []byte, t reflect.Type) (any, error) {
+	fs := token.NewFileSet()
+	expr, err := parser.ParseExprFrom(fs, "(test)", line, 0)
+	if err != nil {
+		return nil, err
+	}
+	switch e := expr.(type) {
+	case *ast.CompositeLit:
+		// The literal's type is ignored by decodeValue.
+	case *ast.CallExpr:
+		if len(e.Args) != 1 {
+			return nil, fmt.Errorf("expected call expression with 1 argument; got %d", len(e.Args))
+		}
+		expr = e.Args[0]
+	default:
+		return nil, fmt.Errorf("expected call expression or composite literal")
+	}
+	v := reflect.New(t).Elem()
+	if err := decodeValue(v, expr); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// decodeValue sets v to the value of the literal e, as written by
+// appendValue.
+func decodeValue(v reflect.Value, e ast.Expr) error {
+	t := v.Type()
+	if id, ok := e.(*ast.Ident); ok && id.Name == "nil" {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map:
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+		return fmt.Errorf("nil is not a valid value of type %v", t)
+	}
+	if isBinaryType(t) {
+		s, err := parseStringLit(e, t)
+		if err != nil {
+			return err
+		}
+		return unmarshalBinary(v, []byte(s))
+	}
+	if isByteSequence(t) {
+		s, err := parseStringLit(e, t)
+		if err != nil {
+			return err
+		}
+		if t.Kind() == reflect.Slice {
+			v.SetBytes([]byte(s))
+			return nil
+		}
+		if len(s) != t.Len() {
+			return fmt.Errorf("string literal of length %d for type %v", len(s), t)
+		}
+		for i := 0; i < len(s); i++ {
+			v.Index(i).SetUint(uint64(s[i]))
+		}
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		id, ok := e.(*ast.Ident)
+		if !ok || (id.Name != "true" && id.Name != "false") {
+			return fmt.Errorf("true or false required for type %v", t)
+		}
+		v.SetBool(id.Name == "true")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		val, kind := numberLit(e)
+		var n int64
+		var err error
+		switch kind {
+		case token.INT:
+			n, err = strconv.ParseInt(val, 0, t.Bits())
+		case token.CHAR:
+			var r rune
+			r, err = parseCharLit(val)
+			n = int64(r)
+			if v.OverflowInt(n) {
+				err = fmt.Errorf("character literal %s overflows type %v", val, t)
+			}
+		default:
+			err = fmt.Errorf("integer literal required for type %v", t)
+		}
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		val, kind := numberLit(e)
+		var n uint64
+		var err error
+		switch kind {
+		case token.INT:
+			n, err = strconv.ParseUint(val, 0, t.Bits())
+		case token.CHAR:
+			var r rune
+			r, err = parseCharLit(val)
+			n = uint64(r)
+			if r < 0 || v.OverflowUint(n) {
+				err = fmt.Errorf("character literal %s overflows type %v", val, t)
+			}
+		default:
+			err = fmt.Errorf("integer literal required for type %v", t)
+		}
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		val, kind := numberLit(e)
+		if kind != token.INT && kind != token.FLOAT && kind != token.IDENT {
+			return fmt.Errorf("float or integer literal required for type %v", t)
+		}
+		f, err := strconv.ParseFloat(val, t.Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		s, err := parseStringLit(e, t)
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case reflect.Slice, reflect.Array:
+		lit, ok := e.(*ast.CompositeLit)
+		if !ok {
+			return fmt.Errorf("composite literal required for type %v", t)
+		}
+		if t.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(t, len(lit.Elts), len(lit.Elts)))
+		} else if len(lit.Elts) > t.Len() {
+			return fmt.Errorf("%d elements in literal of type %v", len(lit.Elts), t)
+		}
+		for i, elt := range lit.Elts {
+			if _, ok := elt.(*ast.KeyValueExpr); ok {
+				return fmt.Errorf("unexpected key in literal of type %v", t)
+			}
+			if err := decodeValue(v.Index(i), elt); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		lit, ok := e.(*ast.CompositeLit)
+		if !ok {
+			return fmt.Errorf("composite literal required for type %v", t)
+		}
+		v.Set(reflect.MakeMapWithSize(t, len(lit.Elts)))
+		for _, elt := range lit.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				return fmt.Errorf("missing key in literal of type %v", t)
+			}
+			key := reflect.New(t.Key()).Elem()
+			if err := decodeValue(key, kv.Key); err != nil {
+				return err
+			}
+			if v.MapIndex(key).IsValid() {
+				return fmt.Errorf("duplicate key in literal of type %v", t)
+			}
+			elem := reflect.New(t.Elem()).Elem()
+			if err := decodeValue(elem, kv.Value); err != nil {
+				return err
+			}
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.Struct:
+		lit, ok := e.(*ast.CompositeLit)
+		if !ok {
+			return fmt.Errorf("composite literal required for type %v", t)
+		}
+		seen := make(map[int]bool)
+		for _, elt := range lit.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				return fmt.Errorf("missing field name in literal of type %v", t)
+			}
+			id, ok := kv.Key.(*ast.Ident)
+			if !ok {
+				return fmt.Errorf("invalid field name in literal of type %v", t)
+			}
+			f, ok := t.FieldByName(id.Name)
+			if !ok || len(f.Index) != 1 || !f.IsExported() {
+				return fmt.Errorf("unknown field %s in literal of type %v", id.Name, t)
+			}
+			if seen[f.Index[0]] {
+				return fmt.Errorf("duplicate field %s in literal of type %v", id.Name, t)
+			}
+			seen[f.Index[0]] = true
+			if err := decodeValue(v.Field(f.Index[0]), kv.Value); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %v", t)
+	}
+	return nil
+}
+
+// numberLit returns the text and kind of e, an integer, floating-point or
+// character literal which may be preceded by a sign. The identifiers NaN and
+// Inf, written by strconv.FormatFloat, are returned with kind token.IDENT.
+func numberLit(e ast.Expr) (val string, kind token.Token) {
+	sign := ""
+	if op, ok := e.(*ast.UnaryExpr); ok {
+		if op.Op != token.SUB && op.Op != token.ADD {
+			return "", token.ILLEGAL
+		}
+		sign, e = op.Op.String(), op.X
+	}
+	switch e := e.(type) {
+	case *ast.BasicLit:
+		if e.Kind == token.CHAR && sign != "" {
+			return "", token.ILLEGAL
+		}
+		return sign + e.Value, e.Kind
+	case *ast.Ident:
+		if e.Name == "Inf" || (e.Name == "NaN" && sign == "") {
+			return sign + e.Name, token.IDENT
+		}
+	}
+	return "", token.ILLEGAL
+}
+
+func parseCharLit(val string) (rune, error) {
+	n := len(val)
+	if n < 2 {
+		return 0, fmt.Errorf("malformed character literal, missing single quotes")
+	}
+	r, _, _, err := strconv.UnquoteChar(val[1:n-1], '\'')
+	return r, err
+}
+
+func parseStringLit(e ast.Expr, t reflect.Type) (string, error) {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", fmt.Errorf("string literal required for type %v", t)
+	}
+	return strconv.Unquote(lit.Value)
+}
@@ -5,6 +5,9 @@
 package fuzz
 
 import (
+	"encoding/binary"
+	"errors"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
@@ -106,7 +109,7 @@ float32(2.5)`,
 	}
 	for _, test := range tests {
 		t.Run(test.in, func(t *testing.T) {
-			vals, err := unmarshalCorpusFile([]byte(test.in))
+			vals, err := unmarshalCorpusFile([]byte(test.in), nil)
 			if test.ok && err != nil {
 				t.Fatalf("unmarshal unexpected error: %v", err)
 			} else if !test.ok && err == nil {
@@ -130,6 +133,216 @@ float32(2.5)`,
 	}
 }
 
+type testLevel uint8
+
+type testPoint struct {
+	X, Y int
+}
+
+type testRecord struct {
+	Name  string
+	Tags  []string
+	Attrs map[string]int
+	Data  []byte
+	Sum   [4]byte
+	Level testLevel
+	Ratio float64
+	Ok    bool
+	Pts   []testPoint
+}
+
+// testMessage implements encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler, and has no exported fields.
+type testMessage struct {
+	n uint16
+}
+
+func (m testMessage) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, m.n)
+	return b, nil
+}
+
+func (m *testMessage) UnmarshalBinary(data []byte) error {
+	if len(data) != 2 {
+		return errors.New("testMessage must be 2 bytes long")
+	}
+	m.n = binary.BigEndian.Uint16(data)
+	return nil
+}
+
+func TestUnmarshalMarshalTyped(t *testing.T) {
+	var tests = []struct {
+		in    string
+		types []reflect.Type
+		ok    bool
+	}{
+		{
+			in:    "fuzz.testLevel(3)",
+			types: []reflect.Type{reflect.TypeOf(testLevel(0))},
+			ok:    true,
+		},
+		{
+			in:    "fuzz.testLevel(256)",
+			types: []reflect.Type{reflect.TypeOf(testLevel(0))},
+			ok:    false, // out of range
+		},
+		{
+			in:    `fuzz.testPoint{X: 1, Y: -2}`,
+			types: []reflect.Type{reflect.TypeOf(testPoint{})},
+			ok:    true,
+		},
+		{
+			in:    `fuzz.testPoint{X: 1, Z: -2}`,
+			types: []reflect.Type{reflect.TypeOf(testPoint{})},
+			ok:    false, // unknown field
+		},
+		{
+			in:    `fuzz.testPoint{X: 1, X: 2}`,
+			types: []reflect.Type{reflect.TypeOf(testPoint{})},
+			ok:    false, // duplicate field
+		},
+		{
+			in:    `fuzz.testPoint{1, 2}`,
+			types: []reflect.Type{reflect.TypeOf(testPoint{})},
+			ok:    false, // missing field names
+		},
+		{
+			in:    `fuzz.testPoint{X: "1", Y: 2}`,
+			types: []reflect.Type{reflect.TypeOf(testPoint{})},
+			ok:    false, // wrong type for field
+		},
+		{
+			in:    `int(1)`,
+			types: []reflect.Type{reflect.TypeOf(testPoint{})},
+			ok:    false, // not a composite literal
+		},
+		{
+			in:    `[]fuzz.testPoint{{X: 1, Y: 2}, {X: 3, Y: 4}}`,
+			types: []reflect.Type{reflect.TypeOf([]testPoint(nil))},
+			ok:    true,
+		},
+		{
+			in:    `[]fuzz.testPoint(nil)`,
+			types: []reflect.Type{reflect.TypeOf([]testPoint(nil))},
+			ok:    true,
+		},
+		{
+			in:    `[]fuzz.testPoint{}`,
+			types: []reflect.Type{reflect.TypeOf([]testPoint(nil))},
+			ok:    true,
+		},
+		{
+			in:    `map[string]int{"a": 1, "b": -2}`,
+			types: []reflect.Type{reflect.TypeOf(map[string]int(nil))},
+			ok:    true,
+		},
+		{
+			in:    `map[string]int{"a": 1, "a": 2}`,
+			types: []reflect.Type{reflect.TypeOf(map[string]int(nil))},
+			ok:    false, // duplicate key
+		},
+		{
+			in:    `map[string]int{"a"}`,
+			types: []reflect.Type{reflect.TypeOf(map[string]int(nil))},
+			ok:    false, // missing value
+		},
+		{
+			in:    `[4]uint8("\x00\x01\x02\x03")`,
+			types: []reflect.Type{reflect.TypeOf([4]byte{})},
+			ok:    true,
+		},
+		{
+			in:    `[4]uint8("\x00\x01\x02")`,
+			types: []reflect.Type{reflect.TypeOf([4]byte{})},
+			ok:    false, // too short
+		},
+		{
+			in:    `[2]int{1, 2}`,
+			types: []reflect.Type{reflect.TypeOf([2]int{})},
+			ok:    true,
+		},
+		{
+			in:    `[2]int{1, 2, 3}`,
+			types: []reflect.Type{reflect.TypeOf([2]int{})},
+			ok:    false, // too many elements
+		},
+		{
+			in:    `[]float64{1.5, -0.25, 1e+100, +Inf, -Inf, NaN}`,
+			types: []reflect.Type{reflect.TypeOf([]float64(nil))},
+			ok:    true,
+		},
+		{
+			in:    `fuzz.testMessage("\x01\x02")`,
+			types: []reflect.Type{reflect.TypeOf(testMessage{})},
+			ok:    true,
+		},
+		{
+			in:    `fuzz.testMessage("\x01")`,
+			types: []reflect.Type{reflect.TypeOf(testMessage{})},
+			ok:    false, // rejected by UnmarshalBinary
+		},
+		{
+			in:    `(*fuzz.testMessage)("\x01\x02")`,
+			types: []reflect.Type{reflect.TypeOf(&testMessage{})},
+			ok:    true,
+		},
+		{
+			in:    `(*fuzz.testMessage)(nil)`,
+			types: []reflect.Type{reflect.TypeOf(&testMessage{})},
+			ok:    true,
+		},
+		{
+			in: `int(5)
+fuzz.testRecord{Name: "rec", Tags: {"a", "b"}, Attrs: {"x": 1}, Data: "\x00\xff", Sum: "abcd", Level: 2, Ratio: 0.5, Ok: true, Pts: {{X: 1, Y: 2}}}
+fuzz.testRecord{Name: "", Tags: nil, Attrs: nil, Data: nil, Sum: "\x00\x00\x00\x00", Level: 0, Ratio: 0, Ok: false, Pts: nil}
+[]byte("x")`,
+			types: []reflect.Type{
+				reflect.TypeOf(0),
+				reflect.TypeOf(testRecord{}),
+				reflect.TypeOf(testRecord{}),
+				reflect.TypeOf([]byte(nil)),
+			},
+			ok: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			in := "go test fuzz v1\n" + test.in
+			vals, err := unmarshalCorpusFile([]byte(in), test.types)
+			if err == nil {
+				err = CheckCorpus(vals, test.types)
+			}
+			if test.ok && err != nil {
+				t.Fatalf("unmarshal unexpected error: %v", err)
+			} else if !test.ok && err == nil {
+				t.Fatalf("unmarshal unexpected success")
+			}
+			if !test.ok {
+				return // skip the rest of the test
+			}
+			newB := marshalCorpusFile(vals...)
+			before, after := strings.TrimSpace(in), strings.TrimSpace(string(newB))
+			if before != after {
+				t.Errorf("values changed after unmarshal then marshal\nbefore: %q\nafter:  %q", before, after)
+			}
+		})
+	}
+}
+
+func TestMarshalMapOrder(t *testing.T) {
+	m := make(map[int]string)
+	for i := 0; i < 100; i++ {
+		m[i] = strconv.Itoa(i)
+	}
+	want := marshalCorpusFile(m)
+	for i := 0; i < 10; i++ {
+		if got := marshalCorpusFile(m); string(got) != string(want) {
+			t.Fatalf("encoding of map changed:\n%s\n%s", got, want)
+		}
+	}
+}
+
 // BenchmarkMarshalCorpusFile measures the time it takes to serialize byte
 // slices of various sizes to a corpus file. The slice contains a repeating
 // sequence of bytes 0-255 to mix escaped and non-escaped characters.
@@ -165,7 +378,7 @@ func BenchmarkUnmarshalCorpusFile(b *testing.B) {
 		b.Run(strconv.Itoa(sz), func(b *testing.B) {
 			for i := 0; i < b.N; i++ {
 				b.SetBytes(int64(sz))
-				unmarshalCorpusFile(data)
+				unmarshalCorpusFile(data, nil)
 			}
 		})
 	}
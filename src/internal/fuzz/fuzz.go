@@ -66,7 +66,9 @@ type CoordinateFuzzingOpts struct {
 	Types []reflect.Type
 
 	// CorpusDir is a directory where files containing values that crash the
-	// code being tested may be written. CorpusDir must be set.
+	// code being tested may be written. CorpusDir must be set. If a file
+	// named CorpusDir+".dict" exists, it is read as a dictionary of tokens
+	// for the mutator to insert into inputs.
 	CorpusDir string
 
 	// CacheDir is a directory containing additional "interesting" values.
@@ -624,6 +626,10 @@ type coordinator struct {
 	// crashMinimizing is the crash that is currently being minimized.
 	crashMinimizing *fuzzResult
 
+	// dictionary holds the tokens read from the dictionary file next to the
+	// seed corpus directory, for the mutator to insert into inputs.
+	dictionary [][]byte
+
 	// coverageMask aggregates coverage that was found for all inputs in the
 	// corpus. Each byte represents a single basic execution block. Each set bit
 	// within the byte indicates that an input has triggered that block at least
@@ -644,6 +650,10 @@ func newCoordinator(opts CoordinateFuzzingOpts) (*coordinator, error) {
 	if err != nil {
 		return nil, err
 	}
+	dictionary, err := readDictionary(opts.CorpusDir + ".dict")
+	if err != nil {
+		return nil, err
+	}
 	c := &coordinator{
 		opts:        opts,
 		startTime:   time.Now(),
@@ -651,6 +661,7 @@ func newCoordinator(opts CoordinateFuzzingOpts) (*coordinator, error) {
 		minimizeC:   make(chan fuzzMinimizeInput),
 		resultC:     make(chan fuzzResult),
 		corpus:      corpus,
+		dictionary:  dictionary,
 		timeLastLog: time.Now(),
 	}
 	if opts.MinimizeLimit > 0 || opts.MinimizeTimeout > 0 {
@@ -983,7 +994,7 @@ func ReadCorpus(dir string, types []reflect.Type) ([]CorpusEntry, error) {
 }
 
 func readCorpusData(data []byte, types []reflect.Type) ([]any, error) {
-	vals, err := unmarshalCorpusFile(data)
+	vals, err := unmarshalCorpusFile(data, types)
 	if err != nil {
 		return nil, fmt.Errorf("unmarshal: %v", err)
 	}
@@ -1038,7 +1049,7 @@ func zeroValue(t reflect.Type) any {
 			return v
 		}
 	}
-	panic(fmt.Sprintf("unsupported type: %v", t))
+	return reflect.Zero(t).Interface()
 }
 
 var zeroVals []any = []any{
@@ -13,8 +13,14 @@ import (
 )
 
 type mutator struct {
-	r       mutatorRand
-	scratch []byte // scratch slice to avoid additional allocations
+	r            mutatorRand
+	scratch      []byte // scratch slice to avoid additional allocations
+	valueScratch []byte // like scratch, for values mutated with reflection
+
+	// cmps and dict are the comparisons recorded by the code under test
+	// and the tokens in the dictionary, which are spliced into inputs.
+	cmps cmpLog
+	dict [][]byte
 }
 
 func newMutator() *mutator {
@@ -120,11 +126,19 @@ func (m *mutator) mutate(vals []any, maxBytes int) {
 		m.mutateBytes(&m.scratch)
 		vals[i] = m.scratch
 	default:
-		panic(fmt.Sprintf("type not supported for mutating: %T", vals[i]))
+		vals[i] = m.mutateAny(v, maxPerVal)
 	}
 }
 
 func (m *mutator) mutateInt(v, maxValue int64) int64 {
+	if len(m.cmps.Ints) > 0 && m.rand(4) == 0 {
+		// Replace v with an operand of a comparison, sign-extended.
+		u, size := m.cmpOperand(uint64(v))
+		shift := 64 - 8*size
+		if x := int64(u<<shift) >> shift; x != v && x <= maxValue && x >= -maxValue {
+			return x
+		}
+	}
 	var max int64
 	for {
 		max = 100
@@ -156,6 +170,12 @@ func (m *mutator) mutateInt(v, maxValue int64) int64 {
 }
 
 func (m *mutator) mutateUInt(v, maxValue uint64) uint64 {
+	if len(m.cmps.Ints) > 0 && m.rand(4) == 0 {
+		// Replace v with an operand of a comparison.
+		if x, _ := m.cmpOperand(v); x != v && x <= maxValue {
+			return x
+		}
+	}
 	var max uint64
 	for {
 		max = 100
@@ -187,6 +207,44 @@ func (m *mutator) mutateUInt(v, maxValue uint64) uint64 {
 	}
 }
 
+// cmpOperand returns an operand of a random recorded integer comparison,
+// along with the size of the operands in bytes. If v, truncated to that size,
+// is one of the operands, the other one is returned.
+func (m *mutator) cmpOperand(v uint64) (uint64, int) {
+	c := m.cmps.Ints[m.rand(len(m.cmps.Ints))]
+	if c.Size < 8 {
+		v &= 1<<(8*c.Size) - 1
+	}
+	switch {
+	case c.X == v:
+		return c.Y, c.Size
+	case c.Y == v:
+		return c.X, c.Size
+	case m.r.bool():
+		return c.Y, c.Size
+	}
+	return c.X, c.Size
+}
+
+// token returns a random token from the dictionary or from the operands of
+// the recorded string comparisons, or nil if there are none.
+func (m *mutator) token() []byte {
+	n := len(m.dict) + 2*len(m.cmps.Strings)
+	if n == 0 {
+		return nil
+	}
+	i := m.rand(n)
+	if i < len(m.dict) {
+		return m.dict[i]
+	}
+	i -= len(m.dict)
+	c := m.cmps.Strings[i/2]
+	if i%2 == 0 {
+		return c.X
+	}
+	return c.Y
+}
+
 func (m *mutator) mutateFloat(v, maxValue float64) float64 {
 	var max float64
 	for {
@@ -260,6 +318,10 @@ var byteSliceMutators = []byteSliceMutator{
 	byteSliceOverwriteConstantBytes,
 	byteSliceShuffleBytes,
 	byteSliceSwapBytes,
+	byteSliceReplaceCmpInt,
+	byteSliceReplaceCmpString,
+	byteSliceInsertToken,
+	byteSliceOverwriteToken,
 }
 
 func (m *mutator) mutateBytes(ptrB *[]byte) {
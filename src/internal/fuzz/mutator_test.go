@@ -6,9 +6,12 @@ package fuzz
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -115,3 +118,102 @@ func TestStringImmutability(t *testing.T) {
 		t.Fatalf("string was mutated: got %x, want %x", []byte(original), originalCopy)
 	}
 }
+
+func TestMutateComposite(t *testing.T) {
+	orig := testRecord{
+		Name:  "rec",
+		Tags:  []string{"a", "b"},
+		Attrs: map[string]int{"x": 1, "y": 2},
+		Data:  []byte{0, 1, 2},
+		Pts:   []testPoint{{X: 1, Y: 2}},
+	}
+	types := []reflect.Type{reflect.TypeOf(testRecord{}), reflect.TypeOf(testMessage{})}
+	origData := marshalCorpusFile(orig, testMessage{n: 7})
+
+	m1, m2 := newMutator(), newMutator()
+	var state, inc uint64
+	m1.r.save(&state, &inc)
+	m2.r.restore(state, inc)
+
+	v1 := []any{orig, testMessage{n: 7}}
+	v2 := []any{orig, testMessage{n: 7}}
+	changed := false
+	for i := 0; i < 1000; i++ {
+		m1.mutate(v1, 1024)
+		m2.mutate(v2, 1024)
+		data1, data2 := marshalCorpusFile(v1...), marshalCorpusFile(v2...)
+		if !bytes.Equal(data1, data2) {
+			t.Fatalf("mutations with the same random state differ:\n%s\n%s", data1, data2)
+		}
+		if !bytes.Equal(data1, origData) {
+			changed = true
+		}
+		vals, err := unmarshalCorpusFile(data1, types)
+		if err != nil {
+			t.Fatalf("unmarshaling mutated value: %v\n%s", err, data1)
+		}
+		if !reflect.DeepEqual(vals, v1) {
+			t.Fatalf("mutated value changed after marshal then unmarshal:\n%#v\n%#v", v1, vals)
+		}
+	}
+	if !changed {
+		t.Errorf("values were never changed by mutation")
+	}
+	if data := marshalCorpusFile(orig, testMessage{n: 7}); !bytes.Equal(data, origData) {
+		t.Errorf("original value was mutated:\n%s\n%s", data, origData)
+	}
+}
+
+func TestMutateCmpOperands(t *testing.T) {
+	cmps := cmpLog{
+		Ints:    []intCmp{{Size: 2, X: 0xfffe, Y: 1234}, {Size: 1, X: 7, Y: 200}},
+		Strings: []stringCmp{{X: []byte("\xffmagic"), Y: []byte("hello")}},
+	}
+	dict := [][]byte{[]byte("token")}
+
+	// The worker receives the comparisons in JSON, while the coordinator
+	// replays the mutations with the ones it sent. Both must mutate the
+	// same way.
+	data, err := json.Marshal(fuzzArgs{CmpLog: cmps, Dictionary: dict})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var args fuzzArgs
+	if err := json.Unmarshal(data, &args); err != nil {
+		t.Fatal(err)
+	}
+	m1, m2 := newMutator(), newMutator()
+	m1.cmps, m1.dict = cmps, dict
+	m2.cmps, m2.dict = args.CmpLog, args.Dictionary
+	var state, inc uint64
+	m1.r.save(&state, &inc)
+	m2.r.restore(state, inc)
+
+	seen := make(map[any]bool)
+	for i := 0; i < 1000; i++ {
+		v1 := []any{int16(1234), uint8(0), "hello"}
+		v2 := []any{int16(1234), uint8(0), "hello"}
+		m1.mutate(v1, 1024)
+		m2.mutate(v2, 1024)
+		if !reflect.DeepEqual(v1, v2) {
+			t.Fatalf("mutations with the same random state differ: %q, %q", v1, v2)
+		}
+		for _, v := range v1 {
+			seen[v] = true
+		}
+	}
+	for _, want := range []any{int16(-2), uint8(7), uint8(200), "\xffmagic"} {
+		if !seen[want] {
+			t.Errorf("comparison operand %#v never used", want)
+		}
+	}
+	found := false
+	for v := range seen {
+		if s, ok := v.(string); ok && strings.Contains(s, "token") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("dictionary token never used")
+	}
+}
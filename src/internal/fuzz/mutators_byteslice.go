@@ -4,6 +4,11 @@
 
 package fuzz
 
+import (
+	"bytes"
+	"encoding/binary"
+)
+
 // byteSliceRemoveBytes removes a random chunk of bytes from b.
 func byteSliceRemoveBytes(m *mutator, b []byte) []byte {
 	if len(b) <= 1 {
@@ -311,3 +316,98 @@ func byteSliceSwapBytes(m *mutator, b []byte) []byte {
 	b = b[:end]
 	return b
 }
+
+// byteSliceReplaceCmpInt replaces an operand of a recorded integer comparison
+// with the other operand, encoded in a random byte order. The first occurrence
+// of the operand in b is replaced if there is one, or else a random position.
+func byteSliceReplaceCmpInt(m *mutator, b []byte) []byte {
+	if len(m.cmps.Ints) == 0 {
+		return nil
+	}
+	c := m.cmps.Ints[m.rand(len(m.cmps.Ints))]
+	if len(b) < c.Size {
+		return nil
+	}
+	from, to := c.X, c.Y
+	if m.r.bool() {
+		from, to = to, from
+	}
+	order := m.randByteOrder()
+	var fromBuf, toBuf [8]byte
+	putUint(order, fromBuf[:c.Size], from)
+	putUint(order, toBuf[:c.Size], to)
+	pos := bytes.Index(b, fromBuf[:c.Size])
+	if pos < 0 {
+		pos = m.rand(len(b) - c.Size + 1)
+	}
+	copy(b[pos:], toBuf[:c.Size])
+	return b
+}
+
+// putUint encodes v into b, which is 1, 2, 4 or 8 bytes long.
+func putUint(order binary.ByteOrder, b []byte, v uint64) {
+	switch len(b) {
+	case 1:
+		b[0] = byte(v)
+	case 2:
+		order.PutUint16(b, uint16(v))
+	case 4:
+		order.PutUint32(b, uint32(v))
+	case 8:
+		order.PutUint64(b, v)
+	}
+}
+
+// byteSliceReplaceCmpString replaces the first occurrence in b of an operand
+// of a recorded string comparison with the other operand.
+func byteSliceReplaceCmpString(m *mutator, b []byte) []byte {
+	if len(m.cmps.Strings) == 0 {
+		return nil
+	}
+	c := m.cmps.Strings[m.rand(len(m.cmps.Strings))]
+	from, to := c.X, c.Y
+	if m.r.bool() {
+		from, to = to, from
+	}
+	pos := bytes.Index(b, from)
+	if len(from) == 0 || pos < 0 || len(b)-len(from)+len(to) > cap(b) {
+		return nil
+	}
+	end := pos + len(from)
+	n := len(b) - len(from) + len(to)
+	if len(to) > len(from) {
+		b = b[:n]
+		copy(b[pos+len(to):], b[end:])
+	} else {
+		copy(b[pos+len(to):], b[end:])
+		b = b[:n]
+	}
+	copy(b[pos:], to)
+	return b
+}
+
+// byteSliceInsertToken inserts a token from the dictionary, or an operand of a
+// recorded string comparison, into a random position in b.
+func byteSliceInsertToken(m *mutator, b []byte) []byte {
+	tok := m.token()
+	if len(tok) == 0 || len(b)+len(tok) >= cap(b) {
+		return nil
+	}
+	pos := m.rand(len(b) + 1)
+	b = b[:len(b)+len(tok)]
+	copy(b[pos+len(tok):], b[pos:])
+	copy(b[pos:], tok)
+	return b
+}
+
+// byteSliceOverwriteToken overwrites a chunk of b with a token from the
+// dictionary, or an operand of a recorded string comparison.
+func byteSliceOverwriteToken(m *mutator, b []byte) []byte {
+	tok := m.token()
+	if len(tok) == 0 || len(tok) > len(b) {
+		return nil
+	}
+	pos := m.rand(len(b) - len(tok) + 1)
+	copy(b[pos:], tok)
+	return b
+}
@@ -58,6 +58,8 @@ func TestByteSliceMutators(t *testing.T) {
 		name     string
 		mutator  func(*mutator, []byte) []byte
 		randVals []int
+		cmps     cmpLog
+		dict     [][]byte
 		input    []byte
 		expected []byte
 	}{
@@ -170,13 +172,41 @@ func TestByteSliceMutators(t *testing.T) {
 			input:    append(make([]byte, 0, 9), []byte{1, 2, 3, 4}...),
 			expected: []byte{3, 2, 1, 4},
 		},
+		{
+			name:     "byteSliceReplaceCmpInt",
+			mutator:  byteSliceReplaceCmpInt,
+			cmps:     cmpLog{Ints: []intCmp{{Size: 2, X: 0x0302, Y: 0xbeef}}},
+			input:    []byte{1, 2, 3, 4},
+			expected: []byte{1, 0xef, 0xbe, 4},
+		},
+		{
+			name:     "byteSliceReplaceCmpString",
+			mutator:  byteSliceReplaceCmpString,
+			cmps:     cmpLog{Strings: []stringCmp{{X: []byte("bc"), Y: []byte("xyz")}}},
+			input:    append(make([]byte, 0, 8), "abcd"...),
+			expected: []byte("axyzd"),
+		},
+		{
+			name:     "byteSliceInsertToken",
+			mutator:  byteSliceInsertToken,
+			dict:     [][]byte{[]byte("XY")},
+			input:    append(make([]byte, 0, 8), "ab"...),
+			expected: []byte("aXYb"),
+		},
+		{
+			name:     "byteSliceOverwriteToken",
+			mutator:  byteSliceOverwriteToken,
+			cmps:     cmpLog{Strings: []stringCmp{{X: []byte("XY"), Y: []byte("Z")}}},
+			input:    []byte("abcd"),
+			expected: []byte("aXYd"),
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			r := &mockRand{values: []int{0, 1, 2, 3, 4, 5}}
 			if tc.randVals != nil {
 				r.values = tc.randVals
 			}
-			m := &mutator{r: r}
+			m := &mutator{r: r, cmps: tc.cmps, dict: tc.dict}
 			b := tc.mutator(m, tc.input)
 			if !bytes.Equal(b, tc.expected) {
 				t.Errorf("got %x, want %x", b, tc.expected)
@@ -0,0 +1,209 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package fuzz
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// mutateAny returns a mutated copy of x, a value of a type other than the
+// basic types listed in zeroVals. x is returned unchanged if the mutated
+// value's encoding would be longer than maxBytes.
+func (m *mutator) mutateAny(x any, maxBytes int) any {
+	v := copyValue(reflect.ValueOf(x))
+	m.mutateValue(v, maxBytes)
+	var b bytes.Buffer
+	marshalValue(&b, v)
+	if b.Len() > maxBytes {
+		return x
+	}
+	return v.Interface()
+}
+
+// copyValue returns a settable deep copy of v, so that the copy can be
+// mutated in place without changing v. Values of types implementing
+// encoding.BinaryMarshaler are not copied, since they are only ever replaced.
+func copyValue(v reflect.Value) reflect.Value {
+	t := v.Type()
+	c := reflect.New(t).Elem()
+	if isBinaryType(t) {
+		c.Set(v)
+		return c
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			break
+		}
+		c.Set(reflect.MakeSlice(t, v.Len(), v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			break
+		}
+		c.Set(reflect.MakeMapWithSize(t, v.Len()))
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(copyValue(iter.Key()), copyValue(iter.Value()))
+		}
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			c.Field(i).Set(copyValue(v.Field(i)))
+		}
+	default:
+		c.Set(v)
+	}
+	return c
+}
+
+// mutateValue mutates the settable value v in place. Composite values have
+// one of their elements, fields or entries mutated, or, for slices and maps,
+// an element or entry added or removed.
+func (m *mutator) mutateValue(v reflect.Value, maxBytes int) {
+	t := v.Type()
+	if isBinaryType(t) {
+		m.mutateBinary(v, maxBytes)
+		return
+	}
+	if isByteSequence(t) {
+		b := m.mutateBytesCopy(byteSequence(v), maxBytes)
+		if t.Kind() == reflect.Slice {
+			v.SetBytes(b)
+			return
+		}
+		// Arrays keep their length, whatever the mutation did to it.
+		for i := 0; i < v.Len() && i < len(b); i++ {
+			v.Index(i).SetUint(uint64(b[i]))
+		}
+		return
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		v.SetBool(!v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(m.mutateInt(v.Int(), int64(uint64(1)<<(t.Bits()-1)-1)))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(m.mutateUInt(v.Uint(), math.MaxUint64>>(64-t.Bits())))
+	case reflect.Float32:
+		v.SetFloat(m.mutateFloat(v.Float(), math.MaxFloat32))
+	case reflect.Float64:
+		v.SetFloat(m.mutateFloat(v.Float(), math.MaxFloat64))
+	case reflect.String:
+		v.SetString(string(m.mutateBytesCopy([]byte(v.String()), maxBytes)))
+	case reflect.Slice:
+		m.mutateSlice(v, maxBytes)
+	case reflect.Array:
+		if v.Len() > 0 {
+			m.mutateValue(v.Index(m.rand(v.Len())), maxBytes)
+		}
+	case reflect.Map:
+		m.mutateMap(v, maxBytes)
+	case reflect.Struct:
+		if t.NumField() > 0 {
+			m.mutateValue(v.Field(m.rand(t.NumField())), maxBytes)
+		}
+	default:
+		panic(fmt.Sprintf("type not supported for mutating: %v", t))
+	}
+}
+
+func (m *mutator) mutateSlice(v reflect.Value, maxBytes int) {
+	t := v.Type()
+	n := v.Len()
+	switch x := m.rand(4); {
+	case n > 0 && x < 2:
+		m.mutateValue(v.Index(m.rand(n)), maxBytes)
+	case n > 0 && x == 2:
+		// Remove an element.
+		i := m.rand(n)
+		reflect.Copy(v.Slice(i, n), v.Slice(i+1, n))
+		v.Set(v.Slice(0, n-1))
+	default:
+		// Insert a mutated copy of an element, or a mutated zero value.
+		e := reflect.New(t.Elem()).Elem()
+		if n > 0 && m.rand(2) == 0 {
+			e = copyValue(v.Index(m.rand(n)))
+		}
+		m.mutateValue(e, maxBytes)
+		i := m.rand(n + 1)
+		s := reflect.MakeSlice(t, n+1, n+1)
+		reflect.Copy(s, v.Slice(0, i))
+		s.Index(i).Set(e)
+		reflect.Copy(s.Slice(i+1, n+1), v.Slice(i, n))
+		v.Set(s)
+	}
+}
+
+func (m *mutator) mutateMap(v reflect.Value, maxBytes int) {
+	t := v.Type()
+	n := v.Len()
+	keys, vals := sortedMapEntries(v)
+	switch x := m.rand(4); {
+	case n > 0 && x < 2:
+		i := m.rand(n)
+		e := copyValue(vals[i])
+		m.mutateValue(e, maxBytes)
+		v.SetMapIndex(keys[i], e)
+	case n > 0 && x == 2:
+		// Remove an entry.
+		v.SetMapIndex(keys[m.rand(n)], reflect.Value{})
+	default:
+		// Add an entry, with a key derived from an existing one or from the
+		// zero value. It may replace an existing entry.
+		k := reflect.New(t.Key()).Elem()
+		if n > 0 && m.rand(2) == 0 {
+			k = copyValue(keys[m.rand(n)])
+		}
+		m.mutateValue(k, maxBytes)
+		e := reflect.New(t.Elem()).Elem()
+		m.mutateValue(e, maxBytes)
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+		v.SetMapIndex(k, e)
+	}
+}
+
+// mutateBinary mutates v, of a type implementing encoding.BinaryMarshaler,
+// by mutating its binary form. v is left unchanged if none of a few mutated
+// forms can be unmarshaled.
+func (m *mutator) mutateBinary(v reflect.Value, maxBytes int) {
+	var data []byte
+	if v.Kind() != reflect.Pointer || !v.IsNil() {
+		var err error
+		data, err = marshalBinary(v)
+		if err != nil {
+			return
+		}
+	}
+	for i := 0; i < 10; i++ {
+		if unmarshalBinary(v, m.mutateBytesCopy(data, maxBytes)) == nil {
+			return
+		}
+	}
+}
+
+// mutateBytesCopy returns a mutated copy of b. b is not modified, and the
+// returned slice does not alias any of the mutator's buffers.
+func (m *mutator) mutateBytesCopy(b []byte, maxBytes int) []byte {
+	if len(b) > maxBytes {
+		panic(fmt.Sprintf("cannot mutate bytes of length %d", len(b)))
+	}
+	if cap(m.valueScratch) < maxBytes {
+		m.valueScratch = make([]byte, 0, maxBytes)
+	}
+	m.valueScratch = append(m.valueScratch[:0], b...)
+	m.mutateBytes(&m.valueScratch)
+	return append([]byte(nil), m.valueScratch...)
+}
@@ -18,12 +18,20 @@ import _ "unsafe" // for go:linkname
 //go:linkname libfuzzerTraceConstCmp4 runtime.libfuzzerTraceConstCmp4
 //go:linkname libfuzzerTraceConstCmp8 runtime.libfuzzerTraceConstCmp8
 
-func libfuzzerTraceCmp1(arg0, arg1 uint8)  {}
-func libfuzzerTraceCmp2(arg0, arg1 uint16) {}
-func libfuzzerTraceCmp4(arg0, arg1 uint32) {}
-func libfuzzerTraceCmp8(arg0, arg1 uint64) {}
-
-func libfuzzerTraceConstCmp1(arg0, arg1 uint8)  {}
-func libfuzzerTraceConstCmp2(arg0, arg1 uint16) {}
-func libfuzzerTraceConstCmp4(arg0, arg1 uint32) {}
-func libfuzzerTraceConstCmp8(arg0, arg1 uint64) {}
+//go:linkname libfuzzerHookStrCmp runtime.libfuzzerHookStrCmp
+
+// The compiler inserts calls to these functions when building with
+// -d=libfuzzer. They record the operands of comparisons for the mutator;
+// see cmplog.go.
+
+func libfuzzerTraceCmp1(arg0, arg1 uint8)  { recordIntCmp(1, uint64(arg0), uint64(arg1)) }
+func libfuzzerTraceCmp2(arg0, arg1 uint16) { recordIntCmp(2, uint64(arg0), uint64(arg1)) }
+func libfuzzerTraceCmp4(arg0, arg1 uint32) { recordIntCmp(4, uint64(arg0), uint64(arg1)) }
+func libfuzzerTraceCmp8(arg0, arg1 uint64) { recordIntCmp(8, arg0, arg1) }
+
+func libfuzzerTraceConstCmp1(arg0, arg1 uint8)  { recordIntCmp(1, uint64(arg0), uint64(arg1)) }
+func libfuzzerTraceConstCmp2(arg0, arg1 uint16) { recordIntCmp(2, uint64(arg0), uint64(arg1)) }
+func libfuzzerTraceConstCmp4(arg0, arg1 uint32) { recordIntCmp(4, uint64(arg0), uint64(arg1)) }
+func libfuzzerTraceConstCmp8(arg0, arg1 uint64) { recordIntCmp(8, arg0, arg1) }
+
+func libfuzzerHookStrCmp(arg0, arg1 string) { recordStringCmp(arg0, arg1) }
@@ -59,6 +59,8 @@ type worker struct {
 	waitErr     error         // last error returned by wait, set before termC is closed.
 	interrupted bool          // true after stop interrupts a running worker.
 	termC       chan struct{} // closed by wait when worker process terminates
+
+	cmpLog cmpLog // comparisons last reported by the worker process
 }
 
 func newWorker(c *coordinator, dir, binPath string, args, env []string) (*worker, error) {
@@ -153,8 +155,13 @@ func (w *worker) coordinate(ctx context.Context) error {
 				Timeout:      input.timeout,
 				Warmup:       input.warmup,
 				CoverageData: input.coverageData,
+				CmpLog:       w.cmpLog,
+				Dictionary:   w.coordinator.dictionary,
 			}
 			entry, resp, isInternalError, err := w.client.fuzz(ctx, input.entry, args)
+			if err == nil {
+				w.cmpLog = resp.CmpLog
+			}
 			canMinimize := true
 			if err != nil {
 				// Error communicating with worker.
@@ -372,7 +379,7 @@ func (w *worker) start() (err error) {
 	w.termC = make(chan struct{})
 	comm := workerComm{fuzzIn: fuzzInW, fuzzOut: fuzzOutR, memMu: w.memMu}
 	m := newMutator()
-	w.client = newWorkerClient(comm, m)
+	w.client = newWorkerClient(comm, m, w.coordinator.opts.Types)
 
 	go func() {
 		w.waitErr = w.cmd.Wait()
@@ -470,15 +477,19 @@ func (w *worker) stop() error {
 // a given input "crashed". The coordinator will also record a crasher if
 // the function times out or terminates the process.
 //
+// types is the list of types of the fuzz function's arguments, which is needed
+// to decode inputs of types other than the basic ones.
+//
 // RunFuzzWorker returns an error if it could not communicate with the
 // coordinator process.
-func RunFuzzWorker(ctx context.Context, fn func(CorpusEntry) error) error {
+func RunFuzzWorker(ctx context.Context, fn func(CorpusEntry) error, types []reflect.Type) error {
 	comm, err := getWorkerComm()
 	if err != nil {
 		return err
 	}
 	srv := &workerServer{
 		workerComm: comm,
+		types:      types,
 		fuzzFn: func(e CorpusEntry) (time.Duration, error) {
 			timer := time.AfterFunc(10*time.Second, func() {
 				panic("deadlocked!") // this error message won't be printed
@@ -565,6 +576,12 @@ type fuzzArgs struct {
 	// CoverageData is the coverage data. If set, the worker should update its
 	// local coverage data prior to fuzzing.
 	CoverageData []byte
+
+	// CmpLog holds the comparisons last reported by the worker, and
+	// Dictionary the tokens from the dictionary file. The mutator splices
+	// both into inputs.
+	CmpLog     cmpLog
+	Dictionary [][]byte
 }
 
 // fuzzResponse contains results from workerServer.fuzz.
@@ -580,6 +597,10 @@ type fuzzResponse struct {
 	// and therefore may be interesting to the coordinator.
 	CoverageData []byte
 
+	// CmpLog holds the comparisons recorded by the worker so far, to be
+	// passed back with the next call.
+	CmpLog cmpLog
+
 	// Err is the error string caused by the value in shared memory, which is
 	// non-empty if the value in shared memory caused a crash.
 	Err string
@@ -618,6 +639,9 @@ type workerServer struct {
 	workerComm
 	m *mutator
 
+	// types is the list of types of the fuzz function's arguments.
+	types []reflect.Type
+
 	// coverageMask is the local coverage data for the worker. It is
 	// periodically updated to reflect the data in the coordinator when new
 	// coverage is found.
@@ -702,6 +726,7 @@ func (ws *workerServer) fuzz(ctx context.Context, args fuzzArgs) (resp fuzzRespo
 		}
 		ws.coverageMask = args.CoverageData
 	}
+	ws.m.cmps, ws.m.dict = args.CmpLog, args.Dictionary
 	start := time.Now()
 	defer func() { resp.TotalDuration = time.Since(start) }()
 
@@ -714,6 +739,7 @@ func (ws *workerServer) fuzz(ctx context.Context, args fuzzArgs) (resp fuzzRespo
 	ws.m.r.save(&mem.header().randState, &mem.header().randInc)
 	defer func() {
 		resp.Count = mem.header().count
+		resp.CmpLog = snapshotCmpLog()
 		ws.memMu <- mem
 	}()
 	if args.Limit > 0 && mem.header().count >= args.Limit {
@@ -721,7 +747,7 @@ func (ws *workerServer) fuzz(ctx context.Context, args fuzzArgs) (resp fuzzRespo
 		return resp
 	}
 
-	originalVals, err := unmarshalCorpusFile(mem.valueCopy())
+	originalVals, err := unmarshalCorpusFile(mem.valueCopy(), ws.types)
 	if err != nil {
 		resp.InternalErr = err.Error()
 		return resp
@@ -796,7 +822,7 @@ func (ws *workerServer) minimize(ctx context.Context, args minimizeArgs) (resp m
 	defer func() { resp.Duration = time.Now().Sub(start) }()
 	mem := <-ws.memMu
 	defer func() { ws.memMu <- mem }()
-	vals, err := unmarshalCorpusFile(mem.valueCopy())
+	vals, err := unmarshalCorpusFile(mem.valueCopy(), ws.types)
 	if err != nil {
 		panic(err)
 	}
@@ -918,6 +944,10 @@ type workerClient struct {
 	workerComm
 	m *mutator
 
+	// types is the list of types of the fuzz function's arguments, used to
+	// decode the inputs the worker reports.
+	types []reflect.Type
+
 	// mu is the mutex protecting the workerComm.fuzzIn pipe. This must be
 	// locked before making calls to the workerServer. It prevents
 	// workerClient.Close from closing fuzzIn while workerClient methods are
@@ -926,8 +956,8 @@ type workerClient struct {
 	mu sync.Mutex
 }
 
-func newWorkerClient(comm workerComm, m *mutator) *workerClient {
-	return &workerClient{workerComm: comm, m: m}
+func newWorkerClient(comm workerComm, m *mutator, types []reflect.Type) *workerClient {
+	return &workerClient{workerComm: comm, m: m, types: types}
 }
 
 // Close shuts down the connection to the RPC server (the worker process) by
@@ -980,7 +1010,7 @@ func (wc *workerClient) minimize(ctx context.Context, entryIn CorpusEntry, args
 	mem.setValue(inp)
 	defer func() { wc.memMu <- mem }()
 	entryOut = entryIn
-	entryOut.Values, err = unmarshalCorpusFile(inp)
+	entryOut.Values, err = unmarshalCorpusFile(inp, wc.types)
 	if err != nil {
 		return CorpusEntry{}, minimizeResponse{}, fmt.Errorf("workerClient.minimize unmarshaling provided value: %v", err)
 	}
@@ -1023,7 +1053,7 @@ func (wc *workerClient) minimize(ctx context.Context, entryIn CorpusEntry, args
 		if resp.WroteToMem {
 			// Minimization succeeded, and mem holds the marshaled data.
 			entryOut.Data = mem.valueCopy()
-			entryOut.Values, err = unmarshalCorpusFile(entryOut.Data)
+			entryOut.Values, err = unmarshalCorpusFile(entryOut.Data, wc.types)
 			if err != nil {
 				return CorpusEntry{}, minimizeResponse{}, fmt.Errorf("workerClient.minimize unmarshaling minimized value: %v", err)
 			}
@@ -1084,11 +1114,12 @@ func (wc *workerClient) fuzz(ctx context.Context, entryIn CorpusEntry, args fuzz
 	needEntryOut := callErr != nil || resp.Err != "" ||
 		(!args.Warmup && resp.CoverageData != nil)
 	if needEntryOut {
-		valuesOut, err := unmarshalCorpusFile(inp)
+		valuesOut, err := unmarshalCorpusFile(inp, wc.types)
 		if err != nil {
 			return CorpusEntry{}, fuzzResponse{}, true, fmt.Errorf("unmarshaling fuzz input value after call: %v", err)
 		}
 		wc.m.r.restore(mem.header().randState, mem.header().randInc)
+		wc.m.cmps, wc.m.dict = args.CmpLog, args.Dictionary
 		if !args.Warmup {
 			// Only mutate the valuesOut if fuzzing actually occurred.
 			numMutations := ((resp.Count - 1) % chainedMutations) + 1
@@ -0,0 +1,16 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package synctest provides support for testing concurrent code.
+//
+// See the testing/synctest package for function documentation.
+package synctest
+
+import _ "unsafe" // for go:linkname
+
+//go:linkname Run runtime.synctestRun
+func Run(f func())
+
+//go:linkname Wait runtime.synctestWait
+func Wait()
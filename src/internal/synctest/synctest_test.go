@@ -0,0 +1,200 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package synctest_test
+
+import (
+	"internal/synctest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNow(t *testing.T) {
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	synctest.Run(func() {
+		if got := time.Now(); !got.Equal(start) {
+			t.Errorf("at start: time.Now() = %v, want %v", got, start)
+		}
+		time.Sleep(1 * time.Second)
+		if got, want := time.Now(), start.Add(1*time.Second); !got.Equal(want) {
+			t.Errorf("after sleep: time.Now() = %v, want %v", got, want)
+		}
+		if got, want := time.Since(start), 1*time.Second; got != want {
+			t.Errorf("time.Since(start) = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestSleepInGoroutines(t *testing.T) {
+	synctest.Run(func() {
+		start := time.Now()
+		var mu sync.Mutex
+		var order []int
+		var wg sync.WaitGroup
+		for _, d := range []int{3, 1, 2} {
+			d := d
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				time.Sleep(time.Duration(d) * time.Hour)
+				mu.Lock()
+				order = append(order, d)
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+		if got, want := time.Since(start), 3*time.Hour; got != want {
+			t.Errorf("elapsed time = %v, want %v", got, want)
+		}
+		if len(order) != 3 || order[0] != 1 || order[1] != 2 || order[2] != 3 {
+			t.Errorf("goroutines woke in order %v, want [1 2 3]", order)
+		}
+	})
+}
+
+func TestTimers(t *testing.T) {
+	synctest.Run(func() {
+		start := time.Now()
+
+		tm := time.NewTimer(1 * time.Second)
+		if got := <-tm.C; got.Sub(start) != 1*time.Second {
+			t.Errorf("timer fired at %v, want %v", got.Sub(start), 1*time.Second)
+		}
+		if tm.Reset(2 * time.Second) {
+			t.Errorf("Reset of expired timer = true, want false")
+		}
+		if !tm.Stop() {
+			t.Errorf("Stop of pending timer = false, want true")
+		}
+
+		ch := make(chan time.Duration)
+		time.AfterFunc(5*time.Second, func() {
+			ch <- time.Since(start)
+		})
+		if got, want := <-ch, 6*time.Second; got != want {
+			t.Errorf("AfterFunc ran at %v, want %v", got, want)
+		}
+
+		tick := time.NewTicker(1 * time.Minute)
+		for i := 1; i <= 3; i++ {
+			<-tick.C
+			if got, want := time.Since(start), 6*time.Second+time.Duration(i)*time.Minute; got != want {
+				t.Errorf("tick %v at %v, want %v", i, got, want)
+			}
+		}
+		tick.Stop()
+	})
+}
+
+func TestWait(t *testing.T) {
+	synctest.Run(func() {
+		ch := make(chan int)
+		done := false
+		go func() {
+			<-ch
+			done = true
+			<-ch
+		}()
+		synctest.Wait()
+		if done {
+			t.Fatalf("goroutine ran before receiving from channel")
+		}
+		ch <- 1
+		synctest.Wait()
+		if !done {
+			t.Fatalf("goroutine not blocked after Wait")
+		}
+		close(ch)
+	})
+}
+
+func TestWaitDoesNotAdvanceTime(t *testing.T) {
+	synctest.Run(func() {
+		start := time.Now()
+		go time.Sleep(1 * time.Second)
+		synctest.Wait()
+		if got := time.Since(start); got != 0 {
+			t.Errorf("time advanced by %v during Wait, want 0", got)
+		}
+	})
+}
+
+func TestDurablyBlocked(t *testing.T) {
+	synctest.Run(func() {
+		var mu sync.Mutex
+		cond := sync.NewCond(&mu)
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			mu.Lock()
+			cond.Wait()
+			mu.Unlock()
+			wg.Done()
+		}()
+		go func() {
+			wg.Wait()
+		}()
+		c1, c2 := make(chan int), make(chan int)
+		go func() {
+			select {
+			case <-c1:
+			case <-c2:
+			}
+		}()
+		synctest.Wait()
+		mu.Lock()
+		cond.Signal()
+		mu.Unlock()
+		wg.Wait()
+		close(c1)
+	})
+}
+
+func TestDeadlock(t *testing.T) {
+	defer wantPanic(t, "deadlock: all goroutines in bubble are blocked")
+	synctest.Run(func() {
+		<-make(chan int)
+	})
+}
+
+func TestChannelFromOutsideBubble(t *testing.T) {
+	ch := make(chan chan int)
+	go synctest.Run(func() {
+		c := make(chan int, 1)
+		ch <- c
+		<-ch
+	})
+	c := <-ch
+	func() {
+		defer wantPanic(t, "send on synctest channel from outside bubble")
+		c <- 1
+	}()
+	ch <- nil
+}
+
+func TestNestedRun(t *testing.T) {
+	synctest.Run(func() {
+		defer wantPanic(t, "synctest.Run called from within a synctest bubble")
+		synctest.Run(func() {})
+	})
+}
+
+func TestWaitOutsideBubble(t *testing.T) {
+	defer wantPanic(t, "synctest.Wait called from outside a bubble")
+	synctest.Wait()
+}
+
+func wantPanic(t *testing.T, want string) {
+	switch e := recover().(type) {
+	case nil:
+		t.Errorf("got no panic, want %q", want)
+	case error:
+		if got := e.Error(); got != want {
+			t.Errorf("got panic %q, want %q", got, want)
+		}
+	default:
+		t.Errorf("got panic %v, want %q", e, want)
+	}
+}
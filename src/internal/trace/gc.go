@@ -111,7 +111,7 @@ func MutatorUtilization(events []*Event, flags UtilFlags) [][]MutatorUtil {
 				ps[ev.P].gc--
 				delete(assists, ev.G)
 			}
-		case EvGCSweepStart:
+		case EvGCSweepStart, EvGCSweepActive:
 			if flags&UtilSweep != 0 {
 				ps[ev.P].gc++
 			}
@@ -0,0 +1,219 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"fmt"
+	"io"
+)
+
+// Starting with Go 1.19, the runtime splits the trace into generations.
+// Each generation begins with an EvGeneration event and is
+// self-contained: it has its own string dictionary, stack table and
+// timer frequency, and the first events about each goroutine and P in
+// it describe their status (EvGoCreate, EvGoWaiting, EvGoInSyscall,
+// EvGoStart and EvProcStart events, as at the beginning of the trace,
+// and EvGCSweepActive for a sweep in progress). A generation can
+// therefore be parsed without reading the ones before it. Traces of
+// older versions consist of a single generation.
+//
+// The runtime switches generations without stopping the world, so the
+// last events of a generation may be later than the first events of the
+// next one.
+
+// Reader reads a trace one generation at a time, so that a trace can be
+// processed without holding all of it in memory.
+type Reader struct {
+	r    io.Reader
+	ver  int
+	off  int    // offset of the next event
+	next uint64 // number of the next generation; 0 at the end of the trace
+	m    merger
+}
+
+// NewReader reads the trace header from r and returns a Reader
+// for the generations that follow.
+func NewReader(r io.Reader) (*Reader, error) {
+	ver, off, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	tr := &Reader{r: r, ver: ver, off: off, next: 1}
+	if ver >= 1019 {
+		// The trace begins with the marker of its first generation,
+		// which is not necessarily generation 1 (see the flight
+		// recorder in runtime/trace).
+		raw, _, next, off, err := readEvents(r, ver, off)
+		if err != nil {
+			return nil, err
+		}
+		if len(raw) != 0 || next == 0 {
+			return nil, fmt.Errorf("trace does not start with a generation")
+		}
+		tr.off = off
+		tr.next = next
+	}
+	return tr, nil
+}
+
+// Version returns the version of the trace as, for example, 1019 for Go 1.19.
+func (tr *Reader) Version() int {
+	return tr.ver
+}
+
+// ReadGeneration reads the next generation of the trace. It returns
+// the events of the generation ordered by time, with timestamps in
+// nanoseconds since the beginning of the trace, and the stacks they
+// refer to. Stack IDs are unique across generations.
+//
+// Status events that only repeat what earlier generations have already
+// established are omitted, and timestamps are adjusted not to decrease
+// from one generation to the next, so that concatenating the events of
+// all generations yields a consistent trace.
+// The events are not post-processed: Link and Stk are not set.
+//
+// At the end of the trace, ReadGeneration returns io.EOF.
+func (tr *Reader) ReadGeneration() (events []*Event, stacks map[uint64][]*Frame, err error) {
+	if tr.next == 0 {
+		return nil, nil, io.EOF
+	}
+	gen := tr.next
+	raw, strings, next, off, err := readEvents(tr.r, tr.ver, tr.off)
+	if err != nil {
+		return nil, nil, err
+	}
+	if next != 0 && next != gen+1 {
+		return nil, nil, fmt.Errorf("generation %v at offset 0x%x follows generation %v", next, tr.off, gen)
+	}
+	tr.off = off
+	tr.next = next
+	events, stacks, ticksPerSec, err := parseEvents(tr.ver, raw, strings)
+	if err != nil {
+		return nil, nil, err
+	}
+	events, stacks = tr.m.merge(events, stacks, ticksPerSec)
+	return events, stacks, nil
+}
+
+// merger joins consecutive generations into a single stream of events.
+type merger struct {
+	n          int     // number of generations merged so far
+	startTicks int64   // timestamp of the first event of the last non-empty generation, in ticks
+	startNs    int64   // same, in nanoseconds since the beginning of the trace
+	nsPerTick  float64 // tick duration in the same generation; 0 before it
+	stackBase  uint64  // offset added to the stack IDs of the next generation
+	lastNs     int64   // timestamp of the last merged event
+
+	alive    map[uint64]bool // goroutines that have been created and have not ended
+	running  map[uint64]bool // goroutines that are running
+	procs    map[int]bool    // Ps that are running
+	sweeping map[int]bool    // Ps that are sweeping
+}
+
+// merge translates the timestamps of the events of a generation from ticks
+// to nanoseconds, renumbers its stacks so that they don't collide with
+// those of earlier generations, and drops the status events that describe
+// state already known from earlier generations. It returns the remaining
+// events and the renumbered stacks.
+func (m *merger) merge(events []*Event, stacks map[uint64][]*Frame, ticksPerSec int64) ([]*Event, map[uint64][]*Frame) {
+	if m.n == 0 {
+		m.alive = make(map[uint64]bool)
+		m.running = make(map[uint64]bool)
+		m.procs = make(map[int]bool)
+		m.sweeping = make(map[int]bool)
+	}
+	m.n++
+
+	base := m.stackBase
+	if base != 0 {
+		renumbered := make(map[uint64][]*Frame, len(stacks))
+		for id, stk := range stacks {
+			renumbered[id+base] = stk
+		}
+		stacks = renumbered
+	}
+	for id := range stacks {
+		if id > m.stackBase {
+			m.stackBase = id
+		}
+	}
+	if len(events) == 0 {
+		return events, stacks
+	}
+
+	// Translate cpu ticks to real time.
+	start := events[0].Ts
+	if m.nsPerTick != 0 {
+		m.startNs += int64(float64(start-m.startTicks) * m.nsPerTick)
+	}
+	m.startTicks = start
+	// Use floating point to avoid integer overflows.
+	m.nsPerTick = 1e9 / float64(ticksPerSec)
+
+	merged := events[:0]
+	for _, ev := range events {
+		ev.Ts = m.startNs + int64(float64(ev.Ts-start)*m.nsPerTick)
+		if ev.Ts < m.lastNs {
+			// The event was written concurrently with the end
+			// of the previous generation.
+			ev.Ts = m.lastNs
+		}
+		m.lastNs = ev.Ts
+		if base != 0 {
+			if ev.StkID != 0 {
+				ev.StkID += base
+			}
+			if ev.Type == EvGoCreate && ev.Args[1] != 0 {
+				ev.Args[1] += base
+			}
+		}
+		if m.n > 1 && m.known(ev) {
+			continue
+		}
+		switch ev.Type {
+		case EvGoCreate:
+			m.alive[ev.Args[0]] = true
+		case EvGoEnd:
+			delete(m.alive, ev.G)
+			delete(m.running, ev.G)
+		case EvGoStart, EvGoStartLabel:
+			m.running[ev.Args[0]] = true
+		case EvGoStop, EvGoSched, EvGoPreempt, EvGoSleep, EvGoBlock,
+			EvGoBlockSend, EvGoBlockRecv, EvGoBlockSelect, EvGoBlockSync,
+			EvGoBlockCond, EvGoBlockNet, EvGoSysBlock, EvGoBlockGC:
+			delete(m.running, ev.G)
+		case EvProcStart:
+			m.procs[ev.P] = true
+		case EvProcStop:
+			delete(m.procs, ev.P)
+		case EvGCSweepStart, EvGCSweepActive:
+			m.sweeping[ev.P] = true
+		case EvGCSweepDone:
+			delete(m.sweeping, ev.P)
+		}
+		merged = append(merged, ev)
+	}
+	return merged, stacks
+}
+
+// known reports whether ev is a status event that describes
+// state that is already known.
+func (m *merger) known(ev *Event) bool {
+	switch ev.Type {
+	case EvGoCreate:
+		return m.alive[ev.Args[0]]
+	case EvGoWaiting, EvGoInSyscall:
+		// The goroutine blocked, or was retaken in a syscall,
+		// in an earlier generation.
+		return m.alive[ev.Args[0]]
+	case EvGoStart, EvGoStartLabel:
+		return m.running[ev.Args[0]]
+	case EvProcStart:
+		return m.procs[ev.P]
+	case EvGCSweepActive:
+		return m.sweeping[ev.P]
+	}
+	return false
+}
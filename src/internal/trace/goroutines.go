@@ -237,7 +237,7 @@ func GoroutineStats(events []*Event) map[uint64]*GDesc {
 				g.blockSyscallTime = 0
 			}
 			g.blockSchedTime = ev.Ts
-		case EvGCSweepStart:
+		case EvGCSweepStart, EvGCSweepActive:
 			g := gs[ev.G]
 			if g != nil {
 				// Sweep can happen during GC on system goroutine.
@@ -100,13 +100,25 @@ func Parse(r io.Reader, bin string) (ParseResult, error) {
 // parse parses, post-processes and verifies the trace. It returns the
 // trace version and the list of events.
 func parse(r io.Reader, bin string) (int, ParseResult, error) {
-	ver, rawEvents, strings, err := readTrace(r)
+	tr, err := NewReader(r)
 	if err != nil {
 		return 0, ParseResult{}, err
 	}
-	events, stacks, err := parseEvents(ver, rawEvents, strings)
-	if err != nil {
-		return 0, ParseResult{}, err
+	ver := tr.Version()
+	var events []*Event
+	stacks := make(map[uint64][]*Frame)
+	for {
+		genEvents, genStacks, err := tr.ReadGeneration()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, ParseResult{}, err
+		}
+		events = append(events, genEvents...)
+		for id, stk := range genStacks {
+			stacks[id] = stk
+		}
 	}
 	events = removeFutile(events)
 	err = postProcessTrace(ver, events)
@@ -135,12 +147,11 @@ type rawEvent struct {
 	sargs []string
 }
 
-// readTrace does wire-format parsing and verification.
-// It does not care about specific event types and argument meaning.
-func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]string, err error) {
-	// Read and validate trace header.
+// readHeader reads and validates the trace header.
+// It returns the trace version and the offset of the first event.
+func readHeader(r io.Reader) (ver int, off int, err error) {
 	var buf [16]byte
-	off, err := io.ReadFull(r, buf[:])
+	off, err = io.ReadFull(r, buf[:])
 	if err != nil {
 		err = fmt.Errorf("failed to read header: read %v, err %v", off, err)
 		return
@@ -150,7 +161,7 @@ func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]stri
 		return
 	}
 	switch ver {
-	case 1005, 1007, 1008, 1009, 1010, 1011:
+	case 1005, 1007, 1008, 1009, 1010, 1011, 1019:
 		// Note: When adding a new version, add canned traces
 		// from the old version to the test suite using mkcanned.bash.
 		break
@@ -158,6 +169,17 @@ func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]stri
 		err = fmt.Errorf("unsupported trace file version %v.%v (update Go toolchain) %v", ver/1000, ver%1000, ver)
 		return
 	}
+	return
+}
+
+// readEvents does wire-format parsing and verification of the events of
+// one generation, starting at offset off. It stops at the end of the input,
+// or at an EvGeneration event that starts the next generation; in the
+// latter case it returns the number of that generation as next.
+// It does not care about specific event types and argument meaning.
+func readEvents(r io.Reader, ver int, start int) (events []rawEvent, strings map[uint64]string, next uint64, off int, err error) {
+	var buf [16]byte
+	off = start
 
 	// Read events.
 	strings = make(map[uint64]string)
@@ -186,6 +208,13 @@ func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]stri
 			err = fmt.Errorf("unknown event type %v at offset 0x%x", typ, off0)
 			return
 		}
+		if typ == EvGeneration {
+			next, off, err = readVal(r, off)
+			if err == nil && next == 0 {
+				err = fmt.Errorf("generation at offset 0x%x has invalid number 0", off0)
+			}
+			return
+		}
 		if typ == EvString {
 			// String dictionary entry [ID, length, string].
 			var id uint64
@@ -311,10 +340,12 @@ func parseHeader(buf []byte) (int, error) {
 	return ver, nil
 }
 
-// Parse events transforms raw events into events.
+// Parse events transforms raw events into events ordered by time.
 // It does analyze and verify per-event-type arguments.
-func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (events []*Event, stacks map[uint64][]*Frame, err error) {
-	var ticksPerSec, lastSeq, lastTs int64
+// Timestamps of the returned events are in ticks; ticksPerSec is the
+// tick frequency.
+func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (events []*Event, stacks map[uint64][]*Frame, ticksPerSec int64, err error) {
+	var lastSeq, lastTs int64
 	var lastG uint64
 	var lastP int
 	timerGoids := make(map[uint64]bool)
@@ -452,7 +483,9 @@ func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (even
 			batches[lastP] = append(batches[lastP], e)
 		}
 	}
-	if len(batches) == 0 {
+	if len(batches) == 0 && ver < 1019 {
+		// Generations, on the other hand, can be empty
+		// if they are ended in quick succession.
 		err = fmt.Errorf("trace is empty")
 		return
 	}
@@ -479,13 +512,8 @@ func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (even
 		return
 	}
 
-	// Translate cpu ticks to real time.
-	minTs := events[0].Ts
-	// Use floating point to avoid integer overflows.
-	freq := 1e9 / float64(ticksPerSec)
+	// Move timers and syscalls to separate fake Ps.
 	for _, ev := range events {
-		ev.Ts = int64(float64(ev.Ts-minTs) * freq)
-		// Move timers and syscalls to separate fake Ps.
 		if timerGoids[ev.G] && ev.Type == EvGoUnblock {
 			ev.P = TimerP
 		}
@@ -659,6 +687,13 @@ func postProcessTrace(ver int, events []*Event) error {
 				return fmt.Errorf("previous sweeping is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
 			}
 			p.evSweep = ev
+		case EvGCSweepActive:
+			// The sweep started before the generations that were
+			// read. Only the first status event of the sweep starts
+			// it here; the others repeat what is already known.
+			if p.evSweep == nil {
+				p.evSweep = ev
+			}
 		case EvGCMarkAssistStart:
 			if g.evMarkAssist != nil {
 				return fmt.Errorf("previous mark assist is not ended before a new one (offset %v, time %v)", ev.Off, ev.Ts)
@@ -976,7 +1011,7 @@ func argNum(raw rawEvent, ver int) int {
 		narg++
 	}
 	switch raw.typ {
-	case EvBatch, EvFrequency, EvTimerGoroutine:
+	case EvBatch, EvFrequency, EvTimerGoroutine, EvGeneration:
 		if ver < 1007 {
 			narg++ // there was an unused arg before 1.7
 		}
@@ -1058,7 +1093,9 @@ const (
 	EvUserTaskEnd       = 46 // end of task [timestamp, internal task id, stack]
 	EvUserRegion        = 47 // trace.WithRegion [timestamp, internal task id, mode(0:start, 1:end), stack, name string]
 	EvUserLog           = 48 // trace.Log [timestamp, internal id, key string id, stack, value string]
-	EvCount             = 49
+	EvGeneration        = 49 // start of a new generation [generation number]
+	EvGCSweepActive     = 50 // sweep is in progress at the start of a generation [timestamp]
+	EvCount             = 51
 )
 
 var EventDescriptions = [EvCount]struct {
@@ -1117,4 +1154,6 @@ var EventDescriptions = [EvCount]struct {
 	EvUserTaskEnd:       {"UserTaskEnd", 1011, true, []string{"taskid"}, nil},
 	EvUserRegion:        {"UserRegion", 1011, true, []string{"taskid", "mode", "typeid"}, []string{"name"}},
 	EvUserLog:           {"UserLog", 1011, true, []string{"id", "keyid"}, []string{"category", "message"}},
+	EvGeneration:        {"Generation", 1019, false, []string{"gen"}, nil},
+	EvGCSweepActive:     {"GCSweepActive", 1019, false, []string{}, nil},
 }
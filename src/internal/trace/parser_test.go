@@ -6,8 +6,10 @@ package trace
 
 import (
 	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -108,3 +110,137 @@ func TestTimestampOverflow(t *testing.T) {
 		t.Fatalf("failed to parse: %v", err)
 	}
 }
+
+func TestParseGenerations(t *testing.T) {
+	// Goroutine 1 runs and goroutine 2 is blocked across a generation
+	// boundary. Their status in the second generation, written before
+	// the first events about them, must not be reported again. P 1 is
+	// still writing events of the first generation when the second one
+	// begins, so the timestamps of the second generation must not go
+	// back in time.
+	w := new(Writer)
+	w.Write([]byte("go 1.19 trace\x00\x00\x00"))
+	w.Emit(EvGeneration, 1)
+	w.Emit(EvBatch, 0, 1000)
+	w.Emit(EvProcStart, 0, 0)
+	w.Emit(EvGoCreate, 1, 1, 0, 0)
+	w.Emit(EvGoCreate, 1, 2, 0, 0)
+	w.Emit(EvGoWaiting, 1, 2)
+	w.Emit(EvGoStart, 1, 1, 1)
+	w.Emit(EvBatch, 1, 2003)
+	w.Emit(EvProcStart, 0, 1)
+	w.Emit(EvProcStop, 5)
+	w.Emit(EvFrequency, 1e9)
+	w.Emit(EvGeneration, 2)
+	w.Emit(EvBatch, 0, 2000)
+	w.Emit(EvProcStart, 0, 0)
+	w.Emit(EvGoCreate, 1, 1, 0, 0)
+	w.Emit(EvGoStart, 1, 1, 1)
+	w.Emit(EvGoCreate, 1, 2, 0, 0)
+	w.Emit(EvGoWaiting, 1, 2)
+	w.Emit(EvGoUnblock, 1, 2, 2, 0)
+	w.Emit(EvGoEnd, 1)
+	w.Emit(EvFrequency, 1e9)
+
+	res, err := Parse(w, "")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	var got []string
+	for _, ev := range res.Events {
+		got = append(got, fmt.Sprintf("%v@%v", EventDescriptions[ev.Type].Name, ev.Ts))
+	}
+	want := []string{
+		"ProcStart@0", "GoCreate@1", "GoCreate@2", "GoWaiting@3", "GoStart@4",
+		"ProcStart@1003", "ProcStop@1008", "GoUnblock@1008", "GoEnd@1008",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+}
+
+func TestParseGenerationsSweep(t *testing.T) {
+	// The trace begins with generation 4, as a flight recorder snapshot
+	// does, while P 0 is sweeping. The next sweep is in progress across
+	// the boundary to generation 5, which reports it again, and that
+	// must not be taken for the start of another sweep.
+	w := new(Writer)
+	w.Write([]byte("go 1.19 trace\x00\x00\x00"))
+	w.Emit(EvGeneration, 4)
+	w.Emit(EvBatch, 0, 1000)
+	w.Emit(EvProcStart, 0, 0)
+	w.Emit(EvGCSweepActive, 1)
+	w.Emit(EvGCSweepDone, 1, 8192, 0)
+	w.Emit(EvGCSweepStart, 1, 0)
+	w.Emit(EvFrequency, 1e9)
+	w.Emit(EvGeneration, 5)
+	w.Emit(EvBatch, 0, 2000)
+	w.Emit(EvProcStart, 0, 0)
+	w.Emit(EvGCSweepActive, 1)
+	w.Emit(EvGCSweepDone, 1, 8192, 0)
+	w.Emit(EvFrequency, 1e9)
+
+	res, err := Parse(w, "")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	var got []string
+	for _, ev := range res.Events {
+		got = append(got, fmt.Sprintf("%v@%v", EventDescriptions[ev.Type].Name, ev.Ts))
+		if ev.Type == EvGCSweepActive || ev.Type == EvGCSweepStart {
+			if ev.Link == nil || ev.Link.Type != EvGCSweepDone {
+				t.Errorf("%v@%v is not linked to the end of the sweep", EventDescriptions[ev.Type].Name, ev.Ts)
+			}
+		}
+	}
+	want := []string{
+		"ProcStart@0", "GCSweepActive@1", "GCSweepDone@2", "GCSweepStart@3",
+		"GCSweepDone@1002",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+}
+
+func TestParseEmptyGeneration(t *testing.T) {
+	// A generation that ends right after it begins has no events.
+	w := new(Writer)
+	w.Write([]byte("go 1.19 trace\x00\x00\x00"))
+	w.Emit(EvGeneration, 1)
+	w.Emit(EvBatch, 0, 1000)
+	w.Emit(EvGoCreate, 1, 1, 0, 0)
+	w.Emit(EvFrequency, 1e9)
+	w.Emit(EvGeneration, 2)
+	w.Emit(EvFrequency, 1e9)
+	w.Emit(EvGeneration, 3)
+	w.Emit(EvBatch, 0, 2000)
+	w.Emit(EvGoCreate, 1, 1, 0, 0)
+	w.Emit(EvGoCreate, 1, 2, 0, 0)
+	w.Emit(EvFrequency, 1e9)
+
+	res, err := Parse(w, "")
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	var got []string
+	for _, ev := range res.Events {
+		got = append(got, fmt.Sprintf("%v@%v", EventDescriptions[ev.Type].Name, ev.Ts))
+	}
+	want := []string{"GoCreate@0", "GoCreate@1001"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got events %v, want %v", got, want)
+	}
+}
+
+func TestParseBadGeneration(t *testing.T) {
+	w := new(Writer)
+	w.Write([]byte("go 1.19 trace\x00\x00\x00"))
+	w.Emit(EvGeneration, 1)
+	w.Emit(EvBatch, 0, 1000)
+	w.Emit(EvGoCreate, 1, 1, 0, 0)
+	w.Emit(EvFrequency, 1e9)
+	w.Emit(EvGeneration, 3)
+	if _, err := Parse(w, ""); err == nil {
+		t.Fatalf("no error on a missing generation")
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slog_test
+
+import (
+	"log/slog"
+	"os"
+)
+
+func ExampleGroup() {
+	// Remove the time attribute so the output is reproducible.
+	removeTime := func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey && len(groups) == 0 {
+			return slog.Attr{}
+		}
+		return a
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{ReplaceAttr: removeTime}))
+	logger.Info("finished",
+		slog.Group("req",
+			slog.String("method", "GET"),
+			slog.String("url", "localhost")),
+		slog.Int("status", 200))
+
+	// Output:
+	// level=INFO msg=finished req.method=GET req.url=localhost status=200
+}
+
+func ExampleLogger_WithGroup() {
+	removeTime := func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey && len(groups) == 0 {
+			return slog.Attr{}
+		}
+		return a
+	}
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{ReplaceAttr: removeTime}))
+	logger.With("id", 7).WithGroup("parser").Warn("unexpected token", "line", 3, "col", 14)
+
+	// Output:
+	// {"level":"WARN","msg":"unexpected token","id":7,"parser":{"line":3,"col":14}}
+}
+
+func ExampleLevelVar() {
+	removeTime := func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey && len(groups) == 0 {
+			return slog.Attr{}
+		}
+		return a
+	}
+	var programLevel slog.LevelVar
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level:       &programLevel,
+		ReplaceAttr: removeTime,
+	}))
+	logger.Debug("not printed")
+	programLevel.Set(slog.LevelDebug)
+	logger.Debug("printed")
+
+	// Output:
+	// level=DEBUG msg=printed
+}
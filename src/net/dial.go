@@ -96,10 +96,32 @@ type Dialer struct {
 	// necessarily the ones passed to Dial. For example, passing "tcp" to Dial
 	// will cause the Control function to be called with "tcp4" or "tcp6".
 	Control func(network, address string, c syscall.RawConn) error
+
+	// If mptcp is set, TCP connections are dialed with Multipath TCP
+	// when the operating system supports it. See SetMultipathTCP.
+	mptcp bool
 }
 
 func (d *Dialer) dualStack() bool { return d.FallbackDelay >= 0 }
 
+// MultipathTCP reports whether Multipath TCP (RFC 8684) will be used
+// by the TCP connections dialed with d.
+//
+// It does not check whether the operating system supports it.
+func (d *Dialer) MultipathTCP() bool {
+	return d.mptcp
+}
+
+// SetMultipathTCP directs the Dial methods to use, or not use,
+// Multipath TCP (RFC 8684) for TCP connections. Multipath TCP is
+// currently only supported on Linux. Where it is not available, or if
+// the kernel refuses to create a Multipath TCP socket, the Dial methods
+// silently use plain TCP instead. The server may also fall back to
+// plain TCP; use TCPConn.MultipathTCP to find out what was negotiated.
+func (d *Dialer) SetMultipathTCP(use bool) {
+	d.mptcp = use
+}
+
 func minNonzeroTime(a, b time.Time) time.Time {
 	if a.IsZero() {
 		return b
@@ -580,7 +602,11 @@ func (sd *sysDialer) dialSingle(ctx context.Context, ra Addr) (c Conn, err error
 	switch ra := ra.(type) {
 	case *TCPAddr:
 		la, _ := la.(*TCPAddr)
-		c, err = sd.dialTCP(ctx, la, ra)
+		if sd.mptcp {
+			c, err = sd.dialMPTCP(ctx, la, ra)
+		} else {
+			c, err = sd.dialTCP(ctx, la, ra)
+		}
 	case *UDPAddr:
 		la, _ := la.(*UDPAddr)
 		c, err = sd.dialUDP(ctx, la, ra)
@@ -616,6 +642,30 @@ type ListenConfig struct {
 	// that do not support keep-alives ignore this field.
 	// If negative, keep-alives are disabled.
 	KeepAlive time.Duration
+
+	// If mptcp is set, TCP listeners accept Multipath TCP connections
+	// when the operating system supports it. See SetMultipathTCP.
+	mptcp bool
+}
+
+// MultipathTCP reports whether Multipath TCP (RFC 8684) will be used
+// by the listeners created with lc.
+//
+// It does not check whether the operating system supports it.
+func (lc *ListenConfig) MultipathTCP() bool {
+	return lc.mptcp
+}
+
+// SetMultipathTCP directs Listen to use, or not use, Multipath TCP
+// (RFC 8684) for TCP listeners. Multipath TCP is currently only
+// supported on Linux. Where it is not available, or if the kernel
+// refuses to create a Multipath TCP socket, Listen silently uses
+// plain TCP instead.
+//
+// Clients that do not request Multipath TCP are still accepted, and
+// their connections use plain TCP.
+func (lc *ListenConfig) SetMultipathTCP(use bool) {
+	lc.mptcp = use
 }
 
 // Listen announces on the local network address.
@@ -636,7 +686,11 @@ func (lc *ListenConfig) Listen(ctx context.Context, network, address string) (Li
 	la := addrs.first(isIPv4)
 	switch la := la.(type) {
 	case *TCPAddr:
-		l, err = sl.listenTCP(ctx, la)
+		if sl.mptcp {
+			l, err = sl.listenMPTCP(ctx, la)
+		} else {
+			l, err = sl.listenTCP(ctx, la)
+		}
 	case *UnixAddr:
 		l, err = sl.listenUnix(ctx, la)
 	default:
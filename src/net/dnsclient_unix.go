@@ -57,6 +57,8 @@ func newRequest(q dnsmessage.Question) (id uint16, udpReq, tcpReq []byte, err er
 		return 0, nil, nil, err
 	}
 	tcpReq, err = b.Finish()
+	// Ask for the AD bit in the response, see RFC 6840, Section 5.7.
+	tcpReq[5] |= 0x20
 	udpReq = tcpReq[2:]
 	l := len(tcpReq) - 2
 	tcpReq[0] = byte(l >> 8)
@@ -64,6 +66,19 @@ func newRequest(q dnsmessage.Question) (id uint16, udpReq, tcpReq []byte, err er
 	return id, udpReq, tcpReq, err
 }
 
+// A dnsHeader is a DNS message header along with the Authentic Data
+// bit, which dnsmessage.Header does not expose.
+type dnsHeader struct {
+	dnsmessage.Header
+	AuthenticData bool
+}
+
+// authenticData reports whether the AD bit (RFC 4035, Section 3.2.3)
+// is set in the DNS message msg.
+func authenticData(msg []byte) bool {
+	return len(msg) > 3 && msg[3]&0x20 != 0
+}
+
 func checkResponse(reqID uint16, reqQues dnsmessage.Question, respHdr dnsmessage.Header, respQues dnsmessage.Question) bool {
 	if !respHdr.Response {
 		return false
@@ -77,16 +92,16 @@ func checkResponse(reqID uint16, reqQues dnsmessage.Question, respHdr dnsmessage
 	return true
 }
 
-func dnsPacketRoundTrip(c Conn, id uint16, query dnsmessage.Question, b []byte) (dnsmessage.Parser, dnsmessage.Header, error) {
+func dnsPacketRoundTrip(c Conn, id uint16, query dnsmessage.Question, b []byte) (dnsmessage.Parser, dnsHeader, error) {
 	if _, err := c.Write(b); err != nil {
-		return dnsmessage.Parser{}, dnsmessage.Header{}, err
+		return dnsmessage.Parser{}, dnsHeader{}, err
 	}
 
 	b = make([]byte, 512) // see RFC 1035
 	for {
 		n, err := c.Read(b)
 		if err != nil {
-			return dnsmessage.Parser{}, dnsmessage.Header{}, err
+			return dnsmessage.Parser{}, dnsHeader{}, err
 		}
 		var p dnsmessage.Parser
 		// Ignore invalid responses as they may be malicious
@@ -100,18 +115,18 @@ func dnsPacketRoundTrip(c Conn, id uint16, query dnsmessage.Question, b []byte)
 		if err != nil || !checkResponse(id, query, h, q) {
 			continue
 		}
-		return p, h, nil
+		return p, dnsHeader{h, authenticData(b[:n])}, nil
 	}
 }
 
-func dnsStreamRoundTrip(c Conn, id uint16, query dnsmessage.Question, b []byte) (dnsmessage.Parser, dnsmessage.Header, error) {
+func dnsStreamRoundTrip(c Conn, id uint16, query dnsmessage.Question, b []byte) (dnsmessage.Parser, dnsHeader, error) {
 	if _, err := c.Write(b); err != nil {
-		return dnsmessage.Parser{}, dnsmessage.Header{}, err
+		return dnsmessage.Parser{}, dnsHeader{}, err
 	}
 
 	b = make([]byte, 1280) // 1280 is a reasonable initial size for IP over Ethernet, see RFC 4035
 	if _, err := io.ReadFull(c, b[:2]); err != nil {
-		return dnsmessage.Parser{}, dnsmessage.Header{}, err
+		return dnsmessage.Parser{}, dnsHeader{}, err
 	}
 	l := int(b[0])<<8 | int(b[1])
 	if l > len(b) {
@@ -119,29 +134,32 @@ func dnsStreamRoundTrip(c Conn, id uint16, query dnsmessage.Question, b []byte)
 	}
 	n, err := io.ReadFull(c, b[:l])
 	if err != nil {
-		return dnsmessage.Parser{}, dnsmessage.Header{}, err
+		return dnsmessage.Parser{}, dnsHeader{}, err
 	}
 	var p dnsmessage.Parser
 	h, err := p.Start(b[:n])
 	if err != nil {
-		return dnsmessage.Parser{}, dnsmessage.Header{}, errCannotUnmarshalDNSMessage
+		return dnsmessage.Parser{}, dnsHeader{}, errCannotUnmarshalDNSMessage
 	}
 	q, err := p.Question()
 	if err != nil {
-		return dnsmessage.Parser{}, dnsmessage.Header{}, errCannotUnmarshalDNSMessage
+		return dnsmessage.Parser{}, dnsHeader{}, errCannotUnmarshalDNSMessage
 	}
 	if !checkResponse(id, query, h, q) {
-		return dnsmessage.Parser{}, dnsmessage.Header{}, errInvalidDNSResponse
+		return dnsmessage.Parser{}, dnsHeader{}, errInvalidDNSResponse
 	}
-	return p, h, nil
+	return p, dnsHeader{h, authenticData(b[:n])}, nil
 }
 
 // exchange sends a query on the connection and hopes for a response.
-func (r *Resolver) exchange(ctx context.Context, server string, q dnsmessage.Question, timeout time.Duration, useTCP bool) (dnsmessage.Parser, dnsmessage.Header, error) {
+func (r *Resolver) exchange(ctx context.Context, server string, q dnsmessage.Question, timeout time.Duration, useTCP bool) (dnsmessage.Parser, dnsHeader, error) {
+	if e := r.encryptedDNS(); e != nil {
+		return e.exchange(ctx, server, q, timeout)
+	}
 	q.Class = dnsmessage.ClassINET
 	id, udpReq, tcpReq, err := newRequest(q)
 	if err != nil {
-		return dnsmessage.Parser{}, dnsmessage.Header{}, errCannotMarshalDNSMessage
+		return dnsmessage.Parser{}, dnsHeader{}, errCannotMarshalDNSMessage
 	}
 	var networks []string
 	if useTCP {
@@ -155,13 +173,13 @@ func (r *Resolver) exchange(ctx context.Context, server string, q dnsmessage.Que
 
 		c, err := r.dial(ctx, network, server)
 		if err != nil {
-			return dnsmessage.Parser{}, dnsmessage.Header{}, err
+			return dnsmessage.Parser{}, dnsHeader{}, err
 		}
 		if d, ok := ctx.Deadline(); ok && !d.IsZero() {
 			c.SetDeadline(d)
 		}
 		var p dnsmessage.Parser
-		var h dnsmessage.Header
+		var h dnsHeader
 		if _, ok := c.(PacketConn); ok {
 			p, h, err = dnsPacketRoundTrip(c, id, q, udpReq)
 		} else {
@@ -169,17 +187,70 @@ func (r *Resolver) exchange(ctx context.Context, server string, q dnsmessage.Que
 		}
 		c.Close()
 		if err != nil {
-			return dnsmessage.Parser{}, dnsmessage.Header{}, mapErr(err)
+			return dnsmessage.Parser{}, dnsHeader{}, mapErr(err)
 		}
 		if err := p.SkipQuestion(); err != dnsmessage.ErrSectionDone {
-			return dnsmessage.Parser{}, dnsmessage.Header{}, errInvalidDNSResponse
+			return dnsmessage.Parser{}, dnsHeader{}, errInvalidDNSResponse
 		}
 		if h.Truncated { // see RFC 5966
 			continue
 		}
 		return p, h, nil
 	}
-	return dnsmessage.Parser{}, dnsmessage.Header{}, errNoAnswerFromDNSServer
+	return dnsmessage.Parser{}, dnsHeader{}, errNoAnswerFromDNSServer
+}
+
+// exchange sends a query to server, an EncryptedDNS.Servers entry, and
+// waits for the response.
+func (e *EncryptedDNS) exchange(ctx context.Context, server string, q dnsmessage.Question, timeout time.Duration) (dnsmessage.Parser, dnsHeader, error) {
+	q.Class = dnsmessage.ClassINET
+	req, err := newPaddedRequest(q)
+	if err != nil {
+		return dnsmessage.Parser{}, dnsHeader{}, errCannotMarshalDNSMessage
+	}
+	srv, err := parseEncryptedDNSServer(server)
+	if err != nil {
+		return dnsmessage.Parser{}, dnsHeader{}, err
+	}
+
+	ctx, cancel := context.WithDeadline(ctx, time.Now().Add(timeout))
+	defer cancel()
+
+	var resp []byte
+	var id uint16
+	for retried := false; ; retried = true {
+		c, reused, err := e.getConn(ctx, server, srv)
+		if err != nil {
+			return dnsmessage.Parser{}, dnsHeader{}, err
+		}
+		id, resp, err = c.roundTrip(ctx, req)
+		if err == nil {
+			break
+		}
+		// A reused connection may have been closed by the server while
+		// it sat idle. Retry once on a new connection in that case.
+		if reused && !retried && c.failed() && ctx.Err() == nil {
+			continue
+		}
+		return dnsmessage.Parser{}, dnsHeader{}, mapErr(err)
+	}
+
+	var p dnsmessage.Parser
+	h, err := p.Start(resp)
+	if err != nil {
+		return dnsmessage.Parser{}, dnsHeader{}, errCannotUnmarshalDNSMessage
+	}
+	rq, err := p.Question()
+	if err != nil {
+		return dnsmessage.Parser{}, dnsHeader{}, errCannotUnmarshalDNSMessage
+	}
+	if !checkResponse(id, q, h, rq) {
+		return dnsmessage.Parser{}, dnsHeader{}, errInvalidDNSResponse
+	}
+	if err := p.SkipQuestion(); err != dnsmessage.ErrSectionDone {
+		return dnsmessage.Parser{}, dnsHeader{}, errInvalidDNSResponse
+	}
+	return p, dnsHeader{h, authenticData(resp)}, nil
 }
 
 // checkHeader performs basic sanity checks on the header.
@@ -234,14 +305,21 @@ func skipToAnswer(p *dnsmessage.Parser, qtype dnsmessage.Type) error {
 
 // Do a lookup for a single name, which must be rooted
 // (otherwise answer will not find the answers).
-func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string, qtype dnsmessage.Type) (dnsmessage.Parser, string, error) {
+func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string, qtype dnsmessage.Type) (dnsmessage.Parser, dnsHeader, string, error) {
 	var lastErr error
 	serverOffset := cfg.serverOffset()
-	sLen := uint32(len(cfg.servers))
+	servers := cfg.servers
+	if e := r.encryptedDNS(); e != nil {
+		servers = e.Servers
+		if len(servers) == 0 {
+			return dnsmessage.Parser{}, dnsHeader{}, "", &DNSError{Err: errNoEncryptedDNSServers.Error(), Name: name}
+		}
+	}
+	sLen := uint32(len(servers))
 
 	n, err := dnsmessage.NewName(name)
 	if err != nil {
-		return dnsmessage.Parser{}, "", errCannotMarshalDNSMessage
+		return dnsmessage.Parser{}, dnsHeader{}, "", errCannotMarshalDNSMessage
 	}
 	q := dnsmessage.Question{
 		Name:  n,
@@ -251,7 +329,7 @@ func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string,
 
 	for i := 0; i < cfg.attempts; i++ {
 		for j := uint32(0); j < sLen; j++ {
-			server := cfg.servers[(serverOffset+j)%sLen]
+			server := servers[(serverOffset+j)%sLen]
 
 			p, h, err := r.exchange(ctx, server, q, cfg.timeout, cfg.useTCP)
 			if err != nil {
@@ -272,7 +350,7 @@ func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string,
 				continue
 			}
 
-			if err := checkHeader(&p, h); err != nil {
+			if err := checkHeader(&p, h.Header); err != nil {
 				dnsErr := &DNSError{
 					Err:    err.Error(),
 					Name:   name,
@@ -286,7 +364,7 @@ func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string,
 					// another server won't help.
 
 					dnsErr.IsNotFound = true
-					return p, server, dnsErr
+					return p, h, server, dnsErr
 				}
 				lastErr = dnsErr
 				continue
@@ -294,7 +372,7 @@ func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string,
 
 			err = skipToAnswer(&p, qtype)
 			if err == nil {
-				return p, server, nil
+				return p, h, server, nil
 			}
 			lastErr = &DNSError{
 				Err:    err.Error(),
@@ -306,11 +384,11 @@ func (r *Resolver) tryOneName(ctx context.Context, cfg *dnsConfig, name string,
 				// server won't help.
 
 				lastErr.(*DNSError).IsNotFound = true
-				return p, server, lastErr
+				return p, h, server, lastErr
 			}
 		}
 	}
-	return dnsmessage.Parser{}, "", lastErr
+	return dnsmessage.Parser{}, dnsHeader{}, "", lastErr
 }
 
 // A resolverConfig represents a DNS stub resolver configuration.
@@ -388,14 +466,14 @@ func (conf *resolverConfig) releaseSema() {
 	<-conf.ch
 }
 
-func (r *Resolver) lookup(ctx context.Context, name string, qtype dnsmessage.Type) (dnsmessage.Parser, string, error) {
+func (r *Resolver) lookup(ctx context.Context, name string, qtype dnsmessage.Type) (dnsmessage.Parser, dnsHeader, string, error) {
 	if !isDomainName(name) {
 		// We used to use "invalid domain name" as the error,
 		// but that is a detail of the specific lookup mechanism.
 		// Other lookups might allow broader name syntax
 		// (for example Multicast DNS allows UTF-8; see RFC 6762).
 		// For consistency with libc resolvers, report no such host.
-		return dnsmessage.Parser{}, "", &DNSError{Err: errNoSuchHost.Error(), Name: name, IsNotFound: true}
+		return dnsmessage.Parser{}, dnsHeader{}, "", &DNSError{Err: errNoSuchHost.Error(), Name: name, IsNotFound: true}
 	}
 	resolvConf.tryUpdate("/etc/resolv.conf")
 	resolvConf.mu.RLock()
@@ -403,11 +481,12 @@ func (r *Resolver) lookup(ctx context.Context, name string, qtype dnsmessage.Typ
 	resolvConf.mu.RUnlock()
 	var (
 		p      dnsmessage.Parser
+		h      dnsHeader
 		server string
 		err    error
 	)
 	for _, fqdn := range conf.nameList(name) {
-		p, server, err = r.tryOneName(ctx, conf, fqdn, qtype)
+		p, h, server, err = r.tryOneName(ctx, conf, fqdn, qtype)
 		if err == nil {
 			break
 		}
@@ -418,7 +497,7 @@ func (r *Resolver) lookup(ctx context.Context, name string, qtype dnsmessage.Typ
 		}
 	}
 	if err == nil {
-		return p, server, nil
+		return p, h, server, nil
 	}
 	if err, ok := err.(*DNSError); ok {
 		// Show original name passed to lookup, not suffixed one.
@@ -426,7 +505,7 @@ func (r *Resolver) lookup(ctx context.Context, name string, qtype dnsmessage.Typ
 		// just one is misleading. See also golang.org/issue/6324.
 		err.Name = name
 	}
-	return dnsmessage.Parser{}, "", err
+	return dnsmessage.Parser{}, dnsHeader{}, "", err
 }
 
 // avoidDNS reports whether this is a hostname for which we should not
@@ -598,14 +677,14 @@ func (r *Resolver) goLookupIPCNAMEOrder(ctx context.Context, network, name strin
 		responseFn = func(fqdn string, qtype dnsmessage.Type) result {
 			dnsWaitGroup.Add(1)
 			defer dnsWaitGroup.Done()
-			p, server, err := r.tryOneName(ctx, conf, fqdn, qtype)
+			p, _, server, err := r.tryOneName(ctx, conf, fqdn, qtype)
 			return result{p, server, err}
 		}
 	} else {
 		queryFn = func(fqdn string, qtype dnsmessage.Type) {
 			dnsWaitGroup.Add(1)
 			go func(qtype dnsmessage.Type) {
-				p, server, err := r.tryOneName(ctx, conf, fqdn, qtype)
+				p, _, server, err := r.tryOneName(ctx, conf, fqdn, qtype)
 				lane <- result{p, server, err}
 				dnsWaitGroup.Done()
 			}(qtype)
@@ -753,7 +832,7 @@ func (r *Resolver) goLookupPTR(ctx context.Context, addr string) ([]string, erro
 	if err != nil {
 		return nil, err
 	}
-	p, server, err := r.lookup(ctx, arpa, dnsmessage.TypePTR)
+	p, _, server, err := r.lookup(ctx, arpa, dnsmessage.TypePTR)
 	if err != nil {
 		return nil, err
 	}
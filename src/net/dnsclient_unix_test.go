@@ -10,6 +10,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/netip"
 	"os"
 	"path"
 	"reflect"
@@ -788,8 +789,9 @@ func BenchmarkGoLookupIPWithBrokenNameServer(b *testing.B) {
 }
 
 type fakeDNSServer struct {
-	rh        func(n, s string, q dnsmessage.Message, t time.Time) (dnsmessage.Message, error)
-	alwaysTCP bool
+	rh            func(n, s string, q dnsmessage.Message, t time.Time) (dnsmessage.Message, error)
+	alwaysTCP     bool
+	authenticData bool // set the AD bit, which dnsmessage.Header lacks
 }
 
 func (server *fakeDNSServer) DialContext(_ context.Context, n, s string) (Conn, error) {
@@ -831,6 +833,9 @@ func (f *fakeDNSConn) Read(b []byte) (int, error) {
 	if err != nil {
 		return 0, fmt.Errorf("cannot marshal DNS message: %v", err)
 	}
+	if f.server.authenticData {
+		bb[2+3] |= 0x20
+	}
 
 	if f.tcp {
 		l := len(bb) - 2
@@ -1380,7 +1385,7 @@ func TestStrictErrorsLookupTXT(t *testing.T) {
 
 	for _, strict := range []bool{true, false} {
 		r := Resolver{StrictErrors: strict, Dial: fake.DialContext}
-		p, _, err := r.lookup(context.Background(), name, dnsmessage.TypeTXT)
+		p, _, _, err := r.lookup(context.Background(), name, dnsmessage.TypeTXT)
 		var wantErr error
 		var wantRRs int
 		if strict {
@@ -1438,7 +1443,7 @@ func lookupWithFake(fake fakeDNSServer, name string, typ dnsmessage.Type) error
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	_, _, err := r.tryOneName(ctx, conf, name, typ)
+	_, _, _, err := r.tryOneName(ctx, conf, name, typ)
 	return err
 }
 
@@ -2161,3 +2166,236 @@ func TestRootNS(t *testing.T) {
 		t.Errorf("records = [%v]; want [%v]", strings.Join(records, " "), want[0])
 	}
 }
+
+func TestLookupRecords(t *testing.T) {
+	svcb := []byte{
+		0, 1, // priority
+		3, 's', 'v', 'c', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0, // target
+		0, 1, 0, 3, 2, 'h', '2', // alpn="h2"
+		0, 3, 0, 2, 0x01, 0xbb, // port=443
+	}
+	caa := append([]byte{0, 5}, "issueletsencrypt.org"...)
+	tlsa := []byte{3, 1, 1, 0xde, 0xad, 0xbe, 0xef}
+
+	answers := []dnsmessage.Resource{
+		{
+			Header: dnsmessage.ResourceHeader{Name: mustNewName("example.com."), Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET, TTL: 60},
+			Body:   &dnsmessage.CNAMEResource{CNAME: mustNewName("alias.example.com.")},
+		},
+		{
+			Header: dnsmessage.ResourceHeader{Name: mustNewName("alias.example.com."), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 300},
+			Body:   &dnsmessage.AResource{A: TestAddr},
+		},
+		{
+			Header: dnsmessage.ResourceHeader{Name: mustNewName("alias.example.com."), Type: dnsmessage.TypeAAAA, Class: dnsmessage.ClassINET, TTL: 300},
+			Body:   &dnsmessage.AAAAResource{AAAA: TestAddr6},
+		},
+		{
+			Header: dnsmessage.ResourceHeader{Name: mustNewName("example.com."), Type: dnsmessage.TypeSOA, Class: dnsmessage.ClassINET, TTL: 3600},
+			Body: &dnsmessage.SOAResource{
+				NS:      mustNewName("ns.example.com."),
+				MBox:    mustNewName("hostmaster.example.com."),
+				Serial:  2022010101,
+				Refresh: 7200,
+				Retry:   3600,
+				Expire:  1209600,
+				MinTTL:  300,
+			},
+		},
+		{
+			Header: dnsmessage.ResourceHeader{Name: mustNewName("example.com."), Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET, TTL: 10},
+			Body:   &dnsmessage.TXTResource{TXT: []string{"v=spf1", " -all"}},
+		},
+		{
+			Header: dnsmessage.ResourceHeader{Name: mustNewName("example.com."), Type: dnsmessage.Type(DNSTypeCAA), Class: dnsmessage.ClassINET, TTL: 10},
+			Body:   &dnsmessage.UnknownResource{Type: dnsmessage.Type(DNSTypeCAA), Data: caa},
+		},
+		{
+			Header: dnsmessage.ResourceHeader{Name: mustNewName("_443._tcp.example.com."), Type: dnsmessage.Type(DNSTypeTLSA), Class: dnsmessage.ClassINET, TTL: 10},
+			Body:   &dnsmessage.UnknownResource{Type: dnsmessage.Type(DNSTypeTLSA), Data: tlsa},
+		},
+		{
+			Header: dnsmessage.ResourceHeader{Name: mustNewName("example.com."), Type: dnsmessage.Type(DNSTypeHTTPS), Class: dnsmessage.ClassINET, TTL: 10},
+			Body:   &dnsmessage.UnknownResource{Type: dnsmessage.Type(DNSTypeHTTPS), Data: svcb},
+		},
+		{
+			Header: dnsmessage.ResourceHeader{Name: mustNewName("example.com."), Type: 99, Class: dnsmessage.ClassINET, TTL: 10},
+			Body:   &dnsmessage.UnknownResource{Type: 99, Data: []byte{1, 2, 3}},
+		},
+	}
+
+	for _, tt := range []struct {
+		name string
+		typ  DNSType
+		ad   bool
+		want []DNSRecord
+	}{
+		{
+			name: "example.com", typ: DNSTypeA, ad: true,
+			want: []DNSRecord{{Name: "alias.example.com.", Type: DNSTypeA, TTL: 300 * time.Second, Data: &DNSAddrData{Addr: netip.AddrFrom4(TestAddr)}}},
+		},
+		{
+			name: "example.com", typ: DNSTypeAAAA,
+			want: []DNSRecord{{Name: "alias.example.com.", Type: DNSTypeAAAA, TTL: 300 * time.Second, Data: &DNSAddrData{Addr: netip.AddrFrom16(TestAddr6)}}},
+		},
+		{
+			name: "example.com", typ: DNSTypeCNAME,
+			want: []DNSRecord{{Name: "example.com.", Type: DNSTypeCNAME, TTL: 60 * time.Second, Data: &DNSNameData{Name: "alias.example.com."}}},
+		},
+		{
+			name: "example.com", typ: DNSTypeSOA,
+			want: []DNSRecord{{Name: "example.com.", Type: DNSTypeSOA, TTL: time.Hour, Data: &DNSSOAData{
+				NS:      "ns.example.com.",
+				MBox:    "hostmaster.example.com.",
+				Serial:  2022010101,
+				Refresh: 7200,
+				Retry:   3600,
+				Expire:  1209600,
+				MinTTL:  300,
+			}}},
+		},
+		{
+			name: "example.com", typ: DNSTypeTXT, ad: true,
+			want: []DNSRecord{{Name: "example.com.", Type: DNSTypeTXT, TTL: 10 * time.Second, Data: &DNSTXTData{TXT: []string{"v=spf1", " -all"}}}},
+		},
+		{
+			name: "example.com", typ: DNSTypeCAA,
+			want: []DNSRecord{{Name: "example.com.", Type: DNSTypeCAA, TTL: 10 * time.Second, Data: &DNSCAAData{Tag: "issue", Value: "letsencrypt.org"}}},
+		},
+		{
+			name: "_443._tcp.example.com", typ: DNSTypeTLSA,
+			want: []DNSRecord{{Name: "_443._tcp.example.com.", Type: DNSTypeTLSA, TTL: 10 * time.Second, Data: &DNSTLSAData{Usage: 3, Selector: 1, MatchingType: 1, CertData: []byte{0xde, 0xad, 0xbe, 0xef}}}},
+		},
+		{
+			name: "example.com", typ: DNSTypeHTTPS,
+			want: []DNSRecord{{Name: "example.com.", Type: DNSTypeHTTPS, TTL: 10 * time.Second, Data: &DNSSVCBData{
+				Priority: 1,
+				Target:   "svc.example.",
+				Params:   []DNSSVCParam{{Key: 1, Value: []byte{2, 'h', '2'}}, {Key: 3, Value: []byte{0x01, 0xbb}}},
+			}}},
+		},
+		{
+			name: "example.com", typ: 99,
+			want: []DNSRecord{{Name: "example.com.", Type: 99, TTL: 10 * time.Second, Data: &DNSUnknownData{Data: []byte{1, 2, 3}}}},
+		},
+	} {
+		fake := fakeDNSServer{
+			rh: func(_, _ string, q dnsmessage.Message, _ time.Time) (dnsmessage.Message, error) {
+				var rrs []dnsmessage.Resource
+				for _, rr := range answers {
+					// Keep the alias the way a recursive resolver would.
+					if rr.Header.Type == q.Questions[0].Type || rr.Header.Type == dnsmessage.TypeCNAME {
+						rrs = append(rrs, rr)
+					}
+				}
+				return dnsmessage.Message{
+					Header: dnsmessage.Header{
+						ID:                 q.Header.ID,
+						Response:           true,
+						RecursionAvailable: true,
+					},
+					Questions: q.Questions,
+					Answers:   rrs,
+				}, nil
+			},
+			authenticData: tt.ad,
+		}
+		r := Resolver{PreferGo: true, Dial: fake.DialContext}
+		resp, err := r.LookupRecords(context.Background(), tt.name, tt.typ)
+		if err != nil {
+			t.Errorf("LookupRecords(%q, %v): %v", tt.name, tt.typ, err)
+			continue
+		}
+		if !reflect.DeepEqual(resp.Records, tt.want) {
+			t.Errorf("LookupRecords(%q, %v) = %+v; want %+v", tt.name, tt.typ, resp.Records, tt.want)
+		}
+		if resp.Authenticated != tt.ad {
+			t.Errorf("LookupRecords(%q, %v).Authenticated = %v; want %v", tt.name, tt.typ, resp.Authenticated, tt.ad)
+		}
+	}
+}
+
+func TestLookupRecordsInvalidData(t *testing.T) {
+	for _, tt := range []struct {
+		typ  DNSType
+		data []byte
+	}{
+		{DNSTypeCAA, []byte{0, 5, 'i', 's'}},
+		{DNSTypeTLSA, []byte{3, 1}},
+		{DNSTypeSVCB, []byte{0, 1, 3, 's', 'v'}},
+		{DNSTypeSVCB, []byte{0, 1, 0, 0, 1, 0, 3, 2}},
+	} {
+		fake := fakeDNSServer{
+			rh: func(_, _ string, q dnsmessage.Message, _ time.Time) (dnsmessage.Message, error) {
+				return dnsmessage.Message{
+					Header: dnsmessage.Header{
+						ID:                 q.Header.ID,
+						Response:           true,
+						RecursionAvailable: true,
+					},
+					Questions: q.Questions,
+					Answers: []dnsmessage.Resource{{
+						Header: dnsmessage.ResourceHeader{Name: q.Questions[0].Name, Type: q.Questions[0].Type, Class: dnsmessage.ClassINET},
+						Body:   &dnsmessage.UnknownResource{Type: q.Questions[0].Type, Data: tt.data},
+					}},
+				}, nil
+			},
+		}
+		r := Resolver{PreferGo: true, Dial: fake.DialContext}
+		_, err := r.LookupRecords(context.Background(), "example.com", tt.typ)
+		if de, ok := err.(*DNSError); !ok || de.Err != "cannot unmarshal DNS message" {
+			t.Errorf("LookupRecords(%v, %x): got %v; want cannot unmarshal DNS message", tt.typ, tt.data, err)
+		}
+	}
+}
+
+func TestLookupRecordsMalformedNames(t *testing.T) {
+	fake := fakeDNSServer{
+		rh: func(_, _ string, q dnsmessage.Message, _ time.Time) (dnsmessage.Message, error) {
+			return dnsmessage.Message{
+				Header: dnsmessage.Header{
+					ID:                 q.Header.ID,
+					Response:           true,
+					RecursionAvailable: true,
+				},
+				Questions: q.Questions,
+				Answers: []dnsmessage.Resource{
+					{
+						Header: dnsmessage.ResourceHeader{Name: q.Questions[0].Name, Type: dnsmessage.TypeMX, Class: dnsmessage.ClassINET},
+						Body:   &dnsmessage.MXResource{Pref: 10, MX: mustNewName("mx.example.com.")},
+					},
+					{
+						Header: dnsmessage.ResourceHeader{Name: q.Questions[0].Name, Type: dnsmessage.TypeMX, Class: dnsmessage.ClassINET},
+						Body:   &dnsmessage.MXResource{Pref: 20, MX: mustNewName("<html>.example.com.")},
+					},
+				},
+			}, nil
+		},
+	}
+	r := Resolver{PreferGo: true, Dial: fake.DialContext}
+	resp, err := r.LookupRecords(context.Background(), "example.com", DNSTypeMX)
+	if de, ok := err.(*DNSError); !ok || de.Err != errMalformedDNSRecordsDetail {
+		t.Fatalf("LookupRecords: got %v; want %s", err, errMalformedDNSRecordsDetail)
+	}
+	want := []DNSRecord{{Name: "example.com.", Type: DNSTypeMX, Data: &MX{Host: "mx.example.com.", Pref: 10}}}
+	if !reflect.DeepEqual(resp.Records, want) {
+		t.Errorf("LookupRecords = %+v; want %+v", resp.Records, want)
+	}
+}
+
+func TestNewRequestAuthenticData(t *testing.T) {
+	_, udpReq, tcpReq, err := newRequest(mustQuestion("example.com.", dnsmessage.TypeA, dnsmessage.ClassINET))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !authenticData(udpReq) || !authenticData(tcpReq[2:]) {
+		t.Error("query does not have the AD bit set")
+	}
+	req, err := newPaddedRequest(mustQuestion("example.com.", dnsmessage.TypeA, dnsmessage.ClassINET))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !authenticData(req) {
+		t.Error("padded query does not have the AD bit set")
+	}
+}
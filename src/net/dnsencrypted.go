@@ -0,0 +1,642 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Encrypted DNS transports: DNS over TLS (RFC 7858) and
+// DNS over HTTPS (RFC 8484).
+
+package net
+
+import (
+	"context"
+	"errors"
+	"internal/bytealg"
+	"internal/itoa"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// EncryptedDNS configures Go's built-in resolver to send queries using
+// DNS over TLS (RFC 7858) or DNS over HTTPS (RFC 8484) instead of
+// unencrypted UDP and TCP.
+//
+// Package net does not implement TLS itself, so DialTLS must be set,
+// typically to the DialContext method of a crypto/tls.Dialer.
+//
+// A connection to each server is kept open and reused by later queries,
+// and concurrent queries are pipelined on it without waiting for earlier
+// responses. Every query carries an EDNS(0) Padding option (RFC 7830)
+// that pads it to a multiple of 128 bytes, as recommended by RFC 8467,
+// to hide the length of the name being looked up.
+//
+// An EncryptedDNS must not be copied after first use.
+type EncryptedDNS struct {
+	// Servers lists the name servers to query, in the order in which
+	// they are tried. Each entry is either "tls://host[:port]" for
+	// DNS over TLS, where port defaults to 853, or a URL of the form
+	// "https://host[:port][/path]" for DNS over HTTPS, where port
+	// defaults to 443 and path to "/dns-query".
+	//
+	// The name servers listed in resolv.conf are not used. Other
+	// options from resolv.conf, such as the search list, the timeout,
+	// the number of attempts and rotation, still apply.
+	Servers []string
+
+	// DialTLS dials a TLS connection to address, which is the host:port
+	// pair taken from a Servers entry. The returned Conn is expected to
+	// have authenticated the server, or to do so before the first Read
+	// or Write returns.
+	//
+	// The host in address may be a host name, in which case DialTLS
+	// must not resolve it with the Resolver using this EncryptedDNS.
+	// For DNS over HTTPS, the connection carries HTTP/1.1, so it must
+	// not negotiate any other application protocol.
+	DialTLS func(ctx context.Context, network, address string) (Conn, error)
+
+	mu    sync.Mutex
+	conns map[string]*encryptedDNSConn // keyed by Servers entry
+}
+
+// CloseIdleConnections closes any connections to the name servers that
+// have no queries in flight.
+func (e *EncryptedDNS) CloseIdleConnections() {
+	e.mu.Lock()
+	var idle []*encryptedDNSConn
+	for _, c := range e.conns {
+		if c.idle() {
+			idle = append(idle, c)
+		}
+	}
+	e.mu.Unlock()
+	for _, c := range idle {
+		c.fail(errEncryptedDNSConnClosed)
+	}
+}
+
+var (
+	errEncryptedDNSConnClosed    = errors.New("encrypted DNS connection closed")
+	errNoEncryptedDNSDialer      = errors.New("EncryptedDNS.DialTLS is nil")
+	errNoEncryptedDNSServers     = errors.New("no encrypted DNS servers configured")
+	errInvalidEncryptedDNSServer = errors.New("invalid encrypted DNS server")
+	errInvalidDoHResponse        = errors.New("invalid DNS over HTTPS response")
+	errEncryptedDNSUnsupported   = errors.New("encrypted DNS is not supported on this system")
+	errTooManyOutstandingQueries = errors.New("too many outstanding DNS queries")
+)
+
+func (r *Resolver) encryptedDNS() *EncryptedDNS {
+	if r == nil {
+		return nil
+	}
+	return r.EncryptedDNS
+}
+
+// checkEncryptedDNS returns an error for a lookup of name if r sets
+// EncryptedDNS. It is called by the resolvers of systems that can't
+// send encrypted queries, so that they don't send them in plain text.
+func (r *Resolver) checkEncryptedDNS(name string) error {
+	if r.encryptedDNS() != nil {
+		return &DNSError{Err: errEncryptedDNSUnsupported.Error(), Name: name}
+	}
+	return nil
+}
+
+// An encryptedDNSServer is a parsed EncryptedDNS.Servers entry.
+type encryptedDNSServer struct {
+	https bool
+	addr  string // host:port to dial
+	host  string // authority for the HTTP Host header
+	path  string // HTTP request path
+}
+
+func parseEncryptedDNSServer(s string) (*encryptedDNSServer, error) {
+	srv := &encryptedDNSServer{}
+	port := "853"
+	switch {
+	case stringsHasPrefix(s, "tls://"):
+		s = s[len("tls://"):]
+	case stringsHasPrefix(s, "https://"):
+		s = s[len("https://"):]
+		srv.https = true
+		port = "443"
+		srv.path = "/dns-query"
+		if i := bytealg.IndexByteString(s, '/'); i >= 0 {
+			if i+1 < len(s) {
+				srv.path = s[i:]
+			}
+			s = s[:i]
+		}
+	default:
+		return nil, errInvalidEncryptedDNSServer
+	}
+	if s == "" || bytealg.IndexByteString(s, '/') >= 0 {
+		return nil, errInvalidEncryptedDNSServer
+	}
+	srv.host = s
+	if _, _, err := SplitHostPort(s); err == nil {
+		srv.addr = s
+	} else {
+		// A literal IPv6 address without a port must still be bracketed.
+		host := s
+		if host[0] == '[' && host[len(host)-1] == ']' {
+			host = host[1 : len(host)-1]
+		} else if bytealg.IndexByteString(host, ':') >= 0 {
+			return nil, errInvalidEncryptedDNSServer
+		}
+		srv.addr = JoinHostPort(host, port)
+	}
+	return srv, nil
+}
+
+// getConn returns an open connection to server, dialing a new one if
+// there is none. It reports whether the connection was already open.
+func (e *EncryptedDNS) getConn(ctx context.Context, server string, srv *encryptedDNSServer) (c *encryptedDNSConn, reused bool, err error) {
+	e.mu.Lock()
+	c = e.conns[server]
+	e.mu.Unlock()
+	if c != nil && !c.failed() {
+		return c, true, nil
+	}
+
+	if e.DialTLS == nil {
+		return nil, false, errNoEncryptedDNSDialer
+	}
+	nc, err := e.DialTLS(ctx, "tcp", srv.addr)
+	if err != nil {
+		return nil, false, mapErr(err)
+	}
+	c = &encryptedDNSConn{
+		e:      e,
+		server: server,
+		srv:    srv,
+		c:      nc,
+	}
+	if !srv.https {
+		c.pending = make(map[uint16]chan encryptedDNSResult)
+	}
+
+	e.mu.Lock()
+	if old := e.conns[server]; old != nil && !old.failed() {
+		// Another query dialed a connection concurrently; use only one.
+		e.mu.Unlock()
+		nc.Close()
+		return old, true, nil
+	}
+	if e.conns == nil {
+		e.conns = make(map[string]*encryptedDNSConn)
+	}
+	e.conns[server] = c
+	e.mu.Unlock()
+
+	go c.readLoop()
+	return c, false, nil
+}
+
+// newPaddedRequest returns a query for q without the message ID, which
+// is filled in by encryptedDNSConn.roundTrip. The query includes an
+// EDNS(0) OPT record with a Padding option that brings the message
+// length to a multiple of 128 bytes (RFC 8467, Section 4.1).
+func newPaddedRequest(q dnsmessage.Question) ([]byte, error) {
+	const blockSize = 128
+	build := func(padding int) ([]byte, error) {
+		b := dnsmessage.NewBuilder(make([]byte, 0, 2*blockSize), dnsmessage.Header{RecursionDesired: true})
+		b.EnableCompression()
+		if err := b.StartQuestions(); err != nil {
+			return nil, err
+		}
+		if err := b.Question(q); err != nil {
+			return nil, err
+		}
+		if err := b.StartAdditionals(); err != nil {
+			return nil, err
+		}
+		// The UDP payload size is meaningless over a stream transport.
+		var rh dnsmessage.ResourceHeader
+		if err := rh.SetEDNS0(0xffff, dnsmessage.RCodeSuccess, false); err != nil {
+			return nil, err
+		}
+		opt := dnsmessage.OPTResource{
+			Options: []dnsmessage.Option{{Code: 12, Data: make([]byte, padding)}}, // RFC 7830
+		}
+		if err := b.OPTResource(rh, opt); err != nil {
+			return nil, err
+		}
+		return b.Finish()
+	}
+	msg, err := build(0)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(msg) % blockSize; n != 0 {
+		if msg, err = build(blockSize - n); err != nil {
+			return nil, err
+		}
+	}
+	// Ask for the AD bit in the response, see RFC 6840, Section 5.7.
+	msg[3] |= 0x20
+	return msg, nil
+}
+
+type encryptedDNSResult struct {
+	msg []byte
+	err error
+}
+
+// An encryptedDNSConn is a connection to a DNS over TLS or DNS over HTTPS
+// server that may carry several queries at once. Queries are written as
+// they are issued, and a separate goroutine reads the responses and
+// hands them to the waiting queries: by message ID for DNS over TLS,
+// where responses may arrive in any order (RFC 7766, Section 6.2.1.1),
+// and in request order for HTTP/1.1.
+type encryptedDNSConn struct {
+	e      *EncryptedDNS
+	server string
+	srv    *encryptedDNSServer
+	c      Conn
+
+	wmu sync.Mutex // serializes writes to c, and queue order with them
+
+	mu      sync.Mutex
+	pending map[uint16]chan encryptedDNSResult // DNS over TLS queries by ID
+	queue   []chan encryptedDNSResult          // DNS over HTTPS queries in order
+	err     error                              // set once c is no longer usable
+}
+
+// roundTrip sends the query msg, whose ID it sets, and waits for the
+// response. It returns the ID used for the query.
+func (c *encryptedDNSConn) roundTrip(ctx context.Context, msg []byte) (uint16, []byte, error) {
+	ch := make(chan encryptedDNSResult, 1)
+	var id uint16
+
+	c.wmu.Lock()
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		c.wmu.Unlock()
+		return 0, nil, err
+	}
+	if c.srv.https {
+		// RFC 8484, Section 4.1: the ID should be 0, to make responses
+		// more cache-friendly. Responses are matched by order instead.
+		c.queue = append(c.queue, ch)
+	} else {
+		if len(c.pending) >= 1<<16 {
+			c.mu.Unlock()
+			c.wmu.Unlock()
+			return 0, nil, errTooManyOutstandingQueries
+		}
+		for {
+			id = uint16(randInt())
+			if _, ok := c.pending[id]; !ok {
+				break
+			}
+		}
+		c.pending[id] = ch
+	}
+	c.mu.Unlock()
+
+	var req []byte
+	if c.srv.https {
+		req = c.srv.httpRequest(msg)
+	} else {
+		req = make([]byte, 2+len(msg))
+		req[0], req[1] = byte(len(msg)>>8), byte(len(msg))
+		copy(req[2:], msg)
+		req[2], req[3] = byte(id>>8), byte(id)
+	}
+	deadline, hasDeadline := ctx.Deadline()
+	if hasDeadline {
+		c.c.SetWriteDeadline(deadline)
+	}
+	_, err := c.c.Write(req)
+	if hasDeadline {
+		c.c.SetWriteDeadline(time.Time{})
+	}
+	c.wmu.Unlock()
+	if err != nil {
+		// A partial write leaves the stream in an unknown state.
+		c.fail(err)
+		return 0, nil, err
+	}
+
+	select {
+	case r := <-ch:
+		return id, r.msg, r.err
+	case <-ctx.Done():
+		if !c.srv.https {
+			c.mu.Lock()
+			if c.pending[id] == ch {
+				delete(c.pending, id)
+			}
+			c.mu.Unlock()
+		}
+		// An abandoned DNS over HTTPS query keeps its place in the
+		// queue, and its response is discarded when it arrives.
+		return 0, nil, ctx.Err()
+	}
+}
+
+// httpRequest returns an HTTP/1.1 POST request carrying msg
+// (RFC 8484, Section 4.1).
+func (srv *encryptedDNSServer) httpRequest(msg []byte) []byte {
+	req := make([]byte, 0, 192+len(msg))
+	req = append(req, "POST "...)
+	req = append(req, srv.path...)
+	req = append(req, " HTTP/1.1\r\nHost: "...)
+	req = append(req, srv.host...)
+	req = append(req, "\r\nContent-Type: application/dns-message\r\nAccept: application/dns-message\r\nContent-Length: "...)
+	req = append(req, itoa.Itoa(len(msg))...)
+	req = append(req, "\r\n\r\n"...)
+	return append(req, msg...)
+}
+
+// readLoop reads responses until the connection fails.
+func (c *encryptedDNSConn) readLoop() {
+	var err error
+	if c.srv.https {
+		err = c.readHTTPResponses()
+	} else {
+		err = c.readTLSResponses()
+	}
+	c.fail(err)
+}
+
+func (c *encryptedDNSConn) readTLSResponses() error {
+	var hdr [2]byte
+	for {
+		if _, err := io.ReadFull(c.c, hdr[:]); err != nil {
+			return err
+		}
+		msg := make([]byte, int(hdr[0])<<8|int(hdr[1]))
+		if _, err := io.ReadFull(c.c, msg); err != nil {
+			return err
+		}
+		if len(msg) < 2 {
+			continue
+		}
+		id := uint16(msg[0])<<8 | uint16(msg[1])
+		c.mu.Lock()
+		ch := c.pending[id]
+		delete(c.pending, id)
+		c.mu.Unlock()
+		// Responses to abandoned or unknown queries are dropped.
+		if ch != nil {
+			ch <- encryptedDNSResult{msg: msg}
+		}
+	}
+}
+
+func (c *encryptedDNSConn) readHTTPResponses() error {
+	r := &dohResponseReader{r: c.c}
+	for {
+		msg, respErr, keepAlive, err := r.readResponse()
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		if len(c.queue) == 0 {
+			c.mu.Unlock()
+			return errInvalidDoHResponse
+		}
+		ch := c.queue[0]
+		c.queue = c.queue[1:]
+		c.mu.Unlock()
+		ch <- encryptedDNSResult{msg: msg, err: respErr}
+		if !keepAlive {
+			return errEncryptedDNSConnClosed
+		}
+	}
+}
+
+// fail marks the connection as unusable, closes it, and reports err to
+// any queries still waiting for a response.
+func (c *encryptedDNSConn) fail(err error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.err = err
+	pending, queue := c.pending, c.queue
+	c.pending, c.queue = nil, nil
+	c.mu.Unlock()
+
+	c.c.Close()
+	for _, ch := range pending {
+		ch <- encryptedDNSResult{err: err}
+	}
+	for _, ch := range queue {
+		ch <- encryptedDNSResult{err: err}
+	}
+
+	c.e.mu.Lock()
+	if c.e.conns[c.server] == c {
+		delete(c.e.conns, c.server)
+	}
+	c.e.mu.Unlock()
+}
+
+func (c *encryptedDNSConn) failed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err != nil
+}
+
+func (c *encryptedDNSConn) idle() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err == nil && len(c.pending) == 0 && len(c.queue) == 0
+}
+
+// A dohResponseReader reads the HTTP/1.1 responses to DNS over HTTPS
+// queries from a connection.
+type dohResponseReader struct {
+	r   io.Reader
+	buf []byte // unread buffered data
+	b   [4096]byte
+}
+
+// readResponse reads one response. If the response framing is valid but
+// the response isn't a DNS message, it returns a non-nil respErr and the
+// connection can still be used if keepAlive is true.
+func (r *dohResponseReader) readResponse() (msg []byte, respErr error, keepAlive bool, err error) {
+	var line []byte
+	var status int
+	for {
+		if line, err = r.readLine(); err != nil {
+			return nil, nil, false, err
+		}
+		// "HTTP/1.x NNN reason"
+		if len(line) < len("HTTP/1.x NNN") || !stringsHasPrefix(string(line), "HTTP/1.") || line[8] != ' ' {
+			return nil, nil, false, errInvalidDoHResponse
+		}
+		var i int
+		var ok bool
+		status, i, ok = dtoi(string(line[9:12]))
+		if !ok || i != 3 {
+			return nil, nil, false, errInvalidDoHResponse
+		}
+		keepAlive = line[7] == '1'
+		if status >= 200 {
+			break
+		}
+		// Skip informational responses and their headers.
+		for len(line) > 0 {
+			if line, err = r.readLine(); err != nil {
+				return nil, nil, false, err
+			}
+		}
+	}
+
+	contentLength := -1
+	chunked := false
+	contentType := ""
+	for {
+		if line, err = r.readLine(); err != nil {
+			return nil, nil, false, err
+		}
+		if len(line) == 0 {
+			break
+		}
+		i := bytealg.IndexByte(line, ':')
+		if i < 0 {
+			return nil, nil, false, errInvalidDoHResponse
+		}
+		key, value := string(line[:i]), string(trimSpace(line[i+1:]))
+		switch {
+		case stringsEqualFold(key, "Content-Length"):
+			n, i, ok := dtoi(value)
+			if !ok || i != len(value) || n > 0xffff {
+				return nil, nil, false, errInvalidDoHResponse
+			}
+			contentLength = n
+		case stringsEqualFold(key, "Transfer-Encoding"):
+			chunked = stringsEqualFold(value, "chunked")
+		case stringsEqualFold(key, "Content-Type"):
+			contentType = value
+		case stringsEqualFold(key, "Connection"):
+			if stringsEqualFold(value, "close") {
+				keepAlive = false
+			}
+		}
+	}
+
+	switch {
+	case chunked:
+		msg, err = r.readChunked()
+	case contentLength >= 0:
+		msg = make([]byte, contentLength)
+		_, err = r.readFull(msg)
+	default:
+		// The body is delimited by the end of the connection, which
+		// can't be told apart from a truncated message.
+		err = errInvalidDoHResponse
+	}
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	if status != 200 {
+		return nil, errors.New("DNS over HTTPS server returned status " + itoa.Itoa(status)), keepAlive, nil
+	}
+	if i := bytealg.IndexByteString(contentType, ';'); i >= 0 {
+		contentType = string(trimSpace([]byte(contentType[:i])))
+	}
+	if !stringsEqualFold(contentType, "application/dns-message") {
+		return nil, errInvalidDoHResponse, keepAlive, nil
+	}
+	return msg, nil, keepAlive, nil
+}
+
+func (r *dohResponseReader) readChunked() ([]byte, error) {
+	var msg []byte
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if i := bytealg.IndexByte(line, ';'); i >= 0 {
+			line = line[:i]
+		}
+		line = trimSpace(line)
+		n, i, ok := xtoi(string(line))
+		if !ok || i != len(line) || len(msg)+n > 0xffff {
+			return nil, errInvalidDoHResponse
+		}
+		if n == 0 {
+			break
+		}
+		msg = append(msg, make([]byte, n)...)
+		if _, err := r.readFull(msg[len(msg)-n:]); err != nil {
+			return nil, err
+		}
+		if line, err := r.readLine(); err != nil || len(line) > 0 {
+			return nil, errInvalidDoHResponse
+		}
+	}
+	// Skip the trailer.
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 {
+			return msg, nil
+		}
+	}
+}
+
+func (r *dohResponseReader) fill() error {
+	if len(r.buf) > 0 {
+		return nil
+	}
+	n, err := r.r.Read(r.b[:])
+	if n > 0 {
+		r.buf = r.b[:n]
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readLine reads a line terminated by CRLF or LF, without the line ending.
+func (r *dohResponseReader) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		if err := r.fill(); err != nil {
+			return nil, err
+		}
+		if i := bytealg.IndexByte(r.buf, '\n'); i >= 0 {
+			line = append(line, r.buf[:i]...)
+			r.buf = r.buf[i+1:]
+			break
+		}
+		line = append(line, r.buf...)
+		r.buf = nil
+		if len(line) > len(r.b) {
+			return nil, errInvalidDoHResponse
+		}
+	}
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return line, nil
+}
+
+func (r *dohResponseReader) readFull(b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		if err := r.fill(); err != nil {
+			return n, err
+		}
+		m := copy(b[n:], r.buf)
+		r.buf = r.buf[m:]
+		n += m
+	}
+	return n, nil
+}
@@ -0,0 +1,418 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package net
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeEncryptedDNSServer is a stand-in DNS over TLS or DNS over HTTPS
+// server. Its DialTLS method returns one end of a Pipe, without TLS, and
+// serves the other end on a new goroutine.
+type fakeEncryptedDNSServer struct {
+	t     *testing.T
+	https bool
+
+	chunked bool // use chunked transfer encoding for DNS over HTTPS
+	status  int  // HTTP status code to send, if not 200
+	oneShot bool // close the connection after one response
+
+	mu      sync.Mutex
+	batch   int // number of queries to read before answering
+	dials   int
+	queries [][]byte // raw DNS messages received
+	wg      sync.WaitGroup
+}
+
+func (s *fakeEncryptedDNSServer) DialTLS(ctx context.Context, network, address string) (Conn, error) {
+	if network != "tcp" {
+		s.t.Errorf("DialTLS network = %q, want tcp", network)
+	}
+	s.mu.Lock()
+	s.dials++
+	s.mu.Unlock()
+	c, sc := Pipe()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer sc.Close()
+		s.serve(sc)
+	}()
+	return c, nil
+}
+
+func (s *fakeEncryptedDNSServer) serve(c Conn) {
+	br := bufio.NewReader(c)
+	for {
+		s.mu.Lock()
+		batch := s.batch
+		s.mu.Unlock()
+		if batch == 0 {
+			batch = 1
+		}
+
+		var queries []dnsmessage.Message
+		for len(queries) < batch {
+			var msg []byte
+			var err error
+			if s.https {
+				msg, err = s.readHTTPRequest(br)
+			} else {
+				msg, err = s.readTLSQuery(br)
+			}
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.queries = append(s.queries, msg)
+			s.mu.Unlock()
+			var q dnsmessage.Message
+			if err := q.Unpack(msg); err != nil {
+				s.t.Errorf("invalid DNS query: %v", err)
+				return
+			}
+			queries = append(queries, q)
+		}
+
+		// DNS over TLS responses may arrive in any order, so answer
+		// them in reverse; HTTP/1.1 responses are always in order.
+		if !s.https {
+			for i, j := 0, len(queries)-1; i < j; i, j = i+1, j-1 {
+				queries[i], queries[j] = queries[j], queries[i]
+			}
+		}
+		for _, q := range queries {
+			resp := fakeEncryptedDNSResponse(q)
+			b, err := resp.Pack()
+			if err != nil {
+				s.t.Error(err)
+				return
+			}
+			if s.https {
+				err = s.writeHTTPResponse(c, b)
+			} else {
+				_, err = c.Write(append([]byte{byte(len(b) >> 8), byte(len(b))}, b...))
+			}
+			if err != nil || s.oneShot {
+				return
+			}
+		}
+	}
+}
+
+func (s *fakeEncryptedDNSServer) readTLSQuery(br *bufio.Reader) ([]byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(br, hdr[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, int(hdr[0])<<8|int(hdr[1]))
+	_, err := io.ReadFull(br, msg)
+	return msg, err
+}
+
+func (s *fakeEncryptedDNSServer) readHTTPRequest(br *bufio.Reader) ([]byte, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if line != "POST /dns-query HTTP/1.1\r\n" {
+		s.t.Errorf("unexpected request line %q", line)
+		return nil, errors.New("bad request")
+	}
+	contentLength := -1
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		key, value, _ := strings.Cut(line, ":")
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(key) {
+		case "host":
+			if value != "dns.example" {
+				s.t.Errorf("Host = %q, want dns.example", value)
+			}
+		case "content-type", "accept":
+			if value != "application/dns-message" {
+				s.t.Errorf("%s = %q, want application/dns-message", key, value)
+			}
+		case "content-length":
+			contentLength, err = strconv.Atoi(value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if contentLength < 0 {
+		s.t.Error("request without Content-Length")
+		return nil, errors.New("bad request")
+	}
+	msg := make([]byte, contentLength)
+	_, err = io.ReadFull(br, msg)
+	return msg, err
+}
+
+func (s *fakeEncryptedDNSServer) writeHTTPResponse(c Conn, body []byte) error {
+	status := s.status
+	if status == 0 {
+		status = 200
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "HTTP/1.1 %d Status\r\nContent-Type: application/dns-message\r\n", status)
+	if s.oneShot {
+		b.WriteString("Connection: close\r\n")
+	}
+	if s.chunked {
+		b.WriteString("Transfer-Encoding: chunked\r\n\r\n")
+		// Split the body in two chunks.
+		half := len(body) / 2
+		fmt.Fprintf(&b, "%x;ext=1\r\n%s\r\n", half, body[:half])
+		fmt.Fprintf(&b, "%X\r\n%s\r\n", len(body)-half, body[half:])
+		b.WriteString("0\r\nTrailer: x\r\n\r\n")
+	} else {
+		fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	_, err := io.WriteString(c, b.String())
+	return err
+}
+
+// fakeEncryptedDNSResponse answers TXT queries with the queried name.
+func fakeEncryptedDNSResponse(q dnsmessage.Message) dnsmessage.Message {
+	r := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:                 q.Header.ID,
+			Response:           true,
+			RecursionAvailable: true,
+		},
+		Questions: q.Questions,
+	}
+	if len(q.Questions) == 1 && q.Questions[0].Type == dnsmessage.TypeTXT {
+		r.Answers = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{
+				Name:  q.Questions[0].Name,
+				Type:  dnsmessage.TypeTXT,
+				Class: dnsmessage.ClassINET,
+			},
+			Body: &dnsmessage.TXTResource{TXT: []string{q.Questions[0].Name.String()}},
+		}}
+	}
+	return r
+}
+
+func newEncryptedDNSTestResolver(t *testing.T, s *fakeEncryptedDNSServer) *Resolver {
+	s.t = t
+	server := "tls://192.0.2.1"
+	if s.https {
+		server = "https://dns.example"
+	}
+	r := &Resolver{EncryptedDNS: &EncryptedDNS{
+		Servers: []string{server},
+		DialTLS: s.DialTLS,
+	}}
+	t.Cleanup(func() {
+		r.EncryptedDNS.CloseIdleConnections()
+		s.wg.Wait()
+	})
+	return r
+}
+
+func checkEncryptedDNSTXT(t *testing.T, r *Resolver, name string) {
+	t.Helper()
+	txt, err := r.LookupTXT(context.Background(), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txt) != 1 || txt[0] != name {
+		t.Fatalf("LookupTXT(%q) = %q, want [%q]", name, txt, name)
+	}
+}
+
+func TestEncryptedDNS(t *testing.T) {
+	tests := []struct {
+		name    string
+		https   bool
+		chunked bool
+	}{
+		{"TLS", false, false},
+		{"HTTPS", true, false},
+		{"HTTPS/chunked", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &fakeEncryptedDNSServer{https: tt.https, chunked: tt.chunked}
+			r := newEncryptedDNSTestResolver(t, s)
+			for _, name := range []string{"a.example.", "a-much-longer-name-to-change-the-query-size.example."} {
+				checkEncryptedDNSTXT(t, r, name)
+			}
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.dials != 1 {
+				t.Errorf("server dialed %d times, want 1", s.dials)
+			}
+			for _, q := range s.queries {
+				if len(q)%128 != 0 {
+					t.Errorf("query length %d is not padded to a multiple of 128", len(q))
+				}
+				var m dnsmessage.Message
+				if err := m.Unpack(q); err != nil {
+					t.Fatal(err)
+				}
+				if s.https && m.Header.ID != 0 {
+					t.Errorf("DNS over HTTPS query ID = %d, want 0", m.Header.ID)
+				}
+				if len(m.Additionals) != 1 || m.Additionals[0].Header.Type != dnsmessage.TypeOPT {
+					t.Fatalf("query has no OPT record: %v", m.Additionals)
+				}
+				opt := m.Additionals[0].Body.(*dnsmessage.OPTResource)
+				if len(opt.Options) != 1 || opt.Options[0].Code != 12 {
+					t.Errorf("query has no Padding option: %v", opt.Options)
+				}
+			}
+		})
+	}
+}
+
+func TestEncryptedDNSPipelining(t *testing.T) {
+	for _, https := range []bool{false, true} {
+		t.Run(fmt.Sprintf("https=%v", https), func(t *testing.T) {
+			s := &fakeEncryptedDNSServer{https: https}
+			r := newEncryptedDNSTestResolver(t, s)
+
+			// Open the connection first, so that the concurrent queries
+			// below don't race to dial.
+			checkEncryptedDNSTXT(t, r, "warmup.example.")
+
+			// The server now only answers after reading two queries, so
+			// the lookups below only succeed if they are pipelined.
+			s.mu.Lock()
+			s.batch = 2
+			s.mu.Unlock()
+
+			var wg sync.WaitGroup
+			for _, name := range []string{"one.example.", "two.example."} {
+				name := name
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					txt, err := r.LookupTXT(context.Background(), name)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if len(txt) != 1 || txt[0] != name {
+						t.Errorf("LookupTXT(%q) = %q, want [%q]", name, txt, name)
+					}
+				}()
+			}
+			wg.Wait()
+
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.dials != 1 {
+				t.Errorf("server dialed %d times, want 1", s.dials)
+			}
+		})
+	}
+}
+
+func TestEncryptedDNSReconnect(t *testing.T) {
+	for _, https := range []bool{false, true} {
+		t.Run(fmt.Sprintf("https=%v", https), func(t *testing.T) {
+			s := &fakeEncryptedDNSServer{https: https, oneShot: true}
+			r := newEncryptedDNSTestResolver(t, s)
+			for _, name := range []string{"one.example.", "two.example.", "three.example."} {
+				checkEncryptedDNSTXT(t, r, name)
+			}
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.dials != 3 {
+				t.Errorf("server dialed %d times, want 3", s.dials)
+			}
+		})
+	}
+}
+
+func TestEncryptedDNSHTTPError(t *testing.T) {
+	s := &fakeEncryptedDNSServer{https: true, status: 503}
+	r := newEncryptedDNSTestResolver(t, s)
+	_, err := r.LookupTXT(context.Background(), "a.example.")
+	var dnsErr *DNSError
+	if !errors.As(err, &dnsErr) {
+		t.Fatalf("LookupTXT error = %v, want a *DNSError", err)
+	}
+	if !strings.Contains(dnsErr.Err, "503") || dnsErr.Server != "https://dns.example" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// The connection remains usable after an error status.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dials != 1 {
+		t.Errorf("server dialed %d times, want 1", s.dials)
+	}
+}
+
+func TestEncryptedDNSNoServers(t *testing.T) {
+	r := &Resolver{EncryptedDNS: &EncryptedDNS{}}
+	_, err := r.LookupTXT(context.Background(), "a.example.")
+	if err == nil || !strings.Contains(err.Error(), errNoEncryptedDNSServers.Error()) {
+		t.Errorf("LookupTXT error = %v, want %v", err, errNoEncryptedDNSServers)
+	}
+}
+
+func TestParseEncryptedDNSServer(t *testing.T) {
+	tests := []struct {
+		in   string
+		want *encryptedDNSServer
+	}{
+		{"tls://192.0.2.1", &encryptedDNSServer{addr: "192.0.2.1:853", host: "192.0.2.1"}},
+		{"tls://192.0.2.1:8853", &encryptedDNSServer{addr: "192.0.2.1:8853", host: "192.0.2.1:8853"}},
+		{"tls://[2001:db8::1]", &encryptedDNSServer{addr: "[2001:db8::1]:853", host: "[2001:db8::1]"}},
+		{"tls://dns.example", &encryptedDNSServer{addr: "dns.example:853", host: "dns.example"}},
+		{"https://dns.example", &encryptedDNSServer{https: true, addr: "dns.example:443", host: "dns.example", path: "/dns-query"}},
+		{"https://dns.example/", &encryptedDNSServer{https: true, addr: "dns.example:443", host: "dns.example", path: "/dns-query"}},
+		{"https://dns.example:8443/q?x=1", &encryptedDNSServer{https: true, addr: "dns.example:8443", host: "dns.example:8443", path: "/q?x=1"}},
+		{"https://[2001:db8::1]/dns", &encryptedDNSServer{https: true, addr: "[2001:db8::1]:443", host: "[2001:db8::1]", path: "/dns"}},
+		{"192.0.2.1", nil},
+		{"udp://192.0.2.1", nil},
+		{"tls://", nil},
+		{"tls://192.0.2.1/dns-query", nil},
+		{"https:///dns-query", nil},
+		{"tls://2001:db8::1", nil},
+	}
+	for _, tt := range tests {
+		got, err := parseEncryptedDNSServer(tt.in)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("parseEncryptedDNSServer(%q) = %+v, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseEncryptedDNSServer(%q): %v", tt.in, err)
+			continue
+		}
+		if *got != *tt.want {
+			t.Errorf("parseEncryptedDNSServer(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
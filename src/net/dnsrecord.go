@@ -0,0 +1,371 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"errors"
+	"internal/itoa"
+	"net/netip"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// A DNSType is a DNS resource record type.
+type DNSType uint16
+
+// Resource record types that can be passed to Resolver.LookupRecords.
+// Other types are supported too, and their data is returned as
+// *DNSUnknownData.
+const (
+	DNSTypeA     DNSType = 1
+	DNSTypeNS    DNSType = 2
+	DNSTypeCNAME DNSType = 5
+	DNSTypeSOA   DNSType = 6
+	DNSTypePTR   DNSType = 12
+	DNSTypeMX    DNSType = 15
+	DNSTypeTXT   DNSType = 16
+	DNSTypeAAAA  DNSType = 28
+	DNSTypeSRV   DNSType = 33
+	DNSTypeTLSA  DNSType = 52
+	DNSTypeSVCB  DNSType = 64
+	DNSTypeHTTPS DNSType = 65
+	DNSTypeCAA   DNSType = 257
+)
+
+var dnsTypeNames = map[DNSType]string{
+	DNSTypeA:     "A",
+	DNSTypeNS:    "NS",
+	DNSTypeCNAME: "CNAME",
+	DNSTypeSOA:   "SOA",
+	DNSTypePTR:   "PTR",
+	DNSTypeMX:    "MX",
+	DNSTypeTXT:   "TXT",
+	DNSTypeAAAA:  "AAAA",
+	DNSTypeSRV:   "SRV",
+	DNSTypeTLSA:  "TLSA",
+	DNSTypeSVCB:  "SVCB",
+	DNSTypeHTTPS: "HTTPS",
+	DNSTypeCAA:   "CAA",
+}
+
+// String returns the mnemonic of t, or "TYPEnnn" (RFC 3597, Section 5)
+// if t has none.
+func (t DNSType) String() string {
+	if s, ok := dnsTypeNames[t]; ok {
+		return s
+	}
+	return "TYPE" + itoa.Uitoa(uint(t))
+}
+
+// A DNSResponse holds the result of Resolver.LookupRecords.
+type DNSResponse struct {
+	// Records holds the answer records of the requested type. Aliases
+	// followed by the name server are not included, but their targets
+	// appear as the Name of the records.
+	Records []DNSRecord
+
+	// Authenticated reports whether the name server set the
+	// Authentic Data (AD) bit, claiming that it validated the
+	// answer with DNSSEC (RFC 4035, Section 3.2.3). The claim is only
+	// as trustworthy as the name server and the path to it, such as a
+	// validating resolver on the local host or one reached with
+	// Resolver.EncryptedDNS.
+	Authenticated bool
+}
+
+// A DNSRecord is a DNS resource record.
+type DNSRecord struct {
+	Name string // owner name, fully qualified, like "example.com."
+	Type DNSType
+	TTL  time.Duration
+
+	// Data is the record data. Its dynamic type depends on Type:
+	//
+	//	A, AAAA      *DNSAddrData
+	//	NS           *NS
+	//	CNAME, PTR   *DNSNameData
+	//	SOA          *DNSSOAData
+	//	MX           *MX
+	//	TXT          *DNSTXTData
+	//	SRV          *SRV
+	//	TLSA         *DNSTLSAData
+	//	SVCB, HTTPS  *DNSSVCBData
+	//	CAA          *DNSCAAData
+	//	other types  *DNSUnknownData
+	Data DNSRecordData
+}
+
+// DNSRecordData is the type-specific data of a DNSRecord.
+type DNSRecordData interface {
+	isDNSRecordData()
+}
+
+// DNSAddrData is the data of an A or AAAA record.
+type DNSAddrData struct {
+	Addr netip.Addr
+}
+
+// DNSNameData is the data of a CNAME or PTR record.
+type DNSNameData struct {
+	Name string
+}
+
+// DNSSOAData is the data of an SOA record (RFC 1035, Section 3.3.13).
+type DNSSOAData struct {
+	NS      string
+	MBox    string
+	Serial  uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+	MinTTL  uint32
+}
+
+// DNSTXTData is the data of a TXT record. Unlike Resolver.LookupTXT,
+// the character-strings of the record are kept separate.
+type DNSTXTData struct {
+	TXT []string
+}
+
+// DNSTLSAData is the data of a TLSA record (RFC 6698, Section 2.1).
+type DNSTLSAData struct {
+	Usage        uint8
+	Selector     uint8
+	MatchingType uint8
+	CertData     []byte
+}
+
+// DNSSVCBData is the data of an SVCB or HTTPS record (RFC 9460,
+// Section 2.2). A Priority of zero denotes AliasMode.
+type DNSSVCBData struct {
+	Priority uint16
+	Target   string
+	Params   []DNSSVCParam
+}
+
+// A DNSSVCParam is a service parameter of an SVCB or HTTPS record, in
+// wire format.
+type DNSSVCParam struct {
+	Key   uint16
+	Value []byte
+}
+
+// DNSCAAData is the data of a CAA record (RFC 8659, Section 4.1).
+type DNSCAAData struct {
+	Flags uint8
+	Tag   string
+	Value string
+}
+
+// DNSUnknownData is the data of a record of a type that this package
+// does not decode, in wire format.
+type DNSUnknownData struct {
+	Data []byte
+}
+
+func (*DNSAddrData) isDNSRecordData()    {}
+func (*NS) isDNSRecordData()             {}
+func (*DNSNameData) isDNSRecordData()    {}
+func (*DNSSOAData) isDNSRecordData()     {}
+func (*MX) isDNSRecordData()             {}
+func (*DNSTXTData) isDNSRecordData()     {}
+func (*SRV) isDNSRecordData()            {}
+func (*DNSTLSAData) isDNSRecordData()    {}
+func (*DNSSVCBData) isDNSRecordData()    {}
+func (*DNSCAAData) isDNSRecordData()     {}
+func (*DNSUnknownData) isDNSRecordData() {}
+
+var errInvalidRecordData = errors.New("invalid DNS record data")
+
+// parseDNSRecords decodes the remaining answers of type qtype from p.
+func parseDNSRecords(p *dnsmessage.Parser, qtype dnsmessage.Type) ([]DNSRecord, error) {
+	var rrs []DNSRecord
+	for {
+		h, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			return rrs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if h.Type != qtype {
+			if err := p.SkipAnswer(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		rr := DNSRecord{
+			Name: h.Name.String(),
+			Type: DNSType(h.Type),
+			TTL:  time.Duration(h.TTL) * time.Second,
+		}
+		if rr.Data, err = parseDNSRecordData(p, h.Type); err != nil {
+			return nil, err
+		}
+		rrs = append(rrs, rr)
+	}
+}
+
+func parseDNSRecordData(p *dnsmessage.Parser, typ dnsmessage.Type) (DNSRecordData, error) {
+	switch typ {
+	case dnsmessage.TypeA:
+		r, err := p.AResource()
+		if err != nil {
+			return nil, err
+		}
+		return &DNSAddrData{Addr: netip.AddrFrom4(r.A)}, nil
+	case dnsmessage.TypeAAAA:
+		r, err := p.AAAAResource()
+		if err != nil {
+			return nil, err
+		}
+		return &DNSAddrData{Addr: netip.AddrFrom16(r.AAAA)}, nil
+	case dnsmessage.TypeNS:
+		r, err := p.NSResource()
+		if err != nil {
+			return nil, err
+		}
+		return &NS{Host: r.NS.String()}, nil
+	case dnsmessage.TypeCNAME:
+		r, err := p.CNAMEResource()
+		if err != nil {
+			return nil, err
+		}
+		return &DNSNameData{Name: r.CNAME.String()}, nil
+	case dnsmessage.TypePTR:
+		r, err := p.PTRResource()
+		if err != nil {
+			return nil, err
+		}
+		return &DNSNameData{Name: r.PTR.String()}, nil
+	case dnsmessage.TypeSOA:
+		r, err := p.SOAResource()
+		if err != nil {
+			return nil, err
+		}
+		return &DNSSOAData{
+			NS:      r.NS.String(),
+			MBox:    r.MBox.String(),
+			Serial:  r.Serial,
+			Refresh: r.Refresh,
+			Retry:   r.Retry,
+			Expire:  r.Expire,
+			MinTTL:  r.MinTTL,
+		}, nil
+	case dnsmessage.TypeMX:
+		r, err := p.MXResource()
+		if err != nil {
+			return nil, err
+		}
+		return &MX{Host: r.MX.String(), Pref: r.Pref}, nil
+	case dnsmessage.TypeTXT:
+		r, err := p.TXTResource()
+		if err != nil {
+			return nil, err
+		}
+		return &DNSTXTData{TXT: r.TXT}, nil
+	case dnsmessage.TypeSRV:
+		r, err := p.SRVResource()
+		if err != nil {
+			return nil, err
+		}
+		return &SRV{Target: r.Target.String(), Port: r.Port, Priority: r.Priority, Weight: r.Weight}, nil
+	}
+
+	// The data of any other type can't contain compressed names
+	// (RFC 3597, Section 4), so it can be decoded on its own.
+	r, err := p.UnknownResource()
+	if err != nil {
+		return nil, err
+	}
+	b := r.Data
+	switch DNSType(typ) {
+	case DNSTypeTLSA:
+		if len(b) < 3 {
+			return nil, errInvalidRecordData
+		}
+		return &DNSTLSAData{Usage: b[0], Selector: b[1], MatchingType: b[2], CertData: b[3:]}, nil
+	case DNSTypeSVCB, DNSTypeHTTPS:
+		if len(b) < 2 {
+			return nil, errInvalidRecordData
+		}
+		d := &DNSSVCBData{Priority: uint16(b[0])<<8 | uint16(b[1])}
+		var ok bool
+		if d.Target, b, ok = parseUncompressedName(b[2:]); !ok {
+			return nil, errInvalidRecordData
+		}
+		for len(b) > 0 {
+			if len(b) < 4 {
+				return nil, errInvalidRecordData
+			}
+			key, n := uint16(b[0])<<8|uint16(b[1]), int(b[2])<<8|int(b[3])
+			if len(b) < 4+n {
+				return nil, errInvalidRecordData
+			}
+			d.Params = append(d.Params, DNSSVCParam{Key: key, Value: b[4 : 4+n]})
+			b = b[4+n:]
+		}
+		return d, nil
+	case DNSTypeCAA:
+		if len(b) < 2 || len(b) < 2+int(b[1]) {
+			return nil, errInvalidRecordData
+		}
+		return &DNSCAAData{Flags: b[0], Tag: string(b[2 : 2+b[1]]), Value: string(b[2+b[1]:])}, nil
+	}
+	return &DNSUnknownData{Data: b}, nil
+}
+
+// parseUncompressedName decodes the uncompressed domain name at the start
+// of b, and returns it along with the rest of b.
+func parseUncompressedName(b []byte) (name string, rest []byte, ok bool) {
+	var s []byte
+	for {
+		if len(b) == 0 {
+			return "", nil, false
+		}
+		n := int(b[0])
+		if n == 0 {
+			b = b[1:]
+			break
+		}
+		if n > 63 || len(b) < 1+n {
+			return "", nil, false
+		}
+		s = append(s, b[1:1+n]...)
+		s = append(s, '.')
+		b = b[1+n:]
+		if len(s) > 254 {
+			return "", nil, false
+		}
+	}
+	if len(s) == 0 {
+		return ".", b, true
+	}
+	return string(s), b, true
+}
+
+// validDNSRecordNames reports whether the owner name of rr and any
+// domain names in its data are valid presentation-format domain names.
+func validDNSRecordNames(rr *DNSRecord) bool {
+	if !isDomainName(rr.Name) {
+		return false
+	}
+	switch d := rr.Data.(type) {
+	case *NS:
+		return isDomainName(d.Host)
+	case *DNSNameData:
+		return isDomainName(d.Name)
+	case *DNSSOAData:
+		return isDomainName(d.NS) && isDomainName(d.MBox)
+	case *MX:
+		return isDomainName(d.Host)
+	case *SRV:
+		return isDomainName(d.Target)
+	case *DNSSVCBData:
+		return isDomainName(d.Target)
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import "testing"
+
+func TestDNSTypeString(t *testing.T) {
+	for _, tt := range []struct {
+		typ  DNSType
+		want string
+	}{
+		{DNSTypeA, "A"},
+		{DNSTypeHTTPS, "HTTPS"},
+		{DNSTypeCAA, "CAA"},
+		{0, "TYPE0"},
+		{65280, "TYPE65280"},
+	} {
+		if got := tt.typ.String(); got != tt.want {
+			t.Errorf("DNSType(%d).String() = %q; want %q", uint16(tt.typ), got, tt.want)
+		}
+	}
+}
+
+func TestParseUncompressedName(t *testing.T) {
+	for _, tt := range []struct {
+		in   []byte
+		name string
+		rest []byte
+		ok   bool
+	}{
+		{[]byte{0}, ".", []byte{}, true},
+		{[]byte{3, 'f', 'o', 'o', 0, 1}, "foo.", []byte{1}, true},
+		{[]byte{3, 'f', 'o', 'o', 3, 'c', 'o', 'm', 0}, "foo.com.", []byte{}, true},
+		{[]byte{}, "", nil, false},
+		{[]byte{3, 'f', 'o'}, "", nil, false},
+		{[]byte{3, 'f', 'o', 'o'}, "", nil, false},
+		{[]byte{0xc0, 0x0c}, "", nil, false}, // compression pointer
+	} {
+		name, rest, ok := parseUncompressedName(tt.in)
+		if name != tt.name || string(rest) != string(tt.rest) || ok != tt.ok {
+			t.Errorf("parseUncompressedName(%x) = %q, %x, %v; want %q, %x, %v", tt.in, name, rest, ok, tt.name, tt.rest, tt.ok)
+		}
+	}
+}
@@ -6,6 +6,7 @@ package net_test
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"log"
@@ -76,6 +77,29 @@ func ExampleDialer_unix() {
 	}
 }
 
+func ExampleEncryptedDNS() {
+	// Package net doesn't implement TLS, so EncryptedDNS needs a TLS
+	// dialer. The server addresses below are IP literals, so dialing
+	// them doesn't itself need a DNS lookup, and the certificates of
+	// the servers are verified against those addresses.
+	d := &tls.Dialer{}
+	r := &net.Resolver{
+		EncryptedDNS: &net.EncryptedDNS{
+			Servers: []string{
+				"https://[2001:db8::53]/dns-query",
+				"tls://192.0.2.53",
+			},
+			DialTLS: d.DialContext,
+		},
+	}
+
+	addrs, err := r.LookupHost(context.Background(), "golang.org")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(addrs)
+}
+
 func ExampleIPv4() {
 	fmt.Println(net.IPv4(8, 8, 8, 8))
 
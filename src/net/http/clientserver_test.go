@@ -19,7 +19,9 @@ import (
 	"net"
 	. "net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
 	"net/http/httputil"
+	"net/textproto"
 	"net/url"
 	"os"
 	"reflect"
@@ -1616,3 +1618,55 @@ func testIdentityTransferEncoding(t *testing.T, h2 bool) {
 		t.Errorf("got response body = %q; want %q", got, want)
 	}
 }
+
+func TestWriteHeader1xx_h1(t *testing.T) { testWriteHeader1xx(t, h1Mode) }
+func TestWriteHeader1xx_h2(t *testing.T) { testWriteHeader1xx(t, h2Mode) }
+
+func testWriteHeader1xx(t *testing.T, h2 bool) {
+	defer afterTest(t)
+	cst := newClientServerTest(t, h2, HandlerFunc(func(w ResponseWriter, r *Request) {
+		h := w.Header()
+		h.Add("Link", "</style.css>; rel=preload; as=style")
+		h.Add("Link", "</script.js>; rel=preload; as=script")
+		w.WriteHeader(StatusEarlyHints)
+
+		h.Add("Link", "</foo.js>; rel=preload; as=script")
+		w.WriteHeader(StatusEarlyHints)
+
+		w.Write([]byte("stuff"))
+	}))
+	defer cst.close()
+
+	var got []string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			got = append(got, fmt.Sprintf("%d %q", code, header["Link"]))
+			return nil
+		},
+	}
+	req, _ := NewRequest("GET", cst.ts.URL, nil)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	res, err := cst.c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	want := []string{
+		`103 ["</style.css>; rel=preload; as=style" "</script.js>; rel=preload; as=script"]`,
+		`103 ["</style.css>; rel=preload; as=style" "</script.js>; rel=preload; as=script" "</foo.js>; rel=preload; as=script"]`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("1xx responses = %q; want %q", got, want)
+	}
+	if res.StatusCode != StatusOK {
+		t.Errorf("StatusCode = %d; want %d", res.StatusCode, StatusOK)
+	}
+	// The header map isn't cleared by a 1xx response.
+	if n := len(res.Header["Link"]); n != 3 {
+		t.Errorf("final response has %d Link headers; want 3", n)
+	}
+	if body, _ := io.ReadAll(res.Body); string(body) != "stuff" {
+		t.Errorf("body = %q; want %q", body, "stuff")
+	}
+}
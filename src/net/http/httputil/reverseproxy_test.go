@@ -16,7 +16,9 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httptrace"
 	"net/http/internal/ascii"
+	"net/textproto"
 	"net/url"
 	"os"
 	"reflect"
@@ -1537,3 +1539,156 @@ func TestJoinURLPath(t *testing.T) {
 		}
 	}
 }
+
+func TestReverseProxyRewrite(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, h := range []string{"Forwarded", "X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto", "X-Added", "User-Agent"} {
+			w.Header()["Got-"+h] = r.Header[h]
+		}
+		w.Header().Set("Got-Host", r.Host)
+		w.Header().Set("Got-Path", r.URL.Path)
+	}))
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL + "/base")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyHandler := &ReverseProxy{
+		Rewrite: func(r *ProxyRequest) {
+			r.SetURL(backendURL)
+			r.SetXForwarded()
+			// X-Added is listed in the client's Connection header, but
+			// hop-by-hop headers are removed before Rewrite is called.
+			r.Out.Header.Set("X-Added", "from-rewrite")
+		},
+	}
+	frontend := httptest.NewServer(proxyHandler)
+	defer frontend.Close()
+
+	req, _ := http.NewRequest("GET", frontend.URL+"/dir", nil)
+	req.Host = "some-name"
+	req.Header.Set("Connection", "X-Added")
+	req.Header.Set("Forwarded", "for=evil")
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	req.Header.Set("X-Forwarded-Host", "evil.example")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("User-Agent", "test-agent")
+	res, err := frontend.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	res.Body.Close()
+
+	for h, want := range map[string][]string{
+		"Forwarded":         nil,
+		"X-Forwarded-For":   {"127.0.0.1"},
+		"X-Forwarded-Host":  {"some-name"},
+		"X-Forwarded-Proto": {"http"},
+		"X-Added":           {"from-rewrite"},
+		"User-Agent":        {"test-agent"},
+		"Host":              {backendURL.Host},
+		"Path":              {"/base/dir"},
+	} {
+		if got := res.Header["Got-"+h]; !reflect.DeepEqual(got, want) {
+			t.Errorf("backend got %s %q; want %q", h, got, want)
+		}
+	}
+}
+
+func TestReverseProxyRewriteAndDirector(t *testing.T) {
+	for _, p := range []*ReverseProxy{
+		{},
+		{Rewrite: func(*ProxyRequest) {}, Director: func(*http.Request) {}},
+	} {
+		var gotErr error
+		p.ErrorHandler = func(w http.ResponseWriter, _ *http.Request, err error) {
+			gotErr = err
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if gotErr == nil || rec.Code != http.StatusBadGateway {
+			t.Errorf("ServeHTTP with Rewrite=%v Director=%v: code %d, error %v; want 502 and an error",
+				p.Rewrite != nil, p.Director != nil, rec.Code, gotErr)
+		}
+	}
+}
+
+func TestSetXForwardedAppend(t *testing.T) {
+	in := httptest.NewRequest("GET", "https://example.com/", nil)
+	in.RemoteAddr = "192.0.2.1:1234"
+	in.Header.Set("X-Forwarded-For", "198.51.100.1")
+	pr := &ProxyRequest{In: in, Out: in.Clone(context.Background())}
+	pr.Out.Header.Del("X-Forwarded-For")
+	pr.Out.Header["X-Forwarded-For"] = pr.In.Header["X-Forwarded-For"]
+	pr.SetXForwarded()
+	if got, want := pr.Out.Header.Get("X-Forwarded-For"), "198.51.100.1, 192.0.2.1"; got != want {
+		t.Errorf("X-Forwarded-For = %q; want %q", got, want)
+	}
+	if got, want := pr.Out.Header.Get("X-Forwarded-Proto"), "https"; got != want {
+		t.Errorf("X-Forwarded-Proto = %q; want %q", got, want)
+	}
+	if in.Header.Get("X-Forwarded-Proto") != "" {
+		t.Error("SetXForwarded modified the inbound request")
+	}
+}
+
+func TestReverseProxy1xxResponses(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Add("Link", "</style.css>; rel=preload; as=style")
+		h.Add("Link", "</script.js>; rel=preload; as=script")
+		w.WriteHeader(http.StatusEarlyHints)
+
+		h.Add("Link", "</foo.js>; rel=preload; as=script")
+		w.WriteHeader(http.StatusProcessing)
+
+		w.Write([]byte("Hello"))
+	}))
+	defer backend.Close()
+	backendURL, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyHandler := NewSingleHostReverseProxy(backendURL)
+	proxyHandler.ErrorLog = log.New(io.Discard, "", 0) // quiet for tests
+	frontend := httptest.NewServer(proxyHandler)
+	defer frontend.Close()
+
+	links := []string{
+		"</style.css>; rel=preload; as=style",
+		"</script.js>; rel=preload; as=script",
+		"</foo.js>; rel=preload; as=script",
+	}
+	var got []string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			got = append(got, fmt.Sprintf("%d %q", code, header["Link"]))
+			return nil
+		},
+	}
+	req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), "GET", frontend.URL, nil)
+	res, err := frontend.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer res.Body.Close()
+
+	want := []string{
+		fmt.Sprintf("%d %q", http.StatusEarlyHints, links[:2]),
+		fmt.Sprintf("%d %q", http.StatusProcessing, links),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("1xx responses = %q; want %q", got, want)
+	}
+	// The proxy must not leak the 1xx headers into the final response,
+	// which carries the backend's own header map.
+	if !reflect.DeepEqual(res.Header["Link"], links) {
+		t.Errorf("final Link headers = %q; want %q", res.Header["Link"], links)
+	}
+
+	body, _ := io.ReadAll(res.Body)
+	if string(body) != "Hello" {
+		t.Errorf("Read body %q; want Hello", body)
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package http_test
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	. "net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResponseControllerFlush_h1(t *testing.T) { testResponseControllerFlush(t, h1Mode) }
+func TestResponseControllerFlush_h2(t *testing.T) { testResponseControllerFlush(t, h2Mode) }
+func testResponseControllerFlush(t *testing.T, h2 bool) {
+	defer afterTest(t)
+	continuec := make(chan struct{})
+	cst := newClientServerTest(t, h2, HandlerFunc(func(w ResponseWriter, r *Request) {
+		ctl := NewResponseController(w)
+		w.Write([]byte("one"))
+		if err := ctl.Flush(); err != nil {
+			t.Errorf("ctl.Flush() = %v, want nil", err)
+			return
+		}
+		<-continuec
+		w.Write([]byte("two"))
+	}))
+	defer cst.close()
+
+	res, err := cst.c.Get(cst.ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected connection error: %v", err)
+	}
+	defer res.Body.Close()
+
+	buf := make([]byte, 16)
+	n, err := res.Body.Read(buf)
+	close(continuec)
+	if err != nil || string(buf[:n]) != "one" {
+		t.Fatalf("Body.Read = %q, %v, want %q, nil", string(buf[:n]), err, "one")
+	}
+
+	got, err := io.ReadAll(res.Body)
+	if err != nil || string(got) != "two" {
+		t.Fatalf("Body.Read = %q, %v, want %q, nil", string(got), err, "two")
+	}
+}
+
+func TestResponseControllerHijack_h1(t *testing.T) { testResponseControllerHijack(t, h1Mode) }
+func TestResponseControllerHijack_h2(t *testing.T) { testResponseControllerHijack(t, h2Mode) }
+func testResponseControllerHijack(t *testing.T, h2 bool) {
+	defer afterTest(t)
+	const header = "X-Header"
+	const value = "set"
+	cst := newClientServerTest(t, h2, HandlerFunc(func(w ResponseWriter, r *Request) {
+		ctl := NewResponseController(w)
+		c, _, err := ctl.Hijack()
+		if h2 {
+			if !errors.Is(err, ErrNotSupported) {
+				t.Errorf("ctl.Hijack = %v, want ErrNotSupported", err)
+			}
+			w.Header().Set(header, value)
+			return
+		}
+		if err != nil {
+			t.Errorf("ctl.Hijack = _, _, %v, want _, _, nil", err)
+			return
+		}
+		fmt.Fprintf(c, "HTTP/1.0 200 OK\r\n%v: %v\r\nContent-Length: 0\r\n\r\n", header, value)
+		c.Close()
+	}))
+	defer cst.close()
+
+	res, err := cst.c.Get(cst.ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if got, want := res.Header.Get(header), value; got != want {
+		t.Errorf("response header %q = %q, want %q", header, got, want)
+	}
+}
+
+func TestResponseControllerSetPastWriteDeadline_h1(t *testing.T) {
+	testResponseControllerSetPastWriteDeadline(t, h1Mode)
+}
+func TestResponseControllerSetPastWriteDeadline_h2(t *testing.T) {
+	testResponseControllerSetPastWriteDeadline(t, h2Mode)
+}
+func testResponseControllerSetPastWriteDeadline(t *testing.T, h2 bool) {
+	defer afterTest(t)
+	donec := make(chan struct{})
+	cst := newClientServerTest(t, h2, HandlerFunc(func(w ResponseWriter, r *Request) {
+		defer close(donec)
+		ctl := NewResponseController(w)
+		w.Write([]byte("one"))
+		if err := ctl.Flush(); err != nil {
+			t.Errorf("before setting deadline: ctl.Flush() = %v, want nil", err)
+		}
+		if err := ctl.SetWriteDeadline(time.Now().Add(-10 * time.Second)); err != nil {
+			t.Errorf("ctl.SetWriteDeadline() = %v, want nil", err)
+		}
+
+		// The HTTP/2 server resets the stream asynchronously, so
+		// keep writing until an error shows up.
+		const maxWrites = 1 << 20
+		for i := 0; i < maxWrites; i++ {
+			w.Write([]byte("two"))
+			if err := ctl.Flush(); err != nil {
+				return
+			}
+		}
+		t.Errorf("no error after writing %v times past the write deadline", maxWrites)
+	}))
+	defer cst.close()
+
+	res, err := cst.c.Get(cst.ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected connection error: %v", err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 3))
+	if string(b) != "one" {
+		t.Errorf("unexpected body: %q", string(b))
+	}
+	<-donec
+}
+
+func TestResponseControllerExtendWriteTimeout_h1(t *testing.T) {
+	testResponseControllerExtendWriteTimeout(t, h1Mode)
+}
+func TestResponseControllerExtendWriteTimeout_h2(t *testing.T) {
+	testResponseControllerExtendWriteTimeout(t, h2Mode)
+}
+func testResponseControllerExtendWriteTimeout(t *testing.T, h2 bool) {
+	defer afterTest(t)
+	const writeTimeout = 50 * time.Millisecond
+	cst := newClientServerTest(t, h2, HandlerFunc(func(w ResponseWriter, r *Request) {
+		ctl := NewResponseController(w)
+		if err := ctl.SetWriteDeadline(time.Now().Add(1 * time.Minute)); err != nil {
+			t.Errorf("ctl.SetWriteDeadline() = %v, want nil", err)
+		}
+		time.Sleep(4 * writeTimeout)
+		w.Write([]byte("body"))
+	}), func(ts *httptest.Server) {
+		ts.Config.WriteTimeout = writeTimeout
+	})
+	defer cst.close()
+
+	res, err := cst.c.Get(cst.ts.URL)
+	if err != nil {
+		t.Fatalf("unexpected connection error: %v", err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil || string(b) != "body" {
+		t.Errorf("reading body = %q, %v, want %q, nil", string(b), err, "body")
+	}
+}
+
+func TestResponseControllerSetReadDeadline_h1(t *testing.T) {
+	testResponseControllerSetReadDeadline(t, h1Mode)
+}
+func TestResponseControllerSetReadDeadline_h2(t *testing.T) {
+	testResponseControllerSetReadDeadline(t, h2Mode)
+}
+func testResponseControllerSetReadDeadline(t *testing.T, h2 bool) {
+	defer afterTest(t)
+	cst := newClientServerTest(t, h2, HandlerFunc(func(w ResponseWriter, r *Request) {
+		ctl := NewResponseController(w)
+		if err := ctl.SetReadDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+			t.Errorf("ctl.SetReadDeadline() = %v, want nil", err)
+		}
+		_, err := io.ReadAll(r.Body)
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("reading request body = %v, want os.ErrDeadlineExceeded", err)
+		}
+	}))
+	defer cst.close()
+
+	// The request body never ends, so the handler's reads
+	// can only be stopped by the deadline.
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	res, err := cst.c.Post(cst.ts.URL, "text/plain", pr)
+	if err == nil {
+		res.Body.Close()
+	}
+}
+
+func TestResponseControllerEnableFullDuplex_h1(t *testing.T) {
+	testResponseControllerEnableFullDuplex(t, h1Mode)
+}
+func TestResponseControllerEnableFullDuplex_h2(t *testing.T) {
+	testResponseControllerEnableFullDuplex(t, h2Mode)
+}
+func testResponseControllerEnableFullDuplex(t *testing.T, h2 bool) {
+	defer afterTest(t)
+	cst := newClientServerTest(t, h2, HandlerFunc(func(w ResponseWriter, r *Request) {
+		ctl := NewResponseController(w)
+		err := ctl.EnableFullDuplex()
+		if h2 {
+			if !errors.Is(err, ErrNotSupported) {
+				t.Errorf("ctl.EnableFullDuplex() = %v, want ErrNotSupported", err)
+			}
+		} else if err != nil {
+			t.Errorf("ctl.EnableFullDuplex() = %v, want nil", err)
+		}
+		w.WriteHeader(200)
+		ctl.Flush()
+		// Echo each line of the request body as it arrives.
+		br := bufio.NewReader(r.Body)
+		for {
+			line, err := br.ReadString('\n')
+			if line != "" {
+				w.Write([]byte(line))
+				ctl.Flush()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}))
+	defer cst.close()
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	res, err := cst.c.Post(cst.ts.URL, "text/plain", pr)
+	if err != nil {
+		t.Fatalf("unexpected connection error: %v", err)
+	}
+	defer res.Body.Close()
+
+	br := bufio.NewReader(res.Body)
+	for i := 0; i < 3; i++ {
+		want := fmt.Sprintf("line %v\n", i)
+		if _, err := io.WriteString(pw, want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := br.ReadString('\n')
+		if err != nil || got != want {
+			t.Fatalf("read %q, %v, want %q, nil", got, err, want)
+		}
+	}
+}
+
+type wrapWriter struct {
+	ResponseWriter
+}
+
+func (w wrapWriter) Unwrap() ResponseWriter {
+	return w.ResponseWriter
+}
+
+// A plainWriter hides every optional method of the wrapped ResponseWriter.
+type plainWriter struct {
+	w ResponseWriter
+}
+
+func (w plainWriter) Header() Header              { return w.w.Header() }
+func (w plainWriter) Write(b []byte) (int, error) { return w.w.Write(b) }
+func (w plainWriter) WriteHeader(code int)        { w.w.WriteHeader(code) }
+
+func TestResponseControllerUnwrap(t *testing.T) {
+	defer afterTest(t)
+	var mu sync.Mutex
+	var errs []error
+	cst := newClientServerTest(t, h1Mode, HandlerFunc(func(w ResponseWriter, r *Request) {
+		// Two levels of wrapping, to check that Unwrap is followed repeatedly.
+		ctl := NewResponseController(wrapWriter{wrapWriter{w}})
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs,
+			ctl.SetReadDeadline(time.Now().Add(time.Minute)),
+			ctl.SetWriteDeadline(time.Now().Add(time.Minute)),
+			ctl.EnableFullDuplex(),
+			ctl.Flush(),
+		)
+	}))
+	defer cst.close()
+
+	res, err := cst.c.Get(cst.ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("call %v through wrapped ResponseWriter = %v, want nil", i, err)
+		}
+	}
+}
+
+func TestResponseControllerNotSupported(t *testing.T) {
+	ctl := NewResponseController(plainWriter{httptest.NewRecorder()})
+	if err := ctl.Flush(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("ctl.Flush() = %v, want ErrNotSupported", err)
+	}
+	if _, _, err := ctl.Hijack(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("ctl.Hijack() = %v, want ErrNotSupported", err)
+	}
+	if err := ctl.SetReadDeadline(time.Time{}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("ctl.SetReadDeadline() = %v, want ErrNotSupported", err)
+	}
+	if err := ctl.SetWriteDeadline(time.Time{}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("ctl.SetWriteDeadline() = %v, want ErrNotSupported", err)
+	}
+	if err := ctl.EnableFullDuplex(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("ctl.EnableFullDuplex() = %v, want ErrNotSupported", err)
+	}
+}
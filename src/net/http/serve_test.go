@@ -611,6 +611,173 @@ func TestShouldRedirectConcurrency(t *testing.T) {
 	mux.HandleFunc("/", func(w ResponseWriter, r *Request) {})
 }
 
+func TestServeMuxPathValue(t *testing.T) {
+	setParallel(t)
+	for _, test := range []struct {
+		pattern string
+		url     string
+		want    map[string]string
+	}{
+		{
+			"/{a}/is/{b}/{c...}",
+			"/now/is/the/time/for/all",
+			map[string]string{
+				"a": "now",
+				"b": "the",
+				"c": "time/for/all",
+				"d": "",
+			},
+		},
+		{
+			"/names/{name}/{other...}",
+			"/names/%2fjohn/address",
+			map[string]string{
+				"name":  "/john",
+				"other": "address",
+			},
+		},
+		{
+			"/names/{name}/{other...}",
+			"/names/john%2Fdoe/there/is%2F/more",
+			map[string]string{
+				"name":  "john/doe",
+				"other": "there/is//more",
+			},
+		},
+		{
+			"GET /items/{id}",
+			"/items/17",
+			map[string]string{
+				"id": "17",
+			},
+		},
+	} {
+		mux := NewServeMux()
+		mux.HandleFunc(test.pattern, func(w ResponseWriter, r *Request) {
+			for name, want := range test.want {
+				got := r.PathValue(name)
+				if got != want {
+					t.Errorf("%q, %q: got %q, want %q", test.pattern, name, got, want)
+				}
+			}
+		})
+		server := httptest.NewServer(mux)
+		res, err := Get(server.URL + test.url)
+		server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != 200 {
+			t.Errorf("%q, %q: got status %d, want 200", test.pattern, test.url, res.StatusCode)
+		}
+	}
+}
+
+func TestSetPathValue(t *testing.T) {
+	mux := NewServeMux()
+	mux.HandleFunc("/a/{b}/c/{d...}", func(_ ResponseWriter, r *Request) {
+		kvs := map[string]string{
+			"b": "X",
+			"d": "Y",
+			"a": "Z",
+		}
+		for k, v := range kvs {
+			r.SetPathValue(k, v)
+		}
+		for k, w := range kvs {
+			if g := r.PathValue(k); g != w {
+				t.Errorf("got %q, want %q", g, w)
+			}
+		}
+		r2 := r.Clone(r.Context())
+		r2.SetPathValue("b", "changed")
+		if g := r.PathValue("b"); g != "X" {
+			t.Errorf("after changing clone, got %q, want %q", g, "X")
+		}
+	})
+	r, err := NewRequest("GET", "http://example.com/a/b/c/d/e", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestServeMuxMethodNotAllowed(t *testing.T) {
+	setParallel(t)
+	mux := NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(w ResponseWriter, r *Request) {})
+	mux.HandleFunc("DELETE /items/{id}", func(w ResponseWriter, r *Request) {})
+	mux.HandleFunc("POST /items/", func(w ResponseWriter, r *Request) {})
+
+	for _, test := range []struct {
+		method, path string
+		code         int
+		allow        string
+	}{
+		{"GET", "/items/1", 200, ""},
+		{"HEAD", "/items/1", 200, ""},
+		{"DELETE", "/items/1", 200, ""},
+		{"PUT", "/items/1", 405, "DELETE, GET, HEAD, POST"},
+		{"PUT", "/items/", 405, "POST"},
+		{"PUT", "/other", 404, ""},
+	} {
+		r, err := NewRequest(test.method, "http://example.com"+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, r)
+		if rr.Code != test.code {
+			t.Errorf("%s %s: got code %d, want %d", test.method, test.path, rr.Code, test.code)
+		}
+		if got := rr.Header().Get("Allow"); got != test.allow {
+			t.Errorf("%s %s: got Allow %q, want %q", test.method, test.path, got, test.allow)
+		}
+	}
+}
+
+func TestServeMuxRegistrationErrors(t *testing.T) {
+	setParallel(t)
+	h := HandlerFunc(func(w ResponseWriter, r *Request) {})
+	for _, test := range []struct {
+		registered []string
+		pattern    string
+		want       string
+	}{
+		{nil, "", "invalid pattern"},
+		{nil, "/{x", "bad wildcard segment"},
+		{nil, "A=B /", "invalid method"},
+		{[]string{"/a"}, "/a", "conflicts with pattern"},
+		{[]string{"/a/{x}"}, "/{y}/b", "conflicts with pattern"},
+		{[]string{"GET /"}, "/foo", "conflicts with pattern"},
+	} {
+		mux := NewServeMux()
+		for _, p := range test.registered {
+			mux.Handle(p, h)
+		}
+		func() {
+			defer func() {
+				err, _ := recover().(error)
+				if err == nil || !strings.Contains(err.Error(), test.want) {
+					t.Errorf("Handle(%q) after %q: got panic %v, want error containing %q",
+						test.pattern, test.registered, err, test.want)
+				}
+			}()
+			mux.Handle(test.pattern, h)
+		}()
+	}
+
+	// Patterns that differ only in host, method or specificity do not conflict.
+	mux := NewServeMux()
+	for _, p := range []string{
+		"/", "/{$}", "/a/{x}", "/a/b", "GET /a/{x}/c", "POST /a/{x}/c",
+		"example.com/a/b", "/a/{rest...}",
+	} {
+		mux.Handle(p, h)
+	}
+}
+
 func BenchmarkServeMux(b *testing.B)           { benchmarkServeMux(b, true) }
 func BenchmarkServeMux_SkipServe(b *testing.B) { benchmarkServeMux(b, false) }
 func benchmarkServeMux(b *testing.B, runHandler bool) {
@@ -3866,7 +4033,7 @@ func testServerReaderFromOrder(t *testing.T, h2 bool) {
 
 // Issue 6157, Issue 6685
 func TestCodesPreventingContentTypeAndBody(t *testing.T) {
-	for _, code := range []int{StatusNotModified, StatusNoContent, StatusContinue} {
+	for _, code := range []int{StatusNotModified, StatusNoContent} {
 		ht := newHandlerTest(HandlerFunc(func(w ResponseWriter, r *Request) {
 			if r.URL.Path == "/header" {
 				w.Header().Set("Content-Length", "123")
@@ -146,6 +146,13 @@ type Resolver struct {
 	// If nil, the default dialer is used.
 	Dial func(ctx context.Context, network, address string) (Conn, error)
 
+	// EncryptedDNS optionally makes Go's built-in DNS resolver send
+	// its queries using DNS over TLS or DNS over HTTPS, to the servers
+	// it lists instead of those in resolv.conf. Setting it implies
+	// PreferGo, and Dial is not used. It is only supported on Unix
+	// systems: elsewhere, DNS lookups fail when it is set.
+	EncryptedDNS *EncryptedDNS
+
 	// lookupGroup merges LookupIPAddr calls together for lookups for the same
 	// host. The lookupGroup key is the LookupIPAddr.host argument.
 	// The return values are ([]IPAddr, error).
@@ -155,7 +162,7 @@ type Resolver struct {
 	// TODO(bradfitz): Timeout time.Duration?
 }
 
-func (r *Resolver) preferGo() bool     { return r != nil && r.PreferGo }
+func (r *Resolver) preferGo() bool     { return r != nil && (r.PreferGo || r.EncryptedDNS != nil) }
 func (r *Resolver) strictErrors() bool { return r != nil && r.StrictErrors }
 
 func (r *Resolver) getLookupGroup() *singleflight.Group {
@@ -622,6 +629,34 @@ func (r *Resolver) LookupTXT(ctx context.Context, name string) ([]string, error)
 	return r.lookupTXT(ctx, name)
 }
 
+// LookupRecords returns the DNS records of the given type for name,
+// along with their TTLs and whether the name server reported them as
+// authenticated with DNSSEC. Unlike the other lookup functions, it
+// can query for any record type, and it always uses the pure Go
+// resolver: it is not supported on Windows, Plan 9, or js/wasm.
+//
+// The domain names in the returned records are validated to be properly
+// formatted presentation-format domain names. If the response contains
+// invalid names, those records are filtered out and an error will be
+// returned alongside the remaining results, if any.
+func (r *Resolver) LookupRecords(ctx context.Context, name string, typ DNSType) (*DNSResponse, error) {
+	resp, err := r.lookupRecords(ctx, name, typ)
+	if err != nil {
+		return nil, err
+	}
+	filtered := resp.Records[:0]
+	for _, rr := range resp.Records {
+		if validDNSRecordNames(&rr) {
+			filtered = append(filtered, rr)
+		}
+	}
+	if len(filtered) != len(resp.Records) {
+		resp.Records = filtered
+		return resp, &DNSError{Err: errMalformedDNSRecordsDetail, Name: name}
+	}
+	return resp, nil
+}
+
 // LookupAddr performs a reverse lookup for the given address, returning a list
 // of names mapping to that address.
 //
@@ -51,6 +51,10 @@ func (*Resolver) lookupAddr(ctx context.Context, addr string) (ptrs []string, er
 	return nil, syscall.ENOPROTOOPT
 }
 
+func (*Resolver) lookupRecords(ctx context.Context, name string, typ DNSType) (*DNSResponse, error) {
+	return nil, syscall.ENOPROTOOPT
+}
+
 // concurrentThreadsLimit returns the number of threads we permit to
 // run concurrently doing DNS lookups.
 func concurrentThreadsLimit() int {
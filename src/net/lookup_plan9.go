@@ -11,6 +11,7 @@ import (
 	"internal/itoa"
 	"io"
 	"os"
+	"syscall"
 )
 
 func query(ctx context.Context, filename, query string, bufSize int) (addrs []string, err error) {
@@ -143,7 +144,10 @@ func lookupProtocol(ctx context.Context, name string) (proto int, err error) {
 	return 0, UnknownNetworkError(name)
 }
 
-func (*Resolver) lookupHost(ctx context.Context, host string) (addrs []string, err error) {
+func (r *Resolver) lookupHost(ctx context.Context, host string) (addrs []string, err error) {
+	if err := r.checkEncryptedDNS(host); err != nil {
+		return nil, err
+	}
 	// Use netdir/cs instead of netdir/dns because cs knows about
 	// host names in local network (e.g. from /lib/ndb/local)
 	lines, err := queryCS(ctx, "net", host, "1")
@@ -223,7 +227,10 @@ func (*Resolver) lookupPort(ctx context.Context, network, service string) (port
 	return 0, unknownPortError
 }
 
-func (*Resolver) lookupCNAME(ctx context.Context, name string) (cname string, err error) {
+func (r *Resolver) lookupCNAME(ctx context.Context, name string) (cname string, err error) {
+	if err := r.checkEncryptedDNS(name); err != nil {
+		return "", err
+	}
 	lines, err := queryDNS(ctx, name, "cname")
 	if err != nil {
 		if stringsHasSuffix(err.Error(), "dns failure") || stringsHasSuffix(err.Error(), "resource does not exist; negrcode 0") {
@@ -240,7 +247,10 @@ func (*Resolver) lookupCNAME(ctx context.Context, name string) (cname string, er
 	return "", errors.New("bad response from ndb/dns")
 }
 
-func (*Resolver) lookupSRV(ctx context.Context, service, proto, name string) (cname string, addrs []*SRV, err error) {
+func (r *Resolver) lookupSRV(ctx context.Context, service, proto, name string) (cname string, addrs []*SRV, err error) {
+	if err := r.checkEncryptedDNS(name); err != nil {
+		return "", nil, err
+	}
 	var target string
 	if service == "" && proto == "" {
 		target = name
@@ -269,7 +279,10 @@ func (*Resolver) lookupSRV(ctx context.Context, service, proto, name string) (cn
 	return
 }
 
-func (*Resolver) lookupMX(ctx context.Context, name string) (mx []*MX, err error) {
+func (r *Resolver) lookupMX(ctx context.Context, name string) (mx []*MX, err error) {
+	if err := r.checkEncryptedDNS(name); err != nil {
+		return nil, err
+	}
 	lines, err := queryDNS(ctx, name, "mx")
 	if err != nil {
 		return
@@ -287,7 +300,10 @@ func (*Resolver) lookupMX(ctx context.Context, name string) (mx []*MX, err error
 	return
 }
 
-func (*Resolver) lookupNS(ctx context.Context, name string) (ns []*NS, err error) {
+func (r *Resolver) lookupNS(ctx context.Context, name string) (ns []*NS, err error) {
+	if err := r.checkEncryptedDNS(name); err != nil {
+		return nil, err
+	}
 	lines, err := queryDNS(ctx, name, "ns")
 	if err != nil {
 		return
@@ -302,7 +318,10 @@ func (*Resolver) lookupNS(ctx context.Context, name string) (ns []*NS, err error
 	return
 }
 
-func (*Resolver) lookupTXT(ctx context.Context, name string) (txt []string, err error) {
+func (r *Resolver) lookupTXT(ctx context.Context, name string) (txt []string, err error) {
+	if err := r.checkEncryptedDNS(name); err != nil {
+		return nil, err
+	}
 	lines, err := queryDNS(ctx, name, "txt")
 	if err != nil {
 		return
@@ -315,7 +334,10 @@ func (*Resolver) lookupTXT(ctx context.Context, name string) (txt []string, err
 	return
 }
 
-func (*Resolver) lookupAddr(ctx context.Context, addr string) (name []string, err error) {
+func (r *Resolver) lookupAddr(ctx context.Context, addr string) (name []string, err error) {
+	if err := r.checkEncryptedDNS(addr); err != nil {
+		return nil, err
+	}
 	arpa, err := reverseaddr(addr)
 	if err != nil {
 		return
@@ -334,6 +356,10 @@ func (*Resolver) lookupAddr(ctx context.Context, addr string) (name []string, er
 	return
 }
 
+func (*Resolver) lookupRecords(ctx context.Context, name string, typ DNSType) (*DNSResponse, error) {
+	return nil, syscall.EPLAN9
+}
+
 // concurrentThreadsLimit returns the number of threads we permit to
 // run concurrently doing DNS lookups.
 func concurrentThreadsLimit() int {
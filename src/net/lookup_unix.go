@@ -135,7 +135,7 @@ func (r *Resolver) lookupSRV(ctx context.Context, service, proto, name string) (
 	} else {
 		target = "_" + service + "._" + proto + "." + name
 	}
-	p, server, err := r.lookup(ctx, target, dnsmessage.TypeSRV)
+	p, _, server, err := r.lookup(ctx, target, dnsmessage.TypeSRV)
 	if err != nil {
 		return "", nil, err
 	}
@@ -181,7 +181,7 @@ func (r *Resolver) lookupSRV(ctx context.Context, service, proto, name string) (
 }
 
 func (r *Resolver) lookupMX(ctx context.Context, name string) ([]*MX, error) {
-	p, server, err := r.lookup(ctx, name, dnsmessage.TypeMX)
+	p, _, server, err := r.lookup(ctx, name, dnsmessage.TypeMX)
 	if err != nil {
 		return nil, err
 	}
@@ -224,7 +224,7 @@ func (r *Resolver) lookupMX(ctx context.Context, name string) ([]*MX, error) {
 }
 
 func (r *Resolver) lookupNS(ctx context.Context, name string) ([]*NS, error) {
-	p, server, err := r.lookup(ctx, name, dnsmessage.TypeNS)
+	p, _, server, err := r.lookup(ctx, name, dnsmessage.TypeNS)
 	if err != nil {
 		return nil, err
 	}
@@ -265,7 +265,7 @@ func (r *Resolver) lookupNS(ctx context.Context, name string) ([]*NS, error) {
 }
 
 func (r *Resolver) lookupTXT(ctx context.Context, name string) ([]string, error) {
-	p, server, err := r.lookup(ctx, name, dnsmessage.TypeTXT)
+	p, _, server, err := r.lookup(ctx, name, dnsmessage.TypeTXT)
 	if err != nil {
 		return nil, err
 	}
@@ -328,6 +328,22 @@ func (r *Resolver) lookupAddr(ctx context.Context, addr string) ([]string, error
 	return r.goLookupPTR(ctx, addr)
 }
 
+func (r *Resolver) lookupRecords(ctx context.Context, name string, typ DNSType) (*DNSResponse, error) {
+	p, h, server, err := r.lookup(ctx, name, dnsmessage.Type(typ))
+	if err != nil {
+		return nil, err
+	}
+	rrs, err := parseDNSRecords(&p, dnsmessage.Type(typ))
+	if err != nil {
+		return nil, &DNSError{
+			Err:    "cannot unmarshal DNS message",
+			Name:   name,
+			Server: server,
+		}
+	}
+	return &DNSResponse{Records: rrs, Authenticated: h.AuthenticData}, nil
+}
+
 // concurrentThreadsLimit returns the number of threads we permit to
 // run concurrently doing DNS lookups via cgo. A DNS lookup may use a
 // file descriptor so we limit this to less than the number of
@@ -6,6 +6,7 @@ package net
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -174,6 +175,27 @@ func TestLookupPTR(t *testing.T) {
 	}
 }
 
+func TestLookupEncryptedDNSUnsupported(t *testing.T) {
+	r := &Resolver{EncryptedDNS: &EncryptedDNS{Servers: []string{"tls://127.0.0.1"}}}
+	checkUnsupported := func(call string, err error) {
+		var dnsErr *DNSError
+		if !errors.As(err, &dnsErr) || dnsErr.Err != errEncryptedDNSUnsupported.Error() {
+			t.Errorf("%s with EncryptedDNS set: got error %v, want %v", call, err, errEncryptedDNSUnsupported)
+		}
+	}
+	ctx := context.Background()
+	_, err := r.LookupHost(ctx, "golang.org")
+	checkUnsupported("LookupHost", err)
+	_, err = r.LookupCNAME(ctx, "golang.org")
+	checkUnsupported("LookupCNAME", err)
+	_, err = r.LookupMX(ctx, "golang.org")
+	checkUnsupported("LookupMX", err)
+	_, err = r.LookupTXT(ctx, "golang.org")
+	checkUnsupported("LookupTXT", err)
+	_, err = r.LookupAddr(ctx, "8.8.8.8")
+	checkUnsupported("LookupAddr", err)
+}
+
 type byPrefAndHost []*MX
 
 func (s byPrefAndHost) Len() int { return len(s) }
@@ -0,0 +1,92 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"errors"
+	"internal/poll"
+	"sync"
+	"syscall"
+)
+
+// These constants aren't in the syscall package, which is frozen.
+const (
+	_IPPROTO_MPTCP = 0x106
+	_SOL_MPTCP     = 0x11c
+	_MPTCP_INFO    = 0x1
+)
+
+var (
+	mptcpOnce      sync.Once
+	mptcpAvailable bool
+	hasSOLMPTCP    bool
+)
+
+func supportsMultipathTCP() bool {
+	mptcpOnce.Do(initMPTCPAvailable)
+	return mptcpAvailable
+}
+
+// initMPTCPAvailable checks whether the kernel supports Multipath TCP
+// by trying to create such a socket.
+func initMPTCPAvailable() {
+	s, err := sysSocket(syscall.AF_INET, syscall.SOCK_STREAM, _IPPROTO_MPTCP)
+	switch {
+	case errors.Is(err, syscall.EPROTONOSUPPORT), errors.Is(err, syscall.EINVAL):
+		// Not supported: EINVAL before Linux 5.6,
+		// EPROTONOSUPPORT since then.
+	case err == nil:
+		poll.CloseFunc(s)
+		fallthrough
+	default:
+		// Any other error, like EMFILE, doesn't tell us that
+		// Multipath TCP isn't supported.
+		mptcpAvailable = true
+	}
+
+	// SOL_MPTCP is only supported since Linux 5.16.
+	major, minor := kernelVersion()
+	hasSOLMPTCP = major > 5 || (major == 5 && minor >= 16)
+}
+
+func (sd *sysDialer) dialMPTCP(ctx context.Context, laddr, raddr *TCPAddr) (*TCPConn, error) {
+	if supportsMultipathTCP() {
+		if c, err := sd.doDialTCPProto(ctx, laddr, raddr, _IPPROTO_MPTCP); err == nil {
+			return c, nil
+		}
+	}
+	// Fall back to plain TCP on any error. Multipath TCP may be
+	// disabled (sysctl net.mptcp.enabled=0 gives ENOPROTOOPT) or
+	// blocked in other ways, such as by SELinux.
+	return sd.dialTCP(ctx, laddr, raddr)
+}
+
+func (sl *sysListener) listenMPTCP(ctx context.Context, laddr *TCPAddr) (*TCPListener, error) {
+	if supportsMultipathTCP() {
+		if l, err := sl.listenTCPProto(ctx, laddr, _IPPROTO_MPTCP); err == nil {
+			return l, nil
+		}
+	}
+	// Fall back to plain TCP on any error, as in dialMPTCP.
+	return sl.listenTCP(ctx, laddr)
+}
+
+// isUsingMultipathTCP reports whether the connection fd uses Multipath
+// TCP, and has not fallen back to plain TCP.
+func isUsingMultipathTCP(fd *netFD) bool {
+	proto, err := fd.pfd.GetsockoptInt(syscall.SOL_SOCKET, syscall.SO_PROTOCOL)
+	if err != nil || proto != _IPPROTO_MPTCP {
+		return false
+	}
+	if !hasSOLMPTCP {
+		// The fallback to plain TCP can't be detected.
+		return true
+	}
+	// MPTCP_INFO fails with EOPNOTSUPP after a fallback to plain TCP,
+	// for instance because the peer doesn't support Multipath TCP.
+	_, err = fd.pfd.GetsockoptInt(_SOL_MPTCP, _MPTCP_INFO)
+	return err != syscall.EOPNOTSUPP
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package net
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMultipathTCP(t *testing.T) {
+	if !supportsMultipathTCP() {
+		t.Skip("Multipath TCP is not supported")
+	}
+	for _, tt := range []struct {
+		listen, dial bool
+		want         bool
+	}{
+		{listen: true, dial: true, want: true},
+		{listen: true, dial: false, want: false},
+		{listen: false, dial: true, want: false},
+		{listen: false, dial: false, want: false},
+	} {
+		var lc ListenConfig
+		lc.SetMultipathTCP(tt.listen)
+		if got := lc.MultipathTCP(); got != tt.listen {
+			t.Fatalf("ListenConfig.MultipathTCP() = %v; want %v", got, tt.listen)
+		}
+		ln, err := lc.Listen(context.Background(), "tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		accepted := make(chan *TCPConn, 1)
+		go func() {
+			c, err := ln.Accept()
+			if err != nil {
+				t.Error(err)
+				accepted <- nil
+				return
+			}
+			accepted <- c.(*TCPConn)
+		}()
+
+		var d Dialer
+		d.SetMultipathTCP(tt.dial)
+		if got := d.MultipathTCP(); got != tt.dial {
+			t.Fatalf("Dialer.MultipathTCP() = %v; want %v", got, tt.dial)
+		}
+		c, err := d.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			ln.Close()
+			t.Fatal(err)
+		}
+		sc := <-accepted
+		ln.Close()
+		if sc == nil {
+			c.Close()
+			t.FailNow()
+		}
+
+		// The handshake is complete on both ends once data has
+		// made a round trip.
+		if _, err := c.Write([]byte("x")); err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, 1)
+		if _, err := sc.Read(b); err != nil {
+			t.Fatal(err)
+		}
+
+		// Without SOL_MPTCP, a connection that fell back to plain TCP
+		// still reports Multipath TCP on the end that asked for it.
+		want := tt.want || !hasSOLMPTCP && tt.dial
+		if got, err := c.(*TCPConn).MultipathTCP(); err != nil || got != want {
+			t.Errorf("listen=%v dial=%v: client MultipathTCP() = %v, %v; want %v", tt.listen, tt.dial, got, err, want)
+		}
+		want = tt.want
+		if got, err := sc.MultipathTCP(); err != nil || got != want {
+			t.Errorf("listen=%v dial=%v: server MultipathTCP() = %v, %v; want %v", tt.listen, tt.dial, got, err, want)
+		}
+		c.Close()
+		sc.Close()
+	}
+}
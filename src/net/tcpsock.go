@@ -217,6 +217,20 @@ func (c *TCPConn) SetNoDelay(noDelay bool) error {
 	return nil
 }
 
+// MultipathTCP reports whether the connection uses Multipath TCP
+// (RFC 8684).
+//
+// A connection dialed or accepted with Multipath TCP falls back to
+// plain TCP if the peer does not support it, or if a middlebox
+// interferes with it. MultipathTCP does its best to detect that, but
+// on Linux only kernels 5.16 and later report the fallback reliably.
+func (c *TCPConn) MultipathTCP() (bool, error) {
+	if !c.ok() {
+		return false, syscall.EINVAL
+	}
+	return isUsingMultipathTCP(c.fd), nil
+}
+
 func newTCPConn(fd *netFD) *TCPConn {
 	c := &TCPConn{conn{fd}}
 	setNoDelay(c.fd, true)
@@ -11,6 +11,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"internal/poll"
 	"internal/testenv"
@@ -821,6 +822,15 @@ func TestHelperProcess(*testing.T) {
 	case "sleep":
 		time.Sleep(3 * time.Second)
 		os.Exit(0)
+	case "leakpipe":
+		// Start a subprocess that inherits our stdout and outlives us.
+		cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess", "--", "sleep")
+		cmd.Stdout = os.Stdout
+		if err := cmd.Start(); err != nil {
+			fmt.Fprintf(os.Stderr, "starting subprocess failed: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
 	case "pipehandle":
 		handle, _ := strconv.ParseUint(args[0], 16, 64)
 		pipe := os.NewFile(uintptr(handle), "")
@@ -1014,6 +1024,103 @@ func TestContextCancel(t *testing.T) {
 	}
 }
 
+func TestCancelWithoutContext(t *testing.T) {
+	c := helperCommand(t, "echo")
+	c.Cancel = func() error { return nil }
+	if err := c.Start(); err == nil {
+		c.Wait()
+		t.Fatal("Start succeeded for a command with Cancel but no Context")
+	}
+}
+
+func TestCancelFunc(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := helperCommandContext(t, ctx, "cat")
+	stdin, err := c.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cancel by closing stdin instead of killing the process:
+	// cat then exits successfully, and Wait reports the Context error.
+	canceled := false
+	c.Cancel = func() error {
+		canceled = true
+		return stdin.Close()
+	}
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	err = c.Wait()
+	if !canceled {
+		t.Errorf("Cancel was not called")
+	}
+	if err != context.Canceled {
+		t.Errorf("Wait() = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestCancelFuncError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := helperCommandContext(t, ctx, "cat")
+	stdin, err := c.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	errCancel := errors.New("cancel failed")
+	c.Cancel = func() error {
+		stdin.Close()
+		return errCancel
+	}
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := c.Wait(); !errors.Is(err, errCancel) {
+		t.Errorf("Wait() = %v, want error wrapping %v", err, errCancel)
+	}
+}
+
+func TestWaitDelayKill(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := helperCommandContext(t, ctx, "sleep")
+	// Cancel does nothing, so the process is only
+	// killed once WaitDelay expires.
+	c.Cancel = func() error { return nil }
+	c.WaitDelay = 10 * time.Millisecond
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	cancel()
+	err := c.Wait()
+	if ee := new(exec.ExitError); !errors.As(err, &ee) {
+		t.Errorf("Wait() = %v, want *exec.ExitError", err)
+	}
+	if d := time.Since(start); d >= 3*time.Second {
+		t.Errorf("Wait took %v, want the process to be killed after WaitDelay", d)
+	}
+}
+
+func TestWaitDelayLeakedPipe(t *testing.T) {
+	// The helper exits immediately, but leaves a subprocess that keeps
+	// its stdout open for a few seconds. WaitDelay bounds how long Wait
+	// waits for it to close.
+	c := helperCommand(t, "leakpipe")
+	c.Stdout = new(strings.Builder)
+	c.WaitDelay = 10 * time.Millisecond
+	start := time.Now()
+	if err := c.Run(); err != exec.ErrWaitDelay {
+		t.Errorf("Run() = %v, want %v", err, exec.ErrWaitDelay)
+	}
+	if d := time.Since(start); d >= 3*time.Second {
+		t.Errorf("Run took %v, want it to return after WaitDelay", d)
+	}
+}
+
 // test that environment variables are de-duped.
 func TestDedupEnvEcho(t *testing.T) {
 	testenv.MustHaveExec(t)
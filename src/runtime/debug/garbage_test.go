@@ -217,6 +217,47 @@ func TestSetGCPercent(t *testing.T) {
 	}
 }
 
+var setMemoryLimitSink any
+
+func TestSetMemoryLimit(t *testing.T) {
+	// Test that the variable is being set and returned correctly.
+	const testLimit = 123456 << 20
+	old := SetMemoryLimit(testLimit)
+	defer SetMemoryLimit(old)
+	if got := SetMemoryLimit(-1); got != testLimit {
+		t.Errorf("SetMemoryLimit(%d); SetMemoryLimit(-1) = %d, want %d", int64(testLimit), got, int64(testLimit))
+	}
+	if got := SetMemoryLimit(-1); got != testLimit {
+		t.Errorf("SetMemoryLimit(-1) changed the limit to %d", got)
+	}
+
+	// Test that the limit bounds the heap goal and causes
+	// collections even when GOGC=off.
+	defer SetGCPercent(SetGCPercent(-1))
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	limit := ms.Sys - ms.HeapReleased + 64<<20
+	SetMemoryLimit(int64(limit))
+	runtime.ReadMemStats(&ms)
+	if ms.NextGC > limit {
+		t.Errorf("NextGC = %d MB, want at most the memory limit %d MB", ms.NextGC>>20, limit>>20)
+	}
+	ngc := ms.NumGC
+	for i := 0; i < 512; i++ {
+		setMemoryLimitSink = make([]byte, 1<<20)
+	}
+	setMemoryLimitSink = nil
+	runtime.ReadMemStats(&ms)
+	if ms.NumGC == ngc {
+		t.Errorf("expected GC to run with GOGC=off and a memory limit, but it did not")
+	}
+	const slack = 16 << 20
+	if total := ms.Sys - ms.HeapReleased; total > limit+slack {
+		t.Errorf("total memory = %d MB, want at most %d MB plus %d MB of slack", total>>20, limit>>20, slack>>20)
+	}
+}
+
 func abs64(a int64) int64 {
 	if a < 0 {
 		return -a
@@ -33,6 +33,7 @@ var Fastlog2 = fastlog2
 
 var Atoi = atoi
 var Atoi32 = atoi32
+var ParseByteCount = parseByteCount
 
 var Nanotime = nanotime
 var NetpollBreak = netpollBreak
@@ -1248,14 +1249,14 @@ type GCController struct {
 	gcControllerState
 }
 
-func NewGCController(gcPercent int) *GCController {
+func NewGCController(gcPercent int, memoryLimit int64) *GCController {
 	// Force the controller to escape. We're going to
 	// do 64-bit atomics on it, and if it gets stack-allocated
 	// on a 32-bit architecture, it may get allocated unaligned
 	// space.
 	g := escape(new(GCController)).(*GCController)
 	g.gcControllerState.test = true // Mark it as a test copy.
-	g.init(int32(gcPercent))
+	g.init(int32(gcPercent), memoryLimit)
 	return g
 }
 
@@ -1311,6 +1312,45 @@ func (c *GCController) EndCycle(bytesMarked uint64, assistTime, elapsed int64, g
 	c.commit(triggerRatio)
 }
 
+func MemoryLimitHeapGoal(limit int64, mappedReady, heapRetained, heapMarked uint64) uint64 {
+	return memoryLimitHeapGoal(limit, mappedReady, heapRetained, heapMarked)
+}
+
+type GCCPULimiter struct {
+	limiter gcCPULimiterState
+}
+
+func NewGCCPULimiter(now int64, gomaxprocs int32) *GCCPULimiter {
+	// Force the controller to escape. We're going to
+	// do 64-bit atomics on it, and if it gets stack-allocated
+	// on a 32-bit architecture, it may get allocated unaligned
+	// space.
+	l := escape(new(GCCPULimiter)).(*GCCPULimiter)
+	l.limiter.test = true
+	l.limiter.update(now, gomaxprocs)
+	return l
+}
+
+func (l *GCCPULimiter) Fill() uint64 {
+	return l.limiter.bucket.fill
+}
+
+func (l *GCCPULimiter) Capacity() uint64 {
+	return l.limiter.bucket.capacity
+}
+
+func (l *GCCPULimiter) Limiting() bool {
+	return l.limiter.limiting()
+}
+
+func (l *GCCPULimiter) AddGCTime(duration int64) {
+	l.limiter.addGCTime(duration)
+}
+
+func (l *GCCPULimiter) Update(now int64, gomaxprocs int32) {
+	l.limiter.update(now, gomaxprocs)
+}
+
 var escapeSink any
 
 //go:noinline
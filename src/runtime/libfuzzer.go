@@ -42,6 +42,12 @@ func libfuzzerTraceConstCmp8(arg0, arg1 uint64) {
 	libfuzzerCall(&__sanitizer_cov_trace_const_cmp8, uintptr(arg0), uintptr(arg1))
 }
 
+// libfuzzerHookStrCmp is called for comparisons of strings.
+// libFuzzer's string comparison hooks take NUL-terminated C strings,
+// which Go strings are not, so nothing is reported for now.
+func libfuzzerHookStrCmp(s1, s2 string) {
+}
+
 //go:linkname __sanitizer_cov_trace_cmp1 __sanitizer_cov_trace_cmp1
 //go:cgo_import_static __sanitizer_cov_trace_cmp1
 var __sanitizer_cov_trace_cmp1 byte
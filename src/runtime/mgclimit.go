@@ -0,0 +1,153 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+import "runtime/internal/atomic"
+
+// gcCPULimiter is a mechanism to limit GC CPU utilization in situations
+// where it might become excessive and inhibit application progress (e.g.
+// a death spiral caused by a memory limit that's too low for the live heap).
+//
+// The core of the limiter is a leaky bucket mechanism that fills with GC
+// CPU time and drains with mutator time. Because the bucket fills and
+// drains with time directly (i.e. without any weighting), this effectively
+// sets a very conservative limit of 50%. This limit could be enforced directly,
+// but the purpose of the bucket is to accommodate spikes in GC CPU utilization
+// without hurting throughput.
+//
+// Note that the bucket in the leaky bucket mechanism can never go negative,
+// so the GC never gets credit for a lot of CPU time spent without the GC
+// running. This is intentional, as an application that stays idle for, say,
+// an entire day, could build up enough credit to fail to prevent a death
+// spiral the following day. The bucket's capacity is the GC's only leeway.
+//
+// While the limiter is enabled, GC assists are skipped and allocations no
+// longer scavenge to maintain the memory limit, so the application may
+// exceed the memory limit.
+var gcCPULimiter gcCPULimiterState
+
+type gcCPULimiterState struct {
+	// lock protects bucket and lastUpdate. It is a simple try-lock
+	// so that update may be called from sysmon, which may not block.
+	lock atomic.Uint32
+
+	// enabled is non-zero when the limiter is active, that is, when
+	// the bucket is full.
+	enabled atomic.Uint32
+
+	// bucket is the leaky bucket, in CPU-nanoseconds. Protected by lock.
+	bucket struct {
+		fill, capacity uint64
+	}
+
+	// gcTime is the GC CPU time in nanoseconds accumulated since the
+	// last update.
+	gcTime atomic.Int64
+
+	// lastUpdate is the nanotime timestamp of the last update.
+	lastUpdate atomic.Int64
+
+	// lastEnabledCycle is the GC cycle during which the limiter was
+	// last enabled.
+	lastEnabledCycle atomic.Uint32
+
+	// test indicates that this is a test-only copy of gcCPULimiterState.
+	test bool
+}
+
+// capacityPerProc is the limiter's bucket capacity for each P in GOMAXPROCS.
+const capacityPerProc = 1e9 // 1 second in nanoseconds
+
+// limiting returns true if the CPU limiter is currently enabled, meaning the Go GC
+// should take action to limit CPU utilization.
+//
+// It is safe to call concurrently with other operations.
+func (l *gcCPULimiterState) limiting() bool {
+	return l.enabled.Load() != 0
+}
+
+// addGCTime notifies the limiter of duration nanoseconds of CPU time spent
+// doing GC work. The time is accounted for on the next update.
+//
+// It is safe to call concurrently with other operations.
+func (l *gcCPULimiterState) addGCTime(duration int64) {
+	if duration > 0 {
+		l.gcTime.Add(duration)
+	}
+}
+
+// update updates the bucket given the GC CPU time accumulated since the
+// last update and the total CPU time available since then, given procs
+// Ps. now is the current nanotime.
+//
+// If another update is in progress, update does nothing.
+//
+//go:nowritebarrierrec
+func (l *gcCPULimiterState) update(now int64, procs int32) {
+	if !l.lock.CompareAndSwap(0, 1) {
+		// Someone else is updating the bucket.
+		return
+	}
+	lastUpdate := l.lastUpdate.Load()
+	if lastUpdate == 0 || now < lastUpdate {
+		// This is the first update, or time went backwards.
+		// Either way we have no window to account for.
+		l.lastUpdate.Store(now)
+		l.lock.Store(0)
+		return
+	}
+	windowTotalTime := (now - lastUpdate) * int64(procs)
+	gcTime := l.gcTime.Swap(0)
+	mutatorTime := windowTotalTime - gcTime
+	if mutatorTime < 0 {
+		// GC time may have been flushed late and cover a longer
+		// window than this one.
+		mutatorTime = 0
+	}
+	l.accumulate(mutatorTime, gcTime, procs)
+	l.lastUpdate.Store(now)
+	l.lock.Store(0)
+}
+
+// accumulate adds time to the bucket and signals whether the limiter is enabled.
+//
+// This is an internal function that deals just with the bucket. Prefer update.
+// l.lock must be held.
+func (l *gcCPULimiterState) accumulate(mutatorTime, gcTime int64, procs int32) {
+	l.bucket.capacity = uint64(procs) * capacityPerProc
+	if l.bucket.fill > l.bucket.capacity {
+		// The capacity shrank, e.g. because GOMAXPROCS went down.
+		l.bucket.fill = l.bucket.capacity
+	}
+	if change := gcTime - mutatorTime; change < 0 {
+		// Drain the bucket, without going below zero.
+		if drain := uint64(-change); drain < l.bucket.fill {
+			l.bucket.fill -= drain
+		} else {
+			l.bucket.fill = 0
+		}
+	} else if change > 0 {
+		// Fill the bucket, without going above capacity.
+		if headroom := l.bucket.capacity - l.bucket.fill; uint64(change) < headroom {
+			l.bucket.fill += uint64(change)
+		} else {
+			l.bucket.fill = l.bucket.capacity
+		}
+	}
+
+	if l.bucket.fill != l.bucket.capacity {
+		l.enabled.Store(0)
+		return
+	}
+	if l.enabled.Swap(1) == 0 && !l.test {
+		// Record the cycle in which the limiter turned on. If
+		// we're between GC cycles, attribute it to the next one.
+		cycle := atomic.Load(&work.cycles)
+		if gcphase == _GCoff {
+			cycle++
+		}
+		l.lastEnabledCycle.Store(cycle)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime_test
+
+import (
+	. "runtime"
+	"testing"
+	"time"
+)
+
+func TestGCCPULimiter(t *testing.T) {
+	const procs = 14
+
+	// Create mock time.
+	ticks := int64(1)
+	advance := func(d time.Duration) int64 {
+		t.Helper()
+		ticks += int64(d)
+		return ticks
+	}
+
+	l := NewGCCPULimiter(ticks, procs)
+
+	// Do the whole thing a few times to check that the limiter
+	// recovers and behaves the same way each time.
+	for i := 0; i < 2; i++ {
+		// Idle time drains an already-empty bucket, which must stay empty.
+		l.Update(advance(10*time.Millisecond), procs)
+		if l.Fill() != 0 {
+			t.Fatalf("expected empty bucket to stay empty, got fill %d", l.Fill())
+		}
+		if l.Capacity() != procs*1e9 {
+			t.Fatalf("expected capacity %d, got %d", uint64(procs*1e9), l.Capacity())
+		}
+		if l.Limiting() {
+			t.Fatal("expected limiter to be off")
+		}
+
+		// Spend exactly 50% of the CPU on GC. The bucket shouldn't move.
+		l.AddGCTime(procs * int64(50*time.Millisecond))
+		l.Update(advance(100*time.Millisecond), procs)
+		if l.Fill() != 0 {
+			t.Fatalf("expected bucket to stay empty at 50%% GC CPU, got fill %d", l.Fill())
+		}
+
+		// Spend all of the CPU on GC for half the bucket's capacity.
+		l.AddGCTime(procs * int64(500*time.Millisecond))
+		l.Update(advance(500*time.Millisecond), procs)
+		if l.Fill() != l.Capacity()/2 {
+			t.Fatalf("expected half-full bucket, got fill %d of %d", l.Fill(), l.Capacity())
+		}
+		if l.Limiting() {
+			t.Fatal("expected limiter to be off with a half-full bucket")
+		}
+
+		// Fill the bucket, and then some.
+		l.AddGCTime(procs * int64(time.Second))
+		l.Update(advance(time.Second), procs)
+		if l.Fill() != l.Capacity() {
+			t.Fatalf("expected full bucket, got fill %d of %d", l.Fill(), l.Capacity())
+		}
+		if !l.Limiting() {
+			t.Fatal("expected limiter to be on with a full bucket")
+		}
+
+		// A little mutator time should turn the limiter off again.
+		l.Update(advance(time.Millisecond), procs)
+		if l.Limiting() {
+			t.Fatal("expected limiter to be off after draining")
+		}
+
+		// Drain the bucket completely.
+		l.Update(advance(2*time.Second), procs)
+		if l.Fill() != 0 {
+			t.Fatalf("expected empty bucket, got fill %d", l.Fill())
+		}
+	}
+
+	// Shrinking GOMAXPROCS shrinks the bucket.
+	l.AddGCTime(procs * int64(time.Second))
+	l.Update(advance(time.Second), procs)
+	l.Update(advance(0), procs/2)
+	if l.Capacity() != procs/2*1e9 || l.Fill() != l.Capacity() {
+		t.Fatalf("expected full bucket of capacity %d, got fill %d of %d", uint64(procs/2*1e9), l.Fill(), l.Capacity())
+	}
+	if !l.Limiting() {
+		t.Fatal("expected limiter to be on with a full bucket")
+	}
+}
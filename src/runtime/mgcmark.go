@@ -414,6 +414,14 @@ func gcAssistAlloc(gp *g) {
 		return
 	}
 
+	// Don't assist if the GC CPU limiter is on. Assists are the main
+	// way the GC takes CPU time away from the application beyond its
+	// background workers, so skipping them is how the limiter takes
+	// effect. The debt will simply carry over to future allocations.
+	if gcCPULimiter.limiting() {
+		return
+	}
+
 	traced := false
 retry:
 	// Compute the amount of scan work we need to do to make the
@@ -589,6 +597,7 @@ func gcAssistAlloc1(gp *g, scanWork int64) {
 	_p_.gcAssistTime += duration
 	if _p_.gcAssistTime > gcAssistTimeSlack {
 		atomic.Xaddint64(&gcController.assistTime, _p_.gcAssistTime)
+		gcCPULimiter.addGCTime(_p_.gcAssistTime)
 		_p_.gcAssistTime = 0
 	}
 }
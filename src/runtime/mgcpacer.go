@@ -90,6 +90,16 @@ type gcControllerState struct {
 
 	_ uint32 // padding so following 64-bit values are 8-byte aligned
 
+	// memoryLimit is the soft memory limit in bytes.
+	//
+	// Initialized from GOMEMLIMIT. GOMEMLIMIT=off is equivalent to MaxInt64
+	// which means no soft memory limit in practice.
+	//
+	// This is an int64 instead of a uint64 to more easily maintain parity with
+	// the SetMemoryLimit API, which sets a maximum at MaxInt64. This value
+	// should never be negative.
+	memoryLimit atomic.Int64
+
 	// heapMinimum is the minimum heap size at which to trigger GC.
 	// For small heaps, this overrides the usual GOGC*live set rule.
 	//
@@ -334,8 +344,9 @@ type gcControllerState struct {
 	_ cpu.CacheLinePad
 }
 
-func (c *gcControllerState) init(gcPercent int32) {
+func (c *gcControllerState) init(gcPercent int32, memoryLimit int64) {
 	c.heapMinimum = defaultHeapMinimum
+	c.memoryLimit.Store(memoryLimit)
 
 	if goexperiment.PacerRedesign {
 		c.consMarkController = piController{
@@ -894,9 +905,14 @@ func (c *gcControllerState) logWorkTime(mode gcMarkWorkerMode, duration int64) {
 	case gcMarkWorkerDedicatedMode:
 		atomic.Xaddint64(&c.dedicatedMarkTime, duration)
 		atomic.Xaddint64(&c.dedicatedMarkWorkersNeeded, 1)
+		gcCPULimiter.addGCTime(duration)
 	case gcMarkWorkerFractionalMode:
 		atomic.Xaddint64(&c.fractionalMarkTime, duration)
+		gcCPULimiter.addGCTime(duration)
 	case gcMarkWorkerIdleMode:
+		// Idle mark workers only use CPU time the application
+		// wasn't going to use anyway, so they don't count
+		// toward the GC CPU limiter.
 		atomic.Xaddint64(&c.idleMarkTime, duration)
 	default:
 		throw("logWorkTime: unknown mark worker mode")
@@ -972,6 +988,15 @@ func (c *gcControllerState) commit(triggerRatio float64) {
 
 	// Don't trigger below the minimum heap size.
 	minTrigger := c.heapMinimum
+
+	// If the memory limit calls for a smaller heap than GOGC does,
+	// use that goal instead. The minimum heap size exists to amortize
+	// the cost of GC for small heaps under GOGC, and doesn't apply here:
+	// the limit takes precedence.
+	if limitGoal := c.memoryLimitHeapGoal(); limitGoal < goal {
+		goal = limitGoal
+		minTrigger = 0
+	}
 	if !isSweepDone() {
 		// Concurrent sweep happens in the heap growth
 		// from gcController.heapLive to trigger, so ensure
@@ -1163,6 +1188,15 @@ func (c *gcControllerState) oldCommit(triggerRatio float64) {
 		}
 	}
 
+	// If the memory limit calls for a smaller heap, use that goal
+	// instead, and make sure we trigger early enough to reach it.
+	if limitGoal := c.memoryLimitHeapGoal(); limitGoal < goal {
+		goal = limitGoal
+		if maxTrigger := c.heapMarked + uint64(0.95*float64(goal-c.heapMarked)); trigger > maxTrigger {
+			trigger = maxTrigger
+		}
+	}
+
 	// Commit to the trigger and goal.
 	c.trigger = trigger
 	atomic.Store64(&c.heapGoal, goal)
@@ -1252,6 +1286,124 @@ func readGOGC() int32 {
 	return 100
 }
 
+// memoryLimitHeapGoalHeadroomPercent is how much headroom, as a percentage
+// of the memory-limit-based heap goal, the pacer leaves below that goal.
+//
+// The GC can never be perfectly precise: the heap can overshoot its goal
+// somewhat, and memory outside the heap can grow between GC cycles. This
+// headroom accounts for that so that the memory limit is more likely to
+// be respected.
+const memoryLimitHeapGoalHeadroomPercent = 3
+
+// memoryLimitHeapGoal returns a heap goal derived from the current memory
+// limit and the runtime's current memory use.
+func (c *gcControllerState) memoryLimitHeapGoal() uint64 {
+	limit := c.memoryLimit.Load()
+	if limit == maxInt64 {
+		// No memory limit, which is the common case.
+		return ^uint64(0)
+	}
+	var mapped, retained uint64
+	if !c.test {
+		// Test copies of the controller aren't tied to the real
+		// heap, so they see the whole limit as available.
+		mapped, retained = mappedReady(), heapRetained()
+	}
+	return memoryLimitHeapGoal(limit, mapped, retained, c.heapMarked)
+}
+
+// memoryLimitHeapGoal computes a heap goal from a memory limit, the total
+// mapped-and-ready memory, the retained heap memory, and the amount of heap
+// marked live by the last GC.
+//
+// The goal is the amount of memory left under the limit after accounting
+// for everything outside the heap, minus any amount by which we're already
+// over the limit, minus some headroom. It is never lower than heapMarked,
+// since the GC can't do better than that.
+func memoryLimitHeapGoal(limit int64, mapped, retained, heapMarked uint64) uint64 {
+	// Memory the heap goal has no control over. Be careful not
+	// to underflow, since these stats aren't updated together.
+	var nonHeap uint64
+	if mapped > retained {
+		nonHeap = mapped - retained
+	}
+
+	// If we're over the limit, for example because retained heap memory
+	// hasn't been returned to the OS yet, pull the goal down further so
+	// that we actually make progress toward the limit.
+	var overage uint64
+	if mapped > uint64(limit) {
+		overage = mapped - uint64(limit)
+	}
+
+	if nonHeap+overage >= uint64(limit) {
+		// The limit is impossible to meet. Just do the best we can:
+		// the GC will collect as aggressively as it can, which
+		// the GC CPU limiter keeps from getting out of hand.
+		return heapMarked
+	}
+	goal := uint64(limit) - (nonHeap + overage)
+	goal -= goal / 100 * memoryLimitHeapGoalHeadroomPercent
+	if goal < heapMarked {
+		goal = heapMarked
+	}
+	return goal
+}
+
+// setMemoryLimit updates memoryLimit and all related pacer state.
+// Returns the old value of memoryLimit.
+//
+// A negative value leaves the memory limit unchanged.
+//
+// Calls gcControllerState.commit.
+//
+// The world must be stopped, or mheap_.lock must be held.
+func (c *gcControllerState) setMemoryLimit(in int64) int64 {
+	if !c.test {
+		assertWorldStoppedOrLockHeld(&mheap_.lock)
+	}
+
+	out := c.memoryLimit.Load()
+	if in >= 0 {
+		c.memoryLimit.Store(in)
+	}
+	// Update pacing in response to memoryLimit change.
+	c.commit(c.triggerRatio)
+	return out
+}
+
+//go:linkname setMemoryLimit runtime/debug.setMemoryLimit
+func setMemoryLimit(in int64) (out int64) {
+	// Run on the system stack since we grab the heap lock.
+	systemstack(func() {
+		lock(&mheap_.lock)
+		out = gcController.setMemoryLimit(in)
+		if in < 0 || out == in {
+			// If we're just checking the value or not changing
+			// it, there's no point in doing the rest.
+			unlock(&mheap_.lock)
+			return
+		}
+		gcPaceSweeper(gcController.trigger)
+		gcPaceScavenger(gcController.heapGoal, gcController.lastHeapGoal)
+		unlock(&mheap_.lock)
+	})
+	return out
+}
+
+func readGOMEMLIMIT() int64 {
+	p := gogetenv("GOMEMLIMIT")
+	if p == "" || p == "off" {
+		return maxInt64
+	}
+	n, ok := parseByteCount(p)
+	if !ok {
+		print("GOMEMLIMIT=", p, "\n")
+		throw("malformed GOMEMLIMIT; see `go doc runtime/debug.SetMemoryLimit`")
+	}
+	return n
+}
+
 type piController struct {
 	kp float64 // Proportional constant.
 	ti float64 // Integral time constant.
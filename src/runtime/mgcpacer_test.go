@@ -313,7 +313,7 @@ func TestGcPacer(t *testing.T) {
 		t.Run(e.name, func(t *testing.T) {
 			t.Parallel()
 
-			c := NewGCController(e.gcPercent)
+			c := NewGCController(e.gcPercent, math.MaxInt64)
 			var bytesAllocatedBlackLast int64
 			results := make([]gcCycleResult, 0, e.length)
 			for i := 0; i < e.length; i++ {
@@ -715,3 +715,92 @@ func (f float64Stream) limit(min, max float64) float64Stream {
 		return v
 	}
 }
+
+func TestMemoryLimitHeapGoal(t *testing.T) {
+	const (
+		mb    = 1 << 20
+		limit = 100 * mb
+	)
+	// goalAfterHeadroom returns g after the pacer's headroom is applied.
+	goalAfterHeadroom := func(g uint64) uint64 {
+		return g - g/100*3
+	}
+	for _, test := range []struct {
+		name                 string
+		limit                int64
+		mapped, retained     uint64
+		heapMarked, wantGoal uint64
+	}{
+		{
+			name:     "NoLimit",
+			limit:    math.MaxInt64,
+			mapped:   10 * mb,
+			retained: 8 * mb,
+			wantGoal: goalAfterHeadroom(math.MaxInt64 - 2*mb),
+		},
+		{
+			name:       "AllHeap",
+			limit:      limit,
+			mapped:     50 * mb,
+			retained:   50 * mb,
+			heapMarked: 20 * mb,
+			wantGoal:   goalAfterHeadroom(limit),
+		},
+		{
+			name:       "NonHeap",
+			limit:      limit,
+			mapped:     50 * mb,
+			retained:   30 * mb,
+			heapMarked: 20 * mb,
+			wantGoal:   goalAfterHeadroom(limit - 20*mb),
+		},
+		{
+			name:       "Overage",
+			limit:      limit,
+			mapped:     110 * mb,
+			retained:   100 * mb,
+			heapMarked: 20 * mb,
+			wantGoal:   goalAfterHeadroom(limit - 10*mb - 10*mb),
+		},
+		{
+			name:       "BelowHeapMarked",
+			limit:      limit,
+			mapped:     110 * mb,
+			retained:   100 * mb,
+			heapMarked: 95 * mb,
+			wantGoal:   95 * mb,
+		},
+		{
+			name:       "Impossible",
+			limit:      limit,
+			mapped:     200 * mb,
+			retained:   50 * mb,
+			heapMarked: 20 * mb,
+			wantGoal:   20 * mb,
+		},
+		{
+			name:       "ZeroLimit",
+			limit:      0,
+			mapped:     50 * mb,
+			retained:   30 * mb,
+			heapMarked: 20 * mb,
+			wantGoal:   20 * mb,
+		},
+		{
+			name:       "InconsistentStats",
+			limit:      limit,
+			mapped:     30 * mb,
+			retained:   31 * mb,
+			heapMarked: 20 * mb,
+			wantGoal:   goalAfterHeadroom(limit),
+		},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			got := MemoryLimitHeapGoal(test.limit, test.mapped, test.retained, test.heapMarked)
+			if got != test.wantGoal {
+				t.Errorf("got goal %d, want %d", got, test.wantGoal)
+			}
+		})
+	}
+}
@@ -41,6 +41,13 @@
 // that there's more unscavenged memory to allocate out of, since each allocation
 // out of scavenged memory incurs a potentially expensive page fault.
 //
+// If a memory limit is set (see debug.SetMemoryLimit), the scavenger also
+// works to keep the total amount of memory mapped and ready for use by the
+// runtime (see mappedReady) below retainMemoryLimitPercent of that limit.
+// Because that total includes memory outside the heap, this second goal
+// is translated into a heap RSS goal by subtracting the non-heap portion,
+// and the scavenger uses whichever of the two goals is lower.
+//
 // The goal is updated after each GC and the scavenger's pacing parameters
 // (which live in mheap_) are updated to match. The pacing parameters work much
 // like the background sweeping parameters. The parameters define a line whose
@@ -78,6 +85,14 @@ const (
 	// the ever-changing layout of the heap.
 	retainExtraPercent = 10
 
+	// retainMemoryLimitPercent represents the fraction of the memory limit
+	// that the scavenger tries to keep total mapped-and-ready memory below.
+	//
+	// Leaving some room below the limit means the scavenger's goal is
+	// reached before the limit itself, giving the GC and the allocator
+	// some slack before synchronous scavenging on allocation kicks in.
+	retainMemoryLimitPercent = 95
+
 	// maxPagesPerPhysPage is the maximum number of supported runtime pages per
 	// physical page, based on maxPhysPageSize.
 	maxPagesPerPhysPage = maxPhysPageSize / pageSize
@@ -104,6 +119,17 @@ func heapRetained() uint64 {
 	return memstats.heap_sys.load() - atomic.Load64(&memstats.heap_released)
 }
 
+// mappedReady returns an estimate of the total memory the runtime has
+// mapped and that is ready for use, that is, everything the runtime
+// has obtained from the OS minus what has been released back to it.
+// This is the quantity the memory limit applies to.
+func mappedReady() uint64 {
+	return heapRetained() + atomic.Load64(&memstats.manual_inuse) +
+		memstats.stacks_sys.load() + memstats.mspan_sys.load() +
+		memstats.mcache_sys.load() + memstats.buckhash_sys.load() +
+		memstats.gcMiscSys.load() + memstats.other_sys.load()
+}
+
 // gcPaceScavenger updates the scavenger's pacing, particularly
 // its rate and RSS goal. For this, it requires the current heapGoal,
 // and the heapGoal for the previous GC cycle.
@@ -120,26 +146,6 @@ func heapRetained() uint64 {
 func gcPaceScavenger(heapGoal, lastHeapGoal uint64) {
 	assertWorldStoppedOrLockHeld(&mheap_.lock)
 
-	// If we're called before the first GC completed, disable scavenging.
-	// We never scavenge before the 2nd GC cycle anyway (we don't have enough
-	// information about the heap yet) so this is fine, and avoids a fault
-	// or garbage data later.
-	if lastHeapGoal == 0 {
-		atomic.Store64(&mheap_.scavengeGoal, ^uint64(0))
-		return
-	}
-	// Compute our scavenging goal.
-	goalRatio := float64(heapGoal) / float64(lastHeapGoal)
-	retainedGoal := uint64(float64(memstats.last_heap_inuse) * goalRatio)
-	// Add retainExtraPercent overhead to retainedGoal. This calculation
-	// looks strange but the purpose is to arrive at an integer division
-	// (e.g. if retainExtraPercent = 12.5, then we get a divisor of 8)
-	// that also avoids the overflow from a multiplication.
-	retainedGoal += retainedGoal / (1.0 / (retainExtraPercent / 100.0))
-	// Align it to a physical page boundary to make the following calculations
-	// a bit more exact.
-	retainedGoal = (retainedGoal + uint64(physPageSize) - 1) &^ (uint64(physPageSize) - 1)
-
 	// Represents where we are now in the heap's contribution to RSS in bytes.
 	//
 	// Guaranteed to always be a multiple of physPageSize on systems where
@@ -153,6 +159,30 @@ func gcPaceScavenger(heapGoal, lastHeapGoal uint64) {
 	// physical page.
 	retainedNow := heapRetained()
 
+	// Start with the goal implied by the memory limit, if any.
+	retainedGoal := memoryLimitRetainedGoal(gcController.memoryLimit.Load(), mappedReady(), retainedNow)
+
+	// If we're called before the first GC completed, don't compute a goal
+	// from the heap goal. We never scavenge for that reason before the 2nd
+	// GC cycle anyway (we don't have enough information about the heap yet)
+	// so this is fine, and avoids a fault or garbage data later.
+	if lastHeapGoal != 0 {
+		// Compute our scavenging goal.
+		goalRatio := float64(heapGoal) / float64(lastHeapGoal)
+		gcGoal := uint64(float64(memstats.last_heap_inuse) * goalRatio)
+		// Add retainExtraPercent overhead to gcGoal. This calculation
+		// looks strange but the purpose is to arrive at an integer division
+		// (e.g. if retainExtraPercent = 12.5, then we get a divisor of 8)
+		// that also avoids the overflow from a multiplication.
+		gcGoal += gcGoal / (1.0 / (retainExtraPercent / 100.0))
+		// Align it to a physical page boundary to make the following calculations
+		// a bit more exact.
+		gcGoal = (gcGoal + uint64(physPageSize) - 1) &^ (uint64(physPageSize) - 1)
+		if gcGoal < retainedGoal {
+			retainedGoal = gcGoal
+		}
+	}
+
 	// If we're already below our goal, or within one page of our goal, then disable
 	// the background scavenger. We disable the background scavenger if there's
 	// less than one physical page of work to do because it's not worth it.
@@ -163,6 +193,30 @@ func gcPaceScavenger(heapGoal, lastHeapGoal uint64) {
 	atomic.Store64(&mheap_.scavengeGoal, retainedGoal)
 }
 
+// memoryLimitRetainedGoal translates the memory limit into a goal for the
+// heap's contribution to RSS (as measured by heapRetained), given the current
+// total mapped-and-ready memory and retained heap memory.
+//
+// Returns ^uint64(0) if there is effectively no memory limit.
+func memoryLimitRetainedGoal(limit int64, mapped, retained uint64) uint64 {
+	if limit == maxInt64 {
+		return ^uint64(0)
+	}
+	goal := uint64(limit) / 100 * retainMemoryLimitPercent
+	// The stats making up mapped and retained aren't updated together,
+	// so be careful not to underflow.
+	nonHeap := uint64(0)
+	if mapped > retained {
+		nonHeap = mapped - retained
+	}
+	if nonHeap >= goal {
+		// Memory outside the heap alone exceeds our goal, so return
+		// as much heap memory as possible.
+		return 0
+	}
+	return goal - nonHeap
+}
+
 // Sleep/wait state of the background scavenger.
 var scavenge struct {
 	lock       mutex
@@ -200,7 +254,10 @@ func wakeScavenger() {
 		// In the case that we're racing with there's the low chance that
 		// we experience a spurious wake-up of the scavenger, but that's
 		// totally safe.
-		stopTimer(scavenge.timer)
+		// Use deltimer rather than stopTimer, which handles timers in
+		// synctest bubbles and may have write barriers, which sysmon
+		// does not allow.
+		deltimer(scavenge.timer)
 
 		// Unpark the goroutine and tell it that there may have been a pacing
 		// change. Note that we skip the scheduler's runnext slot because we
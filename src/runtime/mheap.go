@@ -1202,6 +1202,32 @@ func (h *mheap) allocSpan(npages uintptr, typ spanAllocType, spanclass spanClass
 
 	unlock(&h.lock)
 
+HaveSpan:
+	// Decide if we need to scavenge in response to what we just allocated.
+	// Specifically, we track the maximum amount of memory to scavenge of all
+	// the alternatives below, assuming that the maximum satisfies *all*
+	// conditions we check (e.g. if we need to scavenge X to satisfy the
+	// memory limit and Y to satisfy heap-growth scavenging, and Y > X, then
+	// it's fine to pick Y, because the memory limit is still satisfied).
+	//
+	// It's fine to do this after allocating because we expect any scavenged
+	// pages not to get touched until we return.
+	//
+	// The scavenging algorithm requires the heap lock to be dropped so it
+	// can acquire it only sparingly. This is a potentially expensive operation
+	// so it frees up other goroutines to allocate in the meanwhile.
+	bytesToScavenge := uintptr(0)
+	if limit := gcController.memoryLimit.Load(); limit != maxInt64 && !gcCPULimiter.limiting() {
+		// Assist with scavenging to maintain the memory limit by the amount
+		// that we expect to page in. Don't do this if the GC CPU limiter is
+		// on, since scavenging is expensive and we're already short on CPU.
+		inUse := mappedReady()
+		// Be careful about overflow, especially with uintptrs. Even on 32-bit
+		// platforms someone can set a really big memory limit that isn't maxInt64.
+		if uint64(scav)+inUse > uint64(limit) {
+			bytesToScavenge = uintptr(uint64(scav) + inUse - uint64(limit))
+		}
+	}
 	if growth > 0 {
 		// We just caused a heap growth, so scavenge down what will soon be used.
 		// By scavenging inline we deal with the failure to allocate out of
@@ -1209,19 +1235,24 @@ func (h *mheap) allocSpan(npages uintptr, typ spanAllocType, spanclass spanClass
 		// likely to be re-used.
 		scavengeGoal := atomic.Load64(&h.scavengeGoal)
 		if retained := heapRetained(); retained+uint64(growth) > scavengeGoal {
-			// The scavenging algorithm requires the heap lock to be dropped so it
-			// can acquire it only sparingly. This is a potentially expensive operation
-			// so it frees up other goroutines to allocate in the meanwhile. In fact,
-			// they can make use of the growth we just created.
 			todo := growth
 			if overage := uintptr(retained + uint64(growth) - scavengeGoal); todo > overage {
 				todo = overage
 			}
-			h.pages.scavenge(todo)
+			if todo > bytesToScavenge {
+				bytesToScavenge = todo
+			}
 		}
 	}
+	if bytesToScavenge > 0 {
+		start := nanotime()
+		h.pages.scavenge(bytesToScavenge)
+		// Scavenging on behalf of the memory limit is GC-adjacent work
+		// that takes time away from the application, so count it against
+		// the GC CPU limiter.
+		gcCPULimiter.addGCTime(nanotime() - start)
+	}
 
-HaveSpan:
 	// At this point, both s != nil and base != 0, and the heap
 	// lock is no longer held. Initialize the span.
 	s.init(base, npages)
@@ -1286,6 +1317,7 @@ HaveSpan:
 	if typ.manual() {
 		// Manually managed memory doesn't count toward heap_sys.
 		memstats.heap_sys.add(-int64(nbytes))
+		atomic.Xadd64(&memstats.manual_inuse, int64(nbytes))
 	}
 	// Update consistent stats.
 	stats := memstats.heapStats.acquire()
@@ -1492,6 +1524,7 @@ func (h *mheap) freeSpanLocked(s *mspan, typ spanAllocType) {
 	if typ.manual() {
 		// Manually managed memory doesn't count toward heap_sys, so add it back.
 		memstats.heap_sys.add(int64(nbytes))
+		atomic.Xadd64(&memstats.manual_inuse, -int64(nbytes))
 	}
 	// Update consistent stats.
 	stats := memstats.heapStats.acquire()
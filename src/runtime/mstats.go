@@ -36,6 +36,7 @@ type mstats struct {
 	heap_sys      sysMemStat // virtual address space obtained from system for GC'd heap
 	heap_inuse    uint64     // bytes in mSpanInUse spans
 	heap_released uint64     // bytes released to the os
+	manual_inuse  uint64     // bytes in manually-managed spans (stacks, GC work bufs, etc.)
 
 	// heap_objects is not used by the runtime directly and instead
 	// computed on the fly by updatememstats.
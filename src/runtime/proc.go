@@ -253,6 +253,7 @@ func main() {
 	}
 	fn := main_main // make an indirect call, as the linker doesn't know the address of the main package when laying down the runtime
 	fn()
+	runExitHooks(0)
 	if raceenabled {
 		racefini()
 	}
@@ -281,10 +282,11 @@ func main() {
 	}
 }
 
-// os_beforeExit is called from os.Exit(0).
+// os_beforeExit is called from os.Exit.
 //go:linkname os_beforeExit os.runtime_beforeExit
-func os_beforeExit() {
-	if raceenabled {
+func os_beforeExit(exitCode int) {
+	runExitHooks(exitCode)
+	if exitCode == 0 && raceenabled {
 		racefini()
 	}
 }
@@ -673,7 +675,8 @@ func schedinit() {
 	lockInit(&trace.stringsLock, lockRankTraceStrings)
 	lockInit(&trace.lock, lockRankTrace)
 	lockInit(&cpuprof.lock, lockRankCpuprof)
-	lockInit(&trace.stackTab.lock, lockRankTraceStackTab)
+	lockInit(&trace.stackTab[0].lock, lockRankTraceStackTab)
+	lockInit(&trace.stackTab[1].lock, lockRankTraceStackTab)
 	// Enforce that this lock is always a leaf lock.
 	// All of this lock's critical sections should be
 	// extremely short.
@@ -985,6 +988,10 @@ func casgstatus(gp *g, oldval, newval uint32) {
 		}
 	}
 
+	if b := gp.bubble; b != nil {
+		b.changegstatus(gp, oldval, newval)
+	}
+
 	// Handle tracking for scheduling latencies.
 	if oldval == _Grunning {
 		// Track every 8th time a goroutine transitions out of running.
@@ -3507,6 +3514,14 @@ func park_m(gp *g) {
 		traceGoPark(_g_.m.waittraceev, _g_.m.waittraceskip)
 	}
 
+	// A goroutine in a synctest bubble must not be seen as durably
+	// blocked until unlockf has run: until then, it may not have
+	// registered itself where another goroutine could wake it.
+	b := gp.bubble
+	if b != nil {
+		b.incActive()
+	}
+
 	casgstatus(gp, _Grunning, _Gwaiting)
 	dropg()
 
@@ -3519,9 +3534,15 @@ func park_m(gp *g) {
 				traceGoUnpark(gp, 2)
 			}
 			casgstatus(gp, _Gwaiting, _Grunnable)
+			if b != nil {
+				b.decActive()
+			}
 			execute(gp, true) // Schedule it back, never returns.
 		}
 	}
+	if b != nil {
+		b.decActive()
+	}
 	schedule()
 }
 
@@ -3661,6 +3682,7 @@ func goexit0(gp *g) {
 	gp.param = nil
 	gp.labels = nil
 	gp.timer = nil
+	gp.bubble = nil
 
 	if gcBlackenEnabled != 0 && gp.gcAssistBytes > 0 {
 		// Flush assist credit to the global pool. This gives
@@ -4305,6 +4327,9 @@ func newproc1(fn *funcval, callergp *g, callerpc uintptr) *g {
 	}
 	if isSystemGoroutine(newg, false) {
 		atomic.Xadd(&sched.ngsys, +1)
+	} else {
+		// Only user goroutines join the creator's synctest bubble.
+		newg.bubble = callergp.bubble
 	}
 	// Track initial transition?
 	newg.trackingSeq = uint8(fastrand())
@@ -5361,6 +5386,8 @@ func sysmon() {
 			// Kick the scavenger awake if someone requested it.
 			wakeScavenger()
 		}
+		// Account for GC CPU time spent since the last update.
+		gcCPULimiter.update(now, gomaxprocs)
 		// retake P's blocked in syscalls
 		// and preempt long running G's
 		if retake(now) != 0 {
@@ -469,6 +469,7 @@ type g struct {
 	sysexitticks   int64    // cputicks when syscall has returned (for tracing)
 	traceseq       uint64   // trace event sequencer
 	tracelastp     puintptr // last P emitted an event for this goroutine
+	tracegen       uint64   // last trace generation that has the status of this goroutine
 	lockedm        muintptr
 	sig            uint32
 	writebuf       []byte
@@ -485,6 +486,10 @@ type g struct {
 	timer          *timer         // cached timer for time.Sleep
 	selectDone     uint32         // are we participating in a select and did someone win the race?
 
+	// bubble is the synctest bubble containing this goroutine, if any.
+	// Goroutines started by a goroutine in a bubble join its bubble.
+	bubble *synctestBubble
+
 	// Per-G GC state
 
 	// gcAssistBytes is this G's GC assist credit in terms of
@@ -560,7 +565,11 @@ type m struct {
 	waittraceskip int
 	startingtrace bool
 	syscalltick   uint32
-	freelink      *m // on sched.freem
+	tracegen      uint64 // trace generation the events of this M belong to
+	traceseqlock  uint32 // odd while this M writes trace events; see traceAcquireBuffer
+	tracedepth    int32  // nesting depth of traceAcquireBuffer
+	tracelink     *m     // on the list of Ms traceAdvance waits for
+	freelink      *m     // on sched.freem
 
 	// mFixup is used to synchronize OS related m state
 	// (credentials etc) use mutex to access. To avoid deadlocks
@@ -658,7 +667,17 @@ type p struct {
 		buf [128]*mspan
 	}
 
-	tracebuf traceBufPtr
+	// tracebuf holds the trace buffers of this P, one for each of
+	// the two generations that may be written to at the same time,
+	// indexed by generation number modulo 2.
+	tracebuf [2]traceBufPtr
+
+	// tracegen is the last trace generation that has the status of
+	// this P, and traceg is the goroutine that the P runs as far as
+	// the trace is concerned: the goroutine of the last traceEvGoStart
+	// on this P, until it stops running or blocks.
+	tracegen uint64
+	traceg   guintptr
 
 	// traceSweep indicates the sweep events should be traced.
 	// This is used to defer the sweep start event until a span
@@ -1053,6 +1072,12 @@ const (
 	waitReasonGCWorkerIdle                            // "GC worker (idle)"
 	waitReasonPreempted                               // "preempted"
 	waitReasonDebugCall                               // "debug call"
+	waitReasonSyncWaitGroupWait                       // "sync.WaitGroup.Wait"
+	waitReasonSynctestRun                             // "synctest.Run"
+	waitReasonSynctestWait                            // "synctest.Wait"
+	waitReasonSynctestChanReceive                     // "chan receive (synctest)"
+	waitReasonSynctestChanSend                        // "chan send (synctest)"
+	waitReasonSynctestSelect                          // "select (synctest)"
 )
 
 var waitReasonStrings = [...]string{
@@ -1083,6 +1108,12 @@ var waitReasonStrings = [...]string{
 	waitReasonGCWorkerIdle:          "GC worker (idle)",
 	waitReasonPreempted:             "preempted",
 	waitReasonDebugCall:             "debug call",
+	waitReasonSyncWaitGroupWait:     "sync.WaitGroup.Wait",
+	waitReasonSynctestRun:           "synctest.Run",
+	waitReasonSynctestWait:          "synctest.Wait",
+	waitReasonSynctestChanReceive:   "chan receive (synctest)",
+	waitReasonSynctestChanSend:      "chan send (synctest)",
+	waitReasonSynctestSelect:        "select (synctest)",
 }
 
 func (w waitReason) String() string {
@@ -1092,6 +1123,27 @@ func (w waitReason) String() string {
 	return waitReasonStrings[w]
 }
 
+// isIdleInSynctest reports whether a goroutine waiting for reason w is
+// durably blocked: only another goroutine in its synctest bubble can wake it.
+//go:nosplit
+func (w waitReason) isIdleInSynctest() bool {
+	switch w {
+	case waitReasonChanReceiveNilChan,
+		waitReasonChanSendNilChan,
+		waitReasonSelectNoCases,
+		waitReasonSleep,
+		waitReasonSyncCondWait,
+		waitReasonSyncWaitGroupWait,
+		waitReasonSynctestRun,
+		waitReasonSynctestWait,
+		waitReasonSynctestChanReceive,
+		waitReasonSynctestChanSend,
+		waitReasonSynctestSelect:
+		return true
+	}
+	return false
+}
+
 var (
 	allm       *m
 	gomaxprocs int32
@@ -165,6 +165,12 @@ func selectgo(cas0 *scase, order0 *uint16, pc0 *uintptr, nsends, nrecvs int, blo
 
 	// generate permuted order
 	norder := 0
+	// A select is durably blocked in a synctest bubble only if all of
+	// its channels belong to the bubble.
+	reason := waitReasonSelect
+	if getg().bubble != nil {
+		reason = waitReasonSynctestSelect
+	}
 	for i := range scases {
 		cas := &scases[i]
 
@@ -174,6 +180,14 @@ func selectgo(cas0 *scase, order0 *uint16, pc0 *uintptr, nsends, nrecvs int, blo
 			continue
 		}
 
+		if cas.c.synctest {
+			if getg().bubble == nil {
+				panic(plainError("select on synctest channel from outside bubble"))
+			}
+		} else {
+			reason = waitReasonSelect
+		}
+
 		j := fastrandn(uint32(norder + 1))
 		pollorder[norder] = pollorder[j]
 		pollorder[j] = uint16(i)
@@ -325,7 +339,7 @@ func selectgo(cas0 *scase, order0 *uint16, pc0 *uintptr, nsends, nrecvs int, blo
 	// changes and when we set gp.activeStackChans is not safe for
 	// stack shrinking.
 	atomic.Store8(&gp.parkingOnChan, 1)
-	gopark(selparkcommit, nil, waitReasonSelect, traceEvGoBlockSelect, 1)
+	gopark(selparkcommit, nil, reason, traceEvGoBlockSelect, 1)
 	gp.activeStackChans = false
 
 	sellock(scases, lockorder)
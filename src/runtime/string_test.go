@@ -454,3 +454,49 @@ func TestAtoi32(t *testing.T) {
 		}
 	}
 }
+
+type parseByteCountTest struct {
+	in  string
+	out int64
+	ok  bool
+}
+
+var parseByteCountTests = []parseByteCountTest{
+	{"", 0, false},
+	{"B", 0, false},
+	{"iB", 0, false},
+	{"KiB", 0, false},
+	{"-1", 0, false},
+	{"+1", 0, false},
+	{"1.5MiB", 0, false},
+	{"1KB", 0, false},
+	{"1kiB", 0, false},
+	{"1PiB", 0, false},
+	{"0", 0, true},
+	{"0B", 0, true},
+	{"0KiB", 0, true},
+	{"1", 1, true},
+	{"1B", 1, true},
+	{"1KiB", 1 << 10, true},
+	{"1MiB", 1 << 20, true},
+	{"1GiB", 1 << 30, true},
+	{"1TiB", 1 << 40, true},
+	{"512MiB", 512 << 20, true},
+	{"9223372036854775807", 1<<63 - 1, true},
+	{"9223372036854775807B", 1<<63 - 1, true},
+	{"9223372036854775808", 0, false},
+	{"8388607TiB", 8388607 << 40, true},
+	{"8388608TiB", 0, false},
+	{"18446744073709551616", 0, false},
+}
+
+func TestParseByteCount(t *testing.T) {
+	for i := range parseByteCountTests {
+		test := &parseByteCountTests[i]
+		out, ok := runtime.ParseByteCount(test.in)
+		if test.out != out || test.ok != ok {
+			t.Errorf("parseByteCount(%q) = (%v, %v) want (%v, %v)",
+				test.in, out, ok, test.out, test.ok)
+		}
+	}
+}
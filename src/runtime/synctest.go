@@ -0,0 +1,371 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+import "unsafe"
+
+// A synctestBubble is a group of goroutines started by synctestRun
+// (testing/synctest.Run) that share a fake clock.
+//
+// A goroutine in a bubble is durably blocked when only another
+// goroutine in the bubble can wake it: see waitReason.isIdleInSynctest.
+// Once every goroutine in the bubble is durably blocked, the bubble is
+// idle, and the fake clock advances to the next timer of the bubble.
+//
+// Timers started in a bubble are kept in the bubble's own heap rather
+// than in a P's heap, and are run by the goroutine that called
+// synctestRun.
+type synctestBubble struct {
+	mu      mutex
+	timers  []*timer // heap of pending timers, ordered by when
+	now     int64    // current fake time
+	root    *g       // goroutine that called synctestRun
+	waiter  *g       // goroutine blocked in synctestWait, if any
+	waiting bool     // true if a goroutine is calling synctestWait
+
+	// The bubble is idle when running == 0 and active == 0.
+	total   int // goroutines in the bubble
+	running int // goroutines in the bubble that are not durably blocked
+	active  int // goroutines being parked or being woken up
+}
+
+// synctestBaseTime is the fake time at which every bubble starts:
+// midnight UTC 2000-01-01.
+const synctestBaseTime = 946684800000000000
+
+// changegstatus is called by casgstatus when the status of gp, a
+// goroutine in b, changes from oldval to newval.
+//go:nosplit
+func (b *synctestBubble) changegstatus(gp *g, oldval, newval uint32) {
+	// Most status changes, such as entering or leaving a system call
+	// or growing the stack, don't start or stop a goroutine or change
+	// whether it is durably blocked. Return quickly for those without
+	// locking b.mu: some are made where the stack must not grow.
+	if oldval == _Gcopystack || newval == _Gcopystack {
+		return
+	}
+	totalDelta := 0
+	wasRunning := true
+	switch oldval {
+	case _Gdead:
+		wasRunning = false
+		totalDelta++
+	case _Gwaiting:
+		if gp.waitreason.isIdleInSynctest() {
+			wasRunning = false
+		}
+	}
+	isRunning := true
+	switch newval {
+	case _Gdead:
+		isRunning = false
+		totalDelta--
+	case _Gwaiting:
+		if gp.waitreason.isIdleInSynctest() {
+			isRunning = false
+		}
+	}
+	if wasRunning == isRunning && totalDelta == 0 {
+		return
+	}
+	systemstack(func() {
+		b.updateCounts(gp, totalDelta, wasRunning, isRunning, newval)
+	})
+}
+
+func (b *synctestBubble) updateCounts(gp *g, totalDelta int, wasRunning, isRunning bool, newval uint32) {
+	lock(&b.mu)
+	b.total += totalDelta
+	if wasRunning != isRunning {
+		if isRunning {
+			b.running++
+			// Goroutines waiting for another reason sometimes
+			// enter _Gwaiting without setting a wait reason.
+			// Make sure they are not taken for durably blocked.
+			gp.waitreason = waitReasonZero
+		} else {
+			b.running--
+			if raceenabled && newval != _Gdead {
+				// Everything gp did happens before whatever
+				// follows the bubble becoming idle.
+				racereleasemergeg(gp, unsafe.Pointer(b))
+			}
+		}
+	}
+	if b.total < 0 || b.running < 0 {
+		throw("synctest: bad goroutine count")
+	}
+	wake := b.maybeWakeLocked()
+	unlock(&b.mu)
+	if wake != nil {
+		goready(wake, 0)
+	}
+}
+
+// incActive and decActive bracket the parking of a goroutine in b.
+// While a goroutine is being parked, the bubble is not idle.
+func (b *synctestBubble) incActive() {
+	lock(&b.mu)
+	b.active++
+	unlock(&b.mu)
+}
+
+func (b *synctestBubble) decActive() {
+	lock(&b.mu)
+	b.active--
+	if b.active < 0 {
+		throw("synctest: active < 0")
+	}
+	wake := b.maybeWakeLocked()
+	unlock(&b.mu)
+	if wake != nil {
+		goready(wake, 0)
+	}
+}
+
+// maybeWakeLocked returns the goroutine to wake up if the bubble is
+// idle: the goroutine blocked in synctestWait if any, or else the root
+// goroutine. The caller must hold b.mu and call goready after
+// releasing it.
+func (b *synctestBubble) maybeWakeLocked() *g {
+	if b.running > 0 || b.active > 0 {
+		return nil
+	}
+	// The woken goroutine counts as active until it has run and
+	// decremented active, so that nothing else is woken meanwhile.
+	b.active++
+	if b.waiter != nil {
+		return b.waiter
+	}
+	return b.root
+}
+
+// synctestRun runs f in a new goroutine in a new bubble. It returns when
+// every goroutine in the bubble has exited, and panics if they all
+// become durably blocked with no pending timer to wake them.
+//
+// synctestRun is linked into internal/synctest as Run.
+func synctestRun(f func()) {
+	gp := getg()
+	if gp.bubble != nil {
+		panic(plainError("synctest.Run called from within a synctest bubble"))
+	}
+	b := &synctestBubble{
+		now:     synctestBaseTime,
+		root:    gp,
+		total:   1,
+		running: 1,
+	}
+	lockInit(&b.mu, lockRankSynctest)
+	gp.bubble = b
+	defer func() {
+		gp.bubble = nil
+	}()
+
+	fv := *(**funcval)(unsafe.Pointer(&f))
+	newproc(fv)
+
+	for {
+		b.runTimers()
+		gopark(nil, nil, waitReasonSynctestRun, traceEvGoBlock, 0)
+		if raceenabled {
+			raceacquireg(gp, unsafe.Pointer(b))
+		}
+
+		lock(&b.mu)
+		b.active--
+		if b.total == 1 || len(b.timers) == 0 {
+			break
+		}
+		if next := b.timers[0].when; next > b.now {
+			b.now = next
+		}
+		unlock(&b.mu)
+	}
+	total := b.total
+	unlock(&b.mu)
+	if total != 1 {
+		panic(plainError("deadlock: all goroutines in bubble are blocked"))
+	}
+}
+
+// synctestWait blocks until every other goroutine in the bubble of the
+// current goroutine is durably blocked.
+//
+// synctestWait is linked into internal/synctest as Wait.
+func synctestWait() {
+	gp := getg()
+	b := gp.bubble
+	if b == nil {
+		panic(plainError("synctest.Wait called from outside a bubble"))
+	}
+	lock(&b.mu)
+	// Use b.waiting rather than b.waiter to detect concurrent calls:
+	// b.waiter is not set until this goroutine is parked.
+	if b.waiting {
+		unlock(&b.mu)
+		panic(plainError("synctest.Wait called while another Wait is in progress"))
+	}
+	b.waiting = true
+	unlock(&b.mu)
+
+	gopark(synctestwait_c, nil, waitReasonSynctestWait, traceEvGoBlock, 0)
+
+	lock(&b.mu)
+	b.active--
+	if b.active < 0 {
+		throw("synctest: active < 0")
+	}
+	b.waiter = nil
+	b.waiting = false
+	unlock(&b.mu)
+
+	if raceenabled {
+		raceacquireg(gp, unsafe.Pointer(b))
+	}
+}
+
+func synctestwait_c(gp *g, _ unsafe.Pointer) bool {
+	lock(&gp.bubble.mu)
+	gp.bubble.waiter = gp
+	unlock(&gp.bubble.mu)
+	return true
+}
+
+// runTimers runs the timers of b that have expired.
+// It must be called by the root goroutine of b, so that the timer
+// functions run in the bubble.
+func (b *synctestBubble) runTimers() {
+	for {
+		lock(&b.mu)
+		if len(b.timers) == 0 || b.timers[0].when > b.now {
+			unlock(&b.mu)
+			return
+		}
+		t := b.timers[0]
+		f, arg, seq := t.f, t.arg, t.seq
+		if t.period > 0 {
+			// Leave in heap but adjust next time to fire.
+			delta := t.when - b.now
+			t.when += t.period * (1 + -delta/t.period)
+			if t.when < 0 { // check for overflow.
+				t.when = maxWhen
+			}
+			siftdownTimer(b.timers, 0)
+		} else {
+			b.deltimerLocked(t)
+			t.status = timerNoStatus
+		}
+		unlock(&b.mu)
+
+		if raceenabled {
+			raceacquire(unsafe.Pointer(t))
+		}
+		f(arg, seq)
+	}
+}
+
+// checkTimerBubble panics if the current goroutine and t are not in the
+// same synctest bubble, since they do not share a clock.
+func checkTimerBubble(t *timer) {
+	if b := getg().bubble; t.bubble != b {
+		if t.bubble == nil {
+			panic(plainError("timer created outside synctest bubble used within it"))
+		}
+		panic(plainError("synctest timer used from outside its bubble"))
+	}
+}
+
+// addtimer adds t, a newly created timer, to b.
+func (b *synctestBubble) addtimer(t *timer) {
+	if t.when <= 0 {
+		throw("timer when must be positive")
+	}
+	if t.period < 0 {
+		throw("timer period must be non-negative")
+	}
+	if t.status != timerNoStatus {
+		throw("addtimer called with initialized timer")
+	}
+	lock(&b.mu)
+	b.addtimerLocked(t)
+	unlock(&b.mu)
+}
+
+// deltimer stops t. It reports whether t was stopped before being run.
+func (b *synctestBubble) deltimer(t *timer) bool {
+	lock(&b.mu)
+	pending := b.deltimerLocked(t)
+	unlock(&b.mu)
+	return pending
+}
+
+// resettimer moves t to fire at when, starting it if it is stopped.
+// It reports whether t was modified before being run.
+func (b *synctestBubble) resettimer(t *timer, when int64) bool {
+	if when < 0 {
+		when = maxWhen
+	}
+	lock(&b.mu)
+	pending := b.deltimerLocked(t)
+	t.when = when
+	b.addtimerLocked(t)
+	unlock(&b.mu)
+	return pending
+}
+
+// modtimer modifies t, starting it if it is stopped.
+func (b *synctestBubble) modtimer(t *timer, when, period int64, f func(any, uintptr), arg any, seq uintptr) {
+	if when < 0 {
+		when = maxWhen
+	}
+	lock(&b.mu)
+	b.deltimerLocked(t)
+	t.when = when
+	t.period = period
+	t.f = f
+	t.arg = arg
+	t.seq = seq
+	b.addtimerLocked(t)
+	unlock(&b.mu)
+}
+
+// addtimerLocked adds t to the heap of b.
+// The caller must hold b.mu.
+func (b *synctestBubble) addtimerLocked(t *timer) {
+	t.status = timerWaiting
+	i := len(b.timers)
+	b.timers = append(b.timers, t)
+	siftupTimer(b.timers, i)
+}
+
+// deltimerLocked removes t from the heap of b, if it is there.
+// It reports whether t was removed. The caller must hold b.mu.
+func (b *synctestBubble) deltimerLocked(t *timer) bool {
+	if t.status != timerWaiting {
+		return false
+	}
+	for i, tt := range b.timers {
+		if tt != t {
+			continue
+		}
+		last := len(b.timers) - 1
+		if i != last {
+			b.timers[i] = b.timers[last]
+		}
+		b.timers[last] = nil
+		b.timers = b.timers[:last]
+		if i != last {
+			// Moving to i may have moved the last timer to a new parent,
+			// so sift up to preserve the heap guarantee.
+			siftupTimer(b.timers, i)
+			siftdownTimer(b.timers, i)
+		}
+		t.status = timerRemoved
+		return true
+	}
+	throw("synctest: timer not in heap")
+	return false
+}
@@ -37,6 +37,11 @@ type timer struct {
 
 	// The status field holds one of the values below.
 	status uint32
+
+	// If the timer was started in a synctest bubble, the bubble.
+	// Such timers live in the bubble's heap, not in a P's heap,
+	// and run on the bubble's fake clock. See synctest.go.
+	bubble *synctestBubble
 }
 
 // Code outside this file has to be careful in using a timer value.
@@ -172,6 +177,26 @@ const verifyTimers = false
 
 // time.now is implemented in assembly.
 
+// time_runtimeNow returns the current time, or the fake time of the
+// synctest bubble containing the current goroutine.
+//go:linkname time_runtimeNow time.runtimeNow
+func time_runtimeNow() (sec int64, nsec int32, mono int64) {
+	if b := getg().bubble; b != nil {
+		return b.now / 1e9, int32(b.now % 1e9), b.now
+	}
+	return time_now()
+}
+
+// time_runtimeNano is nanotime, or the fake clock of the synctest
+// bubble containing the current goroutine.
+//go:linkname time_runtimeNano time.runtimeNano
+func time_runtimeNano() int64 {
+	if b := getg().bubble; b != nil {
+		return b.now
+	}
+	return nanotime()
+}
+
 // timeSleep puts the current goroutine to sleep for at least ns nanoseconds.
 //go:linkname timeSleep time.Sleep
 func timeSleep(ns int64) {
@@ -187,7 +212,12 @@ func timeSleep(ns int64) {
 	}
 	t.f = goroutineReady
 	t.arg = gp
-	t.nextwhen = nanotime() + ns
+	t.bubble = gp.bubble
+	if t.bubble != nil {
+		t.nextwhen = t.bubble.now + ns
+	} else {
+		t.nextwhen = nanotime() + ns
+	}
 	if t.nextwhen < 0 { // check for overflow.
 		t.nextwhen = maxWhen
 	}
@@ -200,6 +230,10 @@ func timeSleep(ns int64) {
 // timer function, goroutineReady, before the goroutine has been parked.
 func resetForSleep(gp *g, ut unsafe.Pointer) bool {
 	t := (*timer)(ut)
+	if t.bubble != nil {
+		t.bubble.resettimer(t, t.nextwhen)
+		return true
+	}
 	resettimer(t, t.nextwhen)
 	return true
 }
@@ -210,6 +244,11 @@ func startTimer(t *timer) {
 	if raceenabled {
 		racerelease(unsafe.Pointer(t))
 	}
+	if b := getg().bubble; b != nil {
+		t.bubble = b
+		b.addtimer(t)
+		return
+	}
 	addtimer(t)
 }
 
@@ -217,6 +256,10 @@ func startTimer(t *timer) {
 // It reports whether t was stopped before being run.
 //go:linkname stopTimer time.stopTimer
 func stopTimer(t *timer) bool {
+	if t.bubble != nil {
+		checkTimerBubble(t)
+		return t.bubble.deltimer(t)
+	}
 	return deltimer(t)
 }
 
@@ -227,12 +270,21 @@ func resetTimer(t *timer, when int64) bool {
 	if raceenabled {
 		racerelease(unsafe.Pointer(t))
 	}
+	if t.bubble != nil || getg().bubble != nil {
+		checkTimerBubble(t)
+		return t.bubble.resettimer(t, when)
+	}
 	return resettimer(t, when)
 }
 
 // modTimer modifies an existing timer.
 //go:linkname modTimer time.modTimer
 func modTimer(t *timer, when, period int64, f func(any, uintptr), arg any, seq uintptr) {
+	if t.bubble != nil || getg().bubble != nil {
+		checkTimerBubble(t)
+		t.bubble.modtimer(t, when, period, f, arg, seq)
+		return
+	}
 	modtimer(t, when, period, f, arg, seq)
 }
 
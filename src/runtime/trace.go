@@ -70,12 +70,17 @@ const (
 	traceEvUserTaskEnd       = 46 // end of a task [timestamp, internal task id, stack]
 	traceEvUserRegion        = 47 // trace.WithRegion [timestamp, internal task id, mode(0:start, 1:end), stack, name string]
 	traceEvUserLog           = 48 // trace.Log [timestamp, internal task id, key string id, stack, value string]
-	traceEvCount             = 49
+	traceEvGeneration        = 49 // start of a new generation [generation number]
+	traceEvGCSweepActive     = 50 // denotes that a sweep is in progress on the P at the start of a generation [timestamp]
+	traceEvCount             = 51
 	// Byte is used but only 6 bits are available for event type.
 	// The remaining 2 bits are used to specify the number of arguments.
 	// That means, the max event type value is 63.
 )
 
+// traceAdvancePeriod is the approximate time between trace generations.
+const traceAdvancePeriod = 1e9 // 1 second
+
 const (
 	// Timestamps in trace are cputicks/traceTickDiv.
 	// This makes absolute values of timestamp diffs smaller,
@@ -108,15 +113,22 @@ const (
 
 // trace is global tracing context.
 var trace struct {
+	// gen is the current generation number. It is accessed atomically;
+	// keep it at the top to ensure alignment on 32-bit systems.
+	// Generation numbers keep increasing across tracing sessions, so
+	// that a goroutine or P status recorded in an earlier session never
+	// looks current.
+	gen uint64
+
 	lock          mutex       // protects the following members
 	lockOwner     *g          // to avoid deadlocks during recursive lock locks
 	enabled       bool        // when set runtime traces events
 	shutdown      bool        // set when we are waiting for trace reader to finish after setting enabled to false
 	headerWritten bool        // whether ReadTrace has emitted trace header
-	footerWritten bool        // whether ReadTrace has emitted trace footer
 	shutdownSema  uint32      // used to wait for ReadTrace completion
 	seqStart      uint64      // sequence number when tracing was started
 	ticksStart    int64       // cputicks when tracing was started
+	advancer      *note       // wakes up the goroutine that advances generations
 	ticksEnd      int64       // cputicks when tracing was stopped
 	timeStart     int64       // nanotime when tracing was started
 	timeEnd       int64       // nanotime when tracing was stopped
@@ -125,29 +137,39 @@ var trace struct {
 	empty         traceBufPtr // stack of empty buffers
 	fullHead      traceBufPtr // queue of full buffers
 	fullTail      traceBufPtr
-	reader        guintptr        // goroutine that called ReadTrace, or nil
-	stackTab      traceStackTable // maps stack traces to unique ids
+	pendingHead   traceBufPtr // queue of full buffers of the generation after queueGen
+	pendingTail   traceBufPtr
+	queueGen      uint64   // generation whose buffers go to the queue of full buffers
+	reader        guintptr // goroutine that called ReadTrace, or nil
 
-	// Dictionary for traceEvString.
+	// stackTab maps stack traces to unique ids, separately for each of
+	// the two generations that may be written to at the same time.
+	stackTab [2]traceStackTable
+
+	// Dictionaries for traceEvString, one per generation as stackTab.
 	//
 	// TODO: central lock to access the map is not ideal.
 	//   option: pre-assign ids to all user annotation region names and tags
 	//   option: per-P cache
 	//   option: sync.Map like data structure
 	stringsLock mutex
-	strings     map[string]uint64
-	stringSeq   uint64
+	strings     [2]map[string]uint64
+	stringSeq   [2]uint64
 
 	// markWorkerLabels maps gcMarkWorkerMode to string ID.
-	markWorkerLabels [len(gcMarkWorkerModeStrings)]uint64
+	markWorkerLabels [2][len(gcMarkWorkerModeStrings)]uint64
 
-	bufLock mutex       // protects buf
-	buf     traceBufPtr // global trace buffer, used when running without a p
+	bufLock mutex          // protects buf
+	buf     [2]traceBufPtr // global trace buffers, used when running without a p
 }
 
+// traceAdvanceSema serializes traceAdvance with StartTrace and StopTrace.
+var traceAdvanceSema uint32 = 1
+
 // traceBufHeader is per-P tracing buffer.
 type traceBufHeader struct {
 	link      traceBufPtr             // in trace.empty/full
+	gen       uint64                  // generation of the events in the buffer
 	lastTicks uint64                  // when we wrote the last event
 	pos       int                     // next write offset in arr
 	stk       [traceStackSize]uintptr // scratch buffer for traceback
@@ -182,6 +204,9 @@ func traceBufPtrOf(b *traceBuf) traceBufPtr {
 // Most clients should use the runtime/trace package or the testing package's
 // -test.trace flag instead of calling StartTrace directly.
 func StartTrace() error {
+	// Wait for traceAdvance to finish with the previous tracing session.
+	semacquire(&traceAdvanceSema)
+
 	// Stop the world so that we can take a consistent snapshot
 	// of all goroutines at the beginning of the trace.
 	// Do not stop the world during GC so we ensure we always see
@@ -203,6 +228,7 @@ func StartTrace() error {
 		unlock(&trace.bufLock)
 		unlock(&sched.sysmonlock)
 		startTheWorldGC()
+		semrelease(&traceAdvanceSema)
 		return errorString("tracing is already enabled")
 	}
 
@@ -216,6 +242,31 @@ func StartTrace() error {
 	_g_ := getg()
 	_g_.m.startingtrace = true
 
+	// string to id mapping
+	//  0 : reserved for an empty string
+	//  remaining: other strings registered by traceString
+	for i := range trace.strings {
+		trace.stringSeq[i] = 0
+		trace.strings[i] = make(map[string]uint64)
+	}
+
+	// The trace begins with a new generation. Queue its marker
+	// before any of the events below can be flushed.
+	gen := trace.gen + 1
+	atomic.Store64(&trace.gen, gen)
+	lock(&trace.lock)
+	trace.queueGen = gen
+	traceGenerationMarker(gen)
+	unlock(&trace.lock)
+
+	// The Ps are stopped and will emit traceEvProcStart before any
+	// other event, so their status needs no other events. Loop over
+	// all allocated Ps because dead Ps may be reused later.
+	for _, pp := range allp[:cap(allp)] {
+		pp.tracegen = gen
+		pp.traceg = 0
+	}
+
 	// Obtain current stack ID to use in all traceEvGoCreate events below.
 	mp := acquirem()
 	stkBuf := make([]uintptr, traceStackSize)
@@ -228,8 +279,9 @@ func StartTrace() error {
 		if status != _Gdead {
 			gp.traceseq = 0
 			gp.tracelastp = getg().m.p
+			gp.tracegen = gen
 			// +PCQuantum because traceFrameForPC expects return PCs and subtracts PCQuantum.
-			id := trace.stackTab.put([]uintptr{gp.startpc + sys.PCQuantum})
+			id := trace.stackTab[gen%2].put([]uintptr{gp.startpc + sys.PCQuantum})
 			traceEvent(traceEvGoCreate, -1, uint64(gp.goid), uint64(id), stackID)
 		}
 		if status == _Gwaiting {
@@ -253,38 +305,38 @@ func StartTrace() error {
 	trace.ticksStart = cputicks()
 	trace.timeStart = nanotime()
 	trace.headerWritten = false
-	trace.footerWritten = false
-
-	// string to id mapping
-	//  0 : reserved for an empty string
-	//  remaining: other strings registered by traceString
-	trace.stringSeq = 0
-	trace.strings = make(map[string]uint64)
 
 	trace.seqGC = 0
 	_g_.m.startingtrace = false
 	trace.enabled = true
 
-	// Register runtime goroutine labels.
-	_, pid, bufp := traceAcquireBuffer()
-	for i, label := range gcMarkWorkerModeStrings[:] {
-		trace.markWorkerLabels[i], bufp = traceString(bufp, pid, label)
-	}
-	traceReleaseBuffer(pid)
+	traceRegisterLabels(gen)
+
+	stop := new(note)
+	trace.advancer = stop
 
 	unlock(&trace.bufLock)
 
 	unlock(&sched.sysmonlock)
 
 	startTheWorldGC()
+	semrelease(&traceAdvanceSema)
+
+	// Start the goroutine that periodically begins new generations.
+	// If tracing has been stopped in the meantime, stop is already
+	// signaled and the goroutine exits immediately.
+	go traceAdvancer(stop)
 	return nil
 }
 
 // StopTrace stops tracing, if it was previously enabled.
 // StopTrace only returns after all the reads for the trace have completed.
 func StopTrace() {
-	// Stop the world so that we can collect the trace buffers from all p's below,
-	// and also to avoid races with traceEvent.
+	// Wait for traceAdvance to finish the generation it is working on.
+	semacquire(&traceAdvanceSema)
+
+	// Stop the world so that no events are being written when
+	// tracing is turned off.
 	stopTheWorldGC("stop tracing")
 
 	// See the comment in StartTrace.
@@ -297,48 +349,30 @@ func StopTrace() {
 		unlock(&trace.bufLock)
 		unlock(&sched.sysmonlock)
 		startTheWorldGC()
+		semrelease(&traceAdvanceSema)
 		return
 	}
 
 	traceGoSched()
 
-	// Loop over all allocated Ps because dead Ps may still have
-	// trace buffers.
-	for _, p := range allp[:cap(allp)] {
-		buf := p.tracebuf
-		if buf != 0 {
-			traceFullQueue(buf)
-			p.tracebuf = 0
-		}
-	}
-	if trace.buf != 0 {
-		buf := trace.buf
-		trace.buf = 0
-		if buf.ptr().pos != 0 {
-			traceFullQueue(buf)
-		}
-	}
-
-	for {
-		trace.ticksEnd = cputicks()
-		trace.timeEnd = nanotime()
-		// Windows time can tick only every 15ms, wait for at least one tick.
-		if trace.timeEnd != trace.timeStart {
-			break
-		}
-		osyield()
-	}
-
 	trace.enabled = false
-	trace.shutdown = true
+	notewakeup(trace.advancer)
+	trace.advancer = nil
 	unlock(&trace.bufLock)
 
 	unlock(&sched.sysmonlock)
 
 	startTheWorldGC()
 
-	// The world is started but we've set trace.shutdown, so new tracing can't start.
-	// Wait for the trace reader to flush pending buffers and stop.
+	// No events can be written anymore. Finish the last generation,
+	// then wait for the trace reader to flush pending buffers and stop.
+	// New tracing can't start because trace.shutdown is set.
+	traceFinishGeneration(trace.gen, true)
+	lock(&trace.lock)
+	trace.shutdown = true
+	unlock(&trace.lock)
+	semrelease(&traceAdvanceSema)
+
 	semacquire(&trace.shutdownSema)
 	if raceenabled {
 		raceacquire(unsafe.Pointer(&trace.shutdownSema))
@@ -347,14 +381,14 @@ func StopTrace() {
 	// The lock protects us from races with StartTrace/StopTrace because they do stop-the-world.
 	lock(&trace.lock)
 	for _, p := range allp[:cap(allp)] {
-		if p.tracebuf != 0 {
+		if p.tracebuf != [2]traceBufPtr{} {
 			throw("trace: non-empty trace buffer in proc")
 		}
 	}
-	if trace.buf != 0 {
+	if trace.buf != [2]traceBufPtr{} {
 		throw("trace: non-empty global trace buffer")
 	}
-	if trace.fullHead != 0 || trace.fullTail != 0 {
+	if trace.fullHead != 0 || trace.fullTail != 0 || trace.pendingHead != 0 || trace.pendingTail != 0 {
 		throw("trace: non-empty full trace buffer")
 	}
 	if trace.reading != 0 || trace.reader != 0 {
@@ -365,11 +399,196 @@ func StopTrace() {
 		trace.empty = buf.ptr().link
 		sysFree(unsafe.Pointer(buf), unsafe.Sizeof(*buf.ptr()), &memstats.other_sys)
 	}
-	trace.strings = nil
+	trace.strings = [2]map[string]uint64{}
 	trace.shutdown = false
 	unlock(&trace.lock)
 }
 
+// traceRegisterLabels registers the runtime goroutine labels in the
+// string table of generation gen, before any events of gen are written.
+// trace.bufLock must be held.
+func traceRegisterLabels(gen uint64) {
+	bufp := &trace.buf[gen%2]
+	for i, label := range gcMarkWorkerModeStrings[:] {
+		trace.markWorkerLabels[gen%2][i], bufp = traceString(bufp, traceGlobProc, gen, label)
+	}
+}
+
+// traceGenerationMarker queues a buffer holding the traceEvGeneration
+// event that begins generation gen. The marker is not part of any batch,
+// so that readers can split the trace into generations by looking only
+// at the first byte of each buffer returned by ReadTrace.
+// trace.lock must be held.
+func traceGenerationMarker(gen uint64) {
+	buf := traceBufAlloc()
+	buf.ptr().gen = gen
+	buf.ptr().byte(traceEvGeneration | 0<<traceArgCountShift)
+	buf.ptr().varint(gen)
+	traceFullQueue(buf)
+}
+
+// traceFrequency returns the tracer timer frequency in ticks per second,
+// measured since tracing was started.
+func traceFrequency() uint64 {
+	// Use float64 because (trace.ticksEnd - trace.ticksStart) * 1e9 can overflow int64.
+	freq := float64(trace.ticksEnd-trace.ticksStart) * 1e9 / float64(trace.timeEnd-trace.timeStart) / traceTickDiv
+	return uint64(freq)
+}
+
+// traceAdvance ends the current trace generation and begins a new one.
+//
+// Each generation is self-contained: it carries its own timer frequency,
+// stack table and string table, and every goroutine and P that appears
+// in it is described by status events at its first appearance, as at
+// the beginning of the trace. A reader can therefore parse a trace one
+// generation at a time, and a consumer that only cares about recent
+// execution can discard old generations entirely.
+//
+// traceAdvance does not stop the world. Every M tags the events it
+// writes with the generation that is current when it begins writing
+// them (see traceAcquireBuffer), so the events of two generations may
+// be written at the same time, to separate buffers and tables. Once the
+// new generation is published, traceAdvance waits for the Ms still
+// writing events of the old one, and then finishes the old generation
+// on their behalf. Goroutines and Ps record the last generation that
+// has their status (g.tracegen and p.tracegen), and the first event
+// about them in a new generation is preceded by their status.
+//
+// traceAdvance returns the number of the new generation,
+// or 0 if tracing is not enabled.
+func traceAdvance() uint64 {
+	semacquire(&traceAdvanceSema)
+	if !trace.enabled {
+		semrelease(&traceAdvanceSema)
+		return 0
+	}
+	gen := trace.gen
+
+	// Register the runtime goroutine labels of the new generation
+	// before it can be used.
+	lock(&trace.bufLock)
+	traceRegisterLabels(gen + 1)
+	unlock(&trace.bufLock)
+
+	// Begin the new generation between GC cycles, so that the events of
+	// a GC cycle, and of the stop-the-world phases in it, all belong to
+	// the same generation.
+	semacquire(&gcsema)
+	trace.seqGC = 0
+	atomic.Store64(&trace.gen, gen+1)
+	semrelease(&gcsema)
+
+	// Wait for the Ms that are writing events and may have loaded the
+	// old generation. Any M that begins writing events from now on uses
+	// the new one.
+	var wait *m
+	lock(&sched.lock)
+	for mp := allm; mp != nil; mp = mp.alllink {
+		if atomic.Load(&mp.traceseqlock)%2 == 1 {
+			mp.tracelink = wait
+			wait = mp
+		}
+	}
+	unlock(&sched.lock)
+	for wait != nil {
+		if atomic.Load(&wait.traceseqlock)%2 == 1 {
+			osyield()
+			continue
+		}
+		mp := wait
+		wait = mp.tracelink
+		mp.tracelink = nil
+	}
+
+	traceFinishGeneration(gen, false)
+	semrelease(&traceAdvanceSema)
+	return gen + 1
+}
+
+// traceFinishGeneration writes out generation gen once no more events of it
+// can be written: it queues the buffers of gen, followed by the timer
+// frequency and the stack table. Unless gen is the last generation of the
+// trace, it then resets the string table of gen and queues the marker of
+// the next generation, followed by the buffers of the next generation that
+// filled up in the meantime.
+func traceFinishGeneration(gen uint64, last bool) {
+	// Loop over all allocated Ps because dead Ps may still have
+	// trace buffers. Disable preemption so that the world, and
+	// with it allp, can't change.
+	mp := acquirem()
+	lock(&trace.lock)
+	for _, p := range allp[:cap(allp)] {
+		buf := p.tracebuf[gen%2]
+		if buf != 0 {
+			traceFullQueue(buf)
+			p.tracebuf[gen%2] = 0
+		}
+	}
+	unlock(&trace.lock)
+	releasem(mp)
+
+	lock(&trace.bufLock)
+	buf := trace.buf[gen%2]
+	trace.buf[gen%2] = 0
+	unlock(&trace.bufLock)
+	if buf != 0 && buf.ptr().pos != 0 {
+		lock(&trace.lock)
+		traceFullQueue(buf)
+		unlock(&trace.lock)
+	}
+
+	for {
+		trace.ticksEnd = cputicks()
+		trace.timeEnd = nanotime()
+		// Windows time can tick only every 15ms, wait for at least one tick.
+		if trace.timeEnd != trace.timeStart {
+			break
+		}
+		osyield()
+	}
+	lock(&trace.lock)
+	buf = traceBufAlloc()
+	buf.ptr().gen = gen
+	buf.ptr().byte(traceEvFrequency | 0<<traceArgCountShift)
+	buf.ptr().varint(traceFrequency())
+	traceFullQueue(buf)
+	unlock(&trace.lock)
+	trace.stackTab[gen%2].dump(gen)
+	if last {
+		return
+	}
+
+	// The string table is reused by generation gen+2.
+	strings := make(map[string]uint64)
+	lock(&trace.stringsLock)
+	trace.stringSeq[gen%2] = 0
+	trace.strings[gen%2] = strings
+	unlock(&trace.stringsLock)
+
+	lock(&trace.lock)
+	trace.queueGen = gen + 1
+	traceGenerationMarker(gen + 1)
+	if trace.pendingHead != 0 {
+		if trace.fullHead == 0 {
+			trace.fullHead = trace.pendingHead
+		} else {
+			trace.fullTail.ptr().link = trace.pendingHead
+		}
+		trace.fullTail = trace.pendingTail
+		trace.pendingHead = 0
+		trace.pendingTail = 0
+	}
+	unlock(&trace.lock)
+}
+
+// traceAdvancer begins a new trace generation every traceAdvancePeriod
+// until stop is signaled by StopTrace.
+func traceAdvancer(stop *note) {
+	for !notetsleepg(stop, traceAdvancePeriod) {
+		traceAdvance()
+	}
+}
+
 // ReadTrace returns the next chunk of binary tracing data, blocking until data
 // is available. If tracing is turned off and all the data accumulated while it
 // was on has been returned, ReadTrace returns nil. The caller must copy the
@@ -405,7 +624,7 @@ func ReadTrace() []byte {
 		trace.headerWritten = true
 		trace.lockOwner = nil
 		unlock(&trace.lock)
-		return []byte("go 1.11 trace\x00\x00\x00")
+		return []byte("go 1.19 trace\x00\x00\x00")
 	}
 	// Wait for new data.
 	if trace.fullHead == 0 && !trace.shutdown {
@@ -421,21 +640,6 @@ func ReadTrace() []byte {
 		unlock(&trace.lock)
 		return buf.ptr().arr[:buf.ptr().pos]
 	}
-	// Write footer with timer frequency.
-	if !trace.footerWritten {
-		trace.footerWritten = true
-		// Use float64 because (trace.ticksEnd - trace.ticksStart) * 1e9 can overflow int64.
-		freq := float64(trace.ticksEnd-trace.ticksStart) * 1e9 / float64(trace.timeEnd-trace.timeStart) / traceTickDiv
-		trace.lockOwner = nil
-		unlock(&trace.lock)
-		var data []byte
-		data = append(data, traceEvFrequency|0<<traceArgCountShift)
-		data = traceAppend(data, uint64(freq))
-		// This will emit a bunch of full buffers, we will pick them up
-		// on the next iteration.
-		trace.stackTab.dump()
-		return data
-	}
 	// Done.
 	if trace.shutdown {
 		trace.lockOwner = nil
@@ -473,21 +677,33 @@ func traceReader() *g {
 	return gp
 }
 
-// traceProcFree frees trace buffer associated with pp.
+// traceProcFree frees trace buffers associated with pp.
 func traceProcFree(pp *p) {
-	buf := pp.tracebuf
-	pp.tracebuf = 0
-	if buf == 0 {
-		return
+	for i, buf := range pp.tracebuf {
+		if buf == 0 {
+			continue
+		}
+		pp.tracebuf[i] = 0
+		lock(&trace.lock)
+		traceFullQueue(buf)
+		unlock(&trace.lock)
 	}
-	lock(&trace.lock)
-	traceFullQueue(buf)
-	unlock(&trace.lock)
 }
 
 // traceFullQueue queues buf into queue of full buffers.
+// Buffers of the generation after trace.queueGen wait in trace.pending
+// until traceFinishGeneration is done with trace.queueGen.
 func traceFullQueue(buf traceBufPtr) {
 	buf.ptr().link = 0
+	if buf.ptr().gen != trace.queueGen {
+		if trace.pendingHead == 0 {
+			trace.pendingHead = buf
+		} else {
+			trace.pendingTail.ptr().link = buf
+		}
+		trace.pendingTail = buf
+		return
+	}
 	if trace.fullHead == 0 {
 		trace.fullHead = buf
 	} else {
@@ -543,11 +759,14 @@ func traceEvent(ev byte, skip int, args ...uint64) {
 }
 
 func traceEventLocked(extraBytes int, mp *m, pid int32, bufp *traceBufPtr, ev byte, skip int, args ...uint64) {
+	if pid != traceGlobProc && mp.p.ptr().tracegen != mp.tracegen {
+		traceProcStatus(mp, pid, bufp, ev)
+	}
 	buf := bufp.ptr()
 	// TODO: test on non-zero extraBytes param.
 	maxSize := 2 + 5*traceBytesPerNumber + extraBytes // event type, length, sequence, timestamp, stack id and two add params
 	if buf == nil || len(buf.arr)-buf.pos < maxSize {
-		buf = traceFlush(traceBufPtrOf(buf), pid).ptr()
+		buf = traceFlush(traceBufPtrOf(buf), pid, mp.tracegen).ptr()
 		bufp.set(buf)
 	}
 
@@ -613,18 +832,85 @@ func traceStackID(mp *m, buf []uintptr, skip int) uint64 {
 	if nstk > 0 && gp.goid == 1 {
 		nstk-- // skip runtime.main
 	}
-	id := trace.stackTab.put(buf[:nstk])
+	id := trace.stackTab[mp.tracegen%2].put(buf[:nstk])
 	return uint64(id)
 }
 
+// traceProcStatus emits the status of the P of mp before the first event
+// of the current generation on it, ev: the P is running, unless ev starts
+// it, and so is the goroutine that the P runs, if any. A sweep in progress
+// is reported with traceEvGCSweepActive rather than traceEvGCSweepStart,
+// so that readers don't mistake it for a new sweep.
+func traceProcStatus(mp *m, pid int32, bufp *traceBufPtr, ev byte) {
+	pp := mp.p.ptr()
+	pp.tracegen = mp.tracegen
+	if ev == traceEvProcStart {
+		return
+	}
+	traceEventLocked(0, mp, pid, bufp, traceEvProcStart, -1, uint64(mp.id))
+	if gp := pp.traceg.ptr(); gp != nil {
+		traceGoStatus(mp, pid, bufp, gp, traceEvGoStart)
+	}
+	if pp.traceSweep && pp.traceSwept != 0 {
+		traceEventLocked(0, mp, pid, bufp, traceEvGCSweepActive, -1)
+	}
+}
+
+// traceGoStatus emits the status of gp before the first event about it
+// in the current generation: gp exists and is runnable, or, if ev is
+// traceEvGoWaiting, traceEvGoInSyscall or traceEvGoStart, it is blocked,
+// in a syscall or running on the P of mp.
+func traceGoStatus(mp *m, pid int32, bufp *traceBufPtr, gp *g, ev byte) {
+	gen := mp.tracegen
+	gp.tracegen = gen
+	gp.traceseq = 0
+	gp.tracelastp = mp.p
+	// +PCQuantum because traceFrameForPC expects return PCs and subtracts PCQuantum.
+	id := trace.stackTab[gen%2].put([]uintptr{gp.startpc + sys.PCQuantum})
+	traceEventLocked(0, mp, pid, bufp, traceEvGoCreate, 0, uint64(gp.goid), uint64(id))
+	switch ev {
+	case traceEvGoWaiting, traceEvGoInSyscall:
+		// traceEvGoWaiting and traceEvGoInSyscall are implied to have seq=1.
+		gp.traceseq++
+		traceEventLocked(0, mp, pid, bufp, ev, -1, uint64(gp.goid))
+	case traceEvGoStart:
+		gp.traceseq++
+		if mode := mp.p.ptr().gcMarkWorkerMode; mode != gcMarkWorkerNotWorker {
+			traceEventLocked(0, mp, pid, bufp, traceEvGoStartLabel, -1, uint64(gp.goid), gp.traceseq, trace.markWorkerLabels[gen%2][mode])
+		} else {
+			traceEventLocked(0, mp, pid, bufp, traceEvGoStart, -1, uint64(gp.goid), gp.traceseq)
+		}
+	}
+}
+
 // traceAcquireBuffer returns trace buffer to use and, if necessary, locks it.
+//
+// It also marks the beginning of a section of the current M that writes
+// events, which ends with the matching traceReleaseBuffer. All the events
+// of the section belong to generation mp.tracegen, loaded when the
+// outermost section begins. mp.traceseqlock is odd while the M is in a
+// section, so that traceAdvance can wait for the sections that may still
+// write events of the previous generation.
 func traceAcquireBuffer() (mp *m, pid int32, bufp *traceBufPtr) {
 	mp = acquirem()
-	if p := mp.p.ptr(); p != nil {
-		return mp, p.id, &p.tracebuf
+	mp.tracedepth++
+	if mp.tracedepth == 1 {
+		// Must happen before loading the generation; see traceAdvance.
+		atomic.Xadd(&mp.traceseqlock, 1)
 	}
-	lock(&trace.bufLock)
-	return mp, traceGlobProc, &trace.buf
+	pp := mp.p.ptr()
+	if pp == nil {
+		lock(&trace.bufLock)
+	}
+	if mp.tracedepth == 1 {
+		// StartTrace begins a generation while holding bufLock,
+		// so don't load it before bufLock is acquired.
+		mp.tracegen = atomic.Load64(&trace.gen)
+	}
+	if pp != nil {
+		return mp, pp.id, &pp.tracebuf[mp.tracegen%2]
+	}
+	return mp, traceGlobProc, &trace.buf[mp.tracegen%2]
 }
 
 // traceReleaseBuffer releases a buffer previously acquired with traceAcquireBuffer.
@@ -632,11 +918,17 @@ func traceReleaseBuffer(pid int32) {
 	if pid == traceGlobProc {
 		unlock(&trace.bufLock)
 	}
-	releasem(getg().m)
+	mp := getg().m
+	mp.tracedepth--
+	if mp.tracedepth == 0 {
+		atomic.Xadd(&mp.traceseqlock, 1)
+	}
+	releasem(mp)
 }
 
-// traceFlush puts buf onto stack of full buffers and returns an empty buffer.
-func traceFlush(buf traceBufPtr, pid int32) traceBufPtr {
+// traceFlush puts buf onto stack of full buffers and returns an empty buffer
+// for the events of generation gen.
+func traceFlush(buf traceBufPtr, pid int32, gen uint64) traceBufPtr {
 	owner := trace.lockOwner
 	dolock := owner == nil || owner != getg().m.curg
 	if dolock {
@@ -645,18 +937,9 @@ func traceFlush(buf traceBufPtr, pid int32) traceBufPtr {
 	if buf != 0 {
 		traceFullQueue(buf)
 	}
-	if trace.empty != 0 {
-		buf = trace.empty
-		trace.empty = buf.ptr().link
-	} else {
-		buf = traceBufPtr(sysAlloc(unsafe.Sizeof(traceBuf{}), &memstats.other_sys))
-		if buf == 0 {
-			throw("trace: out of memory")
-		}
-	}
+	buf = traceBufAlloc()
 	bufp := buf.ptr()
-	bufp.link.set(nil)
-	bufp.pos = 0
+	bufp.gen = gen
 
 	// initialize the buffer for a new batch
 	ticks := uint64(cputicks()) / traceTickDiv
@@ -674,8 +957,28 @@ func traceFlush(buf traceBufPtr, pid int32) traceBufPtr {
 	return buf
 }
 
-// traceString adds a string to the trace.strings and returns the id.
-func traceString(bufp *traceBufPtr, pid int32, s string) (uint64, *traceBufPtr) {
+// traceBufAlloc returns an empty buffer, reusing one from trace.empty
+// if possible. trace.lock must be held.
+func traceBufAlloc() traceBufPtr {
+	var buf traceBufPtr
+	if trace.empty != 0 {
+		buf = trace.empty
+		trace.empty = buf.ptr().link
+	} else {
+		buf = traceBufPtr(sysAlloc(unsafe.Sizeof(traceBuf{}), &memstats.other_sys))
+		if buf == 0 {
+			throw("trace: out of memory")
+		}
+	}
+	bufp := buf.ptr()
+	bufp.link.set(nil)
+	bufp.pos = 0
+	return buf
+}
+
+// traceString adds a string to the trace.strings of generation gen
+// and returns the id.
+func traceString(bufp *traceBufPtr, pid int32, gen uint64, s string) (uint64, *traceBufPtr) {
 	if s == "" {
 		return 0, bufp
 	}
@@ -687,7 +990,7 @@ func traceString(bufp *traceBufPtr, pid int32, s string) (uint64, *traceBufPtr)
 		raceacquire(unsafe.Pointer(&trace.stringsLock))
 	}
 
-	if id, ok := trace.strings[s]; ok {
+	if id, ok := trace.strings[gen%2][s]; ok {
 		if raceenabled {
 			racerelease(unsafe.Pointer(&trace.stringsLock))
 		}
@@ -696,9 +999,9 @@ func traceString(bufp *traceBufPtr, pid int32, s string) (uint64, *traceBufPtr)
 		return id, bufp
 	}
 
-	trace.stringSeq++
-	id := trace.stringSeq
-	trace.strings[s] = id
+	trace.stringSeq[gen%2]++
+	id := trace.stringSeq[gen%2]
+	trace.strings[gen%2][s] = id
 
 	if raceenabled {
 		racerelease(unsafe.Pointer(&trace.stringsLock))
@@ -713,7 +1016,7 @@ func traceString(bufp *traceBufPtr, pid int32, s string) (uint64, *traceBufPtr)
 	buf := bufp.ptr()
 	size := 1 + 2*traceBytesPerNumber + len(s)
 	if buf == nil || len(buf.arr)-buf.pos < size {
-		buf = traceFlush(traceBufPtrOf(buf), pid).ptr()
+		buf = traceFlush(traceBufPtrOf(buf), pid, gen).ptr()
 		bufp.set(buf)
 	}
 	buf.byte(traceEvString)
@@ -793,7 +1096,8 @@ func (tab *traceStackTable) put(pcs []uintptr) uint32 {
 	if len(pcs) == 0 {
 		return 0
 	}
-	hash := memhash(unsafe.Pointer(&pcs[0]), 0, uintptr(len(pcs))*unsafe.Sizeof(pcs[0]))
+	// memhash does not retain pcs, so callers can keep them on the stack.
+	hash := memhash(noescape(unsafe.Pointer(&pcs[0])), 0, uintptr(len(pcs))*unsafe.Sizeof(pcs[0]))
 	// First, search the hashtable w/o the mutex.
 	if id := tab.find(pcs, hash); id != 0 {
 		return id
@@ -856,11 +1160,11 @@ func allFrames(pcs []uintptr) []Frame {
 	}
 }
 
-// dump writes all previously cached stacks to trace buffers,
-// releases all memory and resets state.
-func (tab *traceStackTable) dump() {
+// dump writes all previously cached stacks of generation gen to trace
+// buffers, releases all memory and resets state.
+func (tab *traceStackTable) dump(gen uint64) {
 	var tmp [(2 + 4*traceStackSize) * traceBytesPerNumber]byte
-	bufp := traceFlush(0, 0)
+	bufp := traceFlush(0, 0, gen)
 	for _, stk := range tab.tab {
 		stk := stk.ptr()
 		for ; stk != nil; stk = stk.link.ptr() {
@@ -870,7 +1174,7 @@ func (tab *traceStackTable) dump() {
 			tmpbuf = traceAppend(tmpbuf, uint64(len(frames)))
 			for _, f := range frames {
 				var frame traceFrame
-				frame, bufp = traceFrameForPC(bufp, 0, f)
+				frame, bufp = traceFrameForPC(bufp, 0, gen, f)
 				tmpbuf = traceAppend(tmpbuf, uint64(f.PC))
 				tmpbuf = traceAppend(tmpbuf, uint64(frame.funcID))
 				tmpbuf = traceAppend(tmpbuf, uint64(frame.fileID))
@@ -879,7 +1183,7 @@ func (tab *traceStackTable) dump() {
 			// Now copy to the buffer.
 			size := 1 + traceBytesPerNumber + len(tmpbuf)
 			if buf := bufp.ptr(); len(buf.arr)-buf.pos < size {
-				bufp = traceFlush(bufp, 0)
+				bufp = traceFlush(bufp, 0, gen)
 			}
 			buf := bufp.ptr()
 			buf.byte(traceEvStack | 3<<traceArgCountShift)
@@ -905,7 +1209,7 @@ type traceFrame struct {
 
 // traceFrameForPC records the frame information.
 // It may allocate memory.
-func traceFrameForPC(buf traceBufPtr, pid int32, f Frame) (traceFrame, traceBufPtr) {
+func traceFrameForPC(buf traceBufPtr, pid int32, gen uint64, f Frame) (traceFrame, traceBufPtr) {
 	bufp := &buf
 	var frame traceFrame
 
@@ -914,13 +1218,13 @@ func traceFrameForPC(buf traceBufPtr, pid int32, f Frame) (traceFrame, traceBufP
 	if len(fn) > maxLen {
 		fn = fn[len(fn)-maxLen:]
 	}
-	frame.funcID, bufp = traceString(bufp, pid, fn)
+	frame.funcID, bufp = traceString(bufp, pid, gen, fn)
 	frame.line = uint64(f.Line)
 	file := f.File
 	if len(file) > maxLen {
 		file = file[len(file)-maxLen:]
 	}
-	frame.fileID, bufp = traceString(bufp, pid, file)
+	frame.fileID, bufp = traceString(bufp, pid, gen, file)
 	return frame, (*bufp)
 }
 
@@ -1065,41 +1369,64 @@ func traceGCMarkAssistDone() {
 }
 
 func traceGoCreate(newg *g, pc uintptr) {
+	// Same as in traceEvent. The status of newg must be
+	// recorded in the generation of the event.
+	mp, pid, bufp := traceAcquireBuffer()
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(pid)
+		return
+	}
 	newg.traceseq = 0
 	newg.tracelastp = getg().m.p
+	newg.tracegen = mp.tracegen
 	// +PCQuantum because traceFrameForPC expects return PCs and subtracts PCQuantum.
-	id := trace.stackTab.put([]uintptr{pc + sys.PCQuantum})
-	traceEvent(traceEvGoCreate, 2, uint64(newg.goid), uint64(id))
+	id := trace.stackTab[mp.tracegen%2].put([]uintptr{pc + sys.PCQuantum})
+	traceEventLocked(0, mp, pid, bufp, traceEvGoCreate, 2, uint64(newg.goid), uint64(id))
+	traceReleaseBuffer(pid)
 }
 
 func traceGoStart() {
 	_g_ := getg().m.curg
 	_p_ := _g_.m.p
+	mp, pid, bufp := traceAcquireBuffer()
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(pid)
+		return
+	}
+	if _g_.tracegen != mp.tracegen {
+		traceGoStatus(mp, pid, bufp, _g_, traceEvNone)
+	}
 	_g_.traceseq++
-	if _p_.ptr().gcMarkWorkerMode != gcMarkWorkerNotWorker {
-		traceEvent(traceEvGoStartLabel, -1, uint64(_g_.goid), _g_.traceseq, trace.markWorkerLabels[_p_.ptr().gcMarkWorkerMode])
+	if mode := _p_.ptr().gcMarkWorkerMode; mode != gcMarkWorkerNotWorker {
+		traceEventLocked(0, mp, pid, bufp, traceEvGoStartLabel, -1, uint64(_g_.goid), _g_.traceseq, trace.markWorkerLabels[mp.tracegen%2][mode])
 	} else if _g_.tracelastp == _p_ {
-		traceEvent(traceEvGoStartLocal, -1, uint64(_g_.goid))
+		traceEventLocked(0, mp, pid, bufp, traceEvGoStartLocal, -1, uint64(_g_.goid))
 	} else {
 		_g_.tracelastp = _p_
-		traceEvent(traceEvGoStart, -1, uint64(_g_.goid), _g_.traceseq)
+		traceEventLocked(0, mp, pid, bufp, traceEvGoStart, -1, uint64(_g_.goid), _g_.traceseq)
 	}
+	// After the event, which may be preceded by the status of the P.
+	_p_.ptr().traceg.set(_g_)
+	traceReleaseBuffer(pid)
 }
 
 func traceGoEnd() {
 	traceEvent(traceEvGoEnd, -1)
+	getg().m.p.ptr().traceg = 0
 }
 
 func traceGoSched() {
 	_g_ := getg()
 	_g_.tracelastp = _g_.m.p
 	traceEvent(traceEvGoSched, 1)
+	_g_.m.p.ptr().traceg = 0
 }
 
 func traceGoPreempt() {
 	_g_ := getg()
 	_g_.tracelastp = _g_.m.p
 	traceEvent(traceEvGoPreempt, 1)
+	_g_.m.p.ptr().traceg = 0
 }
 
 func traceGoPark(traceEv byte, skip int) {
@@ -1107,17 +1434,27 @@ func traceGoPark(traceEv byte, skip int) {
 		traceEvent(traceEvFutileWakeup, -1)
 	}
 	traceEvent(traceEv & ^traceFutileWakeup, skip)
+	getg().m.p.ptr().traceg = 0
 }
 
 func traceGoUnpark(gp *g, skip int) {
 	_p_ := getg().m.p
+	mp, pid, bufp := traceAcquireBuffer()
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(pid)
+		return
+	}
+	if gp.tracegen != mp.tracegen {
+		traceGoStatus(mp, pid, bufp, gp, traceEvGoWaiting)
+	}
 	gp.traceseq++
 	if gp.tracelastp == _p_ {
-		traceEvent(traceEvGoUnblockLocal, skip, uint64(gp.goid))
+		traceEventLocked(0, mp, pid, bufp, traceEvGoUnblockLocal, skip, uint64(gp.goid))
 	} else {
 		gp.tracelastp = _p_
-		traceEvent(traceEvGoUnblock, skip, uint64(gp.goid), gp.traceseq)
+		traceEventLocked(0, mp, pid, bufp, traceEvGoUnblock, skip, uint64(gp.goid), gp.traceseq)
 	}
+	traceReleaseBuffer(pid)
 }
 
 func traceGoSysCall() {
@@ -1138,9 +1475,21 @@ func traceGoSysExit(ts int64) {
 		ts = 0
 	}
 	_g_ := getg().m.curg
+	mp, pid, bufp := traceAcquireBuffer()
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(pid)
+		return
+	}
+	if _g_.tracegen != mp.tracegen {
+		traceGoStatus(mp, pid, bufp, _g_, traceEvGoInSyscall)
+		// The syscall may have returned before the status,
+		// so the same applies as above.
+		ts = 0
+	}
 	_g_.traceseq++
 	_g_.tracelastp = _g_.m.p
-	traceEvent(traceEvGoSysExit, -1, uint64(_g_.goid), _g_.traceseq, uint64(ts)/traceTickDiv)
+	traceEventLocked(0, mp, pid, bufp, traceEvGoSysExit, -1, uint64(_g_.goid), _g_.traceseq, uint64(ts)/traceTickDiv)
+	traceReleaseBuffer(pid)
 }
 
 func traceGoSysBlock(pp *p) {
@@ -1150,6 +1499,7 @@ func traceGoSysBlock(pp *p) {
 	oldp := mp.p
 	mp.p.set(pp)
 	traceEvent(traceEvGoSysBlock, -1)
+	pp.traceg = 0
 	mp.p = oldp
 	releasem(mp)
 }
@@ -1183,7 +1533,7 @@ func trace_userTaskCreate(id, parentID uint64, taskType string) {
 		return
 	}
 
-	typeStringID, bufp := traceString(bufp, pid, taskType)
+	typeStringID, bufp := traceString(bufp, pid, mp.tracegen, taskType)
 	traceEventLocked(0, mp, pid, bufp, traceEvUserTaskCreate, 3, id, parentID, typeStringID)
 	traceReleaseBuffer(pid)
 }
@@ -1205,7 +1555,7 @@ func trace_userRegion(id, mode uint64, name string) {
 		return
 	}
 
-	nameStringID, bufp := traceString(bufp, pid, name)
+	nameStringID, bufp := traceString(bufp, pid, mp.tracegen, name)
 	traceEventLocked(0, mp, pid, bufp, traceEvUserRegion, 3, id, mode, nameStringID)
 	traceReleaseBuffer(pid)
 }
@@ -1222,7 +1572,7 @@ func trace_userLog(id uint64, category, message string) {
 		return
 	}
 
-	categoryID, bufp := traceString(bufp, pid, category)
+	categoryID, bufp := traceString(bufp, pid, mp.tracegen, category)
 
 	extraSpace := traceBytesPerNumber + len(message) // extraSpace for the value string
 	traceEventLocked(extraSpace, mp, pid, bufp, traceEvUserLog, 3, id, categoryID)
@@ -1241,3 +1591,8 @@ func trace_userLog(id uint64, category, message string) {
 
 	traceReleaseBuffer(pid)
 }
+
+//go:linkname trace_advance runtime/trace.advance
+func trace_advance() uint64 {
+	return traceAdvance()
+}
@@ -198,3 +198,7 @@ func userRegion(id, mode uint64, regionType string)
 
 // emits UserLog event.
 func userLog(id uint64, category, message string)
+
+// starts a new trace generation and returns its number,
+// or 0 if tracing is not enabled.
+func advance() uint64
@@ -0,0 +1,271 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The runtime splits the trace into generations, each of which can be
+// parsed on its own. Every chunk of data returned by runtime.ReadTrace
+// belongs to a single generation, and a generation starts with a chunk
+// holding only its marker event. The marker is encoded as the byte
+// evGeneration followed by the generation number as a varint.
+const evGeneration = 49 // must match traceEvGeneration in runtime/trace.go
+
+// FlightRecorderConfig configures a FlightRecorder.
+type FlightRecorderConfig struct {
+	// MinAge is a lower bound on the age of the events in a snapshot.
+	// The flight recorder keeps at least the events of the last MinAge,
+	// and discards older ones as soon as possible.
+	//
+	// If MinAge is zero, it defaults to 10 seconds.
+	MinAge time.Duration
+
+	// MaxBytes is an upper bound on the size of the trace data kept by
+	// the flight recorder. It takes precedence over MinAge: when the
+	// data exceeds MaxBytes, the oldest data is discarded even if it is
+	// younger than MinAge. The most recent complete generation of the
+	// trace is always kept, so a snapshot may be somewhat larger.
+	//
+	// If MaxBytes is zero, it defaults to 10 MiB.
+	MaxBytes uint64
+}
+
+// A FlightRecorder records the execution trace of the program into an
+// in-memory ring buffer, keeping only the recent past. At any moment,
+// WriteTo can write a snapshot of the buffer, for example when the
+// program detects that something has gone wrong.
+//
+// A FlightRecorder uses the same runtime facility as Start, so only one
+// of them can be active at a time.
+type FlightRecorder struct {
+	cfg FlightRecorderConfig
+
+	mu       sync.Mutex
+	cond     sync.Cond // signaled when a generation is complete or the recorder stops
+	enabled  bool
+	reading  bool            // whether the goroutine reading the trace is running
+	header   []byte          // trace header
+	gens     []*frGeneration // complete generations, oldest first
+	size     uint64          // total size of gens in bytes
+	cur      *frGeneration   // generation being read
+	complete uint64          // number of the last complete generation
+}
+
+// frGeneration is one generation of the trace.
+type frGeneration struct {
+	num    uint64
+	chunks [][]byte
+	size   uint64
+	end    time.Time // when the generation was complete
+}
+
+// NewFlightRecorder creates a new flight recorder with the given configuration.
+func NewFlightRecorder(cfg FlightRecorderConfig) *FlightRecorder {
+	if cfg.MinAge == 0 {
+		cfg.MinAge = 10 * time.Second
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = 10 << 20
+	}
+	fr := &FlightRecorder{cfg: cfg}
+	fr.cond.L = &fr.mu
+	return fr
+}
+
+// Start starts recording the execution trace.
+// Start returns an error if the flight recorder is already running,
+// or if tracing is already enabled, for example by Start.
+func (fr *FlightRecorder) Start() error {
+	tracing.Lock()
+	defer tracing.Unlock()
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if fr.enabled || fr.reading {
+		return errors.New("flight recorder is already running")
+	}
+	if err := runtime.StartTrace(); err != nil {
+		return err
+	}
+	fr.enabled = true
+	fr.reading = true
+	fr.header = nil
+	fr.gens = nil
+	fr.size = 0
+	fr.cur = nil
+	fr.complete = 0
+	go fr.read()
+
+	tracing.recorder = fr
+	atomic.StoreInt32(&tracing.enabled, 1)
+	return nil
+}
+
+// Stop stops recording the execution trace and discards the recorded data.
+// It is a no-op if the flight recorder is not running.
+func (fr *FlightRecorder) Stop() {
+	tracing.Lock()
+	defer tracing.Unlock()
+	if tracing.recorder != fr {
+		return
+	}
+	tracing.recorder = nil
+	atomic.StoreInt32(&tracing.enabled, 0)
+
+	fr.mu.Lock()
+	fr.enabled = false
+	fr.mu.Unlock()
+
+	// StopTrace returns once the reading goroutine has read all the data.
+	runtime.StopTrace()
+
+	fr.mu.Lock()
+	for fr.reading {
+		fr.cond.Wait()
+	}
+	fr.header = nil
+	fr.gens = nil
+	fr.size = 0
+	fr.cur = nil
+	fr.mu.Unlock()
+}
+
+// Enabled reports whether the flight recorder is running.
+func (fr *FlightRecorder) Enabled() bool {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return fr.enabled
+}
+
+// WriteTo writes a snapshot of the recorded trace to w.
+// The snapshot is a complete trace that can be read with
+// "go tool trace" and includes the most recent events.
+// It returns an error if the flight recorder is not running.
+//
+// WriteTo ends the current generation of the trace to include it in the
+// snapshot. This does not stop the world, but it waits for the mark
+// phase of a garbage collection in progress, if any, to end.
+func (fr *FlightRecorder) WriteTo(w io.Writer) (n int64, err error) {
+	fr.mu.Lock()
+	if !fr.enabled {
+		fr.mu.Unlock()
+		return 0, errors.New("flight recorder is not running")
+	}
+	fr.mu.Unlock()
+
+	// Start a new generation and wait until the previous one,
+	// with the events up to now, has been read.
+	gen := advance()
+
+	fr.mu.Lock()
+	for fr.enabled && gen != 0 && fr.complete < gen-1 {
+		fr.cond.Wait()
+	}
+	if !fr.enabled {
+		fr.mu.Unlock()
+		return 0, errors.New("flight recorder is not running")
+	}
+	// Chunks are never modified once read, so they can be
+	// written without holding the lock.
+	header := fr.header
+	gens := fr.gens
+	fr.mu.Unlock()
+
+	m, err := w.Write(header)
+	n += int64(m)
+	if err != nil {
+		return n, err
+	}
+	for _, g := range gens {
+		for _, c := range g.chunks {
+			m, err := w.Write(c)
+			n += int64(m)
+			if err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// read reads the trace from the runtime until tracing stops,
+// splitting it into generations.
+func (fr *FlightRecorder) read() {
+	for {
+		data := runtime.ReadTrace()
+		if data == nil {
+			break
+		}
+		chunk := append([]byte(nil), data...)
+
+		fr.mu.Lock()
+		switch {
+		case fr.header == nil:
+			fr.header = chunk
+		case chunk[0] == evGeneration:
+			if fr.cur != nil {
+				fr.finish(fr.cur)
+			}
+			num, _ := uvarint(chunk[1:])
+			fr.cur = &frGeneration{num: num}
+			fr.cur.add(chunk)
+		case fr.cur != nil:
+			fr.cur.add(chunk)
+		}
+		fr.mu.Unlock()
+	}
+
+	fr.mu.Lock()
+	fr.reading = false
+	fr.cond.Broadcast()
+	fr.mu.Unlock()
+}
+
+// finish adds the complete generation g to the ring and discards
+// the generations that are no longer needed. fr.mu must be held.
+func (fr *FlightRecorder) finish(g *frGeneration) {
+	g.end = time.Now()
+	fr.gens = append(fr.gens, g)
+	fr.size += g.size
+	fr.complete = g.num
+
+	// Keep the newest generation even if it alone exceeds MaxBytes.
+	for len(fr.gens) > 1 {
+		old := fr.gens[0]
+		if fr.size <= fr.cfg.MaxBytes && g.end.Sub(old.end) < fr.cfg.MinAge {
+			break
+		}
+		fr.size -= old.size
+		fr.gens[0] = nil
+		fr.gens = fr.gens[1:]
+	}
+	fr.cond.Broadcast()
+}
+
+func (g *frGeneration) add(chunk []byte) {
+	g.chunks = append(g.chunks, chunk)
+	g.size += uint64(len(chunk))
+}
+
+// uvarint decodes a varint as written by the runtime.
+func uvarint(buf []byte) (v uint64, n int) {
+	for i, b := range buf {
+		if i == 10 {
+			break
+		}
+		v |= uint64(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
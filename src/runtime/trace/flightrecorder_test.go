@@ -0,0 +1,120 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace_test
+
+import (
+	"bytes"
+	"context"
+	"internal/trace"
+	. "runtime/trace"
+	"testing"
+	"time"
+)
+
+func TestFlightRecorder(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	fr := NewFlightRecorder(FlightRecorderConfig{})
+	if err := fr.Start(); err != nil {
+		t.Fatalf("failed to start flight recorder: %v", err)
+	}
+	defer fr.Stop()
+	if !fr.Enabled() {
+		t.Fatalf("flight recorder is not enabled after Start")
+	}
+
+	ctx := context.Background()
+	done := make(chan bool)
+	go func() {
+		Log(ctx, "flight", "before")
+		done <- true
+	}()
+	<-done
+
+	buf := new(bytes.Buffer)
+	if _, err := fr.WriteTo(buf); err != nil {
+		t.Fatalf("failed to write snapshot: %v", err)
+	}
+	saveTrace(t, buf, "TestFlightRecorder")
+	events, _ := parseTrace(t, buf)
+	if !hasLog(events, "before") {
+		t.Errorf("snapshot does not contain the logged message")
+	}
+}
+
+func TestFlightRecorderMinAge(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	fr := NewFlightRecorder(FlightRecorderConfig{MinAge: time.Nanosecond})
+	if err := fr.Start(); err != nil {
+		t.Fatalf("failed to start flight recorder: %v", err)
+	}
+	defer fr.Stop()
+
+	ctx := context.Background()
+	Log(ctx, "flight", "old")
+	Advance()
+	Advance()
+	Log(ctx, "flight", "new")
+
+	// Only the last generation is younger than MinAge, so the
+	// snapshot must only contain the second message.
+	buf := new(bytes.Buffer)
+	if _, err := fr.WriteTo(buf); err != nil {
+		t.Fatalf("failed to write snapshot: %v", err)
+	}
+	events, _ := parseTrace(t, buf)
+	if hasLog(events, "old") {
+		t.Errorf("snapshot contains a message older than MinAge")
+	}
+	if !hasLog(events, "new") {
+		t.Errorf("snapshot does not contain the most recent message")
+	}
+}
+
+func TestFlightRecorderStartStop(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	fr := NewFlightRecorder(FlightRecorderConfig{})
+	if _, err := fr.WriteTo(new(bytes.Buffer)); err == nil {
+		t.Errorf("WriteTo succeeded before Start")
+	}
+	for i := 0; i < 2; i++ {
+		if err := fr.Start(); err != nil {
+			t.Fatalf("failed to start flight recorder: %v", err)
+		}
+		if err := fr.Start(); err == nil {
+			t.Errorf("flight recorder started twice")
+		}
+		if err := Start(new(bytes.Buffer)); err == nil {
+			Stop()
+			t.Errorf("tracing started while the flight recorder is running")
+		}
+		// Stop must not stop the flight recorder.
+		Stop()
+		if !fr.Enabled() || !IsEnabled() {
+			t.Errorf("flight recorder stopped by Stop")
+		}
+		fr.Stop()
+		if fr.Enabled() || IsEnabled() {
+			t.Errorf("flight recorder enabled after Stop")
+		}
+		if _, err := fr.WriteTo(new(bytes.Buffer)); err == nil {
+			t.Errorf("WriteTo succeeded after Stop")
+		}
+	}
+}
+
+func hasLog(events []*trace.Event, message string) bool {
+	for _, ev := range events {
+		if ev.Type == trace.EvUserLog && ev.SArgs[1] == message {
+			return true
+		}
+	}
+	return false
+}
@@ -34,6 +34,24 @@
 // See the net/http/pprof package for more details about all of the
 // debug endpoints installed by this import.
 //
+// Flight recording
+//
+// Tracing is cheap enough to leave enabled for long periods, but a full
+// trace of a long-running program is large. A FlightRecorder instead
+// keeps only the last few seconds of the trace in memory, and writes a
+// snapshot of them on demand, for example when a request takes too long:
+//
+//    fr := trace.NewFlightRecorder(trace.FlightRecorderConfig{MinAge: 5 * time.Second})
+//    fr.Start()
+//    defer fr.Stop()
+//    ...
+//    if elapsed > time.Second {
+//            fr.WriteTo(f)
+//    }
+//
+// The runtime splits traces into generations of about a second each; a
+// snapshot consists of the most recent complete generations.
+//
 // User annotation
 //
 // Package trace provides user annotation APIs that can be used to
@@ -139,15 +157,20 @@ func Start(w io.Writer) error {
 
 // Stop stops the current tracing, if any.
 // Stop only returns after all the writes for the trace have completed.
+// Stop does not stop a flight recorder; use FlightRecorder.Stop instead.
 func Stop() {
 	tracing.Lock()
 	defer tracing.Unlock()
+	if tracing.recorder != nil {
+		return
+	}
 	atomic.StoreInt32(&tracing.enabled, 0)
 
 	runtime.StopTrace()
 }
 
 var tracing struct {
-	sync.Mutex       // gate mutators (Start, Stop)
-	enabled    int32 // accessed via atomic
+	sync.Mutex                 // gate mutators (Start, Stop)
+	enabled    int32           // accessed via atomic
+	recorder   *FlightRecorder // active flight recorder, if any
 }
@@ -137,6 +137,173 @@ func TestTrace(t *testing.T) {
 	}
 }
 
+func TestTraceGenerations(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	// A goroutine that is blocked for the whole trace, and one that
+	// is in a syscall, are only described in the generation in which
+	// they are unblocked, by status events written before that.
+	block := make(chan bool)
+	go func() { <-block }()
+	rp, wp, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	defer rp.Close()
+	defer wp.Close()
+	go func() {
+		var tmp [1]byte
+		rp.Read(tmp[:])
+	}()
+	time.Sleep(time.Millisecond)
+
+	buf := new(bytes.Buffer)
+	if err := Start(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+	const gens = 5
+	for i := 0; i < gens; i++ {
+		// Leave goroutines runnable, running and blocked across
+		// generation boundaries.
+		done := make(chan bool)
+		for j := 0; j < 10; j++ {
+			go func() {
+				time.Sleep(time.Millisecond)
+				done <- true
+			}()
+		}
+		Advance()
+		for j := 0; j < 10; j++ {
+			<-done
+		}
+	}
+	wp.Write([]byte{0})
+	close(block)
+	Stop()
+	saveTrace(t, buf, "TestTraceGenerations")
+
+	data := buf.Bytes()
+	r, err := trace.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to read trace: %v", err)
+	}
+	n := 0
+	for {
+		_, _, err := r.ReadGeneration()
+		if err == io.EOF {
+			break
+		}
+		if err == trace.ErrTimeOrder {
+			t.Skipf("skipping trace: %v", err)
+		}
+		if err != nil {
+			t.Fatalf("failed to read generation %d: %v", n+1, err)
+		}
+		n++
+	}
+	if n < gens+1 {
+		t.Errorf("trace has %d generations, want at least %d", n, gens+1)
+	}
+	events, _ := parseTrace(t, bytes.NewReader(data))
+	created := make(map[uint64]bool)
+	for _, ev := range events {
+		if ev.Type == trace.EvGoCreate {
+			if created[ev.Args[0]] {
+				t.Fatalf("goroutine %d created twice", ev.Args[0])
+			}
+			created[ev.Args[0]] = true
+		}
+	}
+}
+
+func TestTraceGenerationsSweep(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	// Allocate on several Ps while generations advance, so that some
+	// of them are sweeping when the generation switches. The next
+	// generation then describes these sweeps with status events.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	stop := make(chan bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		c := make(chan []byte)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			defer close(c)
+			for {
+				select {
+				case <-stop:
+					return
+				case c <- make([]byte, 1024):
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			var keep [][]byte
+			for b := range c {
+				keep = append(keep, b)
+				if len(keep) == cap(keep) {
+					keep = nil
+				}
+			}
+		}()
+	}
+	advance := func() {
+		for i := 0; i < 20; i++ {
+			time.Sleep(time.Millisecond)
+			Advance()
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := Start(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+	advance()
+	Stop()
+
+	fr := NewFlightRecorder(FlightRecorderConfig{})
+	if err := fr.Start(); err != nil {
+		t.Fatalf("failed to start flight recorder: %v", err)
+	}
+	advance()
+	snapshot := new(bytes.Buffer)
+	_, err := fr.WriteTo(snapshot)
+	fr.Stop()
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("failed to write snapshot: %v", err)
+	}
+
+	saveTrace(t, buf, "TestTraceGenerationsSweep")
+	checkSweepTrace(t, buf)
+	saveTrace(t, snapshot, "TestTraceGenerationsSweepSnapshot")
+	checkSweepTrace(t, snapshot)
+}
+
+// checkSweepTrace parses the trace in buf and checks that it contains
+// sweeps.
+func checkSweepTrace(t *testing.T, buf *bytes.Buffer) {
+	res, err := trace.Parse(buf, "")
+	if err == trace.ErrTimeOrder {
+		t.Skipf("skipping trace: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("failed to parse trace: %v", err)
+	}
+	for _, ev := range res.Events {
+		if ev.Type == trace.EvGCSweepStart {
+			return
+		}
+	}
+	t.Errorf("trace contains no sweeps")
+}
+
 func parseTrace(t *testing.T, r io.Reader) ([]*trace.Event, map[uint64]*trace.GDesc) {
 	res, err := trace.Parse(r, "")
 	if err == trace.ErrTimeOrder {
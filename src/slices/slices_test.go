@@ -0,0 +1,311 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package slices
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+var equalIntTests = []struct {
+	s1, s2 []int
+	want   bool
+}{
+	{[]int{1}, nil, false},
+	{[]int{}, nil, true},
+	{[]int{1, 2, 3}, []int{1, 2, 3}, true},
+	{[]int{1, 2, 3}, []int{1, 2, 3, 4}, false},
+}
+
+var equalFloatTests = []struct {
+	s1, s2       []float64
+	wantEqual    bool
+	wantEqualNaN bool
+}{
+	{[]float64{1, 2}, []float64{1, 2}, true, true},
+	{[]float64{1, 2, math.NaN()}, []float64{1, 2, math.NaN()}, false, true},
+}
+
+func TestEqual(t *testing.T) {
+	for _, test := range equalIntTests {
+		if got := Equal(test.s1, test.s2); got != test.want {
+			t.Errorf("Equal(%v, %v) = %t, want %t", test.s1, test.s2, got, test.want)
+		}
+	}
+	for _, test := range equalFloatTests {
+		if got := Equal(test.s1, test.s2); got != test.wantEqual {
+			t.Errorf("Equal(%v, %v) = %t, want %t", test.s1, test.s2, got, test.wantEqual)
+		}
+	}
+}
+
+// equal is simply ==.
+func equal[T comparable](v1, v2 T) bool {
+	return v1 == v2
+}
+
+// equalNaN is like == except that all NaNs are equal.
+func equalNaN[T comparable](v1, v2 T) bool {
+	isNaN := func(f T) bool { return f != f }
+	return v1 == v2 || (isNaN(v1) && isNaN(v2))
+}
+
+func TestEqualFunc(t *testing.T) {
+	for _, test := range equalIntTests {
+		if got := EqualFunc(test.s1, test.s2, equal[int]); got != test.want {
+			t.Errorf("EqualFunc(%v, %v, equal[int]) = %t, want %t", test.s1, test.s2, got, test.want)
+		}
+	}
+	for _, test := range equalFloatTests {
+		if got := EqualFunc(test.s1, test.s2, equalNaN[float64]); got != test.wantEqualNaN {
+			t.Errorf("EqualFunc(%v, %v, equalNaN[float64]) = %t, want %t", test.s1, test.s2, got, test.wantEqualNaN)
+		}
+	}
+
+	s1 := []int{1, 2, 3}
+	s2 := []string{"1", "2", "3"}
+	if !EqualFunc(s1, s2, func(i int, s string) bool { return string(rune('0'+i)) == s }) {
+		t.Errorf("EqualFunc(%v, %v, intString) = false, want true", s1, s2)
+	}
+}
+
+var compareIntTests = []struct {
+	s1, s2 []int
+	want   int
+}{
+	{[]int{1, 2, 3}, []int{1, 2, 3, 4}, -1},
+	{[]int{1, 2, 3, 4}, []int{1, 2, 3}, +1},
+	{[]int{1, 2, 3}, []int{1, 4, 3}, -1},
+	{[]int{1, 4, 3}, []int{1, 2, 3}, +1},
+	{nil, []int{}, 0},
+}
+
+func TestCompare(t *testing.T) {
+	for _, test := range compareIntTests {
+		if got := Compare(test.s1, test.s2); got != test.want {
+			t.Errorf("Compare(%v, %v) = %d, want %d", test.s1, test.s2, got, test.want)
+		}
+	}
+}
+
+func TestCompareFunc(t *testing.T) {
+	intCmp := func(a, b int) int {
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return +1
+		}
+		return 0
+	}
+	for _, test := range compareIntTests {
+		if got := CompareFunc(test.s1, test.s2, intCmp); got != test.want {
+			t.Errorf("CompareFunc(%v, %v, intCmp) = %d, want %d", test.s1, test.s2, got, test.want)
+		}
+	}
+}
+
+var indexTests = []struct {
+	s    []int
+	v    int
+	want int
+}{
+	{nil, 0, -1},
+	{[]int{}, 0, -1},
+	{[]int{1, 2, 3}, 2, 1},
+	{[]int{1, 2, 2, 3}, 2, 1},
+	{[]int{1, 2, 3, 2}, 2, 1},
+	{[]int{1, 2, 3}, 4, -1},
+}
+
+func TestIndex(t *testing.T) {
+	for _, test := range indexTests {
+		if got := Index(test.s, test.v); got != test.want {
+			t.Errorf("Index(%v, %v) = %d, want %d", test.s, test.v, got, test.want)
+		}
+		if got := IndexFunc(test.s, func(e int) bool { return e == test.v }); got != test.want {
+			t.Errorf("IndexFunc(%v, equalTo(%v)) = %d, want %d", test.s, test.v, got, test.want)
+		}
+		if got, want := Contains(test.s, test.v), test.want != -1; got != want {
+			t.Errorf("Contains(%v, %v) = %t, want %t", test.s, test.v, got, want)
+		}
+	}
+}
+
+var insertTests = []struct {
+	s    []int
+	i    int
+	add  []int
+	want []int
+}{
+	{[]int{1, 2, 3}, 0, []int{4}, []int{4, 1, 2, 3}},
+	{[]int{1, 2, 3}, 1, []int{4}, []int{1, 4, 2, 3}},
+	{[]int{1, 2, 3}, 3, []int{4}, []int{1, 2, 3, 4}},
+	{[]int{1, 2, 3}, 2, []int{4, 5}, []int{1, 2, 4, 5, 3}},
+}
+
+func TestInsert(t *testing.T) {
+	s := []int{1, 2, 3}
+	if got := Insert(s, 0); !Equal(got, s) {
+		t.Errorf("Insert(%v, 0) = %v, want %v", s, got, s)
+	}
+	for _, test := range insertTests {
+		// Check both the reallocating and the in-place paths.
+		for _, extra := range []int{0, len(test.add)} {
+			s := make([]int, len(test.s), len(test.s)+extra)
+			copy(s, test.s)
+			if got := Insert(s, test.i, test.add...); !Equal(got, test.want) {
+				t.Errorf("Insert(%v, %d, %v...) = %v, want %v", test.s, test.i, test.add, got, test.want)
+			}
+		}
+	}
+}
+
+var deleteTests = []struct {
+	s    []int
+	i, j int
+	want []int
+}{
+	{[]int{1, 2, 3}, 0, 0, []int{1, 2, 3}},
+	{[]int{1, 2, 3}, 0, 1, []int{2, 3}},
+	{[]int{1, 2, 3}, 3, 3, []int{1, 2, 3}},
+	{[]int{1, 2, 3}, 0, 2, []int{3}},
+	{[]int{1, 2, 3}, 0, 3, []int{}},
+}
+
+func TestDelete(t *testing.T) {
+	for _, test := range deleteTests {
+		copy := Clone(test.s)
+		if got := Delete(copy, test.i, test.j); !Equal(got, test.want) {
+			t.Errorf("Delete(%v, %d, %d) = %v, want %v", test.s, test.i, test.j, got, test.want)
+		}
+	}
+}
+
+func TestDeletePanics(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		s    []int
+		i, j int
+	}{
+		{"with negative first index", []int{42}, -2, 1},
+		{"with negative second index", []int{42}, 1, -1},
+		{"with out-of-bounds first index", []int{42}, 2, 3},
+		{"with out-of-bounds second index", []int{42}, 0, 2},
+		{"with invalid i>j", []int{42}, 1, 0},
+	} {
+		if !panics(func() { Delete(test.s, test.i, test.j) }) {
+			t.Errorf("Delete %s: got no panic, want panic", test.name)
+		}
+	}
+}
+
+func panics(f func()) (b bool) {
+	defer func() {
+		if x := recover(); x != nil {
+			b = true
+		}
+	}()
+	f()
+	return false
+}
+
+func TestClone(t *testing.T) {
+	s1 := []int{1, 2, 3}
+	s2 := Clone(s1)
+	if !Equal(s1, s2) {
+		t.Errorf("Clone(%v) = %v, want %v", s1, s2, s1)
+	}
+	s1[0] = 4
+	if want := []int{1, 2, 3}; !Equal(s2, want) {
+		t.Errorf("Clone(%v) changed unexpectedly to %v", want, s2)
+	}
+	if got := Clone([]int(nil)); got != nil {
+		t.Errorf("Clone(nil) = %#v, want nil", got)
+	}
+	if got := Clone(s1[:0]); got == nil || len(got) != 0 {
+		t.Errorf("Clone(%v) = %#v, want %#v", s1[:0], got, s1[:0])
+	}
+}
+
+var compactTests = []struct {
+	s    []int
+	want []int
+}{
+	{nil, nil},
+	{[]int{1}, []int{1}},
+	{[]int{1, 2, 3}, []int{1, 2, 3}},
+	{[]int{1, 1, 2}, []int{1, 2}},
+	{[]int{1, 2, 1}, []int{1, 2, 1}},
+	{[]int{1, 2, 2, 3, 3, 4}, []int{1, 2, 3, 4}},
+}
+
+func TestCompact(t *testing.T) {
+	for _, test := range compactTests {
+		copy := Clone(test.s)
+		if got := Compact(copy); !Equal(got, test.want) {
+			t.Errorf("Compact(%v) = %v, want %v", test.s, got, test.want)
+		}
+	}
+}
+
+func TestCompactFunc(t *testing.T) {
+	for _, test := range compactTests {
+		copy := Clone(test.s)
+		if got := CompactFunc(copy, equal[int]); !Equal(got, test.want) {
+			t.Errorf("CompactFunc(%v, equal[int]) = %v, want %v", test.s, got, test.want)
+		}
+	}
+
+	s1 := []string{"a", "a", "A", "B", "b"}
+	copy := Clone(s1)
+	want := []string{"a", "B"}
+	if got := CompactFunc(copy, strings.EqualFold); !Equal(got, want) {
+		t.Errorf("CompactFunc(%v, strings.EqualFold) = %v, want %v", s1, got, want)
+	}
+}
+
+func TestGrow(t *testing.T) {
+	s1 := []int{1, 2, 3}
+
+	copy := Clone(s1)
+	s2 := Grow(copy, 1000)
+	if !Equal(s1, s2) {
+		t.Errorf("Grow(%v) = %v, want %v", s1, s2, s1)
+	}
+	if cap(s2) < 1000+len(s1) {
+		t.Errorf("after Grow(%v) cap = %d, want >= %d", s1, cap(s2), 1000+len(s1))
+	}
+
+	// Test mutation of elements between length and capacity.
+	copy = Clone(s1)
+	s3 := Grow(copy[:1], 2)[:3]
+	if !Equal(s1, s3) {
+		t.Errorf("Grow should not mutate elements between length and capacity")
+	}
+
+	if !panics(func() { Grow(s1, -1) }) {
+		t.Errorf("Grow(-1) did not panic; expected a panic")
+	}
+}
+
+func TestClip(t *testing.T) {
+	s1 := []int{1, 2, 3, 4, 5, 6}[:3]
+	orig := Clone(s1)
+	if len(s1) != 3 {
+		t.Errorf("len(%v) = %d, want 3", s1, len(s1))
+	}
+	if cap(s1) < 6 {
+		t.Errorf("cap(%v[:3]) = %d, want >= 6", orig, cap(s1))
+	}
+	s2 := Clip(s1)
+	if !Equal(s1, s2) {
+		t.Errorf("Clip(%v) = %v, want %v", s1, s2, s1)
+	}
+	if cap(s2) != 3 {
+		t.Errorf("cap(Clip(%v)) = %d, want 3", orig, cap(s2))
+	}
+}
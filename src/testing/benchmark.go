@@ -114,6 +114,9 @@ type B struct {
 	netBytes  uint64
 	// Extra metrics collected by ReportMetric.
 	extra map[string]float64
+	// Number of iterations started by Loop in the current run, or 0 if
+	// Loop has not been called.
+	loopN int
 }
 
 // StartTimer starts timing a test. This function is called automatically
@@ -187,12 +190,17 @@ func (b *B) runN(n int) {
 	runtime.GC()
 	b.raceErrors = -race.Errors()
 	b.N = n
+	b.loopN = 0
 	b.parallelism = 1
 	b.ResetTimer()
 	b.StartTimer()
 	b.benchFunc(b)
 	b.StopTimer()
-	b.previousN = n
+	if b.loopN != 0 {
+		// A benchmark that uses Loop runs its own number of iterations.
+		b.N = b.loopN
+	}
+	b.previousN = b.N
 	b.previousDuration = b.duration
 	b.raceErrors += race.Errors()
 	if b.raceErrors > 0 {
@@ -297,6 +305,12 @@ func (b *B) launch() {
 		b.signal <- true
 	}()
 
+	// A benchmark that uses Loop ramps up within its single call in run1.
+	if b.loopN != 0 {
+		b.result = BenchmarkResult{b.N, b.duration, b.bytes, b.netAllocs, b.netBytes, b.extra}
+		return
+	}
+
 	// Run the benchmark for at least the specified amount of time.
 	if b.benchTime.n > 0 {
 		// We already ran a single iteration in run1.
@@ -309,34 +323,103 @@ func (b *B) launch() {
 		d := b.benchTime.d
 		for n := int64(1); !b.failed && b.duration < d && n < 1e9; {
 			last := n
-			// Predict required iterations.
-			goalns := d.Nanoseconds()
-			prevIters := int64(b.N)
-			prevns := b.duration.Nanoseconds()
-			if prevns <= 0 {
-				// Round up, to avoid div by zero.
-				prevns = 1
-			}
-			// Order of operations matters.
-			// For very fast benchmarks, prevIters ~= prevns.
-			// If you divide first, you get 0 or 1,
-			// which can hide an order of magnitude in execution time.
-			// So multiply first, then divide.
-			n = goalns * prevIters / prevns
-			// Run more iterations than we think we'll need (1.2x).
-			n += n / 5
-			// Don't grow too fast in case we had timing errors previously.
-			n = min(n, 100*last)
-			// Be sure to run at least one more than last time.
-			n = max(n, last+1)
-			// Don't run more than 1e9 times. (This also keeps n in int range on 32 bit platforms.)
-			n = min(n, 1e9)
+			n = predictN(d.Nanoseconds(), int64(b.N), b.duration.Nanoseconds(), last)
 			b.runN(int(n))
 		}
 	}
 	b.result = BenchmarkResult{b.N, b.duration, b.bytes, b.netAllocs, b.netBytes, b.extra}
 }
 
+// predictN predicts the number of iterations needed for a benchmark to
+// run for goalns nanoseconds, given that prevIters iterations took prevns
+// nanoseconds, and that the last attempt ran last iterations.
+func predictN(goalns, prevIters, prevns, last int64) int64 {
+	if prevns <= 0 {
+		// Round up, to avoid div by zero.
+		prevns = 1
+	}
+	// Order of operations matters.
+	// For very fast benchmarks, prevIters ~= prevns.
+	// If you divide first, you get 0 or 1,
+	// which can hide an order of magnitude in execution time.
+	// So multiply first, then divide.
+	n := goalns * prevIters / prevns
+	// Run more iterations than we think we'll need (1.2x).
+	n += n / 5
+	// Don't grow too fast in case we had timing errors previously.
+	n = min(n, 100*last)
+	// Be sure to run at least one more than last time.
+	n = max(n, last+1)
+	// Don't run more than 1e9 times. (This also keeps n in int range on 32 bit platforms.)
+	n = min(n, 1e9)
+	return n
+}
+
+// Loop reports whether the benchmark should run another iteration.
+// It is meant to be the condition of the benchmark loop, in place of
+// a loop over b.N:
+//
+//     func BenchmarkXxx(b *testing.B) {
+//         // setup
+//         for b.Loop() {
+//             // code to measure
+//         }
+//         // cleanup
+//     }
+//
+// A benchmark that uses Loop is called only once, rather than once per
+// value of b.N that the testing package tries: Loop itself runs more
+// iterations until the benchmark has run for long enough. So setup and
+// cleanup run only once, and are not timed: the first call to Loop
+// resets the timer, and the call that ends the loop stops it.
+//
+// The compiler does not inline calls in the body of a "for b.Loop()"
+// loop, so that their arguments and results are kept alive, and the
+// calls are not optimized away.
+//
+// A benchmark should either use Loop or loop over b.N, not both.
+// After the loop, b.N holds the number of iterations that were run.
+func (b *B) Loop() bool {
+	if b.loopN != 0 && b.loopN < b.N {
+		b.loopN++
+		return true
+	}
+	return b.loopSlowPath()
+}
+
+// loopSlowPath handles the first call to Loop, and the calls that end
+// a series of b.N iterations, which decide whether to run more.
+func (b *B) loopSlowPath() bool {
+	if b.loopN == 0 {
+		// Start timing the loop, not the setup before it.
+		b.N = 1
+		b.loopN = 1
+		b.ResetTimer()
+		return true
+	}
+	if b.benchTime.n > 0 {
+		// -benchtime=Nx: run exactly N iterations.
+		if b.N < b.benchTime.n {
+			b.N = b.benchTime.n
+			b.loopN++
+			return true
+		}
+		b.StopTimer()
+		return false
+	}
+	elapsed := b.duration
+	if b.timerOn {
+		elapsed += time.Since(b.start)
+	}
+	if b.failed || elapsed >= b.benchTime.d || b.N >= 1e9 {
+		b.StopTimer()
+		return false
+	}
+	b.N = int(predictN(b.benchTime.d.Nanoseconds(), int64(b.N), elapsed.Nanoseconds(), int64(b.N)))
+	b.loopN++
+	return true
+}
+
 // ReportMetric adds "n unit" to the reported benchmark results.
 // If the metric is per-iteration, the caller should divide by b.N,
 // and by convention units should end in "/op".
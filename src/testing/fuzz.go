@@ -6,6 +6,7 @@ package testing
 
 import (
 	"bytes"
+	"encoding"
 	"errors"
 	"flag"
 	"fmt"
@@ -153,7 +154,7 @@ func (f *F) Skipped() bool {
 func (f *F) Add(args ...any) {
 	var values []any
 	for i := range args {
-		if t := reflect.TypeOf(args[i]); !supportedTypes[t] {
+		if t := reflect.TypeOf(args[i]); !isSupportedType(t) {
 			panic(fmt.Sprintf("testing: unsupported type to Add %v", t))
 		}
 		values = append(values, args[i])
@@ -161,7 +162,7 @@ func (f *F) Add(args ...any) {
 	f.corpus = append(f.corpus, corpusEntry{Values: values, IsSeed: true, Path: fmt.Sprintf("seed#%d", len(f.corpus))})
 }
 
-// supportedTypes represents all of the supported types which can be fuzzed.
+// supportedTypes represents the basic types which can be fuzzed.
 var supportedTypes = map[reflect.Type]bool{
 	reflect.TypeOf(([]byte)("")):  true,
 	reflect.TypeOf((string)("")):  true,
@@ -182,6 +183,57 @@ var supportedTypes = map[reflect.Type]bool{
 	reflect.TypeOf((uint64)(0)):   true,
 }
 
+var (
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// isSupportedType reports whether values of type t can be fuzzed: the types
+// in supportedTypes, other types with the same kinds, types implementing
+// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler, and slices, arrays,
+// maps and structs with exported fields made of supported types.
+func isSupportedType(t reflect.Type) bool {
+	return isSupportedTypeSeen(t, make(map[reflect.Type]bool))
+}
+
+func isSupportedTypeSeen(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if t == nil {
+		return false
+	}
+	if supportedTypes[t] || seen[t] {
+		// Types seen before are recursive, and are being checked further up.
+		return true
+	}
+	seen[t] = true
+	pt := t
+	if t.Kind() != reflect.Pointer {
+		pt = reflect.PointerTo(t)
+	}
+	if pt.Implements(binaryMarshalerType) && pt.Implements(binaryUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	case reflect.Slice, reflect.Array:
+		return isSupportedTypeSeen(t.Elem(), seen)
+	case reflect.Map:
+		return isSupportedTypeSeen(t.Key(), seen) && isSupportedTypeSeen(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() || !isSupportedTypeSeen(f.Type, seen) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
 // Fuzz runs the fuzz function, ff, for fuzz testing. If ff fails for a set of
 // arguments, those arguments will be added to the seed corpus.
 //
@@ -192,8 +244,12 @@ var supportedTypes = map[reflect.Type]bool{
 //     f.Fuzz(func(t *testing.T, b []byte, i int) { ... })
 //
 // The following types are allowed: []byte, string, bool, byte, rune, float32,
-// float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64.
-// More types may be supported in the future.
+// float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+// and other types with the same underlying kinds. Structs whose fields are all
+// exported, and slices, arrays and maps, are allowed if they are made of
+// allowed types. Types whose pointer type implements both
+// encoding.BinaryMarshaler and encoding.BinaryUnmarshaler are allowed as
+// well, and are mutated through their binary form.
 //
 // ff must not call any *F methods, e.g. (*F).Log, (*F).Error, (*F).Skip. Use
 // the corresponding *T method instead. The only *F methods that are allowed in
@@ -232,7 +288,7 @@ func (f *F) Fuzz(ff any) {
 	var types []reflect.Type
 	for i := 1; i < fnType.NumIn(); i++ {
 		t := fnType.In(i)
-		if !supportedTypes[t] {
+		if !isSupportedType(t) {
 			panic(fmt.Sprintf("testing: unsupported type for fuzzing %v", t))
 		}
 		types = append(types, t)
@@ -380,7 +436,7 @@ func (f *F) Fuzz(ff any) {
 				return errors.New(buf.String())
 			}
 			return nil
-		}); err != nil {
+		}, types); err != nil {
 			// Internal errors are marked with f.Fail; user code may call this too, before F.Fuzz.
 			// The worker will exit with fuzzWorkerExitCode, indicating this is a failure
 			// (and 'go test' should exit non-zero) but a failing input should not be recorded.
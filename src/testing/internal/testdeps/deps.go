@@ -166,7 +166,7 @@ func (TestDeps) CoordinateFuzzing(
 	return err
 }
 
-func (TestDeps) RunFuzzWorker(fn func(fuzz.CorpusEntry) error) error {
+func (TestDeps) RunFuzzWorker(fn func(fuzz.CorpusEntry) error, types []reflect.Type) error {
 	// Worker processes may or may not receive a signal when the user presses ^C
 	// On POSIX operating systems, a signal sent to a process group is delivered
 	// to all processes in that group. This is not the case on Windows.
@@ -175,7 +175,7 @@ func (TestDeps) RunFuzzWorker(fn func(fuzz.CorpusEntry) error) error {
 	// process to stop by closing its "fuzz_in" pipe.
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
-	err := fuzz.RunFuzzWorker(ctx, fn)
+	err := fuzz.RunFuzzWorker(ctx, fn, types)
 	if err == ctx.Err() {
 		return nil
 	}
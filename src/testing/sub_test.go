@@ -730,6 +730,61 @@ func TestBenchmarkReadMemStatsBeforeFirstRun(t *T) {
 	})
 }
 
+func TestBenchmarkLoop(t *T) {
+	defer func(old durationOrCountFlag) {
+		benchTime = old
+	}(benchTime)
+
+	benchTime = durationOrCountFlag{d: 10 * time.Millisecond}
+	calls, iters := 0, 0
+	var b1 *B
+	res := Benchmark(func(b *B) {
+		b1 = b
+		calls++
+		setupDone := time.Now()
+		for b.Loop() {
+			iters++
+			if !b.timerOn {
+				t.Errorf("iteration %d: timer is stopped, want running", iters)
+			}
+			if iters == 1 && b.start.Before(setupDone) {
+				t.Errorf("timer was not reset by the first call to Loop")
+			}
+		}
+		if b.timerOn {
+			t.Errorf("after loop: timer is running, want stopped")
+		}
+		if b.N != iters {
+			t.Errorf("after loop: b.N = %d, want %d", b.N, iters)
+		}
+	})
+	if calls != 1 {
+		t.Errorf("benchmark function called %d times, want 1", calls)
+	}
+	if iters < 2 {
+		t.Errorf("ran %d iterations, want at least 2", iters)
+	}
+	if res.N != iters || b1.previousN != iters {
+		t.Errorf("got N = %d and previousN = %d after %d iterations, want both equal to iterations", res.N, b1.previousN, iters)
+	}
+	// The loop only ends once it has run for the benchmark time.
+	if res.T < benchTime.d {
+		t.Errorf("got duration %v, want at least %v", res.T, benchTime.d)
+	}
+
+	benchTime = durationOrCountFlag{n: 100}
+	calls, iters = 0, 0
+	res = Benchmark(func(b *B) {
+		calls++
+		for b.Loop() {
+			iters++
+		}
+	})
+	if calls != 1 || iters != 100 || res.N != 100 {
+		t.Errorf("with -benchtime=100x: got %d calls, %d iterations and N = %d, want 1, 100 and 100", calls, iters, res.N)
+	}
+}
+
 func TestParallelSub(t *T) {
 	c := make(chan int)
 	block := make(chan int)
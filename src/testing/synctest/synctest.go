@@ -0,0 +1,60 @@
+// Copyright 2022 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package synctest provides support for testing concurrent code.
+//
+// The Run function starts a function in an isolated group of goroutines,
+// called a bubble, that uses a fake clock. Within the bubble, functions
+// such as time.Now, time.Sleep and time.NewTimer use the fake clock.
+// The clock advances only when every goroutine in the bubble is durably
+// blocked, so tests of code that waits for time to pass run instantly
+// and give the same results every time.
+//
+// A goroutine in a bubble is durably blocked when it can only be
+// unblocked by another goroutine in the bubble. These operations durably
+// block a goroutine:
+//
+//   - a send or receive on a channel created within the bubble
+//   - a select statement in which every case is a channel created within
+//     the bubble
+//   - time.Sleep
+//   - sync.Cond.Wait
+//   - sync.WaitGroup.Wait
+//
+// Other operations, such as locking a sync.Mutex, a system call or
+// waiting for network I/O, do not durably block a goroutine, since they
+// may be unblocked by something outside the bubble.
+//
+// Channels and timers created within a bubble belong to it. Operating
+// on them from outside the bubble panics.
+package synctest
+
+import "internal/synctest"
+
+// Run executes f in a new goroutine, in a new bubble.
+//
+// Every goroutine started by a goroutine in the bubble joins the bubble.
+// Run waits for all goroutines in the bubble to exit before returning.
+// Timers that have not fired by then are stopped.
+//
+// The fake clock of the bubble starts at midnight UTC 2000-01-01.
+// When every goroutine in the bubble is durably blocked, the clock
+// advances to the time of the next timer to fire in the bubble.
+// If there is no such timer, the goroutines are deadlocked, and Run
+// panics.
+//
+// Run panics if called from within a bubble.
+func Run(f func()) {
+	synctest.Run(f)
+}
+
+// Wait blocks until every goroutine in the bubble of the current
+// goroutine, other than the current one, is durably blocked.
+// It does not advance the clock.
+//
+// Wait panics if called from outside a bubble, or if two goroutines in
+// the same bubble call it at once.
+func Wait() {
+	synctest.Wait()
+}
@@ -60,6 +60,19 @@
 //         }
 //     }
 //
+// Alternatively, a benchmark may use b.Loop as the condition of its loop.
+// The benchmark function is then called only once, Loop deciding how many
+// iterations to run, and the setup before the loop and the cleanup after it
+// are excluded from timing automatically. The compiler also keeps the calls
+// in the loop from being optimized away:
+//
+//     func BenchmarkBigLen(b *testing.B) {
+//         big := NewBig()
+//         for b.Loop() {
+//             big.Len()
+//         }
+//     }
+//
 // If a benchmark needs to test performance in a parallel setting, it may use
 // the RunParallel helper function; such benchmarks are intended to be used with
 // the go test -cpu flag:
@@ -197,6 +210,14 @@
 // because the directory is read-only), the fuzzing engine writes the file to
 // the fuzz cache directory within the build cache instead.
 //
+// The instrumentation also records the values that the code under test
+// compares with its inputs, such as magic numbers and keywords, and the
+// fuzzing engine splices them into the inputs it generates. Further values
+// may be listed in a dictionary file, testdata/fuzz/<Name>.dict, that holds
+// one double-quoted string per line, optionally preceded by a name and an
+// equals sign, as in libFuzzer and AFL dictionaries. Lines starting with '#'
+// are comments.
+//
 // When fuzzing is disabled, the fuzz target is called with the seed inputs
 // registered with F.Add and seed inputs from testdata/fuzz/<Name>. In this
 // mode, the fuzz test acts much like a regular test, with subtests started
@@ -1580,7 +1601,9 @@ func (f matchStringOnly) SetPanicOnExit0(bool)                        {}
 func (f matchStringOnly) CoordinateFuzzing(time.Duration, int64, time.Duration, int64, int, []corpusEntry, []reflect.Type, string, string) error {
 	return errMain
 }
-func (f matchStringOnly) RunFuzzWorker(func(corpusEntry) error) error { return errMain }
+func (f matchStringOnly) RunFuzzWorker(func(corpusEntry) error, []reflect.Type) error {
+	return errMain
+}
 func (f matchStringOnly) ReadCorpus(string, []reflect.Type) ([]corpusEntry, error) {
 	return nil, errMain
 }
@@ -1630,7 +1653,7 @@ type testDeps interface {
 	StopTestLog() error
 	WriteProfileTo(string, io.Writer, int) error
 	CoordinateFuzzing(time.Duration, int64, time.Duration, int64, int, []corpusEntry, []reflect.Type, string, string) error
-	RunFuzzWorker(func(corpusEntry) error) error
+	RunFuzzWorker(func(corpusEntry) error, []reflect.Type) error
 	ReadCorpus(string, []reflect.Type) ([]corpusEntry, error)
 	CheckCorpus([]any, []reflect.Type) error
 	ResetCoverage()